@@ -0,0 +1,141 @@
+package main
+
+// Admin API — operator endpoints under /admin/.
+//
+// Every admin endpoint requires "Authorization: Bearer $ADMIN_TOKEN". When
+// ADMIN_TOKEN is not set the admin API is disabled entirely.
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requireAdmin authenticates an admin request, writing the error response
+// and returning false if it is not allowed.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		http.Error(w, "admin API disabled (ADMIN_TOKEN not set)", http.StatusServiceUnavailable)
+		return false
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// parseSince accepts an RFC 3339 timestamp or a Go duration ("24h") meaning
+// that long ago. An empty value means the beginning of time.
+func parseSince(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), true
+	}
+	return time.Time{}, false
+}
+
+// AdminResendHandler re-delivers stored normalized events to one sink, so a
+// newly onboarded consumer can catch up on history.
+//
+//	POST /admin/resend?sink=NAME[&since=24h|RFC3339][&renormalize=true]
+//
+// With renormalize=true each event is rebuilt from its archived raw payload
+// by the current adapters (picking up the current schema version) instead of
+// re-sending the stored copy. Re-normalization only reads from the SCM; no
+// automations or policy stages run again.
+func AdminResendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	sink, ok := sinkByName(q.Get("sink"))
+	if !ok {
+		http.Error(w, "unknown sink: "+q.Get("sink"), http.StatusBadRequest)
+		return
+	}
+	since, ok := parseSince(q.Get("since"))
+	if !ok {
+		http.Error(w, "since must be an RFC 3339 timestamp or a duration", http.StatusBadRequest)
+		return
+	}
+	renormalize, _ := strconv.ParseBool(q.Get("renormalize"))
+
+	events := store().Since(since)
+	log.Printf("[Admin] Resending %d event(s) since %s to sink %q (renormalize=%t)\n",
+		len(events), since.Format(time.RFC3339), sink.Name(), renormalize)
+
+	delivered, failed := 0, 0
+	var errors []string
+	for _, stored := range events {
+		event := stored.Event
+		if renormalize {
+			fresh, err := renormalizeStored(stored)
+			if err != nil {
+				failed++
+				errors = append(errors, stored.EventID+": "+err.Error())
+				continue
+			}
+			event = fresh
+		}
+		if err := sink.Deliver(event); err != nil {
+			failed++
+			errors = append(errors, stored.EventID+": "+err.Error())
+			continue
+		}
+		delivered++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"sink":      sink.Name(),
+		"since":     since,
+		"matched":   len(events),
+		"delivered": delivered,
+		"failed":    failed,
+		"errors":    errors,
+	})
+}
+
+// renormalizeStored rebuilds a stored event from its raw payload with the
+// current adapter, keeping the original event and delivery IDs.
+func renormalizeStored(stored StoredEvent) (*NormalizedEvent, error) {
+	if stored.Event.RawPayload == nil {
+		// Generated by the gateway (e.g. pull_request.auto_merged), or the
+		// payload was dropped: there is nothing to rebuild from.
+		return stored.Event, nil
+	}
+	adapter, err := NewSCMAdapter(stored.Platform)
+	if err != nil {
+		return nil, err
+	}
+	event, err := adapter.NormalizeEvent(stored.RawEventType, stored.Event.RawPayload)
+	if err != nil {
+		return nil, err
+	}
+	event.EventID = stored.Event.EventID
+	event.DeliveryID = stored.Event.DeliveryID
+	event.SchemaVersion = NormalizedSchemaVersion
+	event.ReceivedAt = stored.Event.ReceivedAt
+	runEnrichers(adapter, event, nil)
+	return anonymizeEvent(event), nil
+}
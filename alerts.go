@@ -0,0 +1,316 @@
+package main
+
+// Alerting — watches pipeline health and notifies operators when a
+// threshold is breached, and again when it recovers.
+//
+// Checks run every ALERT_INTERVAL_SECONDS (default 60):
+//
+//	ALERT_QUEUE_DEPTH            ready messages in the raw or normalized queue (default 1000)
+//	ALERT_DLQ_GROWTH             new dead letters per interval (default 10)
+//	ALERT_DELIVERY_FAILURE_PCT   % of sink deliveries failing per interval (default 20)
+//	ALERT_API_QUOTA_REMAINING    GitHub core rate-limit calls left (default 500)
+//
+// The queue and quota checks cover shared resources and run on the leader
+// only. Delivery counters are per process, so every replica evaluates its own
+// failure rate under an alert key naming the replica. The quota is checked
+// for every GitHub App installation, each having its own rate limit.
+//
+// Notifiers are enabled by configuring their target; any number may be set:
+//
+//	ALERT_WEBHOOK_URL            generic JSON POST of the Alert
+//	ALERT_SLACK_WEBHOOK_URL      Slack incoming webhook
+//	ALERT_PAGERDUTY_ROUTING_KEY  PagerDuty Events API v2 (trigger / resolve)
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Alert is a threshold breach (or its recovery) reported to notifiers.
+type Alert struct {
+	Key       string    `json:"key"` // stable identifier, e.g. "queue_depth:raw_webhook_events"
+	Summary   string    `json:"summary"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Resolved  bool      `json:"resolved"`
+	At        time.Time `json:"at"`
+}
+
+// Notifier delivers alerts to an external channel.
+type Notifier interface {
+	Name() string
+	Notify(alert Alert) error
+}
+
+// postJSON POSTs v as JSON to url and treats 4xx/5xx as errors.
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// webhookNotifier POSTs the Alert as JSON.
+type webhookNotifier struct{ url string }
+
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+func (n *webhookNotifier) Notify(alert Alert) error {
+	return postJSON(n.url, alert)
+}
+
+// slackNotifier posts a message to a Slack incoming webhook.
+type slackNotifier struct{ url string }
+
+func (n *slackNotifier) Name() string { return "slack" }
+
+func (n *slackNotifier) Notify(alert Alert) error {
+	icon := ":rotating_light:"
+	if alert.Resolved {
+		icon = ":white_check_mark:"
+	}
+	return postJSON(n.url, map[string]string{"text": icon + " " + alert.Summary})
+}
+
+// pagerDutyNotifier triggers and resolves PagerDuty incidents, deduplicated
+// by alert key.
+type pagerDutyNotifier struct{ routingKey string }
+
+func (n *pagerDutyNotifier) Name() string { return "pagerduty" }
+
+func (n *pagerDutyNotifier) Notify(alert Alert) error {
+	action := "trigger"
+	if alert.Resolved {
+		action = "resolve"
+	}
+	return postJSON("https://events.pagerduty.com/v2/enqueue", map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": action,
+		"dedup_key":    "scm-gateway:" + alert.Key,
+		"payload": map[string]interface{}{
+			"summary":  alert.Summary,
+			"source":   "scm-gateway",
+			"severity": "error",
+			"custom_details": map[string]float64{
+				"value":     alert.Value,
+				"threshold": alert.Threshold,
+			},
+		},
+	})
+}
+
+// configuredNotifiers returns the notifiers enabled by the environment.
+func configuredNotifiers() []Notifier {
+	var notifiers []Notifier
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, &webhookNotifier{url: url})
+	}
+	if url := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, &slackNotifier{url: url})
+	}
+	if key := os.Getenv("ALERT_PAGERDUTY_ROUTING_KEY"); key != "" {
+		notifiers = append(notifiers, &pagerDutyNotifier{routingKey: key})
+	}
+	return notifiers
+}
+
+// alertMonitor evaluates the checks and tracks which alerts are firing so
+// each breach is notified once and each recovery once.
+type alertMonitor struct {
+	mq        *RabbitMQ
+	notifiers []Notifier
+	firing    map[string]bool
+	replica   string
+
+	lastDLQDepth      int
+	lastDeliveries    int64
+	lastFailures      int64
+	primedDLQBaseline bool
+}
+
+// StartAlerting runs the alert checks until the process exits; the checks of
+// shared resources run on the leader replica only. It returns immediately if
+// no notifier is configured. mq may be nil, in which case the queue checks
+// are skipped.
+func StartAlerting(mq *RabbitMQ) {
+	notifiers := configuredNotifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+	replica, _ := os.Hostname()
+	m := &alertMonitor{mq: mq, notifiers: notifiers, firing: map[string]bool{}, replica: replica}
+
+	interval := time.Duration(envInt("ALERT_INTERVAL_SECONDS", 60)) * time.Second
+	log.Printf("[Alerts] Monitoring every %s with %d notifier(s)\n", interval, len(notifiers))
+
+	for range time.Tick(interval) {
+		m.checkDeliveries()
+		if isLeader() {
+			m.check()
+		}
+	}
+}
+
+// checkDeliveries evaluates this replica's sink delivery failure rate.
+func (m *alertMonitor) checkDeliveries() {
+	deliveries, failures := metrics.deliveries.Load(), metrics.deliveryFailures.Load()
+	if attempted := deliveries - m.lastDeliveries; attempted > 0 {
+		pct := float64(failures-m.lastFailures) * 100 / float64(attempted)
+		limit := float64(envInt("ALERT_DELIVERY_FAILURE_PCT", 20))
+		m.evaluate("delivery_failure_rate:"+m.replica, pct, limit,
+			fmt.Sprintf("%.0f%% of %d sink deliveries failed on %s in the last interval (threshold %.0f%%)", pct, attempted, m.replica, limit))
+	}
+	m.lastDeliveries, m.lastFailures = deliveries, failures
+}
+
+// check runs the health checks of shared resources once.
+func (m *alertMonitor) check() {
+	if m.mq != nil {
+		depthLimit := float64(envInt("ALERT_QUEUE_DEPTH", 1000))
+		for _, q := range append(shardQueues(rawEventsQueue), shardQueues(normalizedEventsQueue)...) {
+			depth, err := m.mq.QueueDepth(q)
+			if err != nil {
+				log.Printf("[Alerts] Warning: %v\n", err)
+				continue
+			}
+			m.evaluate("queue_depth:"+q, float64(depth), depthLimit,
+				fmt.Sprintf("Queue %s has %d messages waiting (threshold %.0f)", q, depth, depthLimit))
+		}
+
+		if dlq, err := m.mq.QueueDepth(deadLetterQueue); err == nil {
+			growth := 0
+			if m.primedDLQBaseline {
+				growth = dlq - m.lastDLQDepth
+			}
+			m.lastDLQDepth, m.primedDLQBaseline = dlq, true
+			limit := float64(envInt("ALERT_DLQ_GROWTH", 10))
+			m.evaluate("dlq_growth", float64(growth), limit,
+				fmt.Sprintf("Dead-letter queue grew by %d messages in one interval (now %d, threshold %.0f)", growth, dlq, limit))
+		}
+	}
+
+	if quotas, err := githubRateLimits(); err == nil {
+		threshold := float64(envInt("ALERT_API_QUOTA_REMAINING", 500))
+		for _, q := range quotas {
+			// Low remaining quota is the breach, so compare the inverted value.
+			m.evaluate("github_api_quota:"+q.Account, threshold-float64(q.Remaining), 0,
+				fmt.Sprintf("GitHub API quota low for %s: %d of %d calls remaining (threshold %.0f)", q.Account, q.Remaining, q.Limit, threshold))
+		}
+	}
+}
+
+// evaluate fires the alert when value exceeds threshold and resolves it when
+// it drops back, notifying only on state changes.
+func (m *alertMonitor) evaluate(key string, value, threshold float64, summary string) {
+	breached := value > threshold
+	if breached == m.firing[key] {
+		return
+	}
+	m.firing[key] = breached
+
+	alert := Alert{Key: key, Summary: summary, Value: value, Threshold: threshold, Resolved: !breached, At: time.Now()}
+	if !breached {
+		alert.Summary = "Resolved: " + summary
+	}
+	log.Printf("[Alerts] %s\n", alert.Summary)
+	for _, n := range m.notifiers {
+		if err := n.Notify(alert); err != nil {
+			log.Printf("[Alerts] Warning: %s notifier failed: %v\n", n.Name(), err)
+		}
+	}
+}
+
+// installationQuota is the core API quota of one GitHub App installation.
+type installationQuota struct {
+	Account          string
+	Remaining, Limit int
+}
+
+// githubRateLimits returns the core API quota of every active GitHub App
+// installation; installations whose quota cannot be read are logged and
+// skipped. Listing installations and querying /rate_limit do not consume
+// installation quota.
+func githubRateLimits() ([]installationQuota, error) {
+	adapter, err := NewGitHubAdapter()
+	if err != nil {
+		return nil, err
+	}
+	jwtToken, err := generateJWT(adapter.appID, adapter.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+	var accounts []string
+	err = paginate("https://api.github.com/app/installations?per_page=100", githubAppPages(context.Background(), jwtToken), func(body []byte) (bool, error) {
+		var page []struct {
+			Account struct {
+				Login string `json:"login"`
+			} `json:"account"`
+			SuspendedAt *string `json:"suspended_at"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("failed to parse installations response: %w", err)
+		}
+		for _, inst := range page {
+			if inst.SuspendedAt == nil {
+				accounts = append(accounts, inst.Account.Login)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing installations failed: %w", err)
+	}
+
+	quotas := make([]installationQuota, 0, len(accounts))
+	for _, account := range accounts {
+		remaining, limit, err := githubRateLimit(adapter, account)
+		if err != nil {
+			log.Printf("[Alerts] Warning: could not read the GitHub API quota of %s: %v\n", account, err)
+			continue
+		}
+		quotas = append(quotas, installationQuota{Account: account, Remaining: remaining, Limit: limit})
+	}
+	return quotas, nil
+}
+
+// githubRateLimit returns the remaining and total core API quota of the
+// GitHub App installation on account.
+func githubRateLimit(adapter *GitHubAdapter, account string) (remaining, limit int, err error) {
+	tok, err := adapter.token(account, "", tokenScope{"metadata": "read"})
+	if err != nil {
+		return 0, 0, err
+	}
+	body, err := makeAuthenticatedRequest(context.Background(), tok, "GET", "https://api.github.com/rate_limit", nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	var rl struct {
+		Resources struct {
+			Core struct {
+				Limit     int `json:"limit"`
+				Remaining int `json:"remaining"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &rl); err != nil {
+		return 0, 0, err
+	}
+	return rl.Resources.Core.Remaining, rl.Resources.Core.Limit, nil
+}
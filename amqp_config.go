@@ -0,0 +1,100 @@
+package main
+
+// RabbitMQ connection options beyond what RABBITMQ_URL can express, for
+// managed brokers:
+//
+//	RABBITMQ_CA_FILE                 PEM CA bundle to verify an amqps:// broker
+//	RABBITMQ_CERT_FILE / _KEY_FILE   client certificate (mutual TLS)
+//	RABBITMQ_TLS_SERVER_NAME         server name to verify, if not the URL host
+//	RABBITMQ_AUTH                    "external" authenticates with the client
+//	                                 certificate (SASL EXTERNAL) instead of the
+//	                                 URL's user and password
+//	RABBITMQ_USERNAME / _PASSWORD    credentials, overriding the URL's
+//	RABBITMQ_VHOST                   virtual host, overriding the URL path
+//	RABBITMQ_CONNECTION_NAME         name shown in the management UI
+//	                                 (default scm-gateway[-<POD_NAME>])
+//	RABBITMQ_HEARTBEAT_SECONDS       heartbeat interval (default 10)
+//	RABBITMQ_DIAL_TIMEOUT_SECONDS    TCP, TLS and AMQP handshake timeout (default 30)
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitmqConfig builds the dial configuration from the environment.
+func rabbitmqConfig() (amqp.Config, error) {
+	cfg := amqp.Config{
+		Vhost:      os.Getenv("RABBITMQ_VHOST"),
+		Heartbeat:  time.Duration(envInt("RABBITMQ_HEARTBEAT_SECONDS", 10)) * time.Second,
+		Properties: amqp.NewConnectionProperties(),
+		Dial:       amqp.DefaultDial(time.Duration(envInt("RABBITMQ_DIAL_TIMEOUT_SECONDS", 30)) * time.Second),
+	}
+
+	name := os.Getenv("RABBITMQ_CONNECTION_NAME")
+	if name == "" {
+		name = "scm-gateway"
+		if pod := os.Getenv("POD_NAME"); pod != "" {
+			name += "-" + pod
+		}
+	}
+	cfg.Properties.SetClientConnectionName(name)
+
+	tlsConfig, err := rabbitmqTLSConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLSClientConfig = tlsConfig
+
+	switch auth := strings.ToLower(os.Getenv("RABBITMQ_AUTH")); auth {
+	case "":
+		if user := os.Getenv("RABBITMQ_USERNAME"); user != "" {
+			cfg.SASL = []amqp.Authentication{&amqp.PlainAuth{Username: user, Password: os.Getenv("RABBITMQ_PASSWORD")}}
+		}
+	case "external":
+		if tlsConfig == nil || len(tlsConfig.Certificates) == 0 {
+			return cfg, fmt.Errorf("rabbitmq: RABBITMQ_AUTH=external needs RABBITMQ_CERT_FILE and RABBITMQ_KEY_FILE")
+		}
+		cfg.SASL = []amqp.Authentication{&amqp.ExternalAuth{}}
+	default:
+		return cfg, fmt.Errorf("rabbitmq: unknown RABBITMQ_AUTH %q", auth)
+	}
+	return cfg, nil
+}
+
+// rabbitmqTLSConfig returns the TLS configuration for the broker, or nil to
+// use the library default (system roots, for amqps:// URLs only).
+func rabbitmqTLSConfig() (*tls.Config, error) {
+	caFile := os.Getenv("RABBITMQ_CA_FILE")
+	certFile, keyFile := os.Getenv("RABBITMQ_CERT_FILE"), os.Getenv("RABBITMQ_KEY_FILE")
+	serverName := os.Getenv("RABBITMQ_TLS_SERVER_NAME")
+	if caFile == "" && certFile == "" && keyFile == "" && serverName == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("rabbitmq: failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("rabbitmq: no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("rabbitmq: failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,349 @@
+package main
+
+// Static analysis runner — a lightweight CI fallback for repos without
+// pipelines.
+//
+// For repos with "analyzers" in their RepoConfig, opened / synchronize /
+// reopened events send the PR head to each analyzer's runner and publish its
+// findings as a check run with annotations.
+//
+//	"analyzers": [
+//	  {"name": "eslint", "runner_url": "https://runners.internal/eslint"}
+//	]
+//
+// Runners receive {"clone_url", "ref", "sha", "repository"} and return
+// {"findings": [{"path", "line", "level", "message"}]}; the clone URL
+// carries short-lived credentials so the runner needs none of its own.
+//
+// PR heads are untrusted code: linters and builds execute it, so they run in
+// the runner's sandbox, never in the gateway process, whose files and
+// environment hold the App key and the admin and webhook secrets. Analyzers
+// configured with a local "command" are reported as failed. Only PRs whose
+// head is the source branch in the repository itself are analyzed: the
+// branch is resolved with git ls-remote, which receives credentials through
+// its environment rather than argv, and fork PRs are skipped.
+//
+// Analyses run on ANALYSIS_WORKERS goroutines (default 2) fed by a queue of
+// ANALYSIS_QUEUE PRs (default 100); a PR that finds the queue full is not
+// analyzed. ANALYSIS_TIMEOUT_SECONDS bounds the branch lookup and each
+// runner call (default 300).
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnalyzerConfig configures one analyzer for a repo. Command is no longer
+// supported and only kept so such configurations are reported as failed.
+type AnalyzerConfig struct {
+	Name      string   `json:"name"`
+	Command   []string `json:"command,omitempty"`
+	RunnerURL string   `json:"runner_url,omitempty"`
+}
+
+// workspaceCloner is implemented by adapters that can hand out an
+// authenticated clone URL for a repository.
+type workspaceCloner interface {
+	authenticatedCloneURL(owner, repo string) (string, error)
+}
+
+var (
+	analysisWorkersOnce sync.Once
+	analysisQueue       chan analysisJob
+)
+
+// analysisJob is a PR analysis waiting for a worker.
+type analysisJob struct {
+	ctx       context.Context
+	publisher CheckPublisher
+	status    StatusPublisher
+	cloner    workspaceCloner
+	event     *NormalizedEvent
+	analyzers []AnalyzerConfig
+}
+
+// enqueueAnalysis hands job to the analysis workers, starting them on first
+// use. It reports false when the queue is full.
+func enqueueAnalysis(job analysisJob) bool {
+	analysisWorkersOnce.Do(func() {
+		analysisQueue = make(chan analysisJob, envInt("ANALYSIS_QUEUE", 100))
+		workers := envInt("ANALYSIS_WORKERS", 2)
+		for i := 0; i < workers; i++ {
+			go analysisWorker()
+		}
+		log.Printf("[Analysis] %d worker(s) analyzing from a queue of %d\n", workers, cap(analysisQueue))
+	})
+	select {
+	case analysisQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// analysisWorker runs queued analyses until the process exits.
+func analysisWorker() {
+	for job := range analysisQueue {
+		analyzePR(job.ctx, job.publisher, job.status, job.cloner, job.event, job.analyzers)
+	}
+}
+
+// runAnalyzers queues an asynchronous analysis of the PR head when the repo
+// has analyzers configured.
+func runAnalyzers(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 || !isFileEnrichableAction(event.Action) {
+		return
+	}
+	analyzers := repoConfigFor(event.Platform, event.Repository.FullName).Analyzers
+	if len(analyzers) == 0 || !featureEnabled(FlagAnalyzers, event.Platform, event.Repository.FullName, true) {
+		return
+	}
+	cloner, ok := adapter.(workspaceCloner)
+	if !ok {
+		log.Printf("[Analysis] Warning: %s adapter cannot clone workspaces\n", adapter.Platform())
+		return
+	}
+	publisher, ok := adapter.(CheckPublisher)
+	if !ok {
+		log.Printf("[Analysis] Warning: %v\n", errUnsupported(adapter, "check runs"))
+		return
+	}
+
+	// Optional: the overall outcome is also set as a commit status.
+	status, _ := adapter.(StatusPublisher)
+
+	job := analysisJob{ctx: adapterContext(adapter), publisher: publisher, status: status, cloner: cloner, event: event, analyzers: analyzers}
+	if !enqueueAnalysis(job) {
+		log.Printf("[Analysis] Warning: analysis queue full, skipping PR #%d in %s\n", event.PR.Number, event.Repository.FullName)
+	}
+}
+
+// prHeadRef is the ref of the PR head. It is the source branch in the
+// repository itself: unlike GitHub's pull/N/head it never resolves to a
+// fork.
+func prHeadRef(event *NormalizedEvent) string {
+	return "refs/heads/" + event.PR.SourceBranch
+}
+
+// gitBaseEnv names the variables git inherits from the gateway.
+var gitBaseEnv = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+// gitEnv returns the minimal environment git runs with: the allowlisted
+// variables, and no user or system configuration.
+func gitEnv() []string {
+	env := []string{"HOME=" + os.TempDir(), "GIT_CONFIG_NOSYSTEM=1"}
+	for _, name := range gitBaseEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// gitCredentialEnv strips the credentials from cloneURL and returns them as
+// git configuration in the environment, keeping them out of argv.
+func gitCredentialEnv(cloneURL string) (string, []string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid clone URL")
+	}
+	if u.User == nil {
+		return cloneURL, nil, nil
+	}
+	password, _ := u.User.Password()
+	basic := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+	u.User = nil
+	return u.String(), []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+		"GIT_TERMINAL_PROMPT=0",
+	}, nil
+}
+
+// analysisStatusContext is the commit status context of the analysis as a
+// whole.
+const analysisStatusContext = "scm-gateway/analysis"
+
+// setAnalysisStatus sets the analysis commit status on sha, if the adapter
+// supports commit statuses.
+func setAnalysisStatus(status StatusPublisher, event *NormalizedEvent, sha, state, description string) {
+	if status == nil {
+		return
+	}
+	owner, repo := event.Repository.Owner, event.Repository.Name
+	if err := status.SetCommitStatus(owner, repo, sha, state, analysisStatusContext, description, event.PR.URL); err != nil {
+		log.Printf("[Analysis] Warning: could not set %s status on %s: %v\n", state, shortSHA(sha), err)
+	}
+}
+
+// analyzePR resolves the PR head, runs every analyzer and publishes one
+// check run per analyzer. With a StatusPublisher the head is also marked
+// pending while the analyzers run, then with their combined outcome. Runner
+// calls are made in ctx, the adapter's request context.
+func analyzePR(ctx context.Context, publisher CheckPublisher, status StatusPublisher, cloner workspaceCloner, event *NormalizedEvent, analyzers []AnalyzerConfig) {
+	owner, repo := event.Repository.Owner, event.Repository.Name
+	timeout := time.Duration(envInt("ANALYSIS_TIMEOUT_SECONDS", 300)) * time.Second
+
+	cloneURL, err := cloner.authenticatedCloneURL(owner, repo)
+	if err != nil {
+		log.Printf("[Analysis] Warning: could not build clone URL for %s: %v\n", event.Repository.FullName, err)
+		return
+	}
+	ref := prHeadRef(event)
+
+	sha, err := resolveRemoteRef(cloneURL, ref, timeout)
+	if err != nil {
+		log.Printf("[Analysis] Warning: could not resolve %s of %s: %v\n", ref, event.Repository.FullName, err)
+		return
+	}
+	// Bitbucket abbreviates the head SHA, so compare prefixes.
+	if event.PR.HeadSHA == "" || !strings.HasPrefix(sha, event.PR.HeadSHA) {
+		log.Printf("[Analysis] Skipping PR #%d of %s: its head %s is not on %s (fork PR or newer push)\n",
+			event.PR.Number, event.Repository.FullName, shortSHA(event.PR.HeadSHA), ref)
+		return
+	}
+	log.Printf("[Analysis] Analyzing %s (%s) of %s\n", ref, shortSHA(sha), event.Repository.FullName)
+	setAnalysisStatus(status, event, sha, StatusPending, fmt.Sprintf("Running %d analyzer(s)", len(analyzers)))
+
+	findings, failed := 0, 0
+	for _, a := range analyzers {
+		var annotations []CheckAnnotation
+		switch {
+		case a.RunnerURL != "":
+			annotations, err = runRemoteAnalyzer(ctx, a, event, cloneURL, ref, sha, timeout)
+		case len(a.Command) > 0:
+			err = fmt.Errorf("command analyzers are not supported, since they would run PR code inside the gateway; use a runner_url")
+		default:
+			err = fmt.Errorf("analyzer %q has no runner_url", a.Name)
+		}
+
+		run := CheckRun{Name: "analysis: " + a.Name, HeadSHA: sha, Annotations: annotations}
+		switch {
+		case err != nil:
+			log.Printf("[Analysis] Warning: analyzer %q failed on %s: %v\n", a.Name, event.Repository.FullName, err)
+			failed++
+			run.Conclusion = CheckNeutral
+			run.Title = "Analyzer failed to run"
+			run.Summary = err.Error()
+		case len(annotations) > 0:
+			findings += len(annotations)
+			run.Conclusion = CheckFailure
+			run.Title = fmt.Sprintf("%d finding(s)", len(annotations))
+			run.Summary = fmt.Sprintf("%s reported %d finding(s).", a.Name, len(annotations))
+			if len(annotations) > maxCheckAnnotations {
+				run.Summary += fmt.Sprintf(" Only the first %d are annotated.", maxCheckAnnotations)
+			}
+		default:
+			run.Conclusion = CheckSuccess
+			run.Title = "No findings"
+			run.Summary = fmt.Sprintf("%s reported no findings.", a.Name)
+		}
+
+		if err := publisher.PublishCheckRun(owner, repo, run); err != nil {
+			log.Printf("[Analysis] Warning: could not publish %q check run: %v\n", run.Name, err)
+		}
+	}
+
+	switch {
+	case failed > 0:
+		setAnalysisStatus(status, event, sha, StatusError, fmt.Sprintf("%d analyzer(s) failed to run", failed))
+	case findings > 0:
+		setAnalysisStatus(status, event, sha, StatusFailure, fmt.Sprintf("%d finding(s)", findings))
+	default:
+		setAnalysisStatus(status, event, sha, StatusSuccess, "No findings")
+	}
+}
+
+// resolveRemoteRef returns the SHA ref points to in the repository at
+// cloneURL, without fetching any of its content.
+func resolveRemoteRef(cloneURL, ref string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	repoURL, credEnv, err := gitCredentialEnv(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", repoURL, ref)
+	cmd.Env = append(gitEnv(), credEnv...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote: %w", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 || fields[1] != ref {
+		return "", fmt.Errorf("git ls-remote: unexpected output %q", strings.TrimSpace(string(out)))
+	}
+	return fields[0], nil
+}
+
+// runRemoteAnalyzer delegates analysis to an external runner service.
+func runRemoteAnalyzer(ctx context.Context, a AnalyzerConfig, event *NormalizedEvent, cloneURL, ref, sha string, timeout time.Duration) ([]CheckAnnotation, error) {
+	payload, err := json.Marshal(map[string]string{
+		"clone_url":  cloneURL,
+		"ref":        ref,
+		"sha":        sha,
+		"repository": event.Repository.FullName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.RunnerURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("runner unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("runner returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Findings []struct {
+			Path    string `json:"path"`
+			Line    int    `json:"line"`
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		} `json:"findings"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse runner response: %w", err)
+	}
+
+	annotations := make([]CheckAnnotation, 0, len(result.Findings))
+	for _, f := range result.Findings {
+		level := f.Level
+		if level != AnnotationNotice && level != AnnotationFailure {
+			level = AnnotationWarning
+		}
+		annotations = append(annotations, CheckAnnotation{
+			Path:      f.Path,
+			StartLine: f.Line,
+			EndLine:   f.Line,
+			Level:     level,
+			Message:   f.Message,
+		})
+	}
+	return annotations, nil
+}
@@ -0,0 +1,324 @@
+package main
+
+// API recording — an opt-in debugging aid that keeps the sanitized SCM API
+// request/response pairs of the last API_RECORDING_MAX normalizations
+// (default 20), viewable via:
+//
+//	GET /admin/recordings               recorded normalizations, newest first
+//	GET /admin/recordings/{event_id}    the exchanges of one normalization
+//
+// Enabled with API_RECORDING=true. Exchanges are captured by the tracing
+// transport (trace.go), so the same attribution applies: calls made with an
+// event's ID in their request context are recorded against it.
+// Credentials are stripped before anything is stored — auth headers and
+// cookies, token-like query parameters and JSON or form fields whose names
+// look like secrets — and only then are bodies cut at API_RECORDING_BODY_MAX
+// bytes (default 16 KiB). Bodies that are neither JSON nor form-encoded, or
+// too large to parse (over 1 MiB), are not recorded at all.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAPIRecordingMax     = 20
+	defaultAPIRecordingBodyMax = 16 << 10
+	apiRecordingParseMax       = 1 << 20 // larger bodies are not parsed, so not recorded
+	redactedValue              = "[REDACTED]"
+)
+
+// APIExchange is one recorded request/response pair.
+type APIExchange struct {
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	Status          int               `json:"status,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	DurationMS      int64             `json:"duration_ms"`
+	At              time.Time         `json:"at"`
+}
+
+// APIRecording is the exchanges recorded during one normalization.
+type APIRecording struct {
+	EventID   string        `json:"event_id"`
+	StartedAt time.Time     `json:"started_at"`
+	Exchanges []APIExchange `json:"exchanges"`
+}
+
+// recordingLog is a bounded, goroutine-safe collection of recordings.
+type recordingLog struct {
+	mu         sync.Mutex
+	max        int
+	recordings map[string]*APIRecording
+	order      []string
+}
+
+var (
+	recordingsOnce sync.Once
+	recordings     *recordingLog
+)
+
+// apiRecordingEnabled reports whether API_RECORDING is on.
+func apiRecordingEnabled() bool {
+	return os.Getenv("API_RECORDING") == "true"
+}
+
+// apiRecordings returns the package-level recording log.
+func apiRecordings() *recordingLog {
+	recordingsOnce.Do(func() {
+		recordings = &recordingLog{
+			max:        envInt("API_RECORDING_MAX", defaultAPIRecordingMax),
+			recordings: map[string]*APIRecording{},
+		}
+	})
+	return recordings
+}
+
+// add appends an exchange to the recording of eventID, evicting the oldest
+// recording when full.
+func (l *recordingLog) add(eventID string, ex APIExchange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec, ok := l.recordings[eventID]
+	if !ok {
+		rec = &APIRecording{EventID: eventID, StartedAt: ex.At}
+		l.recordings[eventID] = rec
+		l.order = append(l.order, eventID)
+		if len(l.order) > l.max {
+			delete(l.recordings, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	rec.Exchanges = append(rec.Exchanges, ex)
+}
+
+// get returns a copy of one recording.
+func (l *recordingLog) get(eventID string) (APIRecording, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.recordings[eventID]
+	if !ok {
+		return APIRecording{}, false
+	}
+	return *rec, true
+}
+
+// summaries lists the recordings, newest first, without their exchanges.
+func (l *recordingLog) summaries() []map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]map[string]interface{}, 0, len(l.order))
+	for i := len(l.order) - 1; i >= 0; i-- {
+		rec := l.recordings[l.order[i]]
+		out = append(out, map[string]interface{}{
+			"event_id":   rec.EventID,
+			"started_at": rec.StartedAt,
+			"exchanges":  len(rec.Exchanges),
+		})
+	}
+	return out
+}
+
+// recordExchange performs req through base and records the sanitized
+// exchange against eventID. The response body is passed through unchanged.
+func recordExchange(base http.RoundTripper, req *http.Request, eventID string) (*http.Response, error) {
+	bodyMax := envInt("API_RECORDING_BODY_MAX", defaultAPIRecordingBodyMax)
+	ex := APIExchange{
+		Method:         req.Method,
+		URL:            sanitizeURL(req.URL),
+		RequestHeaders: sanitizeHeaders(req.Header),
+		At:             time.Now(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, apiRecordingParseMax+1))
+			body.Close()
+			ex.RequestBody = sanitizeBody(data, req.Header.Get("Content-Type"), bodyMax)
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	ex.DurationMS = time.Since(ex.At).Milliseconds()
+	if err != nil {
+		ex.Error = err.Error()
+		apiRecordings().add(eventID, ex)
+		return resp, err
+	}
+
+	ex.Status = resp.StatusCode
+	ex.ResponseHeaders = sanitizeHeaders(resp.Header)
+	prefix, _ := io.ReadAll(io.LimitReader(resp.Body, apiRecordingParseMax+1))
+	ex.ResponseBody = sanitizeBody(prefix, resp.Header.Get("Content-Type"), bodyMax)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+
+	apiRecordings().add(eventID, ex)
+	return resp, nil
+}
+
+// isSecretName reports whether a header, parameter or field name looks like
+// it carries a credential.
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range []string{"authorization", "cookie", "token", "secret", "password", "private_key", "pem", "jwt", "credential", "signature"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeURL redacts userinfo and secret-looking query parameters.
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	for k := range query {
+		if isSecretName(k) {
+			query[k] = []string{redactedValue}
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.Redacted()
+}
+
+// sanitizeHeaders flattens headers, redacting secret-looking ones.
+func sanitizeHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if isSecretName(k) {
+			out[k] = redactedValue
+		} else {
+			out[k] = strings.Join(v, ", ")
+		}
+	}
+	return out
+}
+
+// sanitizeBody redacts secret-looking fields of a JSON or form-encoded body,
+// then truncates it to max bytes. A body that cannot be parsed, because of
+// its format or its size, is replaced by a note: it may carry credentials
+// that could not be found.
+func sanitizeBody(data []byte, contentType string, max int) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) > apiRecordingParseMax {
+		return fmt.Sprintf("[omitted: over %d bytes]", apiRecordingParseMax)
+	}
+	clean, ok := redactBody(data, contentType)
+	if !ok {
+		return fmt.Sprintf("[omitted: %d bytes of %s]", len(data), bodyKind(contentType))
+	}
+	if len(clean) > max {
+		return clean[:max] + "…[truncated]"
+	}
+	return clean
+}
+
+// redactBody returns data with the values of secret-looking JSON or form
+// fields redacted, and false if data is neither.
+func redactBody(data []byte, contentType string) (string, bool) {
+	var v interface{}
+	if json.Unmarshal(data, &v) == nil {
+		out, err := json.Marshal(redactJSON(v))
+		return string(out), err == nil
+	}
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return "", false
+	}
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return "", false
+	}
+	for k := range form {
+		if isSecretName(k) || isSecretFormName(k) {
+			form[k] = []string{redactedValue}
+		}
+	}
+	return form.Encode(), true
+}
+
+// isSecretFormName reports whether a form field carries an OAuth grant
+// (authorization code or JWT assertion) besides isSecretName's credentials.
+func isSecretFormName(name string) bool {
+	switch strings.ToLower(name) {
+	case "code", "assertion", "client_assertion":
+		return true
+	}
+	return false
+}
+
+// bodyKind names a body's media type for the omission note.
+func bodyKind(contentType string) string {
+	if mediaType, _, _ := strings.Cut(contentType, ";"); mediaType != "" {
+		return strings.TrimSpace(mediaType)
+	}
+	return "unknown type"
+}
+
+// redactJSON replaces the values of secret-looking object keys.
+func redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if isSecretName(k) {
+				t[k] = redactedValue
+			} else {
+				t[k] = redactJSON(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = redactJSON(child)
+		}
+	}
+	return v
+}
+
+// AdminRecordingsHandler serves the recorded API exchanges.
+func AdminRecordingsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !apiRecordingEnabled() {
+		http.Error(w, "API recording disabled (set API_RECORDING=true)", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/recordings"), "/")
+	if id == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "success",
+			"recordings": apiRecordings().summaries(),
+		})
+		return
+	}
+	rec, ok := apiRecordings().get(id)
+	if !ok {
+		http.Error(w, "no recording for "+id, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "success",
+		"recording": rec,
+	})
+}
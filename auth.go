@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -9,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,6 +29,11 @@ func getPrivateKeyFromEnv() string {
 
 // generateJWT creates a JWT token for GitHub App authentication
 func generateJWT(appID string, privateKeyPEM string) (string, error) {
+	// Replayed API calls are never authenticated (see cassette.go).
+	if cassetteReplaying() {
+		return "cassette-replay", nil
+	}
+
 	// Parse private key
 	block, _ := pem.Decode([]byte(privateKeyPEM))
 	if block == nil {
@@ -67,63 +74,113 @@ func generateJWT(appID string, privateKeyPEM string) (string, error) {
 	return tokenString, nil
 }
 
-// getInstallationToken exchanges JWT for an installation token
-func getInstallationToken(jwtToken string, owner string, repo string) (string, error) {
-	// Get the app ID from environment
-	appID := os.Getenv("GITHUB_APP_ID")
-
-	// List installations endpoint
-	url := "https://api.github.com/app/installations"
+// tokenScope is the permissions an installation token is minted with, in
+// the form of the access_tokens API (e.g. {"pull_requests": "read"}).
+type tokenScope map[string]string
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
+// tokenScoping reports whether installation tokens are limited to the
+// repository and permissions of the operation (GITHUB_TOKEN_SCOPING, default
+// on). Turning it off mints tokens with every permission of the installation.
+func tokenScoping() bool {
+	return os.Getenv("GITHUB_TOKEN_SCOPING") != "false"
+}
 
-	req.Header.Set("Authorization", "Bearer "+jwtToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "GitHub-App-"+appID)
+// getInstallationToken exchanges JWT for an installation token with every
+// permission of the installation.
+func getInstallationToken(jwtToken string, owner string, repo string) (string, error) {
+	return getScopedInstallationToken(jwtToken, owner, repo, nil)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error: Failed to get installations:", err)
-		return "", err
+// installationIDFor returns the ID of the App's installation covering
+// owner/repo, or owner's account when repo is empty. It fails when the App is
+// not installed there instead of falling back to another account's
+// installation. With no owner, the App's first installation is used.
+func installationIDFor(jwtToken, owner, repo string) (int64, error) {
+	var endpoints []string
+	switch {
+	case owner == "":
+		body, err := makeAppRequest(jwtToken, "GET", "https://api.github.com/app/installations?per_page=1", nil)
+		if err != nil {
+			return 0, fmt.Errorf("listing installations: %w", err)
+		}
+		var installations []struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(body, &installations); err != nil {
+			return 0, fmt.Errorf("failed to parse installations: %w", err)
+		}
+		if len(installations) == 0 {
+			return 0, fmt.Errorf("the GitHub App has no installations")
+		}
+		return installations[0].ID, nil
+	case repo != "":
+		endpoints = []string{fmt.Sprintf("https://api.github.com/repos/%s/%s/installation", owner, repo)}
+	default:
+		endpoints = []string{
+			fmt.Sprintf("https://api.github.com/orgs/%s/installation", owner),
+			fmt.Sprintf("https://api.github.com/users/%s/installation", owner),
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Println("Error: GitHub API returned", resp.StatusCode, ":", string(body))
-		return "", err
+	var lastErr error
+	for _, endpoint := range endpoints {
+		body, err := makeAppRequest(jwtToken, "GET", endpoint, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(body, &installation); err != nil {
+			return 0, fmt.Errorf("failed to parse installation: %w", err)
+		}
+		return installation.ID, nil
+	}
+	target := owner
+	if repo != "" {
+		target = owner + "/" + repo
 	}
+	return 0, fmt.Errorf("the GitHub App is not installed on %s: %w", target, lastErr)
+}
 
-	// Parse installations
-	var installations []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&installations); err != nil {
-		log.Println("Error: Failed to parse installations:", err)
+// getScopedInstallationToken exchanges JWT for a token of the installation
+// covering owner/repo (see installationIDFor). With a scope, and unless
+// GITHUB_TOKEN_SCOPING=false, the token is limited to repo (when set) and the
+// scope's permissions. The granted permissions are logged for audit.
+func getScopedInstallationToken(jwtToken, owner, repo string, scope tokenScope) (string, error) {
+	installationID, err := installationIDFor(jwtToken, owner, repo)
+	if err != nil {
+		log.Println("Error: Failed to find installation:", err)
 		return "", err
 	}
+	tokenURL := fmt.Sprintf("https://api.github.com/app/installations/%d/access_tokens", installationID)
 
-	if len(installations) == 0 {
-		log.Println("Error: No installations found")
-		return "", nil
+	var body io.Reader
+	scoped := scope != nil && tokenScoping()
+	if scoped {
+		request := map[string]interface{}{"permissions": scope}
+		if repo != "" {
+			request["repositories"] = []string{repo}
+		}
+		data, err := json.Marshal(request)
+		if err != nil {
+			return "", err
+		}
+		body = strings.NewReader(string(data))
 	}
-
-	// Get the first installation's token endpoint
-	installationID := int(installations[0]["id"].(float64))
-	tokenURL := "https://api.github.com/app/installations/" + fmt.Sprintf("%d", installationID) + "/access_tokens"
-
-	req, err = http.NewRequest("POST", tokenURL, nil)
+	req, err := http.NewRequest("POST", tokenURL, body)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "GitHub-App-"+appID)
+	setGitHubHeaders(req, "")
+	if scoped {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	resp, err = client.Do(req)
+	resp, err := (&http.Client{}).Do(req)
 	if err != nil {
 		log.Println("Error: Failed to get installation token:", err)
 		return "", err
@@ -133,7 +190,7 @@ func getInstallationToken(jwtToken string, owner string, repo string) (string, e
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
 		log.Println("Error: GitHub API returned", resp.StatusCode, ":", string(body))
-		return "", err
+		return "", fmt.Errorf("minting installation token: GitHub API %d", resp.StatusCode)
 	}
 
 	// Parse token response
@@ -143,25 +200,41 @@ func getInstallationToken(jwtToken string, owner string, repo string) (string, e
 		return "", err
 	}
 
+	target := owner
+	if scoped && repo != "" {
+		target = owner + "/" + repo
+	}
+	log.Printf("[Auth] Installation token for %s (installation %d, %s repositories): %s\n",
+		target, installationID, tokenResp.RepositorySelection, formatPermissions(tokenResp.Permissions))
 	return tokenResp.Token, nil
 }
 
+// formatPermissions renders granted permissions as "name:level" pairs in
+// name order.
+func formatPermissions(permissions map[string]string) string {
+	pairs := make([]string, 0, len(permissions))
+	for name, level := range permissions {
+		pairs = append(pairs, name+":"+level)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
 // makeAuthenticatedRequest makes an authenticated API request to GitHub
-func makeAuthenticatedRequest(token string, method string, url string, body interface{}) ([]byte, error) {
+func makeAuthenticatedRequest(ctx context.Context, token string, method string, url string, body interface{}) ([]byte, error) {
 	var reqBody io.Reader
 	if body != nil {
 		bodyBytes, _ := json.Marshal(body)
 		reqBody = strings.NewReader(string(bodyBytes))
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "GitHub-App")
+	setGitHubHeaders(req, "")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -172,3 +245,90 @@ func makeAuthenticatedRequest(token string, method string, url string, body inte
 
 	return io.ReadAll(resp.Body)
 }
+
+// makeAuthenticatedRequestWithStatus is makeAuthenticatedRequest for callers
+// that need the HTTP status code (e.g. the 202 "still computing" responses
+// of the statistics API).
+func makeAuthenticatedRequestWithStatus(ctx context.Context, token string, method string, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Authorization", "token "+token)
+	setGitHubHeaders(req, "")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// makeAuthenticatedRawRequest makes an authenticated GET-style request with a
+// custom Accept header (e.g. application/vnd.github.diff) and returns the raw
+// body. 4xx/5xx responses are returned as errors.
+func makeAuthenticatedRawRequest(ctx context.Context, token string, method string, url string, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "token "+token)
+	setGitHubHeaders(req, accept)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("GitHub API %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// makeAppRequest makes a request to GitHub authenticated as the App itself
+// (JWT bearer), as required by the /app/* endpoints. Unlike
+// makeAuthenticatedRequest it treats 4xx/5xx responses as errors.
+func makeAppRequest(jwtToken string, method string, url string, body interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, _ := json.Marshal(body)
+		reqBody = strings.NewReader(string(bodyBytes))
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+jwtToken)
+	setGitHubHeaders(req, "")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("GitHub API %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+// Auto-merge — merges PRs that satisfy their repository's conditions and
+// emits pull_request.auto_merged. Opt-in per repo via RepoConfig:
+//
+//	"auto_merge": {
+//	  "enabled": true,
+//	  "min_approvals": 1,                 // approving reviews needed
+//	  "required_checks": ["ci/build"],    // must have succeeded; empty: every
+//	                                      // reported check must succeed, and
+//	                                      // at least one must be reported
+//	  "allow_no_checks": false,           // with no required_checks, merge
+//	                                      // PRs that report no checks at all
+//	  "require_labels": ["automerge"],    // all must be present
+//	  "block_labels": ["do-not-merge"],   // none may be present
+//	  "require_resolved_threads": true,   // no unresolved review threads
+//	  "method": "squash"                  // "merge" (default), "squash" or "rebase"
+//	}
+//
+// A PR is also never merged while it is a draft, has changes requested or
+// pending or failed checks, or has conflicts. PRs are evaluated after each of
+// their events and swept by the "auto_merge" job (default every 5 minutes),
+// which catches approvals and check completions the gateway receives no
+// webhook for. The auto_merge feature flag is the kill switch:
+// FLAG_AUTO_MERGE=off stops every merge, and rollouts can limit it to some
+// repos (see flags.go). Standby deployments never merge.
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventTypeAutoMerged is emitted after the gateway merged a PR.
+const EventTypeAutoMerged = "pull_request.auto_merged"
+
+// AutoMergePolicy is the per-repo configuration of auto-merge.
+type AutoMergePolicy struct {
+	Enabled                bool     `json:"enabled"`
+	MinApprovals           int      `json:"min_approvals,omitempty"`
+	RequiredChecks         []string `json:"required_checks,omitempty"`
+	RequireLabels          []string `json:"require_labels,omitempty"`
+	BlockLabels            []string `json:"block_labels,omitempty"`
+	RequireResolvedThreads bool     `json:"require_resolved_threads,omitempty"`
+	AllowNoChecks          bool     `json:"allow_no_checks,omitempty"`
+	Method                 string   `json:"method,omitempty"`
+}
+
+// autoMergeInFlight holds the PRs being evaluated, so an event and the sweep
+// never merge the same PR twice.
+var autoMergeInFlight sync.Map // prSnapshotKey → true
+
+// autoMergePolicyFor returns the repository's policy, or nil if auto-merge
+// is off for it.
+func autoMergePolicyFor(platform SCMPlatform, fullName string) *AutoMergePolicy {
+	policy := repoConfigFor(platform, fullName).AutoMerge
+	if policy == nil || !featureEnabled(FlagAutoMerge, platform, fullName, policy.Enabled) {
+		return nil
+	}
+	return policy
+}
+
+// runAutoMerge evaluates the PR of event in the background.
+func runAutoMerge(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 || event.Action == "closed" {
+		return
+	}
+	policy := autoMergePolicyFor(event.Platform, event.Repository.FullName)
+	if policy == nil {
+		return
+	}
+	go func() {
+		if _, err := tryAutoMerge(adapter, event.Repository, event.PR, policy); err != nil {
+			log.Printf("[AutoMerge] Warning: PR #%d of %s: %v\n", event.PR.Number, event.Repository.FullName, err)
+		}
+	}()
+}
+
+// sweepAutoMerge evaluates every open PR of the repositories with an
+// auto-merge policy. It is the "auto_merge" scheduled job.
+func sweepAutoMerge() error {
+	if isStandby() {
+		return nil
+	}
+	failures, evaluated := 0, 0
+	for _, snap := range prSnapshots().List("", "", "open") {
+		owner, name, ok := strings.Cut(snap.Repository, "/")
+		if !ok || registry.IsSuspended(snap.Platform, owner) {
+			continue
+		}
+		policy := autoMergePolicyFor(snap.Platform, snap.Repository)
+		if policy == nil {
+			continue
+		}
+		adapter, err := NewSCMAdapter(snap.Platform)
+		if err != nil {
+			continue
+		}
+		evaluated++
+		repo := NormalizedRepository{Name: name, FullName: snap.Repository, Owner: owner}
+		pr := NormalizedPR{
+			Number:       snap.Number,
+			Title:        snap.Title,
+			Author:       snap.Author,
+			SourceBranch: snap.SourceBranch,
+			TargetBranch: snap.TargetBranch,
+			State:        snap.State,
+			URL:          snap.URL,
+		}
+		if _, err := tryAutoMerge(adapter, repo, pr, policy); err != nil {
+			log.Printf("[AutoMerge] Warning: PR #%d of %s: %v\n", snap.Number, snap.Repository, err)
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d pull requests could not be evaluated", failures, evaluated)
+	}
+	return nil
+}
+
+// tryAutoMerge merges the PR if it satisfies policy and reports whether it
+// did. Unmet conditions are not errors.
+func tryAutoMerge(adapter SCMAdapter, repo NormalizedRepository, pr NormalizedPR, policy *AutoMergePolicy) (bool, error) {
+	merger, ok := adapter.(PRMerger)
+	if !ok {
+		return false, errUnsupported(adapter, "merging")
+	}
+	key := prSnapshotKey(adapter.Platform(), repo.FullName, pr.Number)
+	if _, busy := autoMergeInFlight.LoadOrStore(key, true); busy {
+		return false, nil
+	}
+	defer autoMergeInFlight.Delete(key)
+
+	state, err := merger.GetMergeState(repo.Owner, repo.Name, pr.Number)
+	if err != nil {
+		return false, err
+	}
+	if reason := autoMergeBlocker(state, policy); reason != "" {
+		log.Printf("[AutoMerge] PR #%d of %s not merged: %s\n", pr.Number, repo.FullName, reason)
+		return false, nil
+	}
+	if policy.RequireResolvedThreads {
+		unresolved, err := unresolvedThreadCount(adapter, repo, pr.Number)
+		if err != nil {
+			return false, err
+		}
+		if unresolved > 0 {
+			log.Printf("[AutoMerge] PR #%d of %s not merged: %d unresolved review thread(s)\n", pr.Number, repo.FullName, unresolved)
+			return false, nil
+		}
+	}
+
+	// The kill switch may have been flipped while the state was read.
+	if autoMergePolicyFor(adapter.Platform(), repo.FullName) == nil || isStandby() {
+		return false, nil
+	}
+	method := policy.Method
+	if method == "" {
+		method = "merge"
+	}
+	if err := merger.MergePR(repo.Owner, repo.Name, pr.Number, state.HeadSHA, method); err != nil {
+		metrics.autoMergeFailures.Add(1)
+		return false, err
+	}
+	metrics.autoMerged.Add(1)
+	log.Printf("[AutoMerge] Merged PR #%d of %s at %s (%s)\n", pr.Number, repo.FullName, state.HeadSHA, method)
+	publishAutoMerged(adapter.Platform(), repo, pr)
+	return true, nil
+}
+
+// autoMergeBlocker returns why state does not satisfy policy, or "".
+func autoMergeBlocker(state *MergeState, policy *AutoMergePolicy) string {
+	switch {
+	case !state.Open:
+		return "not open"
+	case state.Draft:
+		return "draft"
+	case state.Conflicts:
+		return "merge conflicts"
+	case state.ChangesRequested:
+		return "changes requested"
+	case state.Approvals < policy.MinApprovals:
+		return fmt.Sprintf("%d of %d approvals", state.Approvals, policy.MinApprovals)
+	}
+
+	labels := map[string]bool{}
+	for _, l := range state.Labels {
+		labels[strings.ToLower(l)] = true
+	}
+	for _, l := range policy.RequireLabels {
+		if !labels[strings.ToLower(l)] {
+			return "missing label " + l
+		}
+	}
+	for _, l := range policy.BlockLabels {
+		if labels[strings.ToLower(l)] {
+			return "blocked by label " + l
+		}
+	}
+
+	required := policy.RequiredChecks
+	if len(required) == 0 {
+		// A freshly opened PR has no checks until CI starts.
+		if len(state.Checks) == 0 && !policy.AllowNoChecks {
+			return "no checks reported"
+		}
+		for name := range state.Checks {
+			required = append(required, name)
+		}
+	}
+	for _, name := range required {
+		switch result, ok := state.Checks[name]; {
+		case !ok:
+			return "check " + name + " not reported"
+		case result != "success":
+			return "check " + name + " " + result
+		}
+	}
+	return ""
+}
+
+// unresolvedThreadCount returns the number of unresolved review threads.
+func unresolvedThreadCount(adapter SCMAdapter, repo NormalizedRepository, prNumber int) (int, error) {
+	resolver, ok := adapter.(ThreadResolver)
+	if !ok {
+		return 0, errUnsupported(adapter, "review threads")
+	}
+	threads, err := resolver.ListReviewThreads(repo.Owner, repo.Name, prNumber)
+	if err != nil {
+		return 0, err
+	}
+	unresolved := 0
+	for _, t := range threads {
+		if !t.Resolved {
+			unresolved++
+		}
+	}
+	return unresolved, nil
+}
+
+// publishAutoMerged emits the pull_request.auto_merged event of a merge.
+func publishAutoMerged(platform SCMPlatform, repo NormalizedRepository, pr NormalizedPR) {
+	pr.State = "closed"
+	event := anonymizeEvent(&NormalizedEvent{
+		EventID:       newEventID(),
+		SchemaVersion: NormalizedSchemaVersion,
+		Platform:      platform,
+		EventType:     EventTypeAutoMerged,
+		Action:        "auto_merged",
+		PR:            pr,
+		Repository:    repo,
+		ReceivedAt:    time.Now(),
+	})
+	store().Append(EventTypeAutoMerged, event)
+	prSnapshots().Apply(event)
+	if mq == nil {
+		log.Printf("[AutoMerge] Warning: RabbitMQ not initialised, %s event for PR #%d dropped\n", EventTypeAutoMerged, pr.Number)
+		return
+	}
+	if err := mq.PublishNormalizedEvent(event); err != nil {
+		log.Printf("[AutoMerge] Warning: could not publish %s event: %v\n", EventTypeAutoMerged, err)
+	}
+}
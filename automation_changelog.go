@@ -0,0 +1,192 @@
+package main
+
+// Changelog updates.
+//
+// When a PR is merged into the default branch, repos with a "changelog" entry
+// in their RepoConfig get a line added to their changelog file, written
+// through the adapter (ContentWriter):
+//
+//	"changelog": {
+//	  "path": "CHANGELOG.md",                          // default
+//	  "template": "- {{.Title}} (#{{.PR.Number}})",    // text/template, see below
+//	  "heading": "## [Unreleased]",                    // entries go under it; appended at the end without it
+//	  "mode": "commit",                                // "commit" to the branch, or "pr" for a follow-up PR
+//	  "branch": "main"                                 // default: the repository's default branch
+//	}
+//
+// Template data:
+//
+//	.PR          NormalizedPR
+//	.Repository  NormalizedRepository
+//	.Title       the PR title without its Conventional Commit prefix
+//	.Section     the release notes section of the PR (see release_notes.go)
+//	.Date        the merge date, YYYY-MM-DD
+//
+// An entry already in the file is not added again, so redelivered merge
+// events are harmless. Follow-up PRs come from "changelog/pr-N" branches,
+// whose own merges are not logged.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultChangelogPath     = "CHANGELOG.md"
+	defaultChangelogTemplate = "- {{.Title}} (#{{.PR.Number}})"
+	changelogBranchPrefix    = "changelog/"
+)
+
+// ChangelogPolicy is the per-repo configuration of changelog updates.
+type ChangelogPolicy struct {
+	Path     string `json:"path,omitempty"`
+	Template string `json:"template,omitempty"`
+	Heading  string `json:"heading,omitempty"`
+	Mode     string `json:"mode,omitempty"` // "commit" (default) or "pr"
+	Branch   string `json:"branch,omitempty"`
+}
+
+// changelogTemplateData is the data passed to a repo's changelog template.
+type changelogTemplateData struct {
+	PR         NormalizedPR
+	Repository NormalizedRepository
+	Title      string
+	Section    string
+	Date       string
+}
+
+// payloadDefaultBranch returns the repository's default branch named in a
+// GitHub or Gitea payload, or "".
+func payloadDefaultBranch(payload []byte) string {
+	var p struct {
+		Repository struct {
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+	if json.Unmarshal(payload, &p) != nil {
+		return ""
+	}
+	return p.Repository.DefaultBranch
+}
+
+// withChangelogEntry returns changelog with entry added as the first line
+// under heading, or appended at the end when heading is empty or missing.
+func withChangelogEntry(changelog, heading, entry string) string {
+	if heading != "" {
+		lines := strings.SplitAfter(changelog, "\n")
+		for i, line := range lines {
+			if strings.TrimSpace(line) != heading {
+				continue
+			}
+			// Keep a blank line after the heading if there is one.
+			at := i + 1
+			if at < len(lines) && strings.TrimSpace(lines[at]) == "" {
+				at++
+			}
+			head := strings.Join(lines[:at], "")
+			if !strings.HasSuffix(head, "\n") {
+				head += "\n"
+			}
+			return head + entry + "\n" + strings.Join(lines[at:], "")
+		}
+	}
+	if changelog != "" && !strings.HasSuffix(changelog, "\n") {
+		changelog += "\n"
+	}
+	return changelog + entry + "\n"
+}
+
+// applyChangelog adds the changelog entry of a PR merged into the default
+// branch and writes it back through the adapter.
+func applyChangelog(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 || !prMerged(event) || strings.HasPrefix(event.PR.SourceBranch, changelogBranchPrefix) {
+		return
+	}
+	policy := repoConfigFor(event.Platform, event.Repository.FullName).Changelog
+	if policy == nil {
+		return
+	}
+	branch := policy.Branch
+	if branch == "" {
+		branch = payloadDefaultBranch(event.RawPayload)
+	}
+	if branch == "" || event.PR.TargetBranch != branch {
+		return
+	}
+	writer, ok := adapter.(ContentWriter)
+	if !ok {
+		log.Printf("[Automation] Warning: %v\n", errUnsupported(adapter, "committing files"))
+		return
+	}
+
+	path, source := policy.Path, policy.Template
+	if path == "" {
+		path = defaultChangelogPath
+	}
+	if source == "" {
+		source = defaultChangelogTemplate
+	}
+	tmpl, err := template.New("changelog").Parse(source)
+	if err != nil {
+		log.Printf("[Automation] Warning: invalid changelog template for %s: %v\n", event.Repository.FullName, err)
+		return
+	}
+	section, title := releaseSection(mergedPR{pr: event.PR, labels: payloadLabels(event.RawPayload)},
+		releaseSections(event.Platform, event.Repository.FullName))
+	data := changelogTemplateData{
+		PR:         event.PR,
+		Repository: event.Repository,
+		Title:      title,
+		Section:    section,
+		Date:       time.Now().UTC().Format("2006-01-02"),
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		log.Printf("[Automation] Warning: could not render changelog template for %s: %v\n", event.Repository.FullName, err)
+		return
+	}
+	entry := strings.TrimRight(rendered.String(), "\n")
+
+	owner, repo := event.Repository.Owner, event.Repository.Name
+	current, err := writer.GetFileContent(owner, repo, branch, path)
+	if err != nil && err != errFileNotFound {
+		log.Printf("[Automation] Warning: could not read %s of %s: %v\n", path, event.Repository.FullName, err)
+		return
+	}
+	if strings.Contains(string(current), entry) {
+		return
+	}
+	updated := withChangelogEntry(string(current), policy.Heading, entry)
+	message := fmt.Sprintf("Update %s for #%d", path, event.PR.Number)
+
+	if policy.Mode != "pr" {
+		if _, err := writer.CreateCommit(owner, repo, FileCommit{Branch: branch, Path: path, Message: message, Content: []byte(updated)}); err != nil {
+			log.Printf("[Automation] Warning: could not commit %s for PR #%d: %v\n", path, event.PR.Number, err)
+			return
+		}
+		log.Printf("[Automation] ✓ Added PR #%d to %s of %s\n", event.PR.Number, path, event.Repository.FullName)
+		return
+	}
+
+	head := fmt.Sprintf("%spr-%d", changelogBranchPrefix, event.PR.Number)
+	if err := writer.CreateBranch(owner, repo, head, branch); err != nil {
+		log.Printf("[Automation] Warning: could not create %s for PR #%d: %v\n", head, event.PR.Number, err)
+		return
+	}
+	if _, err := writer.CreateCommit(owner, repo, FileCommit{Branch: head, Path: path, Message: message, Content: []byte(updated)}); err != nil {
+		log.Printf("[Automation] Warning: could not commit %s for PR #%d: %v\n", path, event.PR.Number, err)
+		return
+	}
+	body := fmt.Sprintf("Adds #%d to %s:\n\n%s", event.PR.Number, path, entry)
+	number, err := writer.CreatePR(owner, repo, NewPR{Title: message, Body: body, Head: head, Base: branch})
+	if err != nil {
+		log.Printf("[Automation] Warning: could not open changelog PR for PR #%d: %v\n", event.PR.Number, err)
+		return
+	}
+	log.Printf("[Automation] ✓ Opened PR #%d adding PR #%d to %s of %s\n", number, event.PR.Number, path, event.Repository.FullName)
+}
@@ -0,0 +1,118 @@
+package main
+
+// PR description auto-population.
+//
+// On pull_request.opened, repos with a "description_template" in their
+// RepoConfig get a rendered metadata block appended to the PR description.
+// The block is wrapped in marker comments so re-running the automation
+// replaces it instead of appending a second copy.
+//
+// Template data (text/template):
+//
+//	.PR          NormalizedPR
+//	.Repository  NormalizedRepository
+//	.Tickets     []string  ticket keys found in the source branch name
+//	.Components  []string  top-level directories touched by the PR
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	descriptionBlockStart = "<!-- scm-gateway:metadata:start -->"
+	descriptionBlockEnd   = "<!-- scm-gateway:metadata:end -->"
+)
+
+// descriptionTemplateData is the data passed to a repo's description template.
+type descriptionTemplateData struct {
+	PR         NormalizedPR
+	Repository NormalizedRepository
+	Tickets    []string
+	Components []string
+}
+
+// applyDescriptionTemplate renders the repo's description template for a
+// freshly opened PR and writes the result back through the adapter.
+func applyDescriptionTemplate(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.Action != "opened" || event.PR.Number == 0 {
+		return
+	}
+	cfg := repoConfigFor(event.Platform, event.Repository.FullName)
+	if cfg.DescriptionTemplate == "" {
+		return
+	}
+	writer, ok := adapter.(PRWriter)
+	if !ok {
+		log.Printf("[Automation] Warning: %v\n", errUnsupported(adapter, "updating PR descriptions"))
+		return
+	}
+
+	tmpl, err := template.New("description").Parse(cfg.DescriptionTemplate)
+	if err != nil {
+		log.Printf("[Automation] Warning: invalid description template for %s: %v\n", event.Repository.FullName, err)
+		return
+	}
+
+	data := descriptionTemplateData{
+		PR:         event.PR,
+		Repository: event.Repository,
+		Tickets:    detectTicketKeys(event.PR.SourceBranch),
+		Components: changedComponents(event.Files),
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		log.Printf("[Automation] Warning: could not render description template for %s: %v\n", event.Repository.FullName, err)
+		return
+	}
+
+	description := withMetadataBlock(event.PR.Description, strings.TrimSpace(rendered.String()))
+	if description == event.PR.Description {
+		return
+	}
+	if err := writer.UpdatePRDescription(event.Repository.Owner, event.Repository.Name, event.PR.Number, description); err != nil {
+		log.Printf("[Automation] Warning: could not update description of PR #%d: %v\n", event.PR.Number, err)
+		return
+	}
+	event.PR.Description = description
+	log.Printf("[Automation] ✓ Updated description of PR #%d in %s\n", event.PR.Number, event.Repository.FullName)
+}
+
+// withMetadataBlock returns description with block placed between the
+// metadata markers, replacing a previous block if one is present.
+func withMetadataBlock(description, block string) string {
+	wrapped := fmt.Sprintf("%s\n%s\n%s", descriptionBlockStart, block, descriptionBlockEnd)
+
+	start := strings.Index(description, descriptionBlockStart)
+	end := strings.Index(description, descriptionBlockEnd)
+	if start >= 0 && end > start {
+		return description[:start] + wrapped + description[end+len(descriptionBlockEnd):]
+	}
+	if strings.TrimSpace(description) == "" {
+		return wrapped
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + wrapped
+}
+
+// changedComponents returns the sorted set of top-level directories touched
+// by files; files at the repository root are reported as "(root)".
+func changedComponents(files []NormalizedFile) []string {
+	seen := map[string]bool{}
+	for _, f := range files {
+		component := "(root)"
+		if i := strings.Index(f.Filename, "/"); i > 0 {
+			component = f.Filename[:i]
+		}
+		seen[component] = true
+	}
+	components := make([]string, 0, len(seen))
+	for c := range seen {
+		components = append(components, c)
+	}
+	sort.Strings(components)
+	return components
+}
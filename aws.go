@@ -0,0 +1,297 @@
+package main
+
+// AWS plumbing for the CodeCommit adapter, without the AWS SDK:
+//
+//   - signAWSv4 signs API requests with Signature Version 4.
+//   - verifySNSMessage checks the signature AWS puts on every SNS delivery,
+//     and that it comes from a topic listed in CODECOMMIT_SNS_TOPIC_ARNS.
+//   - unwrapSNSMessage returns the message an SNS notification carries.
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials are static AWS credentials.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string // temporary credentials only
+}
+
+// awsCredentialsFromEnv reads the standard AWS_* credential variables.
+func awsCredentialsFromEnv() awsCredentials {
+	return awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// signAWSv4 adds the X-Amz-Date and Authorization headers of a Signature
+// Version 4 signature to req, whose body is body. Host, Content-Type and the
+// X-Amz-* headers are signed.
+func signAWSv4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac([]byte("AWS4"+creds.secretAccessKey), date)
+	key = mac(key, region)
+	key = mac(key, service)
+	key = mac(key, "aws4_request")
+	signature := hex.EncodeToString(mac(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// SNS message types.
+const (
+	snsNotification             = "Notification"
+	snsSubscriptionConfirmation = "SubscriptionConfirmation"
+)
+
+// snsMessage is an SNS HTTP(S) delivery.
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Token            string `json:"Token"`
+}
+
+// stringToSign returns the canonical form of m that AWS signs: the signed
+// fields of the message type as "name\nvalue\n" pairs, in byte order.
+func (m snsMessage) stringToSign() string {
+	fields := []string{"Message", m.Message, "MessageId", m.MessageID}
+	if m.Type == snsNotification {
+		if m.Subject != "" {
+			fields = append(fields, "Subject", m.Subject)
+		}
+		fields = append(fields, "Timestamp", m.Timestamp, "TopicArn", m.TopicArn, "Type", m.Type)
+	} else {
+		fields = append(fields, "SubscribeURL", m.SubscribeURL, "Timestamp", m.Timestamp,
+			"Token", m.Token, "TopicArn", m.TopicArn, "Type", m.Type)
+	}
+	return strings.Join(fields, "\n") + "\n"
+}
+
+// snsHostPattern matches the hosts SNS signing certificates and subscription
+// confirmations are served from.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// isSNSURL reports whether raw is an HTTPS URL on an SNS host.
+func isSNSURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && snsHostPattern.MatchString(u.Host)
+}
+
+var (
+	snsCertsMu sync.Mutex
+	snsCerts   = map[string]*x509.Certificate{}
+)
+
+// snsSigningCert fetches (once) the certificate at certURL.
+func snsSigningCert(certURL string) (*x509.Certificate, error) {
+	snsCertsMu.Lock()
+	defer snsCertsMu.Unlock()
+	if cert, ok := snsCerts[certURL]; ok && time.Now().Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	if !isSNSURL(certURL) || !strings.HasSuffix(certURL, ".pem") {
+		return nil, fmt.Errorf("untrusted signing certificate URL %q", certURL)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing certificate: HTTP %d", resp.StatusCode)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	snsCerts[certURL] = cert
+	return cert, nil
+}
+
+// verify checks the signature of m.
+func (m snsMessage) verify() error {
+	cert, err := snsSigningCert(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("signing certificate has no RSA key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	switch m.SignatureVersion {
+	case "1":
+		digest := sha1.Sum([]byte(m.stringToSign()))
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA1, digest[:], sig)
+	case "2":
+		digest := sha256.Sum256([]byte(m.stringToSign()))
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	}
+	return fmt.Errorf("unsupported signature version %q", m.SignatureVersion)
+}
+
+// snsTopicAllowed reports whether topicArn is listed in
+// CODECOMMIT_SNS_TOPIC_ARNS. Any AWS account can subscribe the webhook URL to
+// its own topics, so a valid signature alone proves nothing.
+func snsTopicAllowed(topicArn string) bool {
+	for _, allowed := range strings.Split(os.Getenv("CODECOMMIT_SNS_TOPIC_ARNS"), ",") {
+		if strings.TrimSpace(allowed) == topicArn {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySNSMessage authenticates an SNS delivery, writing the error response
+// and returning false if it is not signed by AWS or comes from a topic that
+// is not allowed.
+func verifySNSMessage(w http.ResponseWriter, body []byte) bool {
+	if os.Getenv("CODECOMMIT_SNS_TOPIC_ARNS") == "" {
+		log.Println("Error: CODECOMMIT_SNS_TOPIC_ARNS environment variable not set")
+		http.Error(w, "webhook secret not configured", http.StatusInternalServerError)
+		return false
+	}
+	var m snsMessage
+	if err := json.Unmarshal(body, &m); err != nil || m.Type == "" {
+		log.Println("Error: delivery is not an SNS message")
+		http.Error(w, "not an SNS message", http.StatusBadRequest)
+		return false
+	}
+	if !snsTopicAllowed(m.TopicArn) {
+		log.Printf("Error: SNS topic %s is not in CODECOMMIT_SNS_TOPIC_ARNS\n", m.TopicArn)
+		http.Error(w, "topic not allowed", http.StatusForbidden)
+		return false
+	}
+	if err := m.verify(); err != nil {
+		log.Printf("Error: SNS signature verification failed: %v\n", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// unwrapSNSMessage returns the message a (verified) SNS notification carries.
+// Other SNS deliveries are returned unchanged with their type, so subscription
+// confirmations reach handlePing.
+func unwrapSNSMessage(body []byte) ([]byte, string) {
+	var m snsMessage
+	if json.Unmarshal(body, &m) != nil || m.Type == "" {
+		return body, ""
+	}
+	if m.Type != snsNotification {
+		return body, m.Type
+	}
+	return []byte(m.Message), ""
+}
+
+// confirmSNSSubscription visits the SubscribeURL of a (verified) subscription
+// confirmation, which activates the subscription.
+func confirmSNSSubscription(body []byte) (topicArn string, err error) {
+	var m snsMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", err
+	}
+	if !isSNSURL(m.SubscribeURL) {
+		return m.TopicArn, fmt.Errorf("untrusted SubscribeURL %q", m.SubscribeURL)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Get(m.SubscribeURL)
+	if err != nil {
+		return m.TopicArn, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return m.TopicArn, fmt.Errorf("SubscribeURL answered HTTP %d", resp.StatusCode)
+	}
+	return m.TopicArn, nil
+}
@@ -0,0 +1,537 @@
+package main
+
+// Bitbucket Connect app support — an alternative to app passwords.
+//
+// With BITBUCKET_CONNECT_KEY set, the gateway acts as a Connect app:
+//
+//	GET  /bitbucket/connect.json   app descriptor (install it from this URL)
+//	POST /bitbucket/installed      lifecycle: workspace installed the app
+//	POST /bitbucket/uninstalled    lifecycle: workspace removed the app
+//
+// Each installing workspace gets its own shared secret, stored with the
+// installation in BITBUCKET_CONNECT_FILE (JSON; in memory only if unset).
+// The install callback carries that secret itself, so it proves nothing on a
+// first install: only client keys listed in BITBUCKET_CONNECT_INSTALLS
+// ("workspace=clientKey,…") are accepted, each bound to its workspace.
+// The secret verifies the JWTs Bitbucket sends with lifecycle callbacks and
+// webhooks, and signs the gateway's own API calls for that workspace, so
+// the Bitbucket adapter needs no BITBUCKET_USERNAME/BITBUCKET_APP_PASSWORD.
+//
+// JWTs are HS256 with a query string hash ("qsh") binding the token to the
+// request; see computeQSH.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// connectJWTTTL is how long the JWTs the gateway signs are valid.
+const connectJWTTTL = 3 * time.Minute
+
+// ConnectInstallation is one workspace that installed the Connect app.
+type ConnectInstallation struct {
+	ClientKey     string    `json:"client_key"`
+	SharedSecret  string    `json:"shared_secret"`
+	BaseAPIURL    string    `json:"base_api_url"`
+	Workspace     string    `json:"workspace"`      // slug, e.g. "acme"
+	WorkspaceUUID string    `json:"workspace_uuid"` // e.g. "{6c1f…}"
+	InstalledAt   time.Time `json:"installed_at"`
+}
+
+// connectLifecyclePayload is the body of the installed/uninstalled callbacks.
+type connectLifecyclePayload struct {
+	Key          string `json:"key"`
+	ClientKey    string `json:"clientKey"`
+	SharedSecret string `json:"sharedSecret"`
+	BaseAPIURL   string `json:"baseApiUrl"`
+	Principal    struct {
+		UUID     string `json:"uuid"`
+		Username string `json:"username"`
+		Slug     string `json:"slug"`
+	} `json:"principal"`
+}
+
+// connectClaims are the claims of a Connect JWT.
+type connectClaims struct {
+	jwt.RegisteredClaims
+	QSH string `json:"qsh"`
+}
+
+// connectStore holds installations by client key, optionally persisted.
+type connectStore struct {
+	mu    sync.Mutex
+	path  string
+	byKey map[string]*ConnectInstallation
+}
+
+var (
+	connectOnce  sync.Once
+	connectState *connectStore
+)
+
+// connectAppKey returns the Connect app key; empty means Connect is disabled.
+func connectAppKey() string {
+	return os.Getenv("BITBUCKET_CONNECT_KEY")
+}
+
+// connectAllowedInstalls parses BITBUCKET_CONNECT_INSTALLS into the
+// workspace (slug or UUID) each allowed client key may install into.
+func connectAllowedInstalls() map[string]string {
+	allowed := map[string]string{}
+	for _, entry := range strings.Split(os.Getenv("BITBUCKET_CONNECT_INSTALLS"), ",") {
+		workspace, clientKey, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && workspace != "" && clientKey != "" {
+			allowed[strings.TrimSpace(clientKey)] = strings.TrimSpace(workspace)
+		}
+	}
+	return allowed
+}
+
+// matchesWorkspace reports whether workspace (slug or UUID) names inst's
+// workspace.
+func (inst ConnectInstallation) matchesWorkspace(workspace string) bool {
+	if workspace == "" {
+		return false
+	}
+	return strings.EqualFold(inst.Workspace, workspace) || (inst.WorkspaceUUID != "" && inst.WorkspaceUUID == workspace)
+}
+
+// connectInstallations returns the package-level installation store,
+// loading it on first use.
+func connectInstallations() *connectStore {
+	connectOnce.Do(func() {
+		connectState = &connectStore{path: os.Getenv("BITBUCKET_CONNECT_FILE"), byKey: map[string]*ConnectInstallation{}}
+		if connectState.path == "" {
+			return
+		}
+		data, err := os.ReadFile(connectState.path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("[Bitbucket Connect] Warning: could not read %s: %v\n", connectState.path, err)
+			}
+			return
+		}
+		var list []*ConnectInstallation
+		if err := json.Unmarshal(data, &list); err != nil {
+			log.Printf("[Bitbucket Connect] Warning: could not parse %s: %v\n", connectState.path, err)
+			return
+		}
+		for _, inst := range list {
+			connectState.byKey[inst.ClientKey] = inst
+		}
+		log.Printf("[Bitbucket Connect] Loaded %d installation(s) from %s\n", len(list), connectState.path)
+	})
+	return connectState
+}
+
+// save writes the store to its file. Callers must hold s.mu.
+func (s *connectStore) save() {
+	if s.path == "" {
+		return
+	}
+	list := make([]*ConnectInstallation, 0, len(s.byKey))
+	for _, inst := range s.byKey {
+		list = append(list, inst)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Workspace < list[j].Workspace })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("[Bitbucket Connect] Warning: could not persist installations: %v\n", err)
+	}
+}
+
+// get returns the installation with the given client key.
+func (s *connectStore) get(clientKey string) (ConnectInstallation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, ok := s.byKey[clientKey]
+	if !ok {
+		return ConnectInstallation{}, false
+	}
+	return *inst, true
+}
+
+// forWorkspace returns the installation of a workspace, by slug or UUID.
+func (s *connectStore) forWorkspace(workspace string) (ConnectInstallation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inst := range s.byKey {
+		if inst.matchesWorkspace(workspace) {
+			return *inst, true
+		}
+	}
+	return ConnectInstallation{}, false
+}
+
+// put stores inst, replacing any other installation of the same workspace
+// so that forWorkspace is unambiguous.
+func (s *connectStore) put(inst ConnectInstallation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, other := range s.byKey {
+		if key != inst.ClientKey && (other.matchesWorkspace(inst.Workspace) || other.matchesWorkspace(inst.WorkspaceUUID)) {
+			delete(s.byKey, key)
+		}
+	}
+	s.byKey[inst.ClientKey] = &inst
+	s.save()
+}
+
+func (s *connectStore) remove(clientKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byKey, clientKey)
+	s.save()
+}
+
+// computeQSH returns the Atlassian query string hash of a request: the hex
+// SHA-256 of "METHOD&path&canonical-query", where path is relative to the
+// app's (or product's) base URL and the query is sorted, percent-encoded and
+// excludes the jwt parameter.
+func computeQSH(method, path string, query url.Values) string {
+	if path == "" {
+		path = "/"
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	path = strings.ReplaceAll(path, "&", "%26")
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != "jwt" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for i, v := range values {
+			values[i] = connectEscape(v)
+		}
+		params = append(params, connectEscape(k)+"="+strings.Join(values, ","))
+	}
+
+	canonical := strings.ToUpper(method) + "&" + path + "&" + strings.Join(params, "&")
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// connectEscape percent-encodes like RFC 3986 (spaces as %20, "~" kept).
+func connectEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// signConnectJWT returns a JWT authorising method on u for inst. The qsh
+// path is relative to the installation's base API URL.
+func signConnectJWT(inst ConnectInstallation, method string, u *url.URL) (string, error) {
+	path := u.Path
+	if base, err := url.Parse(inst.BaseAPIURL); err == nil && base.Host == u.Host {
+		path = strings.TrimPrefix(path, strings.TrimRight(base.Path, "/"))
+	}
+	now := time.Now()
+	claims := connectClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    connectAppKey(),
+			Subject:   inst.ClientKey,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(connectJWTTTL)),
+		},
+		QSH: computeQSH(method, path, u.Query()),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(inst.SharedSecret))
+}
+
+// verifyConnectJWT authenticates an incoming request signed by Bitbucket.
+// secretFor returns the shared secret for the token's issuer (client key).
+func verifyConnectJWT(r *http.Request, secretFor func(clientKey string) (string, bool)) (*connectClaims, error) {
+	raw := strings.TrimPrefix(r.Header.Get("Authorization"), "JWT ")
+	if raw == r.Header.Get("Authorization") {
+		raw = r.URL.Query().Get("jwt")
+	}
+	if raw == "" {
+		return nil, fmt.Errorf("JWT missing")
+	}
+
+	claims := &connectClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		iss, _ := t.Claims.GetIssuer()
+		secret, ok := secretFor(iss)
+		if !ok {
+			return nil, fmt.Errorf("unknown client key %q", iss)
+		}
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	path := r.URL.Path
+	if base, err := url.Parse(os.Getenv("GATEWAY_PUBLIC_URL")); err == nil {
+		path = strings.TrimPrefix(path, strings.TrimRight(base.Path, "/"))
+	}
+	if claims.QSH != computeQSH(r.Method, path, r.URL.Query()) {
+		return nil, fmt.Errorf("qsh mismatch")
+	}
+	return claims, nil
+}
+
+// installedSecret looks up the stored shared secret of a client key.
+func installedSecret(clientKey string) (string, bool) {
+	inst, ok := connectInstallations().get(clientKey)
+	return inst.SharedSecret, ok
+}
+
+// verifyConnectWebhook authenticates a webhook delivered to a Connect app,
+// writing the error response and returning false if it is invalid. The JWT
+// issuer's installation must own the workspace of the repository in data,
+// the unwrapped event body, so one workspace cannot inject events for
+// another.
+func verifyConnectWebhook(w http.ResponseWriter, r *http.Request, data []byte) bool {
+	if connectAppKey() == "" {
+		http.Error(w, "Bitbucket Connect not configured", http.StatusUnauthorized)
+		return false
+	}
+	claims, err := verifyConnectJWT(r, installedSecret)
+	if err != nil {
+		log.Printf("Error: Bitbucket Connect JWT verification failed: %v\n", err)
+		http.Error(w, "invalid JWT", http.StatusUnauthorized)
+		return false
+	}
+	inst, _ := connectInstallations().get(claims.Issuer)
+	slug, uuid := connectPayloadWorkspace(data)
+	if !inst.matchesWorkspace(slug) && !inst.matchesWorkspace(uuid) {
+		log.Printf("Error: Bitbucket Connect webhook from %s is for workspace %q, not %q\n", claims.Issuer, slug, inst.Workspace)
+		http.Error(w, "workspace mismatch", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// connectPayloadWorkspace returns the slug and UUID of the workspace owning
+// the repository of a Bitbucket webhook payload.
+func connectPayloadWorkspace(data []byte) (slug, uuid string) {
+	var payload struct {
+		Repository struct {
+			FullName  string `json:"full_name"`
+			Workspace struct {
+				Slug string `json:"slug"`
+				UUID string `json:"uuid"`
+			} `json:"workspace"`
+		} `json:"repository"`
+	}
+	if json.Unmarshal(data, &payload) != nil {
+		return "", ""
+	}
+	slug = payload.Repository.Workspace.Slug
+	if slug == "" {
+		slug, _, _ = strings.Cut(payload.Repository.FullName, "/")
+	}
+	return slug, payload.Repository.Workspace.UUID
+}
+
+// unwrapConnectPayload returns the event body and key of a Connect webhook,
+// which wraps the regular webhook payload as {"event": …, "data": {…}}.
+// Other payloads are returned unchanged.
+func unwrapConnectPayload(body []byte) ([]byte, string) {
+	var envelope struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || envelope.Data == nil {
+		return body, ""
+	}
+	return envelope.Data, envelope.Event
+}
+
+// BitbucketConnectDescriptorHandler serves the Connect app descriptor.
+func BitbucketConnectDescriptorHandler(w http.ResponseWriter, r *http.Request) {
+	key := connectAppKey()
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+	webhooks := make([]map[string]string, 0, len(requiredBitbucketEvents))
+	for _, event := range requiredBitbucketEvents {
+		webhooks = append(webhooks, map[string]string{"event": event, "url": webhookPath + "/bitbucket"})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key":            key,
+		"name":           key,
+		"description":    "SCM event gateway",
+		"baseUrl":        setupBaseURL(r),
+		"authentication": map[string]string{"type": "jwt"},
+		"lifecycle": map[string]string{
+			"installed":   "/bitbucket/installed",
+			"uninstalled": "/bitbucket/uninstalled",
+		},
+		"scopes":   []string{"account", "repository", "pullrequest:write", "webhook"},
+		"contexts": []string{"account"},
+		"modules":  map[string]interface{}{"webhooks": webhooks},
+	})
+}
+
+// BitbucketConnectLifecycleHandler handles the installed and uninstalled
+// callbacks.
+func BitbucketConnectLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	if connectAppKey() == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusInternalServerError)
+		return
+	}
+	var p connectLifecyclePayload
+	if err := json.Unmarshal(body, &p); err != nil || p.ClientKey == "" {
+		http.Error(w, "invalid lifecycle payload", http.StatusBadRequest)
+		return
+	}
+	if p.Key != connectAppKey() {
+		http.Error(w, "unexpected app key", http.StatusBadRequest)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, "/bitbucket/") {
+	case "installed":
+		// The body names its own secret and workspace, so only allowlisted
+		// client keys may install, and only into their own workspace.
+		workspace := p.Principal.Slug
+		if workspace == "" {
+			workspace = p.Principal.Username
+		}
+		allowed, ok := connectAllowedInstalls()[p.ClientKey]
+		if !ok || (!strings.EqualFold(allowed, workspace) && allowed != p.Principal.UUID) {
+			log.Printf("[Bitbucket Connect] Rejected install of %s into %q: not in BITBUCKET_CONNECT_INSTALLS\n", p.ClientKey, workspace)
+			http.Error(w, "installation not allowed", http.StatusForbidden)
+			return
+		}
+		// A re-install must be signed with the secret we already hold, so a
+		// third party cannot replace it; a first install is signed with the
+		// secret it delivers.
+		secretFor := func(clientKey string) (string, bool) {
+			if clientKey != p.ClientKey {
+				return "", false
+			}
+			if secret, ok := installedSecret(clientKey); ok {
+				return secret, true
+			}
+			return p.SharedSecret, true
+		}
+		if _, err := verifyConnectJWT(r, secretFor); err != nil {
+			log.Printf("[Bitbucket Connect] Rejected install of %s: %v\n", p.ClientKey, err)
+			http.Error(w, "invalid JWT", http.StatusUnauthorized)
+			return
+		}
+		connectInstallations().put(ConnectInstallation{
+			ClientKey:     p.ClientKey,
+			SharedSecret:  p.SharedSecret,
+			BaseAPIURL:    p.BaseAPIURL,
+			Workspace:     workspace,
+			WorkspaceUUID: p.Principal.UUID,
+			InstalledAt:   time.Now(),
+		})
+		log.Printf("[Bitbucket Connect] Installed in workspace %s (%s)\n", workspace, p.ClientKey)
+
+	case "uninstalled":
+		// The removed client key comes from the body, which the qsh does
+		// not cover, so only the installation itself may sign its removal.
+		secretFor := func(clientKey string) (string, bool) {
+			if clientKey != p.ClientKey {
+				return "", false
+			}
+			return installedSecret(clientKey)
+		}
+		if _, err := verifyConnectJWT(r, secretFor); err != nil {
+			log.Printf("[Bitbucket Connect] Rejected uninstall of %s: %v\n", p.ClientKey, err)
+			http.Error(w, "invalid JWT", http.StatusUnauthorized)
+			return
+		}
+		connectInstallations().remove(p.ClientKey)
+		log.Printf("[Bitbucket Connect] Uninstalled from %s\n", p.ClientKey)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// connectAuthorize signs a Bitbucket API request with the JWT of the
+// installation owning the target workspace.
+func connectAuthorize(req *http.Request) error {
+	workspace := bitbucketWorkspace(req.URL)
+	inst, ok := connectInstallations().forWorkspace(workspace)
+	if !ok {
+		return fmt.Errorf("Bitbucket adapter: no Connect installation for workspace %q", workspace)
+	}
+	token, err := signConnectJWT(inst, req.Method, req.URL)
+	if err != nil {
+		return fmt.Errorf("Bitbucket adapter: signing Connect JWT failed: %w", err)
+	}
+	req.Header.Set("Authorization", "JWT "+token)
+	return nil
+}
+
+// connectAccessToken exchanges an installation JWT for an OAuth access
+// token, which git accepts for cloning.
+func connectAccessToken(workspace string) (string, error) {
+	inst, ok := connectInstallations().forWorkspace(workspace)
+	if !ok {
+		return "", fmt.Errorf("Bitbucket adapter: no Connect installation for workspace %q", workspace)
+	}
+	form := url.Values{"grant_type": {"urn:bitbucket:oauth2:jwt"}}
+	req, err := http.NewRequest("POST", "https://bitbucket.org/site/oauth2/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	token, err := signConnectJWT(inst, req.Method, req.URL)
+	if err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: signing Connect JWT failed: %w", err)
+	}
+	req.Header.Set("Authorization", "JWT "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: access token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Bitbucket adapter: access token request returned %d: %s", resp.StatusCode, string(body))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: invalid access token response: %w", err)
+	}
+	return tok.AccessToken, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+// Per-workspace Bitbucket credentials.
+//
+// Workspaces integrated through different service accounts list their app
+// passwords in the JSON file named by BITBUCKET_CREDENTIALS_FILE:
+//
+//	{
+//	  "acme":      { "username": "acme-bot", "app_password": "…" },
+//	  "acme-labs": { "username": "labs-ci",  "app_password": "…" }
+//	}
+//
+// Each API call is authenticated with the credentials of the workspace in
+// its URL — the workspace of the webhook being processed — falling back to
+// BITBUCKET_USERNAME / BITBUCKET_APP_PASSWORD for workspaces not listed.
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// bitbucketCredentials is one workspace's service account.
+type bitbucketCredentials struct {
+	Username    string `json:"username"`
+	AppPassword string `json:"app_password"`
+}
+
+var (
+	bitbucketCredentialsOnce sync.Once
+	workspaceCredentials     map[string]bitbucketCredentials // by lower-cased workspace
+)
+
+// loadBitbucketCredentials reads BITBUCKET_CREDENTIALS_FILE once.
+func loadBitbucketCredentials() map[string]bitbucketCredentials {
+	bitbucketCredentialsOnce.Do(func() {
+		path := os.Getenv("BITBUCKET_CREDENTIALS_FILE")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Bitbucket Adapter] Warning: could not read %s: %v\n", path, err)
+			return
+		}
+		var byWorkspace map[string]bitbucketCredentials
+		if err := json.Unmarshal(data, &byWorkspace); err != nil {
+			log.Printf("[Bitbucket Adapter] Warning: could not parse %s: %v\n", path, err)
+			return
+		}
+		workspaceCredentials = make(map[string]bitbucketCredentials, len(byWorkspace))
+		for workspace, creds := range byWorkspace {
+			workspaceCredentials[strings.ToLower(workspace)] = creds
+		}
+		log.Printf("[Bitbucket Adapter] Loaded credentials for %d workspace(s) from %s\n", len(workspaceCredentials), path)
+	})
+	return workspaceCredentials
+}
+
+// credentialsFor returns the username and app password to use for
+// workspace. Both are empty if none are configured.
+func (b *BitbucketAdapter) credentialsFor(workspace string) (string, string) {
+	if creds, ok := loadBitbucketCredentials()[strings.ToLower(workspace)]; ok {
+		return creds.Username, creds.AppPassword
+	}
+	return b.username, b.appPassword
+}
+
+// bitbucketWorkspace extracts the workspace from a Bitbucket API URL
+// (…/repositories/{workspace}/…).
+func bitbucketWorkspace(u *url.URL) string {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "repositories" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultCacheMaxEntries bounds each ttlCache unless CACHE_MAX_ENTRIES is set.
+const defaultCacheMaxEntries = 10000
+
+// ttlCache is a small goroutine-safe cache whose entries expire after a
+// fixed time-to-live. It holds at most CACHE_MAX_ENTRIES entries; when full,
+// the entry closest to expiry is dropped. Since every entry lives for the same
+// time-to-live, entries are kept in order of expiry, so a full cache evicts
+// in constant time.
+type ttlCache struct {
+	mu    sync.Mutex
+	name  string
+	ttl   time.Duration
+	max   int
+	items map[string]*list.Element // of *cacheEntry
+	order *list.List               // oldest (closest to expiry) first
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+var (
+	ttlCachesMu sync.Mutex
+	ttlCaches   []*ttlCache // every cache, for sweepCaches and the memory report
+)
+
+// newTTLCache returns an empty cache with the given time-to-live. name
+// identifies it in the memory report.
+func newTTLCache(name string, ttl time.Duration) *ttlCache {
+	c := &ttlCache{
+		name:  name,
+		ttl:   ttl,
+		max:   envInt("CACHE_MAX_ENTRIES", defaultCacheMaxEntries),
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+	ttlCachesMu.Lock()
+	ttlCaches = append(ttlCaches, c)
+	ttlCachesMu.Unlock()
+	return c
+}
+
+// Get returns the cached value for key if it has not expired.
+func (c *ttlCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key for the cache's time-to-live.
+func (c *ttlCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.value, e.expires = value, expires
+		c.order.MoveToBack(el)
+		return
+	}
+	if len(c.items) >= c.max {
+		c.sweep()
+		for len(c.items) >= c.max {
+			c.remove(c.order.Front())
+			metrics.cacheEvictions.Add(1)
+		}
+	}
+	c.items[key] = c.order.PushBack(&cacheEntry{key: key, value: value, expires: expires})
+}
+
+// Len returns the number of entries, expired ones included.
+func (c *ttlCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// sweep drops expired entries, which are at the front of c.order. Callers
+// must hold c.mu.
+func (c *ttlCache) sweep() {
+	now := time.Now()
+	for el := c.order.Front(); el != nil && now.After(el.Value.(*cacheEntry).expires); el = c.order.Front() {
+		c.remove(el)
+	}
+}
+
+// remove drops the entry of el. Callers must hold c.mu.
+func (c *ttlCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// sweepCaches drops the expired entries of every cache, so entries that are
+// never read again do not stay until the cache fills up.
+func sweepCaches() {
+	ttlCachesMu.Lock()
+	caches := append([]*ttlCache(nil), ttlCaches...)
+	ttlCachesMu.Unlock()
+	for _, c := range caches {
+		c.mu.Lock()
+		c.sweep()
+		c.mu.Unlock()
+	}
+}
+
+// cacheSizes returns the number of entries of every cache, by name.
+func cacheSizes() map[string]int {
+	ttlCachesMu.Lock()
+	caches := append([]*ttlCache(nil), ttlCaches...)
+	ttlCachesMu.Unlock()
+	sizes := make(map[string]int, len(caches))
+	for _, c := range caches {
+		sizes[c.name] += c.Len()
+	}
+	return sizes
+}
@@ -0,0 +1,218 @@
+package main
+
+// Canary mirroring — shadows a percentage of normalized events to a canary
+// sink (e.g. a new Platform BE build) so it can be validated on real traffic
+// before cutover.
+//
+//	CANARY_SINK_URL   canary endpoint; mirroring is off when unset
+//	CANARY_PERCENT    share of events mirrored, 0-100 (default 10)
+//	CANARY_BUFFER     mirrored events waiting for delivery (default 1000)
+//
+// Sampling is deterministic on the event ID, so a replayed event is mirrored
+// if and only if the original was. Mirrored events are delivered by a
+// background goroutine so a slow canary never delays the primary delivery;
+// when its buffer is full, events are dropped and counted. Canary failures never dead-letter or
+// count towards delivery alerts; instead each mirrored delivery is compared
+// with the Platform BE outcome and the tally is served at GET /admin/canary.
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxCanaryMismatches bounds the recent mismatches kept for inspection.
+const maxCanaryMismatches = 50
+
+// CanaryMismatch records a mirrored event whose canary outcome differed from
+// the primary one.
+type CanaryMismatch struct {
+	EventID      string    `json:"event_id"`
+	PRNumber     int       `json:"pr_number"`
+	PrimaryError string    `json:"primary_error,omitempty"`
+	CanaryError  string    `json:"canary_error,omitempty"`
+	PrimaryMS    int64     `json:"primary_ms"`
+	CanaryMS     int64     `json:"canary_ms"`
+	At           time.Time `json:"at"`
+}
+
+// CanaryStats compares canary delivery outcomes with the primary sink.
+type CanaryStats struct {
+	Mirrored        int              `json:"mirrored"`
+	BothSucceeded   int              `json:"both_succeeded"`
+	BothFailed      int              `json:"both_failed"`
+	CanaryOnlyFail  int              `json:"canary_only_failed"`
+	PrimaryOnlyFail int              `json:"primary_only_failed"`
+	Dropped         int              `json:"dropped"` // sampled but not mirrored: buffer full
+	PrimaryTotalMS  int64            `json:"primary_total_ms"`
+	CanaryTotalMS   int64            `json:"canary_total_ms"`
+	Mismatches      []CanaryMismatch `json:"recent_mismatches"`
+}
+
+// canaryMirror delivers sampled events to the canary sink and keeps stats.
+type canaryMirror struct {
+	sink    Sink
+	percent int
+	queue   chan canaryDelivery
+
+	mu    sync.Mutex
+	stats CanaryStats
+}
+
+// canaryDelivery is a sampled event with the outcome of its primary
+// delivery.
+type canaryDelivery struct {
+	event      *NormalizedEvent
+	primaryErr error
+	primaryDur time.Duration
+}
+
+var (
+	canaryOnce sync.Once
+	canary     *canaryMirror
+)
+
+// canarySink returns the configured canary mirror, or nil if disabled.
+func canarySink() *canaryMirror {
+	canaryOnce.Do(func() {
+		url := os.Getenv("CANARY_SINK_URL")
+		if url == "" {
+			return
+		}
+		percent := envInt("CANARY_PERCENT", 10)
+		if percent < 0 || percent > 100 {
+			log.Printf("[Canary] Warning: CANARY_PERCENT=%d out of range, mirroring disabled\n", percent)
+			return
+		}
+		canary = &canaryMirror{
+			sink:    &HTTPSink{name: "canary", url: url},
+			percent: percent,
+			queue:   make(chan canaryDelivery, envInt("CANARY_BUFFER", 1000)),
+		}
+		go canary.run()
+		log.Printf("[Canary] Mirroring %d%% of events to %s\n", percent, url)
+	})
+	return canary
+}
+
+// sampled reports whether event falls inside the mirrored percentage.
+func (c *canaryMirror) sampled(event *NormalizedEvent) bool {
+	h := fnv.New32a()
+	h.Write([]byte(event.EventID))
+	return int(h.Sum32()%100) < c.percent
+}
+
+// Mirror queues event for the canary if sampled, without blocking, along
+// with the outcome of the primary delivery (primaryErr, primaryDur).
+func (c *canaryMirror) Mirror(event *NormalizedEvent, primaryErr error, primaryDur time.Duration) {
+	if !c.sampled(event) {
+		return
+	}
+	select {
+	case c.queue <- canaryDelivery{event, primaryErr, primaryDur}:
+	default:
+		c.mu.Lock()
+		c.stats.Dropped++
+		c.mu.Unlock()
+	}
+}
+
+// run mirrors queued events until the process exits.
+func (c *canaryMirror) run() {
+	for d := range c.queue {
+		c.compare(d.event, d.primaryErr, d.primaryDur)
+	}
+}
+
+// compare delivers event to the canary and records how the outcome compares
+// with the primary delivery.
+func (c *canaryMirror) compare(event *NormalizedEvent, primaryErr error, primaryDur time.Duration) {
+	start := time.Now()
+	canaryErr := c.sink.Deliver(event)
+	canaryDur := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := &c.stats
+	s.Mirrored++
+	s.PrimaryTotalMS += primaryDur.Milliseconds()
+	s.CanaryTotalMS += canaryDur.Milliseconds()
+	switch {
+	case primaryErr == nil && canaryErr == nil:
+		s.BothSucceeded++
+		return
+	case primaryErr != nil && canaryErr != nil:
+		s.BothFailed++
+		return
+	case canaryErr != nil:
+		s.CanaryOnlyFail++
+		log.Printf("[Canary] Warning: canary failed where primary succeeded (event %s): %v\n", event.EventID, canaryErr)
+	default:
+		s.PrimaryOnlyFail++
+	}
+
+	m := CanaryMismatch{
+		EventID:   event.EventID,
+		PRNumber:  event.PR.Number,
+		PrimaryMS: primaryDur.Milliseconds(),
+		CanaryMS:  canaryDur.Milliseconds(),
+		At:        time.Now(),
+	}
+	if primaryErr != nil {
+		m.PrimaryError = primaryErr.Error()
+	}
+	if canaryErr != nil {
+		m.CanaryError = canaryErr.Error()
+	}
+	s.Mismatches = append(s.Mismatches, m)
+	if len(s.Mismatches) > maxCanaryMismatches {
+		s.Mismatches = s.Mismatches[len(s.Mismatches)-maxCanaryMismatches:]
+	}
+}
+
+// Stats returns a copy of the comparison so far.
+func (c *canaryMirror) Stats() CanaryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Mismatches = append([]CanaryMismatch(nil), c.stats.Mismatches...)
+	return s
+}
+
+// AdminCanaryHandler reports canary-vs-primary delivery outcomes.
+//
+//	GET /admin/canary
+func AdminCanaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	c := canarySink()
+	if c == nil {
+		http.Error(w, "canary mirroring disabled (CANARY_SINK_URL not set)", http.StatusNotFound)
+		return
+	}
+
+	stats := c.Stats()
+	agreement := 1.0
+	if stats.Mirrored > 0 {
+		agreement = float64(stats.BothSucceeded+stats.BothFailed) / float64(stats.Mirrored)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"percent":   c.percent,
+		"agreement": agreement,
+		"stats":     stats,
+	})
+}
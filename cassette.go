@@ -0,0 +1,222 @@
+package main
+
+// API cassettes — record the gateway's outbound HTTP interactions to a file
+// and replay them later without credentials or network, so adapter behaviour
+// can be reproduced exactly in local development and tests:
+//
+//	API_CASSETTE        "record" or "replay" (unset: off)
+//	API_CASSETTE_FILE   the cassette, a JSON file (default cassette.json)
+//
+// Recording passes every request through and appends the interaction to the
+// file, sanitized like API recordings (api_recording.go): credentials in
+// headers, query parameters and JSON and form-encoded bodies are redacted, so
+// cassettes can be committed. Response bodies are kept whole, other formats
+// unredacted.
+//
+// Replaying serves each request from the cassette instead of the network.
+// Interactions are matched by method and sanitized URL; requests to the same
+// URL get the recorded responses in order, the last one repeating once they
+// run out. A request the cassette does not hold fails rather than reaching
+// the network. Event streams (WEBHOOK_RELAY_URL) bypass the cassette.
+//
+// GitHub App JWTs are not signed while replaying, so GITHUB_APP_ID and
+// GITHUB_PRIVATE_KEY, like the other platforms' credentials, only need
+// placeholder values.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const defaultCassetteFile = "cassette.json"
+
+// CassetteInteraction is one recorded request and its response.
+type CassetteInteraction struct {
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body"`
+}
+
+// cassetteTransport records to or replays from a cassette file.
+type cassetteTransport struct {
+	base   http.RoundTripper
+	path   string
+	replay bool
+
+	mu           sync.Mutex
+	interactions []CassetteInteraction
+	played       map[string]int // replay: responses served per key
+}
+
+// cassetteMode returns API_CASSETTE, or "" when cassettes are off.
+func cassetteMode() string {
+	return strings.ToLower(os.Getenv("API_CASSETTE"))
+}
+
+// cassetteReplaying reports whether outbound calls are served from a
+// cassette.
+func cassetteReplaying() bool {
+	return cassetteMode() == "replay"
+}
+
+// installCassetteTransport wraps http.DefaultTransport with the cassette
+// named by API_CASSETTE_FILE when API_CASSETTE is set. It exits when a
+// cassette to replay cannot be loaded, since every call would fail.
+func installCassetteTransport() {
+	mode := cassetteMode()
+	if mode == "" {
+		return
+	}
+	path := os.Getenv("API_CASSETTE_FILE")
+	if path == "" {
+		path = defaultCassetteFile
+	}
+	ct := &cassetteTransport{base: http.DefaultTransport, path: path, played: map[string]int{}}
+	switch mode {
+	case "record":
+		// Appending to an existing cassette keeps earlier sessions.
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &ct.interactions); err != nil {
+				log.Fatalf("[Cassette] %s is not a cassette: %v\n", path, err)
+			}
+		}
+		log.Printf("[Cassette] Recording outbound API calls to %s\n", path)
+	case "replay":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("[Cassette] Cannot replay: %v\n", err)
+		}
+		if err := json.Unmarshal(data, &ct.interactions); err != nil {
+			log.Fatalf("[Cassette] %s is not a cassette: %v\n", path, err)
+		}
+		ct.replay = true
+		log.Printf("[Cassette] Replaying %d interaction(s) from %s; the network is not used\n", len(ct.interactions), path)
+	default:
+		log.Printf("[Cassette] Warning: unknown API_CASSETTE %q, cassettes disabled\n", mode)
+		return
+	}
+	http.DefaultTransport = ct
+}
+
+func (ct *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Event streams (the webhook relay) never end, so they cannot be taped.
+	if req.Header.Get("Accept") == "text/event-stream" {
+		return ct.base.RoundTrip(req)
+	}
+	if ct.replay {
+		return ct.play(req)
+	}
+	return ct.record(req)
+}
+
+// play serves req from the cassette.
+func (ct *cassetteTransport) play(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + sanitizeURL(req.URL)
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	var matches []CassetteInteraction
+	for _, in := range ct.interactions {
+		if in.Method+" "+in.URL == key {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette %s has no interaction for %s", ct.path, key)
+	}
+	in := matches[min(ct.played[key], len(matches)-1)]
+	ct.played[key]++
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(in.ResponseBody)),
+		ContentLength: int64(len(in.ResponseBody)),
+		Request:       req,
+	}
+	for k, v := range in.ResponseHeaders {
+		// Redaction changes body sizes.
+		if !strings.EqualFold(k, "Content-Length") {
+			resp.Header.Set(k, v)
+		}
+	}
+	return resp, nil
+}
+
+// record performs req and appends the sanitized interaction to the cassette.
+func (ct *cassetteTransport) record(req *http.Request) (*http.Response, error) {
+	in := CassetteInteraction{
+		Method:         req.Method,
+		URL:            sanitizeURL(req.URL),
+		RequestHeaders: sanitizeHeaders(req.Header),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			in.RequestBody = cassetteBody(data, req.Header.Get("Content-Type"))
+		}
+	}
+
+	resp, err := ct.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	in.Status = resp.StatusCode
+	in.ResponseHeaders = sanitizeHeaders(resp.Header)
+	in.ResponseBody = cassetteBody(data, resp.Header.Get("Content-Type"))
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.interactions = append(ct.interactions, in)
+	if err := ct.save(); err != nil {
+		log.Printf("[Cassette] Warning: could not write %s: %v\n", ct.path, err)
+	}
+	return resp, nil
+}
+
+// cassetteBody redacts a JSON or form-encoded body. Other bodies (diffs,
+// file contents) are kept as they are, since replay needs them whole.
+func cassetteBody(data []byte, contentType string) string {
+	if clean, ok := redactBody(data, contentType); ok {
+		return clean
+	}
+	return string(data)
+}
+
+// save rewrites the cassette file atomically. The caller holds ct.mu.
+func (ct *cassetteTransport) save() error {
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false) // keep URLs readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ct.interactions); err != nil {
+		return err
+	}
+	tmp := ct.path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ct.path)
+}
@@ -0,0 +1,53 @@
+package main
+
+// Platform-agnostic check runs and commit statuses.
+//
+// GitHub publishes check runs through the Checks API; Bitbucket Cloud through
+// Code Insights reports, whose annotations play the same role. Commit
+// statuses are GitHub's statuses and Bitbucket's build statuses.
+
+// Check run conclusions.
+const (
+	CheckSuccess = "success"
+	CheckFailure = "failure"
+	CheckNeutral = "neutral"
+)
+
+// Commit status states.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
+// Annotation levels, in GitHub's vocabulary.
+const (
+	AnnotationNotice  = "notice"
+	AnnotationWarning = "warning"
+	AnnotationFailure = "failure"
+)
+
+// maxCheckAnnotations is the number of annotations GitHub accepts per check
+// run request; further findings are summarized instead.
+const maxCheckAnnotations = 50
+
+// CheckAnnotation anchors a finding to a file and line range.
+type CheckAnnotation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Level     string // AnnotationNotice, AnnotationWarning or AnnotationFailure
+	Title     string
+	Message   string
+}
+
+// CheckRun is a completed check with optional annotations on a commit.
+type CheckRun struct {
+	Name        string
+	HeadSHA     string
+	Conclusion  string // CheckSuccess, CheckFailure or CheckNeutral
+	Title       string
+	Summary     string
+	Annotations []CheckAnnotation
+}
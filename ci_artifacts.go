@@ -0,0 +1,233 @@
+package main
+
+// CI artifact and log proxy — lists the workflow runs of a commit and streams
+// their artifacts and logs with the gateway's installation auth, so tooling
+// that analyses CI failures needs no SCM credentials of its own. Logs and
+// artifacts of private repos routinely hold secrets, so every endpoint
+// requires the admin token:
+//
+//	GET /ci/runs?owner=X&repo=Y&sha=S            → runs with their jobs and artifacts
+//	GET /ci/artifact?owner=X&repo=Y&id=N         → artifact archive (zip)
+//	GET /ci/logs?owner=X&repo=Y&run_id=N         → logs of every job of a run (zip)
+//	GET /ci/logs?owner=X&repo=Y&run_id=N&job_id=M → log of one job (plain text)
+//
+// Downloads are streamed, not buffered, and capped at CI_DOWNLOAD_MAX_MB
+// (default 100): a download whose size is known up front to exceed the cap is
+// refused with 413, one of unknown size is cut off at the cap and reported in
+// the X-Gateway-Truncated trailer.
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultCIDownloadMaxMB = 100
+
+// WorkflowRun is a CI run of a commit.
+type WorkflowRun struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	Event      string             `json:"event"`
+	Status     string             `json:"status"`     // queued, in_progress, completed
+	Conclusion string             `json:"conclusion"` // success, failure, cancelled, … once completed
+	HeadSHA    string             `json:"head_sha"`
+	HeadBranch string             `json:"head_branch"`
+	Attempt    int                `json:"attempt"`
+	URL        string             `json:"url"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Jobs       []WorkflowJob      `json:"jobs"`
+	Artifacts  []WorkflowArtifact `json:"artifacts"`
+}
+
+// WorkflowJob is a job of a CI run (latest attempt).
+type WorkflowJob struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+// WorkflowArtifact is an artifact uploaded by a CI run.
+type WorkflowArtifact struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	Expired   bool      `json:"expired"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ciArtifactReader resolves the adapter of the request's platform (default
+// github) and its CIArtifactReader, writing the error response if either is
+// unavailable.
+func ciArtifactReader(w http.ResponseWriter, r *http.Request) (CIArtifactReader, bool) {
+	platform := SCMPlatform(r.URL.Query().Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	reader, ok := adapter.(CIArtifactReader)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "CI artifacts").Error(), http.StatusNotImplemented)
+		return nil, false
+	}
+	return reader, true
+}
+
+// CIRunsHandler lists the workflow runs of a commit.
+//
+//	GET /ci/runs?owner=X&repo=Y&sha=S[&platform=github]
+func CIRunsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	owner, repo, sha := q.Get("owner"), q.Get("repo"), q.Get("sha")
+	if owner == "" || repo == "" || sha == "" {
+		http.Error(w, "owner, repo and sha parameters are required", http.StatusBadRequest)
+		return
+	}
+	reader, ok := ciArtifactReader(w, r)
+	if !ok {
+		return
+	}
+	runs, err := reader.ListWorkflowRuns(owner, repo, sha)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"owner":  owner,
+		"repo":   repo,
+		"sha":    sha,
+		"count":  len(runs),
+		"runs":   runs,
+	})
+}
+
+// CIArtifactHandler streams an artifact archive.
+//
+//	GET /ci/artifact?owner=X&repo=Y&id=N[&platform=github]
+func CIArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	id, err := strconv.ParseInt(q.Get("id"), 10, 64)
+	if owner == "" || repo == "" || err != nil || id <= 0 {
+		http.Error(w, "owner, repo and a numeric id are required", http.StatusBadRequest)
+		return
+	}
+	reader, ok := ciArtifactReader(w, r)
+	if !ok {
+		return
+	}
+	body, size, err := reader.OpenArtifact(owner, repo, id)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+	streamCIDownload(w, body, size, "application/zip", fmt.Sprintf("artifact-%d.zip", id))
+}
+
+// CILogsHandler streams the logs of a run, or of one of its jobs.
+//
+//	GET /ci/logs?owner=X&repo=Y&run_id=N[&job_id=M][&platform=github]
+func CILogsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	runID, err := strconv.ParseInt(q.Get("run_id"), 10, 64)
+	if owner == "" || repo == "" || err != nil || runID <= 0 {
+		http.Error(w, "owner, repo and a numeric run_id are required", http.StatusBadRequest)
+		return
+	}
+	var jobID int64
+	if s := q.Get("job_id"); s != "" {
+		if jobID, err = strconv.ParseInt(s, 10, 64); err != nil || jobID <= 0 {
+			http.Error(w, "job_id must be numeric", http.StatusBadRequest)
+			return
+		}
+	}
+	reader, ok := ciArtifactReader(w, r)
+	if !ok {
+		return
+	}
+	body, size, err := reader.OpenLogs(owner, repo, runID, jobID)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+	if jobID > 0 {
+		streamCIDownload(w, body, size, "text/plain; charset=utf-8", fmt.Sprintf("job-%d.log", jobID))
+		return
+	}
+	streamCIDownload(w, body, size, "application/zip", fmt.Sprintf("run-%d-logs.zip", runID))
+}
+
+// streamCIDownload copies a download of size bytes (-1 if unknown) to w,
+// enforcing CI_DOWNLOAD_MAX_MB.
+func streamCIDownload(w http.ResponseWriter, body io.Reader, size int64, contentType, filename string) {
+	max := int64(envInt("CI_DOWNLOAD_MAX_MB", defaultCIDownloadMaxMB)) << 20
+	if size > max {
+		http.Error(w, fmt.Sprintf("download is %d bytes, over the %d byte limit", size, max), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	} else {
+		w.Header().Set("Trailer", "X-Gateway-Truncated")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.Copy(w, io.LimitReader(body, max))
+	if err != nil {
+		log.Printf("[CI] Warning: download of %s aborted after %d bytes: %v\n", filename, n, err)
+		return
+	}
+	if size < 0 {
+		truncated := n == max && isMoreData(body)
+		if truncated {
+			log.Printf("[CI] Warning: download of %s truncated at %d bytes\n", filename, max)
+		}
+		w.Header().Set("X-Gateway-Truncated", strconv.FormatBool(truncated))
+	}
+}
+
+// isMoreData reports whether body has at least one more byte.
+func isMoreData(body io.Reader) bool {
+	var b [1]byte
+	n, _ := io.ReadFull(body, b[:])
+	return n > 0
+}
@@ -0,0 +1,244 @@
+// Command fakebe is a stand-in for the Platform BE for local development.
+// It accepts the gateway's event deliveries, checks them, records them and
+// fails on request, so the delivery and retry path can be tested end to end:
+//
+//	go run ./cmd/fakebe -addr :4000 -secret s3cret -fail-rate 0.2
+//	PLATFORM_BE_URL=http://localhost:4000/events   (or a subscription URL)
+//
+// Endpoints:
+//
+//	POST   /events    a delivery: verified, validated, recorded
+//	GET    /events    recorded deliveries, newest last
+//	DELETE /events    forget recorded deliveries
+//	GET    /control   current failure settings
+//	POST   /control   change them: ?fail_rate=0.5&fail_status=503&latency=2s
+//
+// With -secret, deliveries must carry a valid X-Hub-Signature-256 (as
+// subscription deliveries do). Every body is decoded strictly into the
+// gateway client's Event type, so fields the gateway adds or renames without
+// updating pkg/client are reported as schema errors.
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"server/pkg/client"
+)
+
+// delivery is one recorded request.
+type delivery struct {
+	ReceivedAt  time.Time     `json:"received_at"`
+	DeliveryID  string        `json:"delivery_id,omitempty"`
+	EventType   string        `json:"event_type,omitempty"`
+	Status      int           `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	SignatureOK *bool         `json:"signature_ok,omitempty"`
+	Event       *client.Event `json:"event,omitempty"`
+}
+
+// behaviour is how the fake answers deliveries.
+type behaviour struct {
+	FailRate   float64       `json:"fail_rate"`
+	FailStatus int           `json:"fail_status"`
+	Latency    time.Duration `json:"latency"`
+}
+
+type fakeBE struct {
+	secret  string
+	maxKept int
+
+	mu         sync.Mutex
+	behaviour  behaviour
+	deliveries []delivery
+}
+
+func main() {
+	addr := flag.String("addr", ":4000", "listen address")
+	secret := flag.String("secret", "", "HMAC secret deliveries must be signed with (none: not checked)")
+	failRate := flag.Float64("fail-rate", 0, "share of deliveries answered with -fail-status, 0-1")
+	failStatus := flag.Int("fail-status", http.StatusServiceUnavailable, "status code of failed deliveries")
+	latency := flag.Duration("latency", 0, "delay before answering each delivery")
+	keep := flag.Int("keep", 1000, "deliveries kept in memory")
+	flag.Parse()
+
+	be := &fakeBE{
+		secret:    *secret,
+		maxKept:   *keep,
+		behaviour: behaviour{FailRate: *failRate, FailStatus: *failStatus, Latency: *latency},
+	}
+	http.HandleFunc("/events", be.eventsHandler)
+	http.HandleFunc("/control", be.controlHandler)
+
+	log.Printf("[FakeBE] Listening on %s (fail_rate=%.2f fail_status=%d latency=%s)\n",
+		*addr, *failRate, *failStatus, *latency)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (be *fakeBE) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		be.receive(w, r)
+	case http.MethodGet:
+		be.mu.Lock()
+		list := append([]delivery(nil), be.deliveries...)
+		be.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(list), "deliveries": list})
+	case http.MethodDelete:
+		be.mu.Lock()
+		be.deliveries = nil
+		be.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// receive checks, records and answers one delivery.
+func (be *fakeBE) receive(w http.ResponseWriter, r *http.Request) {
+	be.mu.Lock()
+	b := be.behaviour
+	be.mu.Unlock()
+	if b.Latency > 0 {
+		time.Sleep(b.Latency)
+	}
+
+	d := delivery{
+		ReceivedAt: time.Now(),
+		DeliveryID: r.Header.Get("X-Gateway-Delivery"),
+		EventType:  r.Header.Get("X-Gateway-Event"),
+	}
+	body, err := io.ReadAll(r.Body)
+	switch {
+	case err != nil:
+		d.Status, d.Error = http.StatusBadRequest, "cannot read body"
+	case be.secret != "" && !validSignature(body, r.Header.Get("X-Hub-Signature-256"), be.secret):
+		ok := false
+		d.SignatureOK = &ok
+		d.Status, d.Error = http.StatusUnauthorized, "invalid signature"
+	default:
+		if be.secret != "" {
+			ok := true
+			d.SignatureOK = &ok
+		}
+		event, err := decodeEvent(body)
+		d.Event = event
+		if err != nil {
+			d.Status, d.Error = http.StatusBadRequest, "schema: "+err.Error()
+		} else if b.FailRate > 0 && rand.Float64() < b.FailRate {
+			d.Status, d.Error = b.FailStatus, "injected failure"
+		} else {
+			d.Status = http.StatusOK
+		}
+	}
+	if d.EventType == "" && d.Event != nil {
+		d.EventType = d.Event.EventType
+	}
+
+	be.mu.Lock()
+	be.deliveries = append(be.deliveries, d)
+	if len(be.deliveries) > be.maxKept {
+		be.deliveries = be.deliveries[len(be.deliveries)-be.maxKept:]
+	}
+	be.mu.Unlock()
+
+	log.Printf("[FakeBE] %d %s delivery=%s %s\n", d.Status, d.EventType, d.DeliveryID, d.Error)
+	if d.Status != http.StatusOK {
+		http.Error(w, d.Error, d.Status)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// decodeEvent decodes body strictly and checks the fields every event has.
+func decodeEvent(body []byte) (*client.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	var event client.Event
+	if err := dec.Decode(&event); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for name, empty := range map[string]bool{
+		"SchemaVersion": event.SchemaVersion == 0,
+		"Platform":      event.Platform == "",
+		"EventType":     event.EventType == "",
+		"ReceivedAt":    event.ReceivedAt.IsZero(),
+	} {
+		if empty {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return &event, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return &event, nil
+}
+
+func (be *fakeBE) controlHandler(w http.ResponseWriter, r *http.Request) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		b := be.behaviour
+		if v := q.Get("fail_rate"); v != "" {
+			rate, err := strconv.ParseFloat(v, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				http.Error(w, "fail_rate must be between 0 and 1", http.StatusBadRequest)
+				return
+			}
+			b.FailRate = rate
+		}
+		if v := q.Get("fail_status"); v != "" {
+			status, err := strconv.Atoi(v)
+			if err != nil || status < 400 || status > 599 {
+				http.Error(w, "fail_status must be a 4xx or 5xx code", http.StatusBadRequest)
+				return
+			}
+			b.FailStatus = status
+		}
+		if v := q.Get("latency"); v != "" {
+			latency, err := time.ParseDuration(v)
+			if err != nil || latency < 0 {
+				http.Error(w, "latency must be a duration such as 500ms", http.StatusBadRequest)
+				return
+			}
+			b.Latency = latency
+		}
+		be.behaviour = b
+		log.Printf("[FakeBE] Now fail_rate=%.2f fail_status=%d latency=%s\n", b.FailRate, b.FailStatus, b.Latency)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, be.behaviour)
+}
+
+// validSignature checks a "sha256=<hex>" HMAC of body.
+func validSignature(body []byte, signature, secret string) bool {
+	signature = strings.TrimPrefix(signature, "sha256=")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,183 @@
+package main
+
+// Comment de-noising — drops comment events caused by the gateway's own
+// writes, so the reviewers and policies that comment on PRs never react to
+// themselves in a loop.
+//
+// Comment events reach the pipeline in passthrough mode (Bitbucket
+// pullrequest:comment_*, Gerrit comment-added; see PR_ACTION_PASSTHROUGH). An
+// event is treated as self-authored when either
+//
+//   - its author is one of the gateway's identities: the GitHub App
+//     (performed_via_github_app), BITBUCKET_USERNAME and the accounts in
+//     BITBUCKET_CREDENTIALS_FILE, GERRIT_USERNAME, or any login listed in
+//     GATEWAY_BOT_USERS; or
+//   - its body matches a comment the gateway posted on the same PR in the
+//     last 10 minutes, which also catches shared service accounts.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// selfCommentTTL is how long a posted comment is remembered.
+const selfCommentTTL = 10 * time.Minute
+
+var (
+	selfCommentsOnce sync.Once
+	selfCommentsRef  *ttlCache
+)
+
+// selfComments holds the keys of recently posted comments.
+func selfComments() *ttlCache {
+	selfCommentsOnce.Do(func() {
+		selfCommentsRef = newTTLCache("self_comments", selfCommentTTL)
+	})
+	return selfCommentsRef
+}
+
+// selfCommentKey identifies a comment body on one PR.
+func selfCommentKey(platform SCMPlatform, repoFullName string, prNumber int, body string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s", platform, strings.ToLower(repoFullName), prNumber, strings.TrimSpace(body))))
+	return hex.EncodeToString(sum[:])
+}
+
+// rememberSelfComment records a comment the gateway is about to post. It is
+// called before the write, since the resulting webhook can arrive before the
+// API call returns.
+func rememberSelfComment(platform SCMPlatform, repoFullName string, prNumber int, body string) {
+	selfComments().Set(selfCommentKey(platform, repoFullName, prNumber, body), true)
+}
+
+// isRememberedSelfComment reports whether body was posted by the gateway on
+// the PR within selfCommentTTL.
+func isRememberedSelfComment(platform SCMPlatform, repoFullName string, prNumber int, body string) bool {
+	_, ok := selfComments().Get(selfCommentKey(platform, repoFullName, prNumber, body))
+	return ok
+}
+
+// gatewayIdentities returns the lower-cased logins the gateway writes as.
+func gatewayIdentities() map[string]bool {
+	ids := map[string]bool{}
+	add := func(login string) {
+		if login = strings.ToLower(strings.TrimSpace(login)); login != "" {
+			ids[login] = true
+		}
+	}
+	for _, login := range strings.Split(os.Getenv("GATEWAY_BOT_USERS"), ",") {
+		add(login)
+	}
+	add(os.Getenv("BITBUCKET_USERNAME"))
+	for _, creds := range loadBitbucketCredentials() {
+		add(creds.Username)
+	}
+	add(os.Getenv("GERRIT_USERNAME"))
+	return ids
+}
+
+// isGatewayAuthor reports whether a comment by author, posted through the
+// GitHub App viaApp (0 if none), was written by one of the gateway's
+// identities.
+func isGatewayAuthor(author string, viaApp int64) bool {
+	if viaApp != 0 && fmt.Sprint(viaApp) == getAppIDFromEnv() {
+		return true
+	}
+	return gatewayIdentities()[strings.ToLower(author)]
+}
+
+// prComment is the comment carried by a comment event.
+type prComment struct {
+	Author string
+	Body   string
+	ViaApp int64 // GitHub App that posted it, if any
+}
+
+// extractPRComment returns the comment of a comment event's raw payload.
+func extractPRComment(platform SCMPlatform, payload []byte) (prComment, bool) {
+	switch platform {
+	case PlatformGitHub, PlatformGitea:
+		var p struct {
+			Comment *struct {
+				Body string `json:"body"`
+				User struct {
+					Login string `json:"login"`
+				} `json:"user"`
+				PerformedVia *struct {
+					ID int64 `json:"id"`
+				} `json:"performed_via_github_app"`
+			} `json:"comment"`
+		}
+		if json.Unmarshal(payload, &p) != nil || p.Comment == nil {
+			return prComment{}, false
+		}
+		c := prComment{Author: p.Comment.User.Login, Body: p.Comment.Body}
+		if p.Comment.PerformedVia != nil {
+			c.ViaApp = p.Comment.PerformedVia.ID
+		}
+		return c, true
+
+	case PlatformBitbucket:
+		var p struct {
+			Comment *struct {
+				Content struct {
+					Raw string `json:"raw"`
+				} `json:"content"`
+				User struct {
+					Nickname string `json:"nickname"`
+				} `json:"user"`
+			} `json:"comment"`
+		}
+		if json.Unmarshal(payload, &p) != nil || p.Comment == nil {
+			return prComment{}, false
+		}
+		return prComment{Author: p.Comment.User.Nickname, Body: p.Comment.Content.Raw}, true
+
+	case PlatformBitbucketServer:
+		var p struct {
+			Comment *struct {
+				Text   string `json:"text"`
+				Author struct {
+					Slug string `json:"slug"`
+				} `json:"author"`
+			} `json:"comment"`
+		}
+		if json.Unmarshal(payload, &p) != nil || p.Comment == nil {
+			return prComment{}, false
+		}
+		return prComment{Author: p.Comment.Author.Slug, Body: p.Comment.Text}, true
+
+	case PlatformGerrit:
+		var p struct {
+			Type    string        `json:"type"`
+			Comment string        `json:"comment"`
+			Author  gerritAccount `json:"author"`
+		}
+		if json.Unmarshal(payload, &p) != nil || p.Type != "comment-added" {
+			return prComment{}, false
+		}
+		return prComment{Author: p.Author.login(), Body: p.Comment}, true
+	}
+	return prComment{}, false
+}
+
+// isSelfComment reports whether event is a comment the gateway wrote.
+func isSelfComment(event *NormalizedEvent) bool {
+	if event.Thread != nil {
+		// Resolving a thread the gateway started is someone else's action.
+		return false
+	}
+	c, ok := extractPRComment(event.Platform, event.RawPayload)
+	if !ok {
+		return false
+	}
+	if isGatewayAuthor(c.Author, c.ViaApp) {
+		return true
+	}
+	return isRememberedSelfComment(event.Platform, event.Repository.FullName, event.PR.Number, c.Body)
+}
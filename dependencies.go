@@ -0,0 +1,164 @@
+package main
+
+// Cross-repo PR dependencies.
+//
+// A PR declares the PRs it waits for with "depends on" lines in its
+// description:
+//
+//	Depends on acme/api#123
+//	depends on #45, acme/web#7
+//
+// A bare #N is a PR of the same repository. The "dependencies" enricher looks
+// each reference up through the adapter (PRReader.GetPRDetails, so on the
+// same platform) and attaches it to the event as a PRDependency. While an
+// open PR has dependencies that are not merged yet, a
+// pull_request.dependency_blocked event listing them follows the PR's own
+// event.
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventTypeDependencyBlocked is emitted after an event of an open PR whose
+// dependencies are not all merged.
+const EventTypeDependencyBlocked = "pull_request.dependency_blocked"
+
+// maxDependencies caps the references looked up per PR.
+const maxDependencies = 20
+
+// PRDependency is a PR that a PR depends on.
+type PRDependency struct {
+	Repository string // full name, e.g. "acme/api"
+	Number     int
+	Title      string
+	State      string // the dependency's PR state, e.g. "open", "merged"
+	URL        string
+	Validated  bool // true when the PR was found through the SCM API
+}
+
+var (
+	dependsOnPattern     = regexp.MustCompile(`(?im)^[\s>*-]*depends\s+on:?\s+(.+)$`)
+	dependencyRefPattern = regexp.MustCompile(`(?:\b([\w.-]+/[\w.-]+))?#(\d+)\b`)
+)
+
+// parseDependencies returns the PRs referenced on the "depends on" lines of
+// description, in order of first appearance, qualifying bare #N references
+// with repo. A reference to the PR itself (repo#self) is ignored.
+func parseDependencies(description, repo string, self int) []PRDependency {
+	seen := map[string]bool{}
+	var deps []PRDependency
+	for _, line := range dependsOnPattern.FindAllStringSubmatch(description, -1) {
+		for _, m := range dependencyRefPattern.FindAllStringSubmatch(line[1], -1) {
+			number, err := strconv.Atoi(m[2])
+			if err != nil || number == 0 {
+				continue
+			}
+			full := m[1]
+			if full == "" {
+				full = repo
+			}
+			key := strings.ToLower(full) + "#" + m[2]
+			if seen[key] || (strings.EqualFold(full, repo) && number == self) {
+				continue
+			}
+			seen[key] = true
+			deps = append(deps, PRDependency{Repository: full, Number: number})
+		}
+	}
+	if len(deps) > maxDependencies {
+		log.Printf("[Dependencies] PR #%d of %s references %d PRs; checking the first %d\n", self, repo, len(deps), maxDependencies)
+		deps = deps[:maxDependencies]
+	}
+	return deps
+}
+
+// DependenciesEnricher attaches the PRs the PR depends on.
+type DependenciesEnricher struct{}
+
+func (DependenciesEnricher) Name() string { return "dependencies" }
+
+func (DependenciesEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	if event.PR.Number == 0 || !(isFileEnrichableAction(event.Action) || event.Action == "edited") {
+		return nil
+	}
+	deps := parseDependencies(event.PR.Description, event.Repository.FullName, event.PR.Number)
+	if len(deps) == 0 {
+		return nil
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		// Keep the references; they just cannot be checked.
+		event.Dependencies = deps
+		return errUnsupported(adapter, "reading PR details")
+	}
+	for i := range deps {
+		owner, name, _ := strings.Cut(deps[i].Repository, "/")
+		pr, err := reader.GetPRDetails(owner, name, deps[i].Number)
+		if err != nil {
+			// Kept unvalidated: a lookup failure is no proof the PR is missing.
+			log.Printf("[Dependencies] Warning: could not look up %s#%d: %v\n", deps[i].Repository, deps[i].Number, err)
+			continue
+		}
+		deps[i].Title = pr.Title
+		deps[i].State = pr.State
+		deps[i].URL = pr.URL
+		deps[i].Validated = true
+	}
+	event.Dependencies = deps
+	return nil
+}
+
+// blockingDependencies returns the validated dependencies of event that are
+// not merged. Unvalidated ones are left out: their state is unknown.
+func blockingDependencies(event *NormalizedEvent) []PRDependency {
+	var blocking []PRDependency
+	for _, dep := range event.Dependencies {
+		if dep.Validated && dep.State != "merged" {
+			blocking = append(blocking, dep)
+		}
+	}
+	return blocking
+}
+
+// publishDependencyBlocked emits pull_request.dependency_blocked for event
+// when its PR is open and waits for unmerged dependencies.
+func publishDependencyBlocked(event *NormalizedEvent) {
+	if event.Action == "closed" || (event.PR.State != "" && event.PR.State != "open") {
+		return
+	}
+	blocking := blockingDependencies(event)
+	if len(blocking) == 0 {
+		return
+	}
+	refs := make([]string, len(blocking))
+	for i, dep := range blocking {
+		refs[i] = fmt.Sprintf("%s#%d (%s)", dep.Repository, dep.Number, dep.State)
+	}
+	log.Printf("[Dependencies] PR #%d of %s is blocked by %s\n", event.PR.Number, event.Repository.FullName, strings.Join(refs, ", "))
+
+	blocked := anonymizeEvent(&NormalizedEvent{
+		EventID:       newEventID(),
+		SchemaVersion: NormalizedSchemaVersion,
+		Platform:      event.Platform,
+		EventType:     EventTypeDependencyBlocked,
+		Action:        "dependency_blocked",
+		PR:            event.PR,
+		Repository:    event.Repository,
+		Dependencies:  blocking,
+		ReceivedAt:    time.Now(),
+	})
+	store().Append(EventTypeDependencyBlocked, blocked)
+	prSnapshots().Apply(blocked)
+	if mq == nil {
+		log.Printf("[Dependencies] Warning: RabbitMQ not initialised, %s event for PR #%d dropped\n", EventTypeDependencyBlocked, event.PR.Number)
+		return
+	}
+	if err := mq.PublishNormalizedEvent(blocked); err != nil {
+		log.Printf("[Dependencies] Warning: could not publish %s event: %v\n", EventTypeDependencyBlocked, err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+// Deployments — lets the CD system record deployments to environments
+// through the gateway, next to the PR events it already reports:
+//
+//	POST /deployments                  → create a deployment (and optionally its first status)
+//	POST /deployments/{id}/statuses    → report a deployment's progress
+//
+// On GitHub these are the Deployments API's deployments and deployment
+// statuses, shown on the PR and the repository's environments page.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Deployment is a deployment of a ref to an environment.
+type Deployment struct {
+	Ref         string          `json:"ref"`
+	Environment string          `json:"environment"`
+	Description string          `json:"description,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"` // passed through to the SCM
+	Production  *bool           `json:"production_environment,omitempty"`
+	Transient   *bool           `json:"transient_environment,omitempty"`
+	// RequiredContexts are the status checks that must pass on Ref first.
+	// Nil means none: the CD system has already decided to deploy.
+	RequiredContexts []string `json:"required_contexts,omitempty"`
+}
+
+// DeploymentStatus is the state of a deployment.
+type DeploymentStatus struct {
+	State          string `json:"state"`
+	Description    string `json:"description,omitempty"`
+	EnvironmentURL string `json:"environment_url,omitempty"`
+	LogURL         string `json:"log_url,omitempty"`
+}
+
+// deploymentStates are the deployment status states.
+var deploymentStates = map[string]bool{
+	"queued":      true,
+	"pending":     true,
+	"in_progress": true,
+	"success":     true,
+	"failure":     true,
+	"error":       true,
+	"inactive":    true,
+}
+
+// deployerFor returns the Deployer of platform (default github), writing the
+// error response if there is none.
+func deployerFor(w http.ResponseWriter, platform SCMPlatform) (Deployer, bool) {
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	deployer, ok := adapter.(Deployer)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "deployments").Error(), http.StatusNotImplemented)
+		return nil, false
+	}
+	return deployer, true
+}
+
+// DeploymentsHandler creates deployments and deployment statuses.
+//
+//	POST /deployments
+//	{"owner": "acme", "repo": "api", "ref": "main", "environment": "staging", "state": "in_progress"}
+//
+//	POST /deployments/{id}/statuses
+//	{"owner": "acme", "repo": "api", "state": "success", "environment_url": "https://staging.acme.dev"}
+func DeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not record deployments", http.StatusConflict)
+		return
+	}
+
+	var id int64
+	if rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/deployments"), "/"); rest != "" {
+		idStr, ok := strings.CutSuffix(rest, "/statuses")
+		n, err := strconv.ParseInt(idStr, 10, 64)
+		if !ok || err != nil || n <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		id = n
+	}
+
+	var req struct {
+		Platform    SCMPlatform     `json:"platform"`
+		Owner       string          `json:"owner"`
+		Repo        string          `json:"repo"`
+		Ref         string          `json:"ref"`
+		Environment string          `json:"environment"`
+		Description string          `json:"description"`
+		Payload     json.RawMessage `json:"payload"`
+		Production  *bool           `json:"production_environment"`
+		Transient   *bool           `json:"transient_environment"`
+		Contexts    []string        `json:"required_contexts"`
+		DeploymentStatus
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" {
+		http.Error(w, "owner and repo are required", http.StatusBadRequest)
+		return
+	}
+	if id == 0 && (req.Ref == "" || req.Environment == "") {
+		http.Error(w, "ref and environment are required", http.StatusBadRequest)
+		return
+	}
+	if id != 0 && req.State == "" {
+		http.Error(w, "state is required", http.StatusBadRequest)
+		return
+	}
+	if req.State != "" && !deploymentStates[req.State] {
+		http.Error(w, "unknown state: "+req.State, http.StatusBadRequest)
+		return
+	}
+	// One description serves the deployment and its status.
+	req.DeploymentStatus.Description = req.Description
+
+	deployer, ok := deployerFor(w, req.Platform)
+	if !ok {
+		return
+	}
+	created := id == 0
+	if created {
+		var err error
+		if id, err = deployer.CreateDeployment(req.Owner, req.Repo, Deployment{
+			Ref:              req.Ref,
+			Environment:      req.Environment,
+			Description:      req.Description,
+			Payload:          req.Payload,
+			Production:       req.Production,
+			Transient:        req.Transient,
+			RequiredContexts: req.Contexts,
+		}); err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		log.Printf("[Deployments] Created deployment %d of %s/%s@%s to %s\n", id, req.Owner, req.Repo, req.Ref, req.Environment)
+	}
+	if req.State != "" {
+		if err := deployer.SetDeploymentStatus(req.Owner, req.Repo, id, req.DeploymentStatus); err != nil {
+			log.Println("Error:", err)
+			// A created deployment is reported so the caller can retry the status.
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+				"status":        "error",
+				"error":         err.Error(),
+				"deployment_id": id,
+				"created":       created,
+			})
+			return
+		}
+		log.Printf("[Deployments] Deployment %d of %s/%s is %s\n", id, req.Owner, req.Repo, req.State)
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"status":        "success",
+		"deployment_id": id,
+		"created":       created,
+		"state":         req.State,
+	})
+}
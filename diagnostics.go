@@ -0,0 +1,227 @@
+package main
+
+// Permissions preflight — checks that every GitHub App installation grants
+// the permissions the enabled features need, so a missing grant is reported
+// at boot instead of surfacing as a 403 halfway through the pipeline.
+//
+// What is needed follows the configuration: enrichment always needs
+// contents:read and pull_requests:read; each feature turned on in
+// REPO_CONFIG_FILE (for any repo) adds its own, e.g. "auto_merge" needs
+// contents:write and pull_requests:write. The check runs at startup, logging
+// one line per missing grant, and on demand:
+//
+//	GET /diagnostics   (admin token)
+//
+// Installations are checked as a whole: a grant needed only by a feature that
+// one repo enables is still reported on every installation.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// PermissionNeed is a permission a feature needs from the installation.
+type PermissionNeed struct {
+	Feature    string `json:"feature"`
+	Permission string `json:"permission"`
+	Level      string `json:"level"` // "read" or "write"
+}
+
+// MissingGrant is a need an installation does not grant (Granted is its
+// current level, "" for none).
+type MissingGrant struct {
+	PermissionNeed
+	Granted string `json:"granted"`
+}
+
+// InstallationDiagnosis is the preflight result of one installation.
+type InstallationDiagnosis struct {
+	ID          int64             `json:"id"`
+	Account     string            `json:"account"`
+	Suspended   bool              `json:"suspended"`
+	Permissions map[string]string `json:"permissions"`
+	Missing     []MissingGrant    `json:"missing"`
+}
+
+// permissionLevels orders the levels of an installation permission.
+var permissionLevels = map[string]int{"read": 1, "write": 2, "admin": 3}
+
+// requiredGitHubPermissions returns what the configured features need, in
+// feature order.
+func requiredGitHubPermissions() []PermissionNeed {
+	needs := []PermissionNeed{
+		{"enrichment", "contents", "read"},
+		{"enrichment", "pull_requests", "read"},
+	}
+	add := func(feature string, permissions ...string) {
+		for i := 0; i+1 < len(permissions); i += 2 {
+			needs = append(needs, PermissionNeed{feature, permissions[i], permissions[i+1]})
+		}
+	}
+
+	config := loadRepoConfig()
+	all := []RepoConfig{config.Default}
+	for _, repo := range config.Repos {
+		all = append(all, repo)
+	}
+	enabled := map[string]bool{}
+	for _, c := range all {
+		enabled["description_template"] = enabled["description_template"] || c.DescriptionTemplate != ""
+		enabled["policies"] = enabled["policies"] || c.ConventionalCommits != nil || c.FilePolicy != nil
+		enabled["llm_review"] = enabled["llm_review"] || c.LLMReview
+		enabled["analyzers"] = enabled["analyzers"] || len(c.Analyzers) > 0
+		enabled["auto_merge"] = enabled["auto_merge"] || c.AutoMerge != nil
+		enabled["required_contexts"] = enabled["required_contexts"] || len(c.RequiredContexts) > 0
+		enabled["labels"] = enabled["labels"] || len(c.Labels) > 0 || len(c.Milestones) > 0
+		enabled["changelog"] = enabled["changelog"] || c.Changelog != nil
+		enabled["changelog_pr"] = enabled["changelog_pr"] || (c.Changelog != nil && c.Changelog.Mode == "pr")
+		enabled["release_notes"] = enabled["release_notes"] || c.ReleaseNotes != nil
+		enabled["scaffold"] = enabled["scaffold"] || c.Scaffold != nil
+	}
+	if enabled["description_template"] {
+		add("description_template", "pull_requests", "write")
+	}
+	if enabled["policies"] {
+		add("policies", "checks", "write")
+	}
+	if enabled["llm_review"] {
+		add("llm_review", "pull_requests", "write")
+	}
+	if enabled["analyzers"] {
+		add("analyzers", "checks", "write", "statuses", "write")
+	}
+	if enabled["auto_merge"] {
+		add("auto_merge", "contents", "write", "pull_requests", "write", "checks", "read", "statuses", "read")
+	}
+	if enabled["required_contexts"] {
+		add("required_contexts", "administration", "write")
+	}
+	if enabled["labels"] {
+		add("labels", "issues", "write")
+	}
+	if enabled["changelog"] {
+		add("changelog", "contents", "write")
+	}
+	if enabled["changelog_pr"] {
+		add("changelog", "pull_requests", "write")
+	}
+	if enabled["release_notes"] {
+		add("release_notes", "contents", "write")
+	}
+	if enabled["scaffold"] {
+		add("scaffold", "administration", "write", "repository_hooks", "write", "issues", "write")
+	}
+	return needs
+}
+
+// diagnoseGitHubInstallations compares every installation's permissions with
+// requiredGitHubPermissions.
+func diagnoseGitHubInstallations() ([]InstallationDiagnosis, error) {
+	appID, privateKey := getAppIDFromEnv(), getPrivateKeyFromEnv()
+	if appID == "" || privateKey == "" {
+		return nil, fmt.Errorf("GITHUB_APP_ID and GITHUB_PRIVATE_KEY must be set")
+	}
+	jwtToken, err := generateJWT(appID, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+	body, err := makeAppRequest(jwtToken, "GET", "https://api.github.com/app/installations?per_page=100", nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing installations failed: %w", err)
+	}
+	var installations []struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+		Permissions map[string]string `json:"permissions"`
+		SuspendedAt *string           `json:"suspended_at"`
+	}
+	if err := json.Unmarshal(body, &installations); err != nil {
+		return nil, fmt.Errorf("failed to parse installations response: %w", err)
+	}
+
+	needs := requiredGitHubPermissions()
+	diagnoses := make([]InstallationDiagnosis, 0, len(installations))
+	for _, inst := range installations {
+		d := InstallationDiagnosis{
+			ID:          inst.ID,
+			Account:     inst.Account.Login,
+			Suspended:   inst.SuspendedAt != nil,
+			Permissions: inst.Permissions,
+			Missing:     []MissingGrant{},
+		}
+		for _, need := range needs {
+			granted := inst.Permissions[need.Permission]
+			if permissionLevels[granted] < permissionLevels[need.Level] {
+				d.Missing = append(d.Missing, MissingGrant{PermissionNeed: need, Granted: granted})
+			}
+		}
+		diagnoses = append(diagnoses, d)
+	}
+	sort.Slice(diagnoses, func(i, j int) bool { return diagnoses[i].Account < diagnoses[j].Account })
+	return diagnoses, nil
+}
+
+// PreflightPermissions logs the GitHub installations' missing grants. It is a
+// no-op when no GitHub App is configured; errors are logged so a preflight
+// failure never prevents the gateway from booting.
+func PreflightPermissions() {
+	if getAppIDFromEnv() == "" {
+		return
+	}
+	diagnoses, err := diagnoseGitHubInstallations()
+	if err != nil {
+		log.Printf("[Preflight] Warning: could not check GitHub App permissions: %v\n", err)
+		return
+	}
+	missing := 0
+	for _, d := range diagnoses {
+		for _, m := range d.Missing {
+			granted := m.Granted
+			if granted == "" {
+				granted = "none"
+			}
+			log.Printf("[Preflight] ✗ Installation %s: %s needs %s:%s (granted: %s)\n", d.Account, m.Feature, m.Permission, m.Level, granted)
+			missing++
+		}
+	}
+	if missing > 0 {
+		log.Printf("[Preflight] %d missing permission grant(s); update the GitHub App's permissions and accept them on each installation\n", missing)
+		return
+	}
+	log.Printf("[Preflight] ✓ %d GitHub installation(s) grant every permission the enabled features need\n", len(diagnoses))
+}
+
+// DiagnosticsHandler reports the permission preflight on demand.
+//
+//	GET /diagnostics
+func DiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	diagnoses, err := diagnoseGitHubInstallations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	ok := true
+	for _, d := range diagnoses {
+		if len(d.Missing) > 0 {
+			ok = false
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "success",
+		"ok":            ok,
+		"required":      requiredGitHubPermissions(),
+		"installations": diagnoses,
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+// Diff chunking for large PRs.
+//
+// chunkDiff splits a unified diff into chunks whose estimated token count
+// stays under a limit, without ever cutting a hunk in half. Each chunk
+// repeats the "diff --git" / "---" / "+++" header of every file it touches,
+// so it is a valid unified diff on its own. Chunk IDs are derived from the
+// chunk content, so the same diff always yields the same IDs.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultChunkTokens = 3000
+
+// DiffChunk is a size-bounded, self-contained slice of a unified diff.
+type DiffChunk struct {
+	ID     string   `json:"id"`
+	Files  []string `json:"files"`
+	Hunks  int      `json:"hunks"`
+	Tokens int      `json:"tokens"`
+	Diff   string   `json:"diff"`
+}
+
+// estimateTokens approximates the LLM token count of text (~4 bytes/token).
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// diffFile is one file section of a unified diff.
+type diffFile struct {
+	path   string
+	header string   // everything before the first hunk
+	hunks  []string // each starts with "@@"
+}
+
+// parseUnifiedDiff splits a unified diff into file sections and hunks.
+func parseUnifiedDiff(diff string) []diffFile {
+	var files []diffFile
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "diff --git ") || len(files) == 0 {
+			files = append(files, diffFile{path: diffHeaderPath(line)})
+		}
+		f := &files[len(files)-1]
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			f.hunks = append(f.hunks, line)
+		case len(f.hunks) > 0:
+			f.hunks[len(f.hunks)-1] += line
+		default:
+			f.header += line
+		}
+	}
+	return files
+}
+
+// diffHeaderPath extracts the new path from a "diff --git a/x b/y" header.
+func diffHeaderPath(header string) string {
+	header = strings.TrimSpace(header)
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+3:]
+	}
+	return ""
+}
+
+// chunkDiff packs the hunks of diff into chunks of at most maxTokens
+// estimated tokens. A single hunk larger than maxTokens becomes a chunk of
+// its own rather than being split.
+func chunkDiff(diff string, maxTokens int) []DiffChunk {
+	if maxTokens <= 0 {
+		maxTokens = defaultChunkTokens
+	}
+
+	var chunks []DiffChunk
+	var cur strings.Builder
+	var curFiles []string
+	curHunks := 0
+	lastFile := ""
+
+	flush := func() {
+		if cur.Len() == 0 {
+			return
+		}
+		text := cur.String()
+		sum := sha256.Sum256([]byte(text))
+		chunks = append(chunks, DiffChunk{
+			ID:     hex.EncodeToString(sum[:6]),
+			Files:  curFiles,
+			Hunks:  curHunks,
+			Tokens: estimateTokens(text),
+			Diff:   text,
+		})
+		cur.Reset()
+		curFiles, curHunks, lastFile = nil, 0, ""
+	}
+
+	for _, f := range parseUnifiedDiff(diff) {
+		// Files without hunks (binary, pure renames, mode changes) are
+		// carried as their header alone.
+		pieces := f.hunks
+		if len(pieces) == 0 {
+			pieces = []string{""}
+		}
+		for _, hunk := range pieces {
+			piece := hunk
+			if lastFile != f.path {
+				piece = f.header + hunk
+			}
+			if cur.Len() > 0 && estimateTokens(cur.String()+piece) > maxTokens {
+				flush()
+				piece = f.header + hunk
+			}
+			if lastFile != f.path {
+				curFiles = append(curFiles, f.path)
+				lastFile = f.path
+			}
+			cur.WriteString(piece)
+			if hunk != "" {
+				curHunks++
+			}
+		}
+	}
+	flush()
+	return chunks
+}
+
+// PRDiffChunksHandler returns a PR's diff split into chunks.
+//
+//	GET /pr-diff-chunks?owner=X&repo=Y&pr=N[&platform=github][&max_tokens=3000]
+func PRDiffChunksHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	prNumber, err := strconv.Atoi(q.Get("pr"))
+	if owner == "" || repo == "" || err != nil {
+		http.Error(w, "owner, repo and numeric pr parameters are required", http.StatusBadRequest)
+		return
+	}
+	maxTokens, _ := strconv.Atoi(q.Get("max_tokens"))
+
+	platform := SCMPlatform(q.Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "PR diffs").Error(), http.StatusNotImplemented)
+		return
+	}
+
+	diff, err := reader.GetPRDiff(owner, repo, prNumber)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	chunks := chunkDiff(diff, maxTokens)
+	totalTokens := 0
+	for _, c := range chunks {
+		totalTokens += c.Tokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "success",
+		"owner":        owner,
+		"repo":         repo,
+		"pr_number":    prNumber,
+		"total_chunks": len(chunks),
+		"total_tokens": totalTokens,
+		"chunks":       chunks,
+	})
+}
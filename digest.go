@@ -0,0 +1,144 @@
+package main
+
+// Digest sinks — the "digest" job (default daily at 08:00) posts a summary of
+// the period's activity to each digest sink, for chat channels and reports
+// that want one message a day rather than every event:
+//
+//	DIGEST_SINKS   comma-separated name=url pairs, like EXTRA_SINKS; the job
+//	               does nothing when unset
+//
+// A digest covers the events stored since the previous digest (the last 24
+// hours for the first one after a start), counted by event type and
+// repository, plus the open and stale PRs of the PR snapshots (see
+// stale_prs.go). The event store is per replica, so the job runs on every
+// replica and each digest names the replica it covers.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Digest is the summary posted to digest sinks.
+type Digest struct {
+	Gateway      string         `json:"gateway"`
+	Replica      string         `json:"replica"`
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Events       int            `json:"events"`
+	ByEventType  map[string]int `json:"by_event_type"`
+	ByRepository map[string]int `json:"by_repository"` // "platform:owner/repo"
+	OpenPRs      int            `json:"open_prs"`
+	StalePRs     int            `json:"stale_prs"`
+}
+
+var (
+	digestMu     sync.Mutex
+	lastDigestAt time.Time
+)
+
+// digestSinks parses DIGEST_SINKS into name → URL.
+func digestSinks() map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("DIGEST_SINKS"), ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || url == "" {
+			if strings.TrimSpace(pair) != "" {
+				log.Printf("[Digest] Warning: ignoring malformed DIGEST_SINKS entry %q\n", pair)
+			}
+			continue
+		}
+		out[name] = url
+	}
+	return out
+}
+
+// sendDigests is the "digest" scheduler job.
+func sendDigests() error {
+	sinks := digestSinks()
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	now := time.Now()
+	from := lastDigestAt
+	if from.IsZero() {
+		from = now.Add(-24 * time.Hour)
+	}
+	digest := buildDigest(from, now)
+
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("digest: failed to marshal: %w", err)
+	}
+	failures := 0
+	for name, url := range sinks {
+		if err := postDigest(url, body); err != nil {
+			log.Printf("[Digest] Warning: could not deliver digest to %q: %v\n", name, err)
+			failures++
+		}
+	}
+	// Advanced even on failures, so sinks that received this digest are
+	// not sent its events again.
+	lastDigestAt = now
+	if failures > 0 {
+		return fmt.Errorf("%d of %d digest sink(s) failed", failures, len(sinks))
+	}
+	log.Printf("[Digest] Delivered digest of %d event(s) to %d sink(s)\n", digest.Events, len(sinks))
+	return nil
+}
+
+// buildDigest summarises the events stored in [from, to) and the current PR
+// snapshots.
+func buildDigest(from, to time.Time) Digest {
+	replica, _ := os.Hostname()
+	d := Digest{
+		Gateway:      gatewayRegion(),
+		Replica:      replica,
+		From:         from,
+		To:           to,
+		ByEventType:  map[string]int{},
+		ByRepository: map[string]int{},
+	}
+	for _, e := range store().Since(from) {
+		if !e.StoredAt.Before(to) {
+			break
+		}
+		d.Events++
+		d.ByEventType[e.Event.EventType]++
+		d.ByRepository[fmt.Sprintf("%s:%s", e.Platform, e.Event.Repository.FullName)]++
+	}
+
+	staleCutoff := to.Add(-time.Duration(envInt("STALE_PR_DAYS", 14)) * 24 * time.Hour)
+	for _, snap := range prSnapshots().List("", "", "open") {
+		d.OpenPRs++
+		if !snap.LastActiveAt.IsZero() && snap.LastActiveAt.Before(staleCutoff) {
+			d.StalePRs++
+		}
+	}
+	return d
+}
+
+// postDigest POSTs a digest body to url.
+func postDigest(url string, body []byte) error {
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("sink returned error %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+// CI dispatch — lets the Platform BE start CI as a reaction to normalized
+// events, through the gateway's SCM credentials:
+//
+//	POST /dispatch
+//	{"platform": "github", "owner": "acme", "repo": "api",
+//	 "kind": "workflow_dispatch", "workflow": "nightly.yml", "ref": "main",
+//	 "inputs": {"suite": "full"}}
+//
+// Kinds:
+//
+//	repository_dispatch  GitHub: event_type, inputs as client_payload
+//	workflow_dispatch    GitHub: workflow (file name or ID), ref, inputs
+//	pipeline             Bitbucket Pipelines: ref (branch), workflow as the
+//	                     custom pipeline name (default pipeline when empty),
+//	                     inputs as pipeline variables
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Dispatch kinds.
+const (
+	dispatchRepository = "repository_dispatch"
+	dispatchWorkflow   = "workflow_dispatch"
+	dispatchPipeline   = "pipeline"
+)
+
+// DispatchRequest describes the CI run to start.
+type DispatchRequest struct {
+	Kind      string                 `json:"kind"`
+	EventType string                 `json:"event_type,omitempty"`
+	Workflow  string                 `json:"workflow,omitempty"`
+	Ref       string                 `json:"ref,omitempty"`
+	Inputs    map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// DispatchHandler starts a CI run.
+//
+//	POST /dispatch
+func DispatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not trigger CI", http.StatusConflict)
+		return
+	}
+	var req struct {
+		DispatchRequest
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.Kind == "" {
+		http.Error(w, "owner, repo and kind are required", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	trigger, ok := adapter.(CITrigger)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "CI dispatch").Error(), http.StatusNotImplemented)
+		return
+	}
+	runID, err := trigger.TriggerCI(req.Owner, req.Repo, req.DispatchRequest)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[Dispatch] %s %s/%s: %s %s%s on %q\n",
+		req.Platform, req.Owner, req.Repo, req.Kind, req.EventType, req.Workflow, req.Ref)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"platform": req.Platform,
+		"kind":     req.Kind,
+		"run_id":   runID,
+	})
+}
@@ -0,0 +1,197 @@
+package main
+
+// Enrichers — the stages that add data to a freshly normalized event. Each
+// is a small Enricher selected by name, so a new kind of enrichment is a new
+// implementation here rather than another adapter method:
+//
+//	files         changed files (PRReader.GetPRFiles) on opened/synchronize/reopened,
+//	              with their sizes and whether they are binary (see file_stats.go)
+//	commits       the PR's commits (PRReader.GetPRCommits) on the same actions
+//	owners        owners of the changed files, from the repo's "owners" rules
+//	tickets       issue-tracker keys referenced by the PR (see tickets.go)
+//	dependencies  PRs the description says it depends on (see dependencies.go)
+//	identity      the PR author's corporate identity (see identity.go)
+//
+// The chain is ENRICHERS (comma-separated, default "files,tickets"),
+// overridden per repo by "enrichers" in REPO_CONFIG_FILE. "files" always runs
+// first because path rules and the other enrichers work on its result; the
+// rest run in the configured order. A failing enricher is logged and the
+// chain moves on.
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultEnrichers is the chain used when ENRICHERS is unset.
+const defaultEnrichers = "files,tickets"
+
+// Enricher adds one kind of data to a normalized event.
+type Enricher interface {
+	Name() string
+	Enrich(adapter SCMAdapter, event *NormalizedEvent) error
+}
+
+// enrichersByName are the available enrichers.
+var enrichersByName = map[string]Enricher{
+	"files":        FilesEnricher{},
+	"commits":      CommitsEnricher{},
+	"owners":       OwnersEnricher{},
+	"tickets":      TicketEnricher{},
+	"dependencies": DependenciesEnricher{},
+	"identity":     IdentityEnricher{},
+}
+
+// FilesEnricher attaches the PR's changed files.
+type FilesEnricher struct{}
+
+func (FilesEnricher) Name() string { return "files" }
+
+func (FilesEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	if event.PR.Number == 0 || !isFileEnrichableAction(event.Action) {
+		return nil
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		return errUnsupported(adapter, "reading PR files")
+	}
+	log.Printf("[%s Adapter] Fetching files for PR #%d in %s\n", event.Platform, event.PR.Number, event.Repository.FullName)
+	files, err := reader.GetPRFiles(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		return err
+	}
+	event.Files = files
+	statFiles(adapter, event)
+	return nil
+}
+
+// CommitsEnricher attaches the PR's commits, oldest first.
+type CommitsEnricher struct{}
+
+func (CommitsEnricher) Name() string { return "commits" }
+
+func (CommitsEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	if event.PR.Number == 0 || !isFileEnrichableAction(event.Action) {
+		return nil
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		return errUnsupported(adapter, "reading PR commits")
+	}
+	commits, err := reader.GetPRCommits(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		return err
+	}
+	event.Commits = commits
+	return nil
+}
+
+// OwnerRule assigns owners to the paths matching Pattern (a path_rules glob).
+type OwnerRule struct {
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+}
+
+// OwnersEnricher attaches the owners of the changed files. As in CODEOWNERS,
+// the last rule matching a file decides its owners.
+type OwnersEnricher struct{}
+
+func (OwnersEnricher) Name() string { return "owners" }
+
+func (OwnersEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	rules := repoConfigFor(event.Platform, event.Repository.FullName).Owners
+	if len(rules) == 0 || len(event.Files) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var owners []string
+	for _, f := range event.Files {
+		var matched []string
+		for _, rule := range rules {
+			if matchAnyGlob([]string{rule.Pattern}, f.Filename) {
+				matched = rule.Owners
+			}
+		}
+		for _, owner := range matched {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	sort.Strings(owners)
+	event.Owners = owners
+	return nil
+}
+
+// TicketEnricher links the issue-tracker tickets referenced by the PR.
+type TicketEnricher struct{}
+
+func (TicketEnricher) Name() string { return "tickets" }
+
+func (TicketEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	enrichTickets(adapterContext(adapter), event)
+	return nil
+}
+
+// deploymentEnrichers returns the enricher names configured by ENRICHERS.
+func deploymentEnrichers() []string {
+	spec := os.Getenv("ENRICHERS")
+	if spec == "" {
+		spec = defaultEnrichers
+	}
+	return strings.Split(spec, ",")
+}
+
+// enricherChain returns the enrichers configured for the event's repository,
+// "files" first.
+func enricherChain(event *NormalizedEvent) []Enricher {
+	names := repoConfigFor(event.Platform, event.Repository.FullName).Enrichers
+	if names == nil {
+		names = deploymentEnrichers()
+	}
+
+	var chain []Enricher
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		e, ok := enrichersByName[name]
+		if !ok {
+			log.Printf("[Enrichers] Warning: unknown enricher %q ignored\n", name)
+			continue
+		}
+		if name == "files" {
+			chain = append([]Enricher{e}, chain...)
+		} else {
+			chain = append(chain, e)
+		}
+	}
+	return chain
+}
+
+// runEnrichers runs the event's enricher chain. gate, if not nil, is asked
+// once after "files" whether the rest of enrichment should be skipped; a
+// non-empty reason is recorded in EnrichmentSkipped and stops the chain.
+func runEnrichers(adapter SCMAdapter, event *NormalizedEvent, gate func(*NormalizedEvent) string) {
+	gated := gate == nil
+	for _, e := range enricherChain(event) {
+		if !gated && e.Name() != "files" {
+			gated = true
+			if reason := gate(event); reason != "" {
+				event.EnrichmentSkipped = reason
+				return
+			}
+		}
+		if err := e.Enrich(adapter, event); err != nil {
+			log.Printf("[Enrichers] Warning: %s enricher failed for PR #%d in %s: %v\n",
+				e.Name(), event.PR.Number, event.Repository.FullName, err)
+		}
+	}
+	if !gated {
+		event.EnrichmentSkipped = gate(event)
+	}
+}
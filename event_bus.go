@@ -18,6 +18,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -67,27 +68,94 @@ func DeliverEvent(event *NormalizedEvent, url string) error {
 
 // StartEventBusConsumer begins consuming normalized events from the
 // normalized_pr_events queue (the "Unified Event Bus") and delivers each one
-// to the Platform BE.
+// to every configured sink — the Platform BE plus any EXTRA_SINKS.
 //
 // Reads PLATFORM_BE_URL from the environment at startup. If the variable is
 // not set, events are logged only (dev mode) — matching the Python behaviour.
 //
-// This function blocks until the broker closes the channel; call it in a
-// goroutine from main.
+// One consumer runs per queue shard, each supervised and restarted if the
+// channel closes. This function never returns; call it in a goroutine from
+// main.
 func StartEventBusConsumer(mq *RabbitMQ) {
-	platformBEURL := os.Getenv("PLATFORM_BE_URL")
-	if platformBEURL == "" {
+	if os.Getenv("PLATFORM_BE_URL") == "" {
 		log.Println("[EventBus] PLATFORM_BE_URL not set — events will be logged only (dev mode)")
 	} else {
-		log.Printf("[EventBus] Delivering normalized events to Platform BE at %s\n", platformBEURL)
+		log.Printf("[EventBus] Delivering normalized events to Platform BE at %s\n", os.Getenv("PLATFORM_BE_URL"))
 	}
+	for _, sink := range configuredSinks() {
+		log.Printf("[EventBus] Sink enabled: %s\n", sink.Name())
+	}
+
+	deliver := deliverToSinks(mq)
+	superviseShards("EventBus", normalizedEventsQueue, func(queue string) error {
+		return mq.ConsumeNormalizedEvents(queue, deliver)
+	})
+}
+
+// deliverToSinks returns a closure that delivers one normalized event to
+// every configured sink, dead-lettering failed deliveries.
+func deliverToSinks(mq *RabbitMQ) func(*NormalizedEvent) {
+	return func(event *NormalizedEvent) {
+		traceHop(event.EventID, "consumed", normalizedEventsQueue)
+		if isStandby() {
+			// Held in the event store for replay on promotion.
+			metrics.standbyHeld.Add(1)
+			traceHop(event.EventID, "held", "standby")
+			return
+		}
+		if mode := staleEventsMode(); mode != "" {
+			if reason := staleReason(event); reason != "" {
+				metrics.staleEvents.Add(1)
+				if mode == "drop" {
+					log.Printf("[EventBus] Dropping stale event (PR #%d): %s\n", event.PR.Number, reason)
+					traceHop(event.EventID, "dropped", "stale: "+reason)
+					return
+				}
+				event.StaleReason = reason
+				traceHop(event.EventID, "flagged", "stale: "+reason)
+			}
+		}
+		if mode := schemaValidationMode(); mode != "" {
+			if violations := validateEvent(event); len(violations) > 0 {
+				metrics.schemaViolations.Add(1)
+				summary := strings.Join(violations, "; ")
+				log.Printf("[EventBus] Warning: event %s (PR #%d) does not match its schema: %s\n",
+					event.EventID, event.PR.Number, summary)
+				if mode == "strict" {
+					traceHop(event.EventID, "dropped", "schema: "+summary)
+					if body, err := json.Marshal(event); err == nil {
+						mq.deadLetter("schema", fmt.Errorf("schema violation: %s", summary), body)
+					}
+					return
+				}
+			}
+		}
+		for _, sink := range configuredSinks() {
+			metrics.deliveries.Add(1)
+			start := time.Now()
+			err := sink.Deliver(event)
+			traceDelivery(event.EventID, sink.Name(), time.Since(start), err)
+			if sink.Name() == defaultSinkName {
+				if c := canarySink(); c != nil {
+					c.Mirror(event, err, time.Since(start))
+				}
+			}
+			if err == nil {
+				countDelivery(event)
+			} else {
+				metrics.deliveryFailures.Add(1)
+				log.Printf("[EventBus] Warning: could not deliver event (PR #%d) to sink %q: %v\n",
+					event.PR.Number, sink.Name(), err)
+				if body, mErr := json.Marshal(event); mErr == nil {
+					mq.deadLetter("sink:"+sink.Name(), err, body)
+				}
+			}
+		}
 
-	if err := mq.ConsumeNormalizedEvents(func(event *NormalizedEvent) {
-		if err := DeliverEvent(event, platformBEURL); err != nil {
-			log.Printf("[EventBus] Warning: could not deliver event (PR #%d): %v\n",
-				event.PR.Number, err)
+		// Fan out to registered outgoing webhooks.
+		subscriptions().Dispatch(event)
+		if t := tapSink(); t != nil {
+			t.Tap(event)
 		}
-	}); err != nil {
-		log.Fatalf("[EventBus] Fatal error, consumer stopped: %v\n", err)
 	}
 }
@@ -0,0 +1,330 @@
+package main
+
+// Event store — keeps recent normalized events, together with the raw
+// webhook they were built from, so they can be replayed to a sink or
+// re-normalized by a newer build.
+//
+// The store is an in-memory ring of the last EVENT_STORE_MAX events
+// (default 5000). When EVENT_STORE_FILE is set every stored event is also
+// appended to that JSON-lines file, which is reloaded on startup. With
+// PAYLOAD_ENCRYPTION set the file is envelope-encrypted (see
+// payload_crypto.go): each time the file is opened a {"data_key": ...} line
+// introduces a new wrapped data key, and the {"sealed": ...} lines after it
+// are events encrypted under that key.
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultEventStoreMax = 5000
+
+// StoredEvent is one entry of the event store.
+type StoredEvent struct {
+	EventID      string           `json:"event_id"`
+	Platform     SCMPlatform      `json:"platform"`
+	RawEventType string           `json:"raw_event_type"` // header value, needed to re-normalize
+	Event        *NormalizedEvent `json:"event"`
+	StoredAt     time.Time        `json:"stored_at"`
+}
+
+// EventStore is a bounded, goroutine-safe store of StoredEvents in arrival
+// order, optionally persisted to a JSON-lines file.
+type EventStore struct {
+	mu     sync.RWMutex
+	max    int
+	events []StoredEvent
+	file   *os.File
+	seal   *envelope // nil unless PAYLOAD_ENCRYPTION is set
+}
+
+// storeLine is an encryption line of the store file: a data key or an
+// encrypted event. Plain lines are StoredEvents.
+type storeLine struct {
+	DataKey string `json:"data_key,omitempty"`
+	Sealed  []byte `json:"sealed,omitempty"`
+}
+
+var (
+	eventStoreOnce sync.Once
+	eventStore     *EventStore
+)
+
+// store returns the package-level event store, opening it on first use.
+func store() *EventStore {
+	eventStoreOnce.Do(func() {
+		eventStore = NewEventStore(envInt("EVENT_STORE_MAX", defaultEventStoreMax), os.Getenv("EVENT_STORE_FILE"))
+	})
+	return eventStore
+}
+
+// NewEventStore returns a store holding up to max events. If path is
+// non-empty, existing events are loaded from it and new ones appended.
+func NewEventStore(max int, path string) *EventStore {
+	s := &EventStore{max: max}
+	if path == "" {
+		return s
+	}
+
+	wrapper, err := payloadKeyWrapper()
+	if err != nil {
+		// Never fall back to writing payloads in the clear.
+		log.Printf("[EventStore] Warning: payload encryption misconfigured, events kept in memory only: %v\n", err)
+		return s
+	}
+
+	if f, err := os.Open(path); err == nil {
+		var current *envelope
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+		for scanner.Scan() {
+			e, key, err := decodeStoreLine(scanner.Bytes(), wrapper, current)
+			if err != nil {
+				log.Printf("[EventStore] Warning: skipping unreadable entry in %s: %v\n", path, err)
+				continue
+			}
+			if key != nil {
+				current = key
+				continue
+			}
+			s.add(e)
+		}
+		f.Close()
+		log.Printf("[EventStore] Loaded %d event(s) from %s\n", len(s.events), path)
+	}
+
+	if wrapper != nil {
+		if s.seal, err = newEnvelope(wrapper); err != nil {
+			log.Printf("[EventStore] Warning: could not create a data key, events kept in memory only: %v\n", err)
+			return s
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err == nil {
+		err = s.writeDataKey(f)
+	}
+	if err != nil {
+		log.Printf("[EventStore] Warning: could not open %s, events kept in memory only: %v\n", path, err)
+		if f != nil {
+			f.Close()
+		}
+		return s
+	}
+	s.file = f
+	return s
+}
+
+// decodeStoreLine parses one line of the store file. A data key line returns
+// the opened key; any other line returns the event, decrypting it with
+// current when sealed.
+func decodeStoreLine(line []byte, wrapper keyWrapper, current *envelope) (StoredEvent, *envelope, error) {
+	var e StoredEvent
+	var enc storeLine
+	if err := json.Unmarshal(line, &enc); err != nil {
+		return e, nil, err
+	}
+	switch {
+	case enc.DataKey != "":
+		if wrapper == nil {
+			return e, nil, fmt.Errorf("encrypted entries need PAYLOAD_ENCRYPTION")
+		}
+		key, err := openEnvelope(wrapper, enc.DataKey)
+		return e, key, err
+	case enc.Sealed != nil:
+		if current == nil {
+			return e, nil, fmt.Errorf("encrypted entry without a readable data key")
+		}
+		plain, err := gcmOpen(current.aead, enc.Sealed)
+		if err != nil {
+			return e, nil, err
+		}
+		line = plain
+	}
+	err := json.Unmarshal(line, &e)
+	return e, nil, err
+}
+
+// encodeLine renders e as a line of the store file, sealed when encryption
+// is on.
+func (s *EventStore) encodeLine(e StoredEvent) ([]byte, error) {
+	line, err := json.Marshal(e)
+	if err != nil || s.seal == nil {
+		return line, err
+	}
+	sealed, err := gcmSeal(s.seal.aead, line)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storeLine{Sealed: sealed})
+}
+
+// writeDataKey writes the wrapped data key line that the following sealed
+// lines of w are read with. It does nothing when encryption is off.
+func (s *EventStore) writeDataKey(w io.Writer) error {
+	if s.seal == nil {
+		return nil
+	}
+	line, err := json.Marshal(storeLine{DataKey: s.seal.wrapped})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// add appends e to the ring. Callers must hold s.mu for writing (or own s).
+func (s *EventStore) add(e StoredEvent) {
+	s.events = append(s.events, e)
+	if len(s.events) > s.max {
+		// Copy so the dropped prefix can be garbage collected.
+		s.events = append([]StoredEvent(nil), s.events[len(s.events)-s.max:]...)
+	}
+}
+
+// Len returns the number of stored events.
+func (s *EventStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.events)
+}
+
+// Append stores event along with the raw event type it was normalized from.
+func (s *EventStore) Append(rawEventType string, event *NormalizedEvent) {
+	countStoredEvent(event) // see usage.go
+	e := StoredEvent{
+		EventID:      event.EventID,
+		Platform:     event.Platform,
+		RawEventType: rawEventType,
+		Event:        event,
+		StoredAt:     time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(e)
+	if s.file != nil {
+		line, err := s.encodeLine(e)
+		if err == nil {
+			line = append(line, '\n')
+			_, err = s.file.Write(line)
+		}
+		if err != nil {
+			log.Printf("[EventStore] Warning: could not persist event %s: %v\n", e.EventID, err)
+		}
+	}
+}
+
+// Since returns the stored events received at or after t, oldest first.
+func (s *EventStore) Since(t time.Time) []StoredEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []StoredEvent
+	for _, e := range s.events {
+		if !e.StoredAt.Before(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Get returns the stored event with the given ID.
+func (s *EventStore) Get(eventID string) (StoredEvent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].EventID == eventID {
+			return s.events[i], true
+		}
+	}
+	return StoredEvent{}, false
+}
+
+// Prune drops events stored before cutoff and, when the store is file-backed,
+// rewrites the file to match. It returns the number of events removed.
+func (s *EventStore) Prune(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0:0]
+	for _, e := range s.events {
+		if !e.StoredAt.Before(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(s.events) - len(kept)
+	s.events = kept
+	if removed == 0 || s.file == nil {
+		return removed, nil
+	}
+
+	// Rewrite via a temp file so a crash never leaves a truncated store.
+	path := s.file.Name()
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".event-store-*")
+	if err != nil {
+		return removed, err
+	}
+	w := bufio.NewWriter(tmp)
+	if err := s.writeDataKey(w); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return removed, err
+	}
+	for _, e := range s.events {
+		line, err := s.encodeLine(e)
+		if err != nil {
+			continue
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return removed, err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return removed, err
+	}
+
+	s.file.Close()
+	s.file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		s.file = nil
+		return removed, fmt.Errorf("reopen %s, events now kept in memory only: %w", path, err)
+	}
+	return removed, nil
+}
+
+// pruneEventStore is the "event_retention" scheduler job: it removes events
+// older than EVENT_RETENTION_HOURS (default 168, one week).
+func pruneEventStore() error {
+	cutoff := time.Now().Add(-time.Duration(envInt("EVENT_RETENTION_HOURS", 168)) * time.Hour)
+	removed, err := store().Prune(cutoff)
+	if removed > 0 {
+		log.Printf("[EventStore] Pruned %d event(s) stored before %s\n", removed, cutoff.Format(time.RFC3339))
+	}
+	return err
+}
+
+// newEventID returns a random 128-bit hex identifier for a gateway event.
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,241 @@
+package main
+
+// Gateway federation — chains gateways so regional collectors forward their
+// normalized events to a central aggregator, which delivers them to its own
+// sinks as if it had normalized them itself.
+//
+// On a regional gateway, FEDERATION_UPSTREAM_URL adds the "federation" sink,
+// which POSTs every event to the aggregator's ingest endpoint:
+//
+//	POST /federation/events   (signed with FEDERATION_SECRET)
+//
+// Both sides share FEDERATION_SECRET. X-Hub-Signature-256 is the HMAC of
+// "<X-Gateway-Timestamp>.<body>", so a captured request cannot be replayed
+// once federationMaxSkew has passed, and is acknowledged as a duplicate
+// within it. A gateway with FEDERATION_SECRET set accepts forwarded events;
+// aggregators can themselves forward further upstream. Federation requires an
+// explicit GATEWAY_REGION on every gateway: the hostname fallback differs per
+// replica, which would let events loop between replicas of one region.
+//
+// Forwarded events keep their EventID and DeliveryID, and carry an Origin:
+// the gateway (GATEWAY_REGION) that normalized the event and every gateway
+// that has forwarded it since. An aggregator drops an event it has already
+// stored (a redelivery) and rejects one whose Origin lists itself (a loop)
+// or that has been forwarded more than FEDERATION_MAX_HOPS times (default 8).
+//
+// Forwarded events are stored, applied to /prs and published to the
+// aggregator's event bus, but not enriched again: the regional gateway has
+// already done that, and has the SCM credentials to do it.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// federationSinkName is the name of the sink forwarding to the upstream
+// gateway.
+const federationSinkName = "federation"
+
+// federationMaxSkew is how far a forwarded request's timestamp may be from
+// the receiver's clock.
+const federationMaxSkew = 5 * time.Minute
+
+var (
+	federationSeenOnce sync.Once
+	federationSeenRef  *ttlCache
+)
+
+// federationSeen holds the signatures of accepted requests for as long as
+// their timestamps are valid.
+func federationSeen() *ttlCache {
+	federationSeenOnce.Do(func() {
+		federationSeenRef = newTTLCache("federation_seen", 2*federationMaxSkew)
+	})
+	return federationSeenRef
+}
+
+// federationSignature signs a forwarded request's timestamp and body.
+func federationSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// EventOrigin records where a forwarded event came from.
+type EventOrigin struct {
+	Gateway string   // the gateway that normalized the event
+	Hops    []string // every gateway that forwarded it, in order
+}
+
+// FederationSink forwards events to an upstream gateway.
+type FederationSink struct {
+	url    string
+	secret string
+}
+
+func (s *FederationSink) Name() string {
+	return federationSinkName
+}
+
+// Deliver POSTs a copy of event, with this gateway added to its Origin, to
+// the upstream's ingest endpoint.
+func (s *FederationSink) Deliver(event *NormalizedEvent) error {
+	self := gatewayRegion()
+	forwarded := *event
+	origin := EventOrigin{Gateway: self}
+	if event.Origin != nil {
+		origin = EventOrigin{Gateway: event.Origin.Gateway, Hops: slices.Clone(event.Origin.Hops)}
+	}
+	origin.Hops = append(origin.Hops, self)
+	forwarded.Origin = &origin
+
+	body, err := json.Marshal(&forwarded)
+	if err != nil {
+		return fmt.Errorf("federation: failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("federation: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stored, ok := store().Get(event.EventID); ok {
+		// The upstream stores it for replay, which re-normalizes the raw payload.
+		req.Header.Set("X-Gateway-Event", stored.RawEventType)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Gateway-Timestamp", timestamp)
+	req.Header.Set("X-Hub-Signature-256", federationSignature(s.secret, timestamp, body))
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("federation: failed to reach upstream gateway at %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("federation: upstream gateway returned error %d for %s: %s", resp.StatusCode, s.url, respBody)
+	}
+	return nil
+}
+
+// federationSink returns the sink configured by FEDERATION_UPSTREAM_URL, or
+// nil.
+func federationSink() Sink {
+	url := os.Getenv("FEDERATION_UPSTREAM_URL")
+	if url == "" {
+		return nil
+	}
+	secret := os.Getenv("FEDERATION_SECRET")
+	if secret == "" {
+		log.Println("[Federation] Warning: FEDERATION_UPSTREAM_URL is set but FEDERATION_SECRET is not; not forwarding events")
+		return nil
+	}
+	if os.Getenv("GATEWAY_REGION") == "" {
+		log.Println("[Federation] Warning: FEDERATION_UPSTREAM_URL is set but GATEWAY_REGION is not; not forwarding events")
+		return nil
+	}
+	return &FederationSink{url: url, secret: secret}
+}
+
+// FederationIngestHandler accepts events forwarded by a downstream gateway.
+//
+//	POST /federation/events
+func FederationIngestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secret := os.Getenv("FEDERATION_SECRET")
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if os.Getenv("GATEWAY_REGION") == "" {
+		log.Println("[Federation] Warning: rejecting forwarded event: FEDERATION_SECRET is set but GATEWAY_REGION is not")
+		http.Error(w, "federation requires GATEWAY_REGION", http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	timestamp := r.Header.Get("X-Gateway-Timestamp")
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if !hmac.Equal([]byte(signature), []byte(federationSignature(secret, timestamp, body))) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid X-Gateway-Timestamp", http.StatusBadRequest)
+		return
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > federationMaxSkew || skew < -federationMaxSkew {
+		http.Error(w, "stale request: X-Gateway-Timestamp is outside the allowed window", http.StatusUnauthorized)
+		return
+	}
+	var event NormalizedEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event.EventID == "" || event.Origin == nil || len(event.Origin.Hops) == 0 {
+		http.Error(w, "event_id and origin are required", http.StatusBadRequest)
+		return
+	}
+
+	self := gatewayRegion()
+	if slices.Contains(event.Origin.Hops, self) {
+		log.Printf("[Federation] Warning: rejecting event %s: it already passed through %s (%v)\n", event.EventID, self, event.Origin.Hops)
+		http.Error(w, "federation loop: event already passed through "+self, http.StatusLoopDetected)
+		return
+	}
+	if maxHops := envInt("FEDERATION_MAX_HOPS", 8); len(event.Origin.Hops) > maxHops {
+		log.Printf("[Federation] Warning: rejecting event %s after %d hops\n", event.EventID, len(event.Origin.Hops))
+		http.Error(w, fmt.Sprintf("federation loop: more than %d hops", maxHops), http.StatusLoopDetected)
+		return
+	}
+	_, seen := federationSeen().Get(signature)
+	if _, ok := store().Get(event.EventID); ok || seen {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "success",
+			"event_id":  event.EventID,
+			"duplicate": true,
+		})
+		return
+	}
+	if mq == nil {
+		http.Error(w, "event bus unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	traceHop(event.EventID, "federated", "from "+event.Origin.Hops[len(event.Origin.Hops)-1])
+	if err := mq.PublishNormalizedEvent(&event); err != nil {
+		// Not stored yet, so the sender's retry is not taken for a duplicate.
+		log.Printf("[Federation] Warning: could not publish event %s: %v\n", event.EventID, err)
+		http.Error(w, "could not publish event", http.StatusServiceUnavailable)
+		return
+	}
+	federationSeen().Set(signature, true)
+	store().Append(r.Header.Get("X-Gateway-Event"), &event)
+	prSnapshots().Apply(&event)
+	log.Printf("[Federation] Accepted event %s (PR #%d) from %s via %v\n", event.EventID, event.PR.Number, event.Origin.Gateway, event.Origin.Hops)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":   "success",
+		"event_id": event.EventID,
+	})
+}
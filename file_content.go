@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// FileContentHandler reads a single file at a ref, or creates or updates it
+// on a branch with the gateway's credentials. Both require the admin token.
+//
+//	GET /file-content?owner=acme&repo=api&ref=main&path=config/app.yaml[&platform=github]
+//
+//	PUT /file-content
+//	{"platform": "github", "owner": "acme", "repo": "api", "branch": "main",
+//	 "path": "config/app.yaml", "message": "Update app config", "content": "...",
+//	 "encoding": "base64", "author": {"name": "Jane Doe", "email": "jane@example.com"}}
+func FileContentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		getFileContent(w, r)
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not commit files", http.StatusConflict)
+		return
+	}
+	var req struct {
+		FileCommit
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		Content  string      `json:"content"`
+		Encoding string      `json:"encoding"` // "" (UTF-8 text) or "base64"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.Branch == "" || req.Path == "" || req.Message == "" {
+		http.Error(w, "owner, repo, branch, path and message are required", http.StatusBadRequest)
+		return
+	}
+	if req.Author != nil && (req.Author.Name == "" || req.Author.Email == "") {
+		http.Error(w, "author needs a name and an email", http.StatusBadRequest)
+		return
+	}
+	switch req.Encoding {
+	case "":
+		req.FileCommit.Content = []byte(req.Content)
+	case "base64":
+		content, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			http.Error(w, "invalid base64 content: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.FileCommit.Content = content
+	default:
+		http.Error(w, "encoding must be empty or base64", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writer, ok := adapter.(ContentWriter)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "committing files").Error(), http.StatusNotImplemented)
+		return
+	}
+	sha, err := writer.CreateCommit(req.Owner, req.Repo, req.FileCommit)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[FileContent] Committed %s to %s %s/%s@%s\n", req.Path, req.Platform, req.Owner, req.Repo, req.Branch)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"sha":    sha,
+	})
+}
+
+// getFileContent serves the raw content of a file.
+func getFileContent(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	owner, repo, path, ref := q.Get("owner"), q.Get("repo"), q.Get("path"), q.Get("ref")
+	if owner == "" || repo == "" || ref == "" || path == "" {
+		http.Error(w, "owner, repo, ref and path are required", http.StatusBadRequest)
+		return
+	}
+	platform := SCMPlatform(q.Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reader, ok := adapter.(FileReader)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "reading files").Error(), http.StatusNotImplemented)
+		return
+	}
+	content, err := reader.GetFileContent(owner, repo, ref, path)
+	if err == errFileNotFound {
+		http.Error(w, fmt.Sprintf("%s not found at %s", path, ref), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(content))
+	w.Write(content)
+}
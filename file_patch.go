@@ -0,0 +1,65 @@
+package main
+
+// Per-file patches — with the "file_patches" feature flag on for a repo,
+// each NormalizedFile carries its unified diff hunks in Patch, so consumers
+// get line-level context without fetching the diff themselves. GitHub returns
+// them with the changed files; Bitbucket's are cut from the PR diff.
+//
+// Patches are capped at FILE_PATCH_MAX_BYTES (default 16384) per file: whole
+// hunks are kept up to the cap and PatchTruncated is set. A file whose first
+// hunk alone exceeds the cap gets no patch. Binary files have none either.
+
+import "strings"
+
+const defaultFilePatchMaxBytes = 16384
+
+// FlagFilePatches gates attaching patches to changed files.
+const FlagFilePatches = "file_patches"
+
+// filePatchesEnabled reports whether files of the repo carry patches.
+func filePatchesEnabled(platform SCMPlatform, fullName string) bool {
+	return featureEnabled(FlagFilePatches, platform, fullName, false)
+}
+
+// setPatch attaches patch (hunks, each starting with "@@") to f, capped at
+// FILE_PATCH_MAX_BYTES.
+func setPatch(f *NormalizedFile, patch string) {
+	limit := envInt("FILE_PATCH_MAX_BYTES", defaultFilePatchMaxBytes)
+	if len(patch) <= limit {
+		f.Patch = patch
+		return
+	}
+	f.PatchTruncated = true
+	var kept strings.Builder
+	for _, hunk := range splitHunks(patch) {
+		if kept.Len()+len(hunk) > limit {
+			break
+		}
+		kept.WriteString(hunk)
+	}
+	f.Patch = kept.String()
+}
+
+// splitHunks splits a patch before every "@@" hunk header.
+func splitHunks(patch string) []string {
+	var hunks []string
+	for _, line := range strings.SplitAfter(patch, "\n") {
+		if strings.HasPrefix(line, "@@") || len(hunks) == 0 {
+			hunks = append(hunks, line)
+			continue
+		}
+		hunks[len(hunks)-1] += line
+	}
+	return hunks
+}
+
+// diffPatches maps the paths of a unified diff to their hunks.
+func diffPatches(diff string) map[string]string {
+	patches := map[string]string{}
+	for _, f := range parseUnifiedDiff(diff) {
+		if f.path != "" && len(f.hunks) > 0 {
+			patches[f.path] = strings.Join(f.hunks, "")
+		}
+	}
+	return patches
+}
@@ -0,0 +1,69 @@
+package main
+
+// File stats — whether each changed file is binary and how large it is at the
+// PR head, set by the "files" enricher so analyzers can skip binaries and huge
+// files without fetching their content.
+//
+// IsBinary comes from the platform where it tells (Gerrit marks binaries;
+// GitHub gives them neither a patch nor line counts) and otherwise from the
+// file extension. SizeBytes comes from the file list where the platform
+// includes it (Gerrit) and otherwise, for adapters implementing
+// FileSizeReader (GitHub), from one listing of the head commit's tree. It is
+// 0 for removed files and where the size is unknown.
+
+import (
+	"log"
+	"path"
+	"strings"
+)
+
+// binaryExtensions are the extensions of files treated as binary when the
+// platform does not say.
+var binaryExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".ico": true, ".webp": true, ".tiff": true,
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".7z": true, ".rar": true, ".tar": true,
+	".jar": true, ".war": true, ".class": true, ".pyc": true, ".o": true, ".a": true, ".so": true, ".dylib": true,
+	".dll": true, ".exe": true, ".bin": true, ".wasm": true,
+	".ttf": true, ".otf": true, ".woff": true, ".woff2": true, ".eot": true,
+	".mp3": true, ".mp4": true, ".mov": true, ".avi": true, ".wav": true, ".ogg": true, ".flac": true,
+	".sqlite": true, ".db": true, ".parquet": true,
+}
+
+// isBinaryPath reports whether name has a binary file extension.
+func isBinaryPath(name string) bool {
+	return binaryExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// statFiles fills IsBinary and SizeBytes of the event's files where the
+// adapter has not.
+func statFiles(adapter SCMAdapter, event *NormalizedEvent) {
+	missing := false
+	for i := range event.Files {
+		f := &event.Files[i]
+		if !f.IsBinary {
+			f.IsBinary = isBinaryPath(f.Filename)
+		}
+		if f.SizeBytes == 0 && f.Status != "removed" {
+			missing = true
+		}
+	}
+	reader, ok := adapter.(FileSizeReader)
+	ref := event.PR.HeadSHA
+	if ref == "" {
+		ref = event.PR.SourceBranch
+	}
+	if !missing || !ok || ref == "" {
+		return
+	}
+	sizes, err := reader.GetFileSizes(event.Repository.Owner, event.Repository.Name, ref)
+	if err != nil {
+		log.Printf("[Enrichers] Warning: could not read file sizes for PR #%d in %s: %v\n", event.PR.Number, event.Repository.FullName, err)
+		return
+	}
+	for i := range event.Files {
+		if f := &event.Files[i]; f.SizeBytes == 0 && f.Status != "removed" {
+			f.SizeBytes = sizes[f.Filename]
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+// Feature flags — gate risky automations per repository or tenant, with
+// percentage rollouts, so they can be trialed on a few repos before an
+// organization-wide enablement.
+//
+// A flag is a rule:
+//
+//	{"enabled": false, "percent": 10, "repos": ["acme/api", "github:acme/*"], "exclude": ["acme/legacy"]}
+//
+// evaluated for a repository as: excluded → off; listed in repos → on;
+// enabled → on; otherwise on for a stable percent of repositories (hashed
+// with the flag name, so each flag samples different repos). Rules come from
+// three providers, the first that defines the flag wins:
+//
+//	FLAG_<NAME>          env: "on", "off" or a percentage ("25%")
+//	FEATURE_FLAGS_URL    remote JSON {"flags": {name: rule}}, refreshed every
+//	                     FEATURE_FLAGS_REFRESH_SECONDS (default 60)
+//	FEATURE_FLAGS_FILE   local JSON in the same format
+//
+// A flag no provider defines keeps the feature's own default (for
+// llm_review, the repo's "llm_review" setting). Gated features:
+//
+//	llm_review         automated LLM reviews (reviewer.go)
+//	analyzers          static analyzers (analysis.go)
+//	pr_snapshot_poll   open-PR polling by the pr_snapshot_refresh job
+//	auto_merge         merging by the auto-merge engine (auto_merge.go);
+//	                   defaults to the repo's "auto_merge" policy
+//	file_patches       per-file diff hunks in NormalizedFile (file_patch.go);
+//	                   off by default
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlagRule is the definition of one feature flag.
+type FlagRule struct {
+	Enabled bool     `json:"enabled"`
+	Percent int      `json:"percent,omitempty"`
+	Repos   []string `json:"repos,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+type flagFile struct {
+	Flags map[string]FlagRule `json:"flags"`
+}
+
+// Feature flag names.
+const (
+	FlagLLMReview      = "llm_review"
+	FlagAnalyzers      = "analyzers"
+	FlagPRSnapshotPoll = "pr_snapshot_poll"
+	FlagAutoMerge      = "auto_merge"
+)
+
+var (
+	fileFlagsOnce sync.Once
+	fileFlags     map[string]FlagRule
+
+	remoteFlagsMu      sync.Mutex
+	remoteFlags        map[string]FlagRule
+	remoteFlagsFetched time.Time
+)
+
+// parseFlags parses a flag document.
+func parseFlags(data []byte) (map[string]FlagRule, error) {
+	var f flagFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return f.Flags, nil
+}
+
+// envFlag returns the rule FLAG_<NAME> defines, if any.
+func envFlag(name string) (FlagRule, bool) {
+	value := strings.TrimSpace(os.Getenv("FLAG_" + strings.ToUpper(name)))
+	switch strings.ToLower(value) {
+	case "":
+		return FlagRule{}, false
+	case "on", "true":
+		return FlagRule{Enabled: true}, true
+	case "off", "false":
+		return FlagRule{}, true
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		log.Printf("[Flags] Warning: ignoring FLAG_%s=%q (want on, off or a percentage)\n", strings.ToUpper(name), value)
+		return FlagRule{}, false
+	}
+	return FlagRule{Percent: percent}, true
+}
+
+// remoteFlagRules returns the rules served at FEATURE_FLAGS_URL, refetched
+// once the last fetch is older than the refresh interval. A failed fetch
+// keeps the previous rules.
+func remoteFlagRules() map[string]FlagRule {
+	flagsURL := os.Getenv("FEATURE_FLAGS_URL")
+	if flagsURL == "" {
+		return nil
+	}
+	remoteFlagsMu.Lock()
+	defer remoteFlagsMu.Unlock()
+
+	refresh := time.Duration(envInt("FEATURE_FLAGS_REFRESH_SECONDS", 60)) * time.Second
+	if time.Since(remoteFlagsFetched) < refresh {
+		return remoteFlags
+	}
+	remoteFlagsFetched = time.Now()
+
+	rules, err := fetchRemoteFlags(flagsURL)
+	if err != nil {
+		log.Printf("[Flags] Warning: could not fetch %s: %v\n", redactURL(flagsURL), err)
+		return remoteFlags
+	}
+	remoteFlags = rules
+	return remoteFlags
+}
+
+func fetchRemoteFlags(flagsURL string) (map[string]FlagRule, error) {
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Get(flagsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("flag provider returned %d", resp.StatusCode)
+	}
+	var f flagFile
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	return f.Flags, nil
+}
+
+// fileFlagRules returns the rules of FEATURE_FLAGS_FILE, read once.
+func fileFlagRules() map[string]FlagRule {
+	fileFlagsOnce.Do(func() {
+		path := os.Getenv("FEATURE_FLAGS_FILE")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Flags] Warning: could not read %s: %v\n", path, err)
+			return
+		}
+		rules, err := parseFlags(data)
+		if err != nil {
+			log.Printf("[Flags] Warning: could not parse %s: %v\n", path, err)
+			return
+		}
+		fileFlags = rules
+		log.Printf("[Flags] Loaded %s (%d flags)\n", path, len(rules))
+	})
+	return fileFlags
+}
+
+// flagRule returns the rule for name and the provider that defined it.
+func flagRule(name string) (FlagRule, string, bool) {
+	if rule, ok := envFlag(name); ok {
+		return rule, "env", true
+	}
+	if rule, ok := remoteFlagRules()[name]; ok {
+		return rule, "remote", true
+	}
+	if rule, ok := fileFlagRules()[name]; ok {
+		return rule, "file", true
+	}
+	return FlagRule{}, "", false
+}
+
+// evaluate reports whether the rule turns flag on for platform:fullName.
+func (r FlagRule) evaluate(flag string, platform SCMPlatform, fullName string) bool {
+	keys := []string{fullName, string(platform) + ":" + fullName}
+	for _, key := range keys {
+		if matchAnyGlob(r.Exclude, key) {
+			return false
+		}
+	}
+	for _, key := range keys {
+		if matchAnyGlob(r.Repos, key) {
+			return true
+		}
+	}
+	if r.Enabled {
+		return true
+	}
+	if r.Percent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(flag + "|" + string(platform) + ":" + strings.ToLower(fullName)))
+	return int(h.Sum32()%100) < r.Percent
+}
+
+// featureEnabled reports whether the named feature is on for a repository;
+// fallback is the answer when no provider defines the flag.
+func featureEnabled(name string, platform SCMPlatform, fullName string, fallback bool) bool {
+	rule, _, ok := flagRule(name)
+	if !ok {
+		return fallback
+	}
+	return rule.evaluate(name, platform, fullName)
+}
+
+// flagReport describes the known flags for the configuration report.
+func flagReport() map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, name := range []string{FlagLLMReview, FlagAnalyzers, FlagPRSnapshotPoll, FlagAutoMerge, FlagFilePatches} {
+		rule, provider, ok := flagRule(name)
+		if !ok {
+			out[name] = "default"
+			continue
+		}
+		out[name] = map[string]interface{}{"provider": provider, "rule": rule}
+	}
+	return out
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 )
 
@@ -9,16 +11,19 @@ import (
 //
 //  1. Identify platform (already encoded in the message).
 //  2. Build the right SCMAdapter.
-//  3. Call NormalizeEvent — fetches PR metadata + changed files from the SCM API.
-//  4. Publish the resulting NormalizedEvent to the normalized events queue
+//  3. Call NormalizeEvent — maps the payload and backfills PR metadata.
+//  4. Run the enricher chain — changed files, tickets, … (see enrichers.go).
+//  5. Publish the resulting NormalizedEvent to the normalized events queue
 //     (the "Unified Event Bus" in the sequence diagram).
 //
-// This function blocks until the broker closes the channel; call it in a
-// goroutine from main.
+// One consumer runs per queue shard (see queue_shards.go); each is supervised
+// and restarted if the channel closes (see superviseConsumer). This function
+// never returns; call it in a goroutine from main.
 func StartConsumer(mq *RabbitMQ) {
-	if err := mq.ConsumeRawEvents(processRawEvent(mq)); err != nil {
-		log.Fatalf("[Consumer] Fatal error, consumer stopped: %v\n", err)
-	}
+	handle := processRawEvent(mq)
+	superviseShards("Consumer", rawEventsQueue, func(queue string) error {
+		return mq.ConsumeRawEvents(queue, handle)
+	})
 }
 
 // processRawEvent returns a closure that handles a single RawWebhookMessage
@@ -26,27 +31,115 @@ func StartConsumer(mq *RabbitMQ) {
 func processRawEvent(mq *RabbitMQ) func(RawWebhookMessage) {
 	return func(msg RawWebhookMessage) {
 		log.Printf("[Consumer] Received event — platform=%s type=%s\n", msg.Platform, msg.EventType)
+		traceHop(msg.EventID, "consumed", rawEventsQueue)
 
-		// Build the adapter for the detected platform.
-		adapter, err := NewSCMAdapter(msg.Platform)
+		// Build the adapter for the detected platform. Its API calls, and
+		// those of the enrichers, reviewers and analyzers using it, are
+		// attributed to this event's trace and tenant.
+		ctx := withUsageTenant(withTraceID(context.Background(), msg.EventID), msg.Platform, msg.Repo)
+		adapter, err := NewScopedSCMAdapter(ctx, msg.Platform)
 		if err != nil {
 			log.Printf("[Consumer] Warning: could not create adapter for %q: %v\n", msg.Platform, err)
+			traceHop(msg.EventID, "normalize_failed", err.Error())
+			traceRawPayload(msg.EventID, msg.Payload)
 			return
 		}
 
-		// NormalizeEvent parses the payload, fetches PR details and files from
-		// the SCM API, and returns a platform-agnostic NormalizedEvent.
+		// NormalizeEvent parses the payload, backfills PR details from the SCM
+		// API, and returns a platform-agnostic NormalizedEvent.
 		event, err := adapter.NormalizeEvent(msg.EventType, msg.Payload)
 		if err != nil {
 			log.Printf("[Consumer] Warning: could not normalize event: %v\n", err)
+			traceHop(msg.EventID, "normalize_failed", err.Error())
+			traceRawPayload(msg.EventID, msg.Payload)
+			return
+		}
+		event.EventID = msg.EventID
+		event.DeliveryID = msg.DeliveryID
+		event.SchemaVersion = NormalizedSchemaVersion
+		traceHop(event.EventID, "normalized", fmt.Sprintf("PR #%d", event.PR.Number))
+
+		// Security alerts are not about a PR and have a queue of their own.
+		if event.EventType == EventTypeSecurityAlert {
+			publishSecurityAlert(mq, msg.EventType, event)
+			return
+		}
+
+		// Comments the gateway wrote itself would otherwise feed back into
+		// the reviewers and policies.
+		if isSelfComment(event) {
+			log.Printf("[Consumer] Suppressing self-authored comment event on PR #%d\n", event.PR.Number)
+			traceHop(event.EventID, "suppressed", "self-authored comment")
 			return
 		}
 
+		// The enricher chain fetches the changed files, then — unless the
+		// gate skips the rest — commits, owners, tickets (see enrichers.go).
+		runEnrichers(adapter, event, enrichmentGate)
+		if event.EnrichmentSkipped != "" {
+			traceHop(event.EventID, "enrichment_skipped", event.EnrichmentSkipped)
+		} else {
+			enrichEvent(adapter, event)
+			traceHop(event.EventID, "enriched", fmt.Sprintf("%d file(s)", len(event.Files)))
+		}
+
+		// From here on the event leaves the pipeline: apply the tenant's
+		// privacy mode first.
+		event = anonymizeEvent(event)
+
 		logNormalizedEvent(event)
 
+		// Keep the event (and its raw payload) for replay.
+		store().Append(msg.EventType, event)
+		prSnapshots().Apply(event)
+
 		// Publish to the Unified Event Bus (normalized_pr_events queue).
 		if err := mq.PublishNormalizedEvent(event); err != nil {
 			log.Printf("[Consumer] Warning: could not publish normalized event: %v\n", err)
+			traceHop(event.EventID, "publish_failed", err.Error())
+			return
 		}
+		traceHop(event.EventID, "published", normalizedEventsQueue)
+
+		// Open PRs waiting for unmerged dependencies get a follow-up event.
+		publishDependencyBlocked(event)
+	}
+}
+
+// enrichmentGate returns why enrichment past the file list should be skipped,
+// or "" to run it. Changed-path rules may also trim the file list here, and
+// short-circuit the quota-heavy stages for e.g. documentation-only PRs.
+func enrichmentGate(event *NormalizedEvent) string {
+	if isStandby() {
+		// Write-backs to the SCM belong to the active region.
+		return "standby"
+	}
+	if registry.IsSuspended(event.Platform, event.Repository.Owner) {
+		log.Printf("[Consumer] Skipping enrichment of PR #%d: installation for %s is suspended\n", event.PR.Number, event.Repository.Owner)
+		return "installation suspended"
+	}
+	if skip, reason := applyPathRules(event); skip {
+		log.Printf("[Consumer] Skipping enrichment of PR #%d: %s\n", event.PR.Number, reason)
+		return reason
 	}
+	return ""
+}
+
+// enrichEvent runs the post-enrichment stages: per-repo automations, policy
+// stages, the asynchronous reviewers / analyzers, and auto-merge.
+func enrichEvent(adapter SCMAdapter, event *NormalizedEvent) {
+	// Per-repo automations that write back to the SCM.
+	applyDescriptionTemplate(adapter, event)
+	applyChangelog(adapter, event)
+
+	// Optional policy stages that report findings on the PR.
+	runConventionalCommitsPolicy(adapter, event)
+	runFilePolicy(adapter, event)
+
+	// Automated reviewers and analyzers run asynchronously and post back via the adapter.
+	runReviewers(adapter, event)
+	runAnalyzers(adapter, event)
+
+	// Merges PRs that meet the repo's auto-merge conditions.
+	runAutoMerge(adapter, event)
 }
@@ -62,7 +62,7 @@ func AuthTestHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Test an authenticated API request (get authenticated user)
 	log.Println("Step 3: Making authenticated API request...")
-	responseBody, err := makeAuthenticatedRequest(installationToken, "GET", "https://api.github.com/user", nil)
+	responseBody, err := makeAuthenticatedRequest(r.Context(), installationToken, "GET", "https://api.github.com/user", nil)
 	if err != nil {
 		log.Println("Error: Failed to make authenticated request:", err)
 		http.Error(w, "Failed to make authenticated request", http.StatusInternalServerError)
@@ -0,0 +1,105 @@
+package main
+
+// Liveness — queue consumers are supervised: when one stops (channel closed,
+// broker hiccup) it is restarted with backoff. If a consumer stays down past
+// CONSUMER_LIVENESS_GRACE_SECONDS (default 60), GET /healthz starts failing
+// so the orchestrator restarts the pod with a fresh broker connection.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// consumerState is the supervision state of one consumer.
+type consumerState struct {
+	Running   bool      `json:"running"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	DownSince time.Time `json:"down_since,omitempty"`
+}
+
+var (
+	consumersMu sync.Mutex
+	consumers   = map[string]*consumerState{}
+)
+
+// superviseConsumer runs consume, restarting it with exponential backoff
+// (1s up to 30s) whenever it returns. It blocks forever; call it in a
+// goroutine.
+func superviseConsumer(name string, consume func() error) {
+	backoff := time.Second
+	for {
+		consumersMu.Lock()
+		state := consumers[name]
+		if state == nil {
+			state = &consumerState{}
+			consumers[name] = state
+		}
+		state.Running, state.DownSince = true, time.Time{}
+		consumersMu.Unlock()
+
+		started := time.Now()
+		err := consume()
+		if err == nil {
+			err = errConsumerStopped
+		}
+		log.Printf("[%s] Warning: consumer stopped, restarting in %s: %v\n", name, backoff, err)
+
+		consumersMu.Lock()
+		state.Running = false
+		state.LastError = err.Error()
+		state.DownSince = time.Now()
+		state.Restarts++
+		consumersMu.Unlock()
+
+		// A consumer that ran for a while earned a fresh backoff.
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// errConsumerStopped is reported when a consumer returns without an error,
+// i.e. the broker closed its delivery channel.
+var errConsumerStopped = errors.New("delivery channel closed")
+
+// HealthzHandler is the liveness probe.
+//
+//	GET /healthz
+//
+// Returns 503 if any consumer has been down longer than the grace period.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	grace := time.Duration(envInt("CONSUMER_LIVENESS_GRACE_SECONDS", 60)) * time.Second
+
+	consumersMu.Lock()
+	snapshot := make(map[string]consumerState, len(consumers))
+	healthy := true
+	for name, s := range consumers {
+		snapshot[name] = *s
+		if !s.Running && time.Since(s.DownSince) > grace {
+			healthy = false
+		}
+	}
+	consumersMu.Unlock()
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    status,
+		"leader":    isLeader(),
+		"role":      gatewayRole(),
+		"consumers": snapshot,
+	})
+}
@@ -0,0 +1,318 @@
+package main
+
+// Webhook self-registration — verifies at startup that the SCM webhooks point
+// at this gateway's public URL.
+//
+// Enabled by setting GATEWAY_PUBLIC_URL (e.g. https://hooks.example.com).
+// WEBHOOK_SYNC_MODE controls what happens on a mismatch:
+//   - "warn" (default) → log every mismatch, change nothing.
+//   - "fix"            → rewrite the hook URL / content type / events.
+//
+// GitHub: the App's single webhook is checked via /app/hook/config; the
+// subscribed events live in the App settings and can only be reported.
+// Bitbucket: each repo listed in BITBUCKET_WEBHOOK_REPOS ("ws/repo,ws/repo2")
+// is checked via /repositories/{ws}/{repo}/hooks. Hooks created or repaired
+// in fix mode are signed with the Bitbucket webhook secret. A hook on another
+// path or host is never taken over, except on the hosts listed in
+// GATEWAY_FORMER_PUBLIC_URLS (e.g. the old hostname before a DNS change).
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// webhookPath is the route every SCM webhook should be delivered to.
+const webhookPath = "/webhook"
+
+// requiredGitHubEvents are the App subscriptions the pipeline depends on.
+var requiredGitHubEvents = []string{"pull_request", githubReviewThreadEvent}
+
+// requiredBitbucketEvents are the repo hook events the pipeline depends on.
+var requiredBitbucketEvents = []string{
+	"pullrequest:created",
+	"pullrequest:updated",
+	"pullrequest:fulfilled",
+	"pullrequest:rejected",
+	"pullrequest:comment_resolved",
+	"pullrequest:comment_reopened",
+}
+
+// SyncWebhooks checks the configured SCM webhooks against GATEWAY_PUBLIC_URL
+// and warns about or fixes mismatches. It is a no-op when the variable is
+// unset. Errors are logged rather than returned so a misconfigured SCM never
+// prevents the gateway from booting. The scheduler re-runs the check
+// periodically as the "webhook_sync" job.
+func SyncWebhooks() {
+	if err := syncWebhooks(); err != nil {
+		log.Printf("[HookSync] Warning: %v\n", err)
+	}
+}
+
+// syncWebhooks performs one sync pass, logging each failed check and
+// returning an error summarising them.
+func syncWebhooks() error {
+	publicURL := strings.TrimRight(os.Getenv("GATEWAY_PUBLIC_URL"), "/")
+	if publicURL == "" {
+		return nil
+	}
+	expectedURL := publicURL + webhookPath
+	fix := strings.EqualFold(os.Getenv("WEBHOOK_SYNC_MODE"), "fix")
+	if fix && (!isLeader() || isStandby()) {
+		// Only the leader of the active region rewrites hooks; everyone
+		// else just reports.
+		fix = false
+	}
+
+	log.Printf("[HookSync] Verifying webhooks point at %s (fix=%t)\n", expectedURL, fix)
+
+	failures := 0
+	if getAppIDFromEnv() != "" && getPrivateKeyFromEnv() != "" {
+		if err := syncGitHubAppHook(expectedURL, fix); err != nil {
+			log.Printf("[HookSync] Warning: GitHub hook check failed: %v\n", err)
+			failures++
+		}
+	}
+
+	if repos := os.Getenv("BITBUCKET_WEBHOOK_REPOS"); repos != "" {
+		adapter, err := NewBitbucketAdapter()
+		if err != nil {
+			return err
+		}
+		for _, fullName := range strings.Split(repos, ",") {
+			fullName = strings.TrimSpace(fullName)
+			if fullName == "" {
+				continue
+			}
+			if err := syncBitbucketRepoHook(adapter, fullName, expectedURL, fix); err != nil {
+				log.Printf("[HookSync] Warning: Bitbucket hook check for %s failed: %v\n", fullName, err)
+				failures++
+			}
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d webhook check(s) failed", failures)
+	}
+	return nil
+}
+
+// isGatewayHookURL reports whether url delivers to the gateway: either the
+// generic route (expectedURL) or the platform's pinned route.
+func isGatewayHookURL(url, expectedURL string, platform SCMPlatform) bool {
+	return url == expectedURL || url == expectedURL+"/"+string(platform)
+}
+
+// isFormerGatewayHookURL reports whether hookURL delivers to a webhook route
+// on the gateway's current host or one of GATEWAY_FORMER_PUBLIC_URLS.
+func isFormerGatewayHookURL(hookURL, expectedURL string, platform SCMPlatform) bool {
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return false
+	}
+	path := strings.TrimRight(u.Path, "/")
+	if path != webhookPath && path != webhookPath+"/"+string(platform) {
+		return false
+	}
+	hosts := []string{expectedURL}
+	for _, former := range strings.Split(os.Getenv("GATEWAY_FORMER_PUBLIC_URLS"), ",") {
+		if former = strings.TrimSpace(former); former != "" {
+			hosts = append(hosts, former)
+		}
+	}
+	for _, h := range hosts {
+		if !strings.Contains(h, "://") {
+			h = "https://" + h
+		}
+		if gw, err := url.Parse(h); err == nil && strings.EqualFold(gw.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ghHookConfig is the GitHub App webhook configuration.
+type ghHookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+}
+
+// syncGitHubAppHook compares the App webhook config with expectedURL.
+func syncGitHubAppHook(expectedURL string, fix bool) error {
+	jwtToken, err := generateJWT(getAppIDFromEnv(), getPrivateKeyFromEnv())
+	if err != nil {
+		return fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	body, err := makeAppRequest(jwtToken, "GET", "https://api.github.com/app/hook/config", nil)
+	if err != nil {
+		return err
+	}
+	var cfg ghHookConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return fmt.Errorf("failed to parse hook config: %w", err)
+	}
+
+	patch := map[string]string{}
+	if !isGatewayHookURL(cfg.URL, expectedURL, PlatformGitHub) {
+		log.Printf("[HookSync] GitHub App hook URL mismatch: have %q, want %q\n", cfg.URL, expectedURL)
+		patch["url"] = expectedURL
+	}
+	if cfg.ContentType != "json" {
+		log.Printf("[HookSync] GitHub App hook content type is %q, want \"json\"\n", cfg.ContentType)
+		patch["content_type"] = "json"
+	}
+
+	if len(patch) > 0 && fix {
+		if _, err := makeAppRequest(jwtToken, "PATCH", "https://api.github.com/app/hook/config", patch); err != nil {
+			return fmt.Errorf("failed to update hook config: %w", err)
+		}
+		log.Println("[HookSync] ✓ GitHub App hook config updated")
+	} else if len(patch) == 0 {
+		log.Println("[HookSync] ✓ GitHub App hook config OK")
+	}
+
+	// Event subscriptions are part of the App settings and cannot be changed
+	// through the API, so missing ones are only reported.
+	body, err = makeAppRequest(jwtToken, "GET", "https://api.github.com/app", nil)
+	if err != nil {
+		return err
+	}
+	var app struct {
+		Events []string `json:"events"`
+	}
+	if err := json.Unmarshal(body, &app); err != nil {
+		return fmt.Errorf("failed to parse app response: %w", err)
+	}
+	if missing := missingEvents(app.Events, requiredGitHubEvents); len(missing) > 0 {
+		log.Printf("[HookSync] Warning: GitHub App is not subscribed to %v — enable them in the App settings\n", missing)
+	}
+	return nil
+}
+
+// bbHook is a Bitbucket repository webhook. Secret is write-only: the API
+// reports only whether one is set.
+type bbHook struct {
+	UUID        string   `json:"uuid,omitempty"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret,omitempty"`
+	SecretSet   bool     `json:"secret_set,omitempty"`
+}
+
+// syncBitbucketRepoHook finds the gateway hook on a Bitbucket repo and
+// compares its URL and events with what the pipeline expects.
+func syncBitbucketRepoHook(b *BitbucketAdapter, fullName, expectedURL string, fix bool) error {
+	hooksURL := fmt.Sprintf("%s/repositories/%s/hooks", b.baseURL, fullName)
+	var list struct {
+		Values []bbHook `json:"values"`
+	}
+	err := paginate(hooksURL, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []bbHook `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("failed to parse hooks response: %w", err)
+		}
+		list.Values = append(list.Values, page.Values...)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Prefer an exact URL match; otherwise take over a hook delivering to a
+	// webhook route on a current or former gateway host.
+	var hook *bbHook
+	for i := range list.Values {
+		if isGatewayHookURL(list.Values[i].URL, expectedURL, PlatformBitbucket) {
+			hook = &list.Values[i]
+			break
+		}
+	}
+	if hook == nil {
+		for i := range list.Values {
+			if isFormerGatewayHookURL(list.Values[i].URL, expectedURL, PlatformBitbucket) {
+				hook = &list.Values[i]
+				break
+			}
+		}
+	}
+
+	if hook == nil {
+		log.Printf("[HookSync] Bitbucket %s has no webhook pointing at the gateway\n", fullName)
+		if !fix {
+			return nil
+		}
+		newHook := bbHook{
+			Description: "SCM webhook gateway",
+			URL:         expectedURL,
+			Active:      true,
+			Events:      requiredBitbucketEvents,
+			Secret:      webhookSecret(PlatformBitbucket),
+		}
+		if _, err := b.do("POST", hooksURL, newHook); err != nil {
+			return fmt.Errorf("failed to create hook: %w", err)
+		}
+		log.Printf("[HookSync] ✓ Bitbucket %s webhook created\n", fullName)
+		return nil
+	}
+
+	changed := false
+	if hook.URL != expectedURL {
+		log.Printf("[HookSync] Bitbucket %s hook URL mismatch: have %q, want %q\n", fullName, hook.URL, expectedURL)
+		hook.URL = expectedURL
+		changed = true
+	}
+	if missing := missingEvents(hook.Events, requiredBitbucketEvents); len(missing) > 0 {
+		log.Printf("[HookSync] Bitbucket %s hook is missing events %v\n", fullName, missing)
+		hook.Events = append(hook.Events, missing...)
+		changed = true
+	}
+	if !hook.Active {
+		log.Printf("[HookSync] Bitbucket %s hook is inactive\n", fullName)
+		hook.Active = true
+		changed = true
+	}
+	if secret := webhookSecret(PlatformBitbucket); secret != "" && !hook.SecretSet {
+		log.Printf("[HookSync] Bitbucket %s hook has no secret, so its deliveries fail signature checks\n", fullName)
+		hook.Secret = secret
+		changed = true
+	}
+
+	if !changed {
+		log.Printf("[HookSync] ✓ Bitbucket %s hook OK\n", fullName)
+		return nil
+	}
+	if !fix {
+		return nil
+	}
+	hookURL := fmt.Sprintf("%s/%s", hooksURL, hook.UUID)
+	uuid := hook.UUID
+	hook.UUID, hook.SecretSet = "", false
+	if _, err := b.do("PUT", hookURL, hook); err != nil {
+		return fmt.Errorf("failed to update hook %s: %w", uuid, err)
+	}
+	log.Printf("[HookSync] ✓ Bitbucket %s hook updated\n", fullName)
+	return nil
+}
+
+// missingEvents returns the entries of required not present in have.
+func missingEvents(have, required []string) []string {
+	set := make(map[string]bool, len(have))
+	for _, e := range have {
+		set[e] = true
+	}
+	var missing []string
+	for _, e := range required {
+		if !set[e] {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
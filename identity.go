@@ -0,0 +1,221 @@
+package main
+
+// Identity mapping — resolves platform usernames (GitHub login, Bitbucket
+// nickname, …) to canonical corporate identities, so analytics aggregate one
+// person across platforms. The "identity" enricher attaches the PR author's
+// identity as CanonicalAuthor.
+//
+// Identities come from the JSON file named by IDENTITY_MAP_FILE:
+//
+//	{"people": [
+//	  {"id": "jdoe", "email": "jane.doe@example.com", "name": "Jane Doe",
+//	   "accounts": {"github": "janedoe", "bitbucket": "jdoe"}}
+//	]}
+//
+// and, for usernames the file does not know, from a SCIM 2.0 directory
+// (SCIM_BASE_URL, bearer SCIM_TOKEN) — the usual front of LDAP and identity
+// providers. The directory is queried with IDENTITY_SCIM_FILTER (default
+// `userName eq "{login}"`; {platform} and {login} are substituted). Lookups
+// are cached for an hour, misses included.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSCIMFilter = `userName eq "{login}"`
+	identityCacheTTL  = time.Hour
+)
+
+// CanonicalIdentity is the corporate identity behind a platform account.
+// Source is "file" or "scim".
+type CanonicalIdentity struct {
+	ID     string
+	Email  string
+	Name   string
+	Source string
+}
+
+// identityMapFile is the format of IDENTITY_MAP_FILE.
+type identityMapFile struct {
+	People []struct {
+		ID       string            `json:"id"`
+		Email    string            `json:"email"`
+		Name     string            `json:"name"`
+		Accounts map[string]string `json:"accounts"` // platform → username
+	} `json:"people"`
+}
+
+var (
+	identityMapOnce sync.Once
+	identityMap     map[string]CanonicalIdentity // by identityKey
+)
+
+func identityKey(platform SCMPlatform, login string) string {
+	return string(platform) + ":" + strings.ToLower(login)
+}
+
+// loadIdentityMap reads IDENTITY_MAP_FILE once; later calls reuse the result.
+func loadIdentityMap() map[string]CanonicalIdentity {
+	identityMapOnce.Do(func() {
+		identityMap = map[string]CanonicalIdentity{}
+		path := os.Getenv("IDENTITY_MAP_FILE")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Identity] Warning: could not read %s: %v\n", path, err)
+			return
+		}
+		var file identityMapFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			log.Printf("[Identity] Warning: could not parse %s: %v\n", path, err)
+			return
+		}
+		for _, p := range file.People {
+			for platform, login := range p.Accounts {
+				identityMap[identityKey(SCMPlatform(platform), login)] = CanonicalIdentity{ID: p.ID, Email: p.Email, Name: p.Name, Source: "file"}
+			}
+		}
+		log.Printf("[Identity] Loaded %s (%d people, %d accounts)\n", path, len(file.People), len(identityMap))
+	})
+	return identityMap
+}
+
+// scimDirectory looks identities up in a SCIM directory, caching results
+// (including "no such user") for identityCacheTTL.
+type scimDirectory struct {
+	cache *ttlCache
+}
+
+var (
+	scimOnce sync.Once
+	scimRef  *scimDirectory
+)
+
+// scim returns the SCIM directory client.
+func scim() *scimDirectory {
+	scimOnce.Do(func() {
+		scimRef = &scimDirectory{cache: newTTLCache("scim_identities", identityCacheTTL)}
+	})
+	return scimRef
+}
+
+// lookup returns the identity of login, or nil. Without SCIM_BASE_URL it
+// always returns nil; API errors are logged and not cached.
+func (d *scimDirectory) lookup(ctx context.Context, platform SCMPlatform, login string) *CanonicalIdentity {
+	baseURL := strings.TrimRight(os.Getenv("SCIM_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil
+	}
+	key := identityKey(platform, login)
+	if cached, ok := d.cache.Get(key); ok {
+		return cached.(*CanonicalIdentity)
+	}
+
+	identity, err := fetchSCIMUser(ctx, baseURL, platform, login)
+	if err != nil {
+		log.Printf("[Identity] Warning: SCIM lookup of %s failed: %v\n", key, err)
+		return nil
+	}
+	d.cache.Set(key, identity)
+	return identity
+}
+
+// fetchSCIMUser queries the directory's /Users with IDENTITY_SCIM_FILTER. It
+// returns nil (and no error) when no user matches.
+func fetchSCIMUser(ctx context.Context, baseURL string, platform SCMPlatform, login string) (*CanonicalIdentity, error) {
+	filter := os.Getenv("IDENTITY_SCIM_FILTER")
+	if filter == "" {
+		filter = defaultSCIMFilter
+	}
+	// Quotes would end the SCIM string literal early.
+	login = strings.ReplaceAll(login, `"`, "")
+	filter = strings.NewReplacer("{platform}", string(platform), "{login}", login).Replace(filter)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/Users?count=2&filter="+url.QueryEscape(filter), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("SCIM_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	setAPIHeaders(req, "application/scim+json")
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("SCIM API %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Resources []struct {
+			ID          string `json:"id"`
+			UserName    string `json:"userName"`
+			DisplayName string `json:"displayName"`
+			Name        struct {
+				Formatted string `json:"formatted"`
+			} `json:"name"`
+			Emails []struct {
+				Value   string `json:"value"`
+				Primary bool   `json:"primary"`
+			} `json:"emails"`
+		} `json:"Resources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse SCIM response: %w", err)
+	}
+	// An ambiguous filter must not attribute work to the wrong person.
+	if len(list.Resources) != 1 {
+		return nil, nil
+	}
+	user := list.Resources[0]
+	identity := &CanonicalIdentity{ID: user.UserName, Name: user.DisplayName, Source: "scim"}
+	if identity.ID == "" {
+		identity.ID = user.ID
+	}
+	if identity.Name == "" {
+		identity.Name = user.Name.Formatted
+	}
+	for _, e := range user.Emails {
+		if e.Primary || identity.Email == "" {
+			identity.Email = e.Value
+		}
+	}
+	return identity, nil
+}
+
+// resolveIdentity returns the canonical identity of a platform account, or
+// nil if neither the mapping file nor the directory (queried in ctx) knows it.
+func resolveIdentity(ctx context.Context, platform SCMPlatform, login string) *CanonicalIdentity {
+	if login == "" {
+		return nil
+	}
+	if identity, ok := loadIdentityMap()[identityKey(platform, login)]; ok {
+		return &identity
+	}
+	return scim().lookup(ctx, platform, login)
+}
+
+// IdentityEnricher attaches the canonical identity of the PR author.
+type IdentityEnricher struct{}
+
+func (IdentityEnricher) Name() string { return "identity" }
+
+func (IdentityEnricher) Enrich(adapter SCMAdapter, event *NormalizedEvent) error {
+	event.CanonicalAuthor = resolveIdentity(adapterContext(adapter), event.Platform, event.PR.Author)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+// Ingest buffer — verified webhooks are handed to a bounded in-memory buffer
+// and published to RabbitMQ by background workers, so a slow broker (a
+// publish can wait up to 5s) never holds the SCM's HTTP connection open.
+//
+//	INGEST_BUFFER    messages the buffer holds (default 1000)
+//	INGEST_WORKERS   publishing goroutines (default 2)
+//
+// Each worker owns a lane of the buffer and every repository is routed to one
+// lane, so events of a PR are published in the order they arrived.
+//
+// When a lane is full the delivery is dropped and counted as an overflow
+// in /admin/metrics; with WEBHOOK_RESPONSES=structured the SCM is told 503 so
+// it can redeliver. Buffered messages are lost if the process dies before
+// publishing them.
+
+import (
+	"log"
+	"sync"
+)
+
+const (
+	defaultIngestBuffer  = 1000
+	defaultIngestWorkers = 2
+)
+
+var (
+	ingestOnce  sync.Once
+	ingestLanes []chan RawWebhookMessage
+)
+
+// StartIngestWorkers starts the goroutines that publish buffered webhooks to
+// the raw events queue. It must run before the HTTP server accepts webhooks.
+func StartIngestWorkers(mq *RabbitMQ) {
+	ingestOnce.Do(func() {
+		workers := max(envInt("INGEST_WORKERS", defaultIngestWorkers), 1)
+		laneSize := max(envInt("INGEST_BUFFER", defaultIngestBuffer)/workers, 1)
+		for i := 0; i < workers; i++ {
+			lane := make(chan RawWebhookMessage, laneSize)
+			ingestLanes = append(ingestLanes, lane)
+			go ingestWorker(mq, lane)
+		}
+		log.Printf("[Ingest] %d worker(s) publishing from lanes of %d\n", workers, laneSize)
+	})
+}
+
+// ingestWorker publishes the messages of one lane until the process exits.
+func ingestWorker(mq *RabbitMQ, lane chan RawWebhookMessage) {
+	for msg := range lane {
+		if err := mq.PublishRawEvent(msg); err != nil {
+			metrics.ingestPublishFailures.Add(1)
+			log.Printf("[Ingest] Warning: could not publish raw event to queue: %v\n", err)
+			traceHop(msg.EventID, "queue_failed", err.Error())
+			continue
+		}
+		traceHop(msg.EventID, "queued", rawEventsQueue)
+	}
+}
+
+// enqueueIngest hands msg to the lane of its repository without blocking. It
+// reports false when the workers are not running or the lane is full.
+func enqueueIngest(msg RawWebhookMessage) bool {
+	if len(ingestLanes) == 0 {
+		return false
+	}
+	select {
+	case ingestLanes[repoHash(msg.Platform, msg.Repo)%uint32(len(ingestLanes))] <- msg:
+		metrics.ingestBuffered.Add(1)
+		traceHop(msg.EventID, "buffered", "ingest")
+		return true
+	default:
+		metrics.ingestOverflows.Add(1)
+		return false
+	}
+}
+
+// ingestBacklog returns the number of messages waiting to be published.
+func ingestBacklog() int {
+	n := 0
+	for _, lane := range ingestLanes {
+		n += len(lane)
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package main
+
+// GitHub App installation suspension.
+//
+// When an organization suspends the app, GitHub sends an "installation"
+// webhook with action "suspend" (and "unsuspend" when it is lifted). While an
+// installation is suspended every API call on its behalf would fail, so the
+// gateway fails fast instead: the adapter refuses to mint tokens for the
+// account, and events from it are published without enrichment. Suspension
+// state is kept in the repo registry (GET /repos) and cleared automatically
+// by the unsuspend event.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// githubInstallationEvent is the webhook event type of installation changes.
+const githubInstallationEvent = "installation"
+
+// ghInstallationPayload is the subset of the installation event we care about.
+type ghInstallationPayload struct {
+	Action       string `json:"action"`
+	Installation struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	} `json:"installation"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// errInstallationSuspended is returned for API calls on behalf of a
+// suspended installation.
+func errInstallationSuspended(platform SCMPlatform, account string) error {
+	return fmt.Errorf("%s installation for %q is suspended; enrichment and writes are paused until it is unsuspended", platform, account)
+}
+
+// handleInstallationEvent updates the suspension state from a GitHub
+// installation event and acknowledges it. Installation events are never
+// queued: they carry no PR.
+func handleInstallationEvent(w http.ResponseWriter, payload []byte) {
+	var p ghInstallationPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		http.Error(w, "invalid installation payload", http.StatusBadRequest)
+		return
+	}
+	account := p.Installation.Account.Login
+
+	switch p.Action {
+	case "suspend":
+		registry.SetSuspended(InstallationSuspension{
+			Platform:       PlatformGitHub,
+			Account:        account,
+			InstallationID: p.Installation.ID,
+			SuspendedAt:    time.Now(),
+			SuspendedBy:    p.Sender.Login,
+		})
+		log.Printf("[Installations] GitHub installation %d for %s suspended by %s; pausing enrichment and writes\n",
+			p.Installation.ID, account, p.Sender.Login)
+	case "unsuspend", "deleted":
+		if registry.ClearSuspended(PlatformGitHub, account) {
+			log.Printf("[Installations] GitHub installation %d for %s %s; resuming\n", p.Installation.ID, account, p.Action)
+		}
+	default:
+		log.Printf("[Installations] GitHub installation %d for %s: %s\n", p.Installation.ID, account, p.Action)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "received",
+		"action":    p.Action,
+		"account":   account,
+		"suspended": registry.IsSuspended(PlatformGitHub, account),
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+// Label and milestone sync — keeps every registered repository's labels and
+// milestones in line with a standard set, since triage automation depends
+// on consistent labels. The standard set is the repo's "labels" and
+// "milestones" in REPO_CONFIG_FILE, so a "default" entry enforces one set
+// org-wide:
+//
+//	"labels": [
+//	  {"name": "bug", "color": "d73a4a", "description": "Something isn't working"}
+//	],
+//	"milestones": [
+//	  {"title": "Q4", "description": "...", "due_on": "2026-12-31"}
+//	]
+//
+// Missing labels and milestones are created and differing ones updated;
+// labels and milestones outside the set are left alone. Label names compare
+// case-insensitively, milestone titles exactly; a milestone without "state"
+// should be open, and one without "due_on" may have any due date.
+//
+//	GET  /admin/labels  → drift report, changes nothing
+//	POST /admin/labels  → also applies the standard set
+//
+// The "label_sync" job applies it hourly. Adapters without LabelManager are
+// reported as unsupported.
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// LabelDrift is the comparison of one repository's labels and milestones
+// with its standard set.
+type LabelDrift struct {
+	Platform          SCMPlatform `json:"platform"`
+	Repository        string      `json:"repository"`
+	MissingLabels     []string    `json:"missing_labels,omitempty"`
+	ChangedLabels     []string    `json:"changed_labels,omitempty"`
+	MissingMilestones []string    `json:"missing_milestones,omitempty"`
+	ChangedMilestones []string    `json:"changed_milestones,omitempty"`
+	Drifted           bool        `json:"drifted"`
+	Fixed             bool        `json:"fixed,omitempty"`
+	Error             string      `json:"error,omitempty"`
+}
+
+// reconcileLabels compares the labels and milestones of every registered
+// repo with its config and, with fix, applies the ones that drifted.
+func reconcileLabels(fix bool) []LabelDrift {
+	report := []LabelDrift{}
+	adapters := map[SCMPlatform]SCMAdapter{}
+	for _, rec := range registry.List() {
+		if rec.FullName == appHookKey || rec.Suspended {
+			continue
+		}
+		config := repoConfigFor(rec.Platform, rec.FullName)
+		if len(config.Labels) == 0 && len(config.Milestones) == 0 {
+			continue
+		}
+		adapter, ok := adapters[rec.Platform]
+		if !ok {
+			var err error
+			if adapter, err = NewSCMAdapter(rec.Platform); err != nil {
+				log.Printf("[LabelSync] Warning: %v\n", err)
+				adapter = nil
+			}
+			adapters[rec.Platform] = adapter
+		}
+		report = append(report, reconcileRepoLabels(adapter, rec.Platform, rec.FullName, config, fix))
+	}
+	return report
+}
+
+// reconcileRepoLabels compares one repository and, with fix, updates it.
+func reconcileRepoLabels(adapter SCMAdapter, platform SCMPlatform, fullName string, config RepoConfig, fix bool) LabelDrift {
+	d := LabelDrift{Platform: platform, Repository: fullName}
+	if adapter == nil {
+		d.Error = "adapter not configured"
+		return d
+	}
+	manager, ok := adapter.(LabelManager)
+	if !ok {
+		d.Error = errUnsupported(adapter, "labels and milestones").Error()
+		return d
+	}
+	owner, repo, _ := strings.Cut(fullName, "/")
+
+	var labelFixes []RepoLabel
+	if len(config.Labels) > 0 {
+		have, err := manager.ListLabels(owner, repo)
+		if err != nil {
+			d.Error = err.Error()
+			return d
+		}
+		for _, want := range config.Labels {
+			label, found := findLabel(have, want.Name)
+			switch {
+			case !found:
+				d.MissingLabels = append(d.MissingLabels, want.Name)
+			case !strings.EqualFold(label.Color, want.Color) || label.Description != want.Description:
+				d.ChangedLabels = append(d.ChangedLabels, want.Name)
+			default:
+				continue
+			}
+			labelFixes = append(labelFixes, want)
+		}
+	}
+
+	var milestoneFixes []RepoMilestone
+	if len(config.Milestones) > 0 {
+		have, err := manager.ListMilestones(owner, repo)
+		if err != nil {
+			d.Error = err.Error()
+			return d
+		}
+		for _, want := range config.Milestones {
+			if want.State == "" {
+				want.State = "open"
+			}
+			milestone, found := findMilestone(have, want.Title)
+			switch {
+			case !found:
+				d.MissingMilestones = append(d.MissingMilestones, want.Title)
+			case milestone.Description != want.Description || milestone.State != want.State ||
+				(want.DueOn != "" && milestone.DueOn != want.DueOn):
+				d.ChangedMilestones = append(d.ChangedMilestones, want.Title)
+				want.Number = milestone.Number
+			default:
+				continue
+			}
+			milestoneFixes = append(milestoneFixes, want)
+		}
+	}
+
+	d.Drifted = len(labelFixes) > 0 || len(milestoneFixes) > 0
+	if !d.Drifted {
+		return d
+	}
+	log.Printf("[LabelSync] %s %s drifted: labels missing %v, changed %v; milestones missing %v, changed %v\n",
+		platform, fullName, d.MissingLabels, d.ChangedLabels, d.MissingMilestones, d.ChangedMilestones)
+
+	if !fix {
+		return d
+	}
+	for _, label := range labelFixes {
+		if err := manager.SetLabel(owner, repo, label); err != nil {
+			d.Error = err.Error()
+			return d
+		}
+	}
+	for _, milestone := range milestoneFixes {
+		if err := manager.SetMilestone(owner, repo, milestone); err != nil {
+			d.Error = err.Error()
+			return d
+		}
+	}
+	d.Fixed = true
+	log.Printf("[LabelSync] ✓ %s %s labels and milestones updated\n", platform, fullName)
+	return d
+}
+
+// findLabel returns the label named name, ignoring case.
+func findLabel(labels []RepoLabel, name string) (RepoLabel, bool) {
+	for _, l := range labels {
+		if strings.EqualFold(l.Name, name) {
+			return l, true
+		}
+	}
+	return RepoLabel{}, false
+}
+
+// findMilestone returns the milestone titled title.
+func findMilestone(milestones []RepoMilestone, title string) (RepoMilestone, bool) {
+	for _, m := range milestones {
+		if m.Title == title {
+			return m, true
+		}
+	}
+	return RepoMilestone{}, false
+}
+
+// syncLabels is the "label_sync" scheduler job.
+func syncLabels() error {
+	if isStandby() {
+		return nil
+	}
+	failed := 0
+	report := reconcileLabels(true)
+	for _, d := range report {
+		if d.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories could not be synced", failed, len(report))
+	}
+	return nil
+}
+
+// AdminLabelsHandler reports (GET) or fixes (POST) drift of the
+// repositories' labels and milestones.
+func AdminLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	fix := r.Method == http.MethodPost
+	if fix && isStandby() {
+		http.Error(w, "a standby deployment does not change labels", http.StatusConflict)
+		return
+	}
+
+	report := reconcileLabels(fix)
+	drifted, fixed, failed := 0, 0, 0
+	for _, d := range report {
+		if d.Drifted {
+			drifted++
+		}
+		if d.Fixed {
+			fixed++
+		}
+		if d.Error != "" {
+			failed++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":       "success",
+		"checked":      len(report),
+		"drifted":      drifted,
+		"fixed":        fixed,
+		"failed":       failed,
+		"repositories": report,
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+// Leader election — when the gateway runs as several replicas in Kubernetes,
+// only one of them should run scheduled work that writes to the SCMs
+// (webhook sync, auto-merge, label sync, alerting); each replica's local
+// housekeeping jobs run everywhere (see scheduler.go). Replicas compete for a coordination.k8s.io/v1 Lease;
+// the holder renews it every few seconds and everyone else stands by.
+//
+//	LEADER_ELECTION=true       enable; otherwise every replica is leader
+//	LEADER_LEASE_NAME          Lease object name (default "scm-gateway")
+//	LEADER_LEASE_NAMESPACE     defaults to the pod's service-account namespace
+//	LEADER_LEASE_SECONDS       lease duration (default 15)
+//	POD_NAME                   holder identity (default: hostname)
+//
+// The pod's service account needs get/create/update on leases.
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sMicroTime is the layout of Kubernetes MicroTime fields.
+const k8sMicroTime = "2006-01-02T15:04:05.000000Z07:00"
+
+var (
+	leaderElectionEnabled atomic.Bool
+	leading               atomic.Bool
+)
+
+// isLeader reports whether this replica should run scheduled work. Without
+// leader election every replica is the leader.
+func isLeader() bool {
+	return !leaderElectionEnabled.Load() || leading.Load()
+}
+
+// k8sLease is the subset of a Lease object the election reads and writes.
+type k8sLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions"`
+	} `json:"spec"`
+}
+
+// leaseClient talks to the Kubernetes API with the pod's service account.
+type leaseClient struct {
+	baseURL  string
+	token    string
+	client   *http.Client
+	identity string
+	duration int
+}
+
+// newLeaseClient builds a client from the in-cluster environment.
+func newLeaseClient() (*leaseClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in Kubernetes (KUBERNETES_SERVICE_HOST unset)")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	caPEM, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+
+	namespace := os.Getenv("LEADER_LEASE_NAMESPACE")
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	name := os.Getenv("LEADER_LEASE_NAME")
+	if name == "" {
+		name = "scm-gateway"
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	return &leaseClient{
+		baseURL: fmt.Sprintf("https://%s:%s/apis/coordination.k8s.io/v1/namespaces/%s/leases",
+			host, port, namespace),
+		token: strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		identity: identity,
+		duration: envInt("LEADER_LEASE_SECONDS", 15),
+	}, nil
+}
+
+// do sends a request to the leases API and returns the body and status.
+func (c *leaseClient) do(method, url string, lease *k8sLease) ([]byte, int, error) {
+	var body io.Reader
+	if lease != nil {
+		b, err := json.Marshal(lease)
+		if err != nil {
+			return nil, 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	return respBody, resp.StatusCode, err
+}
+
+// tryAcquire creates, renews or takes over the lease and reports whether this
+// replica holds it afterwards.
+func (c *leaseClient) tryAcquire(name string) (bool, error) {
+	now := time.Now().UTC().Format(k8sMicroTime)
+
+	body, status, err := c.do("GET", c.baseURL+"/"+name, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if status == http.StatusNotFound {
+		var lease k8sLease
+		lease.APIVersion, lease.Kind = "coordination.k8s.io/v1", "Lease"
+		lease.Metadata.Name = name
+		lease.Spec.HolderIdentity = c.identity
+		lease.Spec.LeaseDurationSeconds = c.duration
+		lease.Spec.AcquireTime, lease.Spec.RenewTime = now, now
+		_, status, err = c.do("POST", c.baseURL, &lease)
+		if err != nil {
+			return false, err
+		}
+		// 409: another replica created it first.
+		return status == http.StatusCreated, nil
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("GET lease returned %d: %s", status, string(body))
+	}
+
+	var lease k8sLease
+	if err := json.Unmarshal(body, &lease); err != nil {
+		return false, err
+	}
+
+	if lease.Spec.HolderIdentity != c.identity {
+		if lease.Spec.HolderIdentity != "" && !leaseExpired(&lease) {
+			return false, nil
+		}
+		lease.Spec.HolderIdentity = c.identity
+		lease.Spec.AcquireTime = now
+		lease.Spec.LeaseTransitions++
+	}
+	lease.Spec.LeaseDurationSeconds = c.duration
+	lease.Spec.RenewTime = now
+
+	// The resourceVersion makes this a compare-and-swap: 409 means we lost.
+	_, status, err = c.do("PUT", c.baseURL+"/"+name, &lease)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusOK, nil
+}
+
+// StartLeaderElection competes for the lease until the process exits. It
+// returns immediately unless LEADER_ELECTION=true.
+func StartLeaderElection() {
+	if os.Getenv("LEADER_ELECTION") != "true" {
+		return
+	}
+	leaderElectionEnabled.Store(true)
+
+	c, err := newLeaseClient()
+	if err != nil {
+		// Fail safe: never lead rather than risk duplicate scheduled work.
+		log.Printf("[Leader] Warning: leader election disabled, this replica will only run local scheduled jobs: %v\n", err)
+		return
+	}
+	name := os.Getenv("LEADER_LEASE_NAME")
+	if name == "" {
+		name = "scm-gateway"
+	}
+	log.Printf("[Leader] Competing for lease %q as %s\n", name, c.identity)
+
+	// Renew well inside the lease duration so a healthy leader never lapses.
+	interval := time.Duration(c.duration) * time.Second / 3
+	for {
+		held, err := c.tryAcquire(name)
+		if err != nil {
+			log.Printf("[Leader] Warning: lease update failed: %v\n", err)
+			held = false
+		}
+		if held != leading.Load() {
+			if held {
+				log.Printf("[Leader] Acquired lease %q — running scheduled jobs\n", name)
+			} else {
+				log.Printf("[Leader] Lost lease %q — standing by\n", name)
+			}
+			leading.Store(held)
+		}
+		time.Sleep(interval)
+	}
+}
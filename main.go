@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +14,9 @@ import (
 var mq *RabbitMQ
 
 func main() {
+	// Attach to systemd or the Windows service control manager (see service.go).
+	initService()
+
 	// Load environment variables from .env file
 	if err := godotenv.Load(".env"); err != nil {
 		log.Println("Warning: .env file not found, checking system environment variables")
@@ -20,6 +24,22 @@ func main() {
 		log.Println("✓ Successfully loaded .env file")
 	}
 
+	// One-shot normalization of a payload file (see cli_normalize.go).
+	normalize := flag.String("normalize", "", "normalize a webhook payload file for `platform` (github, bitbucket, gerrit or auto) and exit")
+	eventType := flag.String("event-type", "", "raw event type for -normalize (default: inferred from the payload)")
+	enrich := flag.Bool("enrich", false, "with -normalize, also run the enricher chain against the SCM API")
+	flag.Parse()
+
+	// Record or replay outbound API calls (API_CASSETTE, see cassette.go).
+	installCassetteTransport()
+
+	if *normalize != "" {
+		if flag.NArg() != 1 {
+			log.Fatal("usage: server -normalize <platform> [-event-type T] [-enrich] <payload.json>")
+		}
+		os.Exit(runNormalizeCommand(*normalize, flag.Arg(0), *eventType, *enrich))
+	}
+
 	// Verify environment variables are loaded
 	appID := getAppIDFromEnv()
 	if appID != "" {
@@ -28,6 +48,12 @@ func main() {
 		log.Println("⚠ Warning: GITHUB_APP_ID is not set")
 	}
 
+	// Record outbound API calls for /admin/trace (and /admin/recordings).
+	installTracingTransport()
+
+	// A DR standby must hold deliveries from its very first event.
+	InitGatewayRole()
+
 	// Connect to RabbitMQ and start the async consumer.
 	rabbitmqURL := os.Getenv("RABBITMQ_URL")
 	if rabbitmqURL == "" {
@@ -36,30 +62,149 @@ func main() {
 	var err error
 	mq, err = NewRabbitMQ(rabbitmqURL)
 	if err != nil {
-		log.Printf("Warning: could not connect to RabbitMQ (%s): %v — webhook events will be dropped\n", rabbitmqURL, err)
+		log.Printf("Warning: could not connect to RabbitMQ (%s): %v — webhook events will be dropped\n", redactURL(rabbitmqURL), err)
 	} else {
-		log.Println("Connected to RabbitMQ:", rabbitmqURL)
+		log.Println("Connected to RabbitMQ:", redactURL(rabbitmqURL))
+		StartIngestWorkers(mq)
 		go StartConsumer(mq)
 		go StartEventBusConsumer(mq)
+		go StartSecurityAlertConsumer(mq)
 		defer mq.Close()
 	}
 
+	// In multi-replica deployments only the lease holder runs scheduled work.
+	go StartLeaderElection()
+
+	// Active/passive regions: follow the role lease (ROLE_LEASE=true).
+	go StartRoleLease()
+
+	// Watch queue depth, dead letters, delivery failures and API quota.
+	go StartAlerting(mq)
+
+	// Log heap usage and drop expired cache entries (MEMORY_REPORT_SECONDS).
+	go StartMemoryReport()
+
+	// Verify SCM webhooks point at this gateway (GATEWAY_PUBLIC_URL).
+	go SyncWebhooks()
+
+	// Report GitHub App permissions the enabled features lack.
+	go PreflightPermissions()
+
+	// Pull deliveries from a smee.io-style relay (WEBHOOK_RELAY_URL).
+	go StartWebhookRelay()
+
+	// Periodic jobs (webhook sync, event retention); leader replica only.
+	StartScheduler()
+
+	// What this deployment resolved its configuration to (also GET /config).
+	logConfigReport()
+
 	// Register HTTP routes
 	http.HandleFunc("/", handler)
 	http.HandleFunc("/webhook", WebhookHandler)
+	http.HandleFunc("/webhook/", WebhookHandler)
+	http.HandleFunc("/federation/events", FederationIngestHandler)
+	http.HandleFunc("/healthz", HealthzHandler)
+	http.HandleFunc("/config", ConfigHandler)
+	http.HandleFunc("/diagnostics", DiagnosticsHandler)
 	http.HandleFunc("/auth-test", AuthTestHandler)
 	http.HandleFunc("/repo-files", GetRepositoryFilesHandler)
 	http.HandleFunc("/pr-files", GetPRFilesHandler)
+	http.HandleFunc("/file-content", FileContentHandler)
+	http.HandleFunc("/repos", ReposHandler)
+	http.HandleFunc("/prs", PRsHandler)
+	http.HandleFunc("/schemas", SchemasHandler)
+	http.HandleFunc("/schemas/", SchemasHandler)
+	http.HandleFunc("/pr-diff-chunks", PRDiffChunksHandler)
+	http.HandleFunc("/pr-threads", ReviewThreadsHandler)
+	http.HandleFunc("/pr-threads/resolve", ResolveReviewThreadHandler)
+	http.HandleFunc("/pr-comment", PRCommentHandler)
+	http.HandleFunc("/pr-review", PRReviewHandler)
+	http.HandleFunc("/pr-merge", PRMergeHandler)
+	http.HandleFunc("/pr-labels", PRLabelsHandler)
+	http.HandleFunc("/repo-stats", RepoStatsHandler)
+	http.HandleFunc("/sbom", SBOMHandler)
+	http.HandleFunc("/ci/runs", CIRunsHandler)
+	http.HandleFunc("/ci/artifact", CIArtifactHandler)
+	http.HandleFunc("/ci/logs", CILogsHandler)
+	http.HandleFunc("/dispatch", DispatchHandler)
+	http.HandleFunc("/deployments", DeploymentsHandler)
+	http.HandleFunc("/deployments/", DeploymentsHandler)
+	http.HandleFunc("/release-notes", ReleaseNotesHandler)
+	http.HandleFunc("/subscriptions", SubscriptionsHandler)
+	http.HandleFunc("/subscriptions/", SubscriptionsHandler)
+	http.HandleFunc("/setup", SetupHandler)
+	http.HandleFunc("/setup/callback", SetupCallbackHandler)
+	http.HandleFunc("/bitbucket/connect.json", BitbucketConnectDescriptorHandler)
+	http.HandleFunc("/bitbucket/installed", BitbucketConnectLifecycleHandler)
+	http.HandleFunc("/bitbucket/uninstalled", BitbucketConnectLifecycleHandler)
+	http.HandleFunc("/admin/resend", AdminResendHandler)
+	http.HandleFunc("/admin/canary", AdminCanaryHandler)
+	http.HandleFunc("/admin/jobs", AdminJobsHandler)
+	http.HandleFunc("/admin/trace/", AdminTraceHandler)
+	http.HandleFunc("/admin/recordings", AdminRecordingsHandler)
+	http.HandleFunc("/admin/recordings/", AdminRecordingsHandler)
+	http.HandleFunc("/admin/metrics", AdminMetricsHandler)
+	http.HandleFunc("/admin/usage", AdminUsageHandler)
+	http.HandleFunc("/admin/promote", AdminPromoteHandler)
+	http.HandleFunc("/admin/required-contexts", AdminRequiredContextsHandler)
+	http.HandleFunc("/admin/scaffold", AdminScaffoldHandler)
+	http.HandleFunc("/admin/labels", AdminLabelsHandler)
 
 	// Log startup information
 	log.Println("listening on Port 3000")
 	log.Println("Available endpoints:")
 	log.Println("  GET/POST /          - Basic handler")
 	log.Println("  POST     /webhook    - GitHub webhook handler")
+	log.Println("  POST     /webhook/{github|bitbucket|bitbucket_server|gerrit|gitea|codecommit} - Webhook handler with the platform pinned")
+	log.Println("  POST     /federation/events - Events forwarded by a downstream gateway (FEDERATION_SECRET)")
+	log.Println("  GET      /healthz    - Liveness probe (consumer health, leader state)")
+	log.Println("  GET      /config     - Resolved configuration, secrets redacted (admin token)")
+	log.Println("  GET      /diagnostics - GitHub App permissions missing for the enabled features (admin token)")
 	log.Println("  GET      /auth-test  - GitHub App authentication test")
 	log.Println("  GET      /repo-files - Get repository file list (requires ?owner=X&repo=Y)")
 	log.Println("  GET      /pr-files   - Get PR changed files (requires ?owner=X&repo=Y&pr=N)")
+	log.Println("  GET      /file-content - Raw content of a file (admin token, ?owner=X&repo=Y&ref=R&path=P)")
+	log.Println("  PUT      /file-content - Create or update a file on a branch (admin token)")
+	log.Println("  GET      /repos      - Repo registry (webhook verification state)")
+	log.Println("  GET      /prs        - Current pull requests (optional ?owner=X&repo=Y&state=open|closed|all)")
+	log.Println("  POST     /prs        - Open a pull request (admin token)")
+	log.Println("  GET      /schemas[/{version}[/{event_type}]] - JSON Schemas of the normalized events")
+	log.Println("  GET      /pr-diff-chunks - PR diff split into token-bounded chunks (requires ?owner=X&repo=Y&pr=N)")
+	log.Println("  GET      /pr-threads - PR review threads (requires ?owner=X&repo=Y&pr=N, optional &state=resolved|unresolved)")
+	log.Println("  POST     /pr-threads/resolve - Resolve a PR review thread (admin token)")
+	log.Println("  GET      /pr-comment - PR conversation and inline comments (requires ?owner=X&repo=Y&pr=N)")
+	log.Println("  POST     /pr-comment - Post a comment on a PR (admin token)")
+	log.Println("  POST     /pr-review  - Approve a PR, request changes or comment (admin token)")
+	log.Println("  POST     /pr-merge   - Merge a PR with merge, squash or rebase (admin token)")
+	log.Println("  GET/POST/DELETE /pr-labels - List, add or remove a PR's labels (writes need the admin token)")
+	log.Println("  GET      /repo-stats - Repository languages, contributors and activity (requires ?owner=X&repo=Y)")
+	log.Println("  GET      /sbom       - Repository SBOM as SPDX JSON (requires ?owner=X&repo=Y)")
+	log.Println("  GET      /ci/runs    - Workflow runs of a commit with jobs and artifacts (admin token, ?owner=X&repo=Y&sha=S)")
+	log.Println("  GET      /ci/artifact, /ci/logs - Stream a run's artifact or logs (admin token, ?owner=X&repo=Y&id=N or &run_id=N[&job_id=M])")
+	log.Println("  POST     /dispatch   - Trigger repository_dispatch, workflow_dispatch or a Bitbucket pipeline (admin token)")
+	log.Println("  POST     /deployments[/{id}/statuses] - Create a deployment or report its status (admin token)")
+	log.Println("  GET      /release-notes - Draft release notes from the PRs merged since the previous release (requires ?owner=X&repo=Y, optional &tag=T&since=RFC3339)")
+	log.Println("  GET/POST /subscriptions - List or register outgoing webhook subscriptions (admin token)")
+	log.Println("  GET/DELETE /subscriptions/{id} - Show or remove a subscription (admin token)")
+	log.Println("  GET /subscriptions/{id}/deliveries - Recent deliveries to a subscription (admin token)")
+	log.Println("  POST /subscriptions/{id}/deliveries/{did}/redeliver - Resend one delivery (admin token)")
+	log.Println("  GET /setup - Create the GitHub App from a manifest (GITHUB_APP_SETUP=true, requires ?token=)")
+	log.Println("  GET /bitbucket/connect.json - Bitbucket Connect app descriptor (BITBUCKET_CONNECT_KEY)")
+	log.Println("  POST /bitbucket/installed, /bitbucket/uninstalled - Bitbucket Connect lifecycle callbacks")
+	log.Println("  POST     /admin/resend - Re-deliver stored events to a sink (requires ?sink=NAME, admin token)")
+	log.Println("  GET      /admin/canary - Canary vs Platform BE delivery comparison (admin token)")
+	log.Println("  GET      /admin/jobs - Scheduled job status (admin token)")
+	log.Println("  GET      /admin/trace/{delivery_id} - Full trail of one webhook delivery (admin token)")
+	log.Println("  GET      /admin/recordings[/{event_id}] - Recorded SCM API exchanges (admin token, API_RECORDING=true)")
+	log.Println("  GET      /admin/metrics - Delivery and pagination counters (admin token)")
+	log.Println("  GET      /admin/usage - Monthly usage per tenant as JSON or CSV (optional ?month=YYYY-MM&format=csv, admin token)")
+	log.Println("  POST     /admin/promote - Switch a standby deployment active (admin token)")
+	log.Println("  GET/POST /admin/required-contexts - Report or fix drift of branches' required status checks (admin token)")
+	log.Println("  POST     /admin/scaffold - Create a repository from a template and set it up (admin token)")
+	log.Println("  GET/POST /admin/labels - Report or fix drift of repositories' labels and milestones (admin token)")
+	logSetupToken()
 
-	// Start server
-	log.Fatal(http.ListenAndServe(":3000", nil))
+	// Start server; returns after a graceful shutdown.
+	serveHTTP()
 }
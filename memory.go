@@ -0,0 +1,63 @@
+package main
+
+// Memory bounds — the gateway runs for months between deploys, so every
+// piece of in-memory state that grows with traffic is bounded:
+//
+//	caches         (repo stats, SBOMs, PR states, Bitbucket repos, Jira
+//	               tickets, SCIM identities, self-posted comments) expire
+//	               after their TTL and hold at most CACHE_MAX_ENTRIES
+//	               (default 10000) each, evicting the oldest when full
+//	repo registry  at most REGISTRY_MAX_REPOS (default 50000) repos,
+//	               evicting the one longest without a delivery or ping
+//	event store    EVENT_STORE_MAX events (see event_store.go)
+//	traces         TRACE_MAX traces (see trace.go)
+//
+// Evictions are counted in GET /admin/metrics ("cache_evictions",
+// "registry_evictions"), next to the current heap size. Every replica also
+// reports on itself every MEMORY_REPORT_SECONDS (default 900): it drops
+// expired cache entries and logs heap usage and the size of each structure,
+// so growth shows up in the logs long before it becomes an outage.
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heapAlloc returns the bytes of allocated heap objects.
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// StartMemoryReport reports memory use every MEMORY_REPORT_SECONDS.
+func StartMemoryReport() {
+	for range time.Tick(time.Duration(envInt("MEMORY_REPORT_SECONDS", 900)) * time.Second) {
+		reportMemory()
+	}
+}
+
+// reportMemory drops expired cache entries and logs memory use.
+func reportMemory() {
+	sweepCaches()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	sizes := cacheSizes()
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	caches := make([]string, len(names))
+	for i, name := range names {
+		caches[i] = fmt.Sprintf("%s=%d", name, sizes[name])
+	}
+	log.Printf("[Memory] heap %d MiB in use (%d MiB from the OS), %d goroutines, %d GCs; repos=%d events=%d traces=%d; caches: %s\n",
+		m.HeapInuse>>20, m.HeapSys>>20, runtime.NumGoroutine(), m.NumGC,
+		registry.Len(), store().Len(), traceLogs().len(), strings.Join(caches, " "))
+}
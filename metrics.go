@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// gatewayMetrics are process-wide counters read by the alerting module and
+// GET /admin/metrics.
+// They only ever increase; consumers compute rates from deltas.
+type gatewayMetrics struct {
+	deliveries       atomic.Int64 // sink delivery attempts
+	deliveryFailures atomic.Int64 // sink deliveries that returned an error
+
+	pagesFetched        atomic.Int64 // SCM list pages fetched (see paginator.go)
+	paginationTruncated atomic.Int64 // listings cut off at PAGINATION_MAX_PAGES
+
+	standbyHeld atomic.Int64 // events not delivered because this deployment is on standby
+	staleEvents atomic.Int64 // events flagged or dropped as stale (see staleness.go)
+
+	schemaViolations atomic.Int64 // events that did not match their schema (see schemas.go)
+	apiDeprecations  atomic.Int64 // API responses announcing a deprecation (see outbound_headers.go)
+
+	autoMerged        atomic.Int64 // PRs merged by the auto-merge engine (see auto_merge.go)
+	autoMergeFailures atomic.Int64 // merges the SCM refused
+
+	ingestBuffered        atomic.Int64 // webhooks handed to the ingest buffer (see ingest.go)
+	ingestOverflows       atomic.Int64 // webhooks dropped because the buffer was full
+	ingestPublishFailures atomic.Int64 // buffered webhooks the broker refused
+
+	tapped      atomic.Int64 // events copied to the sampling tap (see tap.go)
+	tapDropped  atomic.Int64 // tap copies dropped because its queue was full
+	tapFailures atomic.Int64 // tap copies its sink refused
+
+	cacheEvictions    atomic.Int64 // cache entries dropped to stay within CACHE_MAX_ENTRIES (see cache.go)
+	registryEvictions atomic.Int64 // repos dropped to stay within REGISTRY_MAX_REPOS (see registry.go)
+}
+
+var metrics gatewayMetrics
+
+// AdminMetricsHandler reports the current counter values.
+//
+//	GET /admin/metrics
+func AdminMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":               "success",
+		"deliveries":           metrics.deliveries.Load(),
+		"delivery_failures":    metrics.deliveryFailures.Load(),
+		"pages_fetched":        metrics.pagesFetched.Load(),
+		"pagination_truncated": metrics.paginationTruncated.Load(),
+		"standby_held":         metrics.standbyHeld.Load(),
+		"stale_events":         metrics.staleEvents.Load(),
+		"schema_violations":    metrics.schemaViolations.Load(),
+		"api_deprecations":     metrics.apiDeprecations.Load(),
+		"auto_merged":          metrics.autoMerged.Load(),
+		"auto_merge_failures":  metrics.autoMergeFailures.Load(),
+		"ingest_buffered":      metrics.ingestBuffered.Load(),
+		"ingest_overflows":     metrics.ingestOverflows.Load(),
+		"ingest_failures":      metrics.ingestPublishFailures.Load(),
+		"ingest_backlog":       ingestBacklog(),
+		"tapped":               metrics.tapped.Load(),
+		"tap_dropped":          metrics.tapDropped.Load(),
+		"tap_failures":         metrics.tapFailures.Load(),
+		"cache_evictions":      metrics.cacheEvictions.Load(),
+		"registry_evictions":   metrics.registryEvictions.Load(),
+		"heap_alloc_bytes":     heapAlloc(),
+		"role":                 gatewayRole(),
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+// Outbound request headers — every call to an SCM or tracker API identifies
+// the gateway the same way, so the providers' deprecation notices and rate
+// limit reports can be attributed to a build:
+//
+//	User-Agent             scm-gateway/<version> (<commit>) github-app/<id>,
+//	                       or USER_AGENT verbatim
+//	X-GitHub-Api-Version   GITHUB_API_VERSION (default 2022-11-28)
+//
+// The version and commit are set at build time:
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.buildCommit=$(git rev-parse --short HEAD)"
+//
+// Responses announcing a deprecation (Deprecation or Sunset headers) are
+// logged once per endpoint and counted in /admin/metrics.
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Set with -ldflags "-X main.version=… -X main.buildCommit=…".
+var (
+	version     = "dev"
+	buildCommit = ""
+)
+
+const (
+	defaultGitHubAPIVersion = "2022-11-28"
+	githubJSON              = "application/vnd.github+json"
+)
+
+// userAgent returns the User-Agent of outbound API calls.
+func userAgent() string {
+	if ua := os.Getenv("USER_AGENT"); ua != "" {
+		return ua
+	}
+	ua := "scm-gateway/" + version
+	if buildCommit != "" {
+		ua += " (" + buildCommit + ")"
+	}
+	if appID := getAppIDFromEnv(); appID != "" {
+		ua += " github-app/" + appID
+	}
+	return ua
+}
+
+// githubAPIVersion returns the GitHub REST API version requests are pinned to.
+func githubAPIVersion() string {
+	if v := os.Getenv("GITHUB_API_VERSION"); v != "" {
+		return v
+	}
+	return defaultGitHubAPIVersion
+}
+
+// setGitHubHeaders sets the Accept (githubJSON when empty), API version and
+// User-Agent headers of a GitHub API request.
+func setGitHubHeaders(req *http.Request, accept string) {
+	if accept == "" {
+		accept = githubJSON
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", githubAPIVersion())
+	req.Header.Set("User-Agent", userAgent())
+}
+
+// setAPIHeaders sets the Accept and User-Agent headers of a request to any
+// other API (Bitbucket, Gerrit, Jira).
+func setAPIHeaders(req *http.Request, accept string) {
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", userAgent())
+}
+
+var reportedDeprecations sync.Map // "METHOD host/path" → true
+
+// noteDeprecation logs the first response from an endpoint that announces
+// its deprecation.
+func noteDeprecation(req *http.Request, resp *http.Response) {
+	deprecation, sunset := resp.Header.Get("Deprecation"), resp.Header.Get("Sunset")
+	if deprecation == "" && sunset == "" {
+		return
+	}
+	metrics.apiDeprecations.Add(1)
+	key := req.Method + " " + req.URL.Host + req.URL.Path
+	if _, seen := reportedDeprecations.LoadOrStore(key, true); seen {
+		return
+	}
+	notice := []string{}
+	if deprecation != "" {
+		notice = append(notice, "deprecated "+deprecation)
+	}
+	if sunset != "" {
+		notice = append(notice, "sunset "+sunset)
+	}
+	if link := resp.Header.Get("Link"); strings.Contains(link, `rel="deprecation"`) {
+		notice = append(notice, "see "+link)
+	}
+	log.Printf("[API] Warning: %s is %s (User-Agent %q)\n", key, strings.Join(notice, ", "), req.Header.Get("User-Agent"))
+}
@@ -0,0 +1,147 @@
+package main
+
+// Pagination shared by every list-fetching code path.
+//
+// Each SCM signals the next page differently, so a pageFetcher knows how to
+// fetch one page and find the next one:
+//
+//   - GitHub:    RFC 5988 Link header, rel="next"      (linkHeaderNext)
+//   - Bitbucket: "next" URL in the JSON body           (bitbucketNext)
+//   - GitLab:    X-Next-Page header with page/per_page (pageNumberNext)
+//
+// paginate drives a fetcher until there are no more pages, the handler has
+// seen enough, or PAGINATION_MAX_PAGES (default 10) pages were fetched for
+// the one request. Pages fetched and truncated listings are counted in
+// metrics.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+const defaultPaginationMaxPages = 10
+
+// pageFetcher fetches the page at url and returns its body and the URL of
+// the next page, or "" on the last page.
+type pageFetcher func(url string) (body []byte, next string, err error)
+
+// paginate fetches url and its following pages, passing each body to
+// handle. handle returns false to stop early.
+func paginate(url string, fetch pageFetcher, handle func(body []byte) (more bool, err error)) error {
+	maxPages := envInt("PAGINATION_MAX_PAGES", defaultPaginationMaxPages)
+	for page := 0; url != ""; page++ {
+		if page == maxPages {
+			metrics.paginationTruncated.Add(1)
+			log.Printf("Warning: stopped after %d pages of %s (PAGINATION_MAX_PAGES)\n", maxPages, url)
+			return nil
+		}
+		body, next, err := fetch(url)
+		if err != nil {
+			return err
+		}
+		metrics.pagesFetched.Add(1)
+		more, err := handle(body)
+		if err != nil || !more {
+			return err
+		}
+		url = next
+	}
+	return nil
+}
+
+// linkNextPattern matches the rel="next" entry of a Link header.
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// linkHeaderNext returns the rel="next" URL of a Link header (GitHub).
+func linkHeaderNext(h http.Header) string {
+	if m := linkNextPattern.FindStringSubmatch(h.Get("Link")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// bitbucketNext returns the "next" URL of a Bitbucket paged response.
+func bitbucketNext(body []byte) string {
+	var page struct {
+		Next string `json:"next"`
+	}
+	json.Unmarshal(body, &page)
+	return page.Next
+}
+
+// pageNumberNext returns current with its page parameter set to the
+// X-Next-Page header (GitLab), or "" when the header is empty.
+func pageNumberNext(current string, h http.Header) string {
+	next, err := strconv.Atoi(h.Get("X-Next-Page"))
+	if err != nil || next == 0 {
+		return ""
+	}
+	u, err := url.Parse(current)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("page", strconv.Itoa(next))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// githubPages returns a fetcher for GitHub list endpoints authenticated with
+// an installation token.
+func githubPages(ctx context.Context, token string) pageFetcher {
+	return githubAuthorizedPages(ctx, "token "+token)
+}
+
+// githubAppPages fetches GitHub pages of App endpoints, authenticated with
+// the App's JWT.
+func githubAppPages(ctx context.Context, jwtToken string) pageFetcher {
+	return githubAuthorizedPages(ctx, "Bearer "+jwtToken)
+}
+
+// githubAuthorizedPages fetches GitHub pages with the given Authorization
+// header and follows the Link header.
+func githubAuthorizedPages(ctx context.Context, authorization string) pageFetcher {
+	return func(pageURL string) ([]byte, string, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		req.Header.Set("Authorization", authorization)
+		setGitHubHeaders(req, "")
+
+		resp, err := (&http.Client{}).Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode >= 400 {
+			if apiErr := githubAPIError(body); apiErr != nil {
+				return nil, "", apiErr
+			}
+			return nil, "", fmt.Errorf("GitHub API %d: %s", resp.StatusCode, string(body))
+		}
+		return body, linkHeaderNext(resp.Header), nil
+	}
+}
+
+// bitbucketPages returns a fetcher for Bitbucket paged endpoints.
+func (b *BitbucketAdapter) bitbucketPages() pageFetcher {
+	return func(pageURL string) ([]byte, string, error) {
+		body, err := b.request(pageURL)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, bitbucketNext(body), nil
+	}
+}
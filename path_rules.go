@@ -0,0 +1,112 @@
+package main
+
+// Changed-path rules.
+//
+// Lets a repo limit enrichment to the paths it cares about, evaluated on the
+// changed-file list before any further (quota-heavy) enrichment runs:
+//
+//	"path_rules": {
+//	  "include": ["src/**", "go.mod"],       // only these files are kept
+//	  "skip_if_only": ["docs/**", "**/*.md"]  // skip enrichment when every
+//	}                                         // changed file matches
+//
+// Patterns are globs where "*" and "?" stay within a path segment and "**"
+// spans any number of segments.
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// PathRules is the per-repo configuration of changed-path rules.
+type PathRules struct {
+	Include    []string `json:"include,omitempty"`
+	SkipIfOnly []string `json:"skip_if_only,omitempty"`
+}
+
+// applyPathRules filters event.Files by the repo's include rules and reports
+// whether the remaining enrichment should be skipped, with the reason.
+func applyPathRules(event *NormalizedEvent) (skip bool, reason string) {
+	rules := repoConfigFor(event.Platform, event.Repository.FullName).PathRules
+	if rules == nil || len(event.Files) == 0 {
+		return false, ""
+	}
+
+	if len(rules.SkipIfOnly) > 0 {
+		only := true
+		for _, f := range event.Files {
+			if !matchAnyGlob(rules.SkipIfOnly, f.Filename) {
+				only = false
+				break
+			}
+		}
+		if only {
+			return true, "all changed files match skip_if_only"
+		}
+	}
+
+	if len(rules.Include) > 0 {
+		kept := event.Files[:0]
+		for _, f := range event.Files {
+			if matchAnyGlob(rules.Include, f.Filename) ||
+				(f.PreviousFilename != "" && matchAnyGlob(rules.Include, f.PreviousFilename)) {
+				kept = append(kept, f)
+			}
+		}
+		event.Files = kept
+		if len(kept) == 0 {
+			return true, "no changed files match include"
+		}
+	}
+	return false, ""
+}
+
+// matchAnyGlob reports whether path matches any of patterns.
+func matchAnyGlob(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if globRegexp(p).MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	globMu    sync.Mutex
+	globCache = map[string]*regexp.Regexp{}
+)
+
+// globRegexp compiles a path glob into an anchored regular expression.
+func globRegexp(pattern string) *regexp.Regexp {
+	globMu.Lock()
+	defer globMu.Unlock()
+	if re, ok := globCache[pattern]; ok {
+		return re
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re := regexp.MustCompile(b.String())
+	globCache[pattern] = re
+	return re
+}
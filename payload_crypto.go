@@ -0,0 +1,210 @@
+package main
+
+// Payload encryption at rest — webhook payloads carry patches, i.e.
+// proprietary source code, so the persisted event store (EVENT_STORE_FILE)
+// can be envelope-encrypted: every event is sealed with AES-256-GCM under a
+// random data key, and only the data key, wrapped by a key-encryption key, is
+// written next to it.
+//
+//	PAYLOAD_ENCRYPTION=local           wrap with PAYLOAD_ENCRYPTION_KEY
+//	                                   (base64, 32 bytes)
+//	PAYLOAD_ENCRYPTION=vault           wrap with HashiCorp Vault's transit
+//	                                   engine: VAULT_ADDR, VAULT_TOKEN and
+//	                                   PAYLOAD_ENCRYPTION_VAULT_KEY (key name)
+//
+// The key-encryption key never touches disk; with Vault it never leaves the
+// KMS. Unencrypted entries written before encryption was enabled are still
+// read.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// keyWrapper wraps and unwraps data keys with a key-encryption key.
+type keyWrapper interface {
+	Wrap(dataKey []byte) (string, error)
+	Unwrap(wrapped string) ([]byte, error)
+}
+
+// payloadKeyWrapper returns the configured key wrapper, or nil when payload
+// encryption is off.
+func payloadKeyWrapper() (keyWrapper, error) {
+	switch mode := os.Getenv("PAYLOAD_ENCRYPTION"); mode {
+	case "":
+		return nil, nil
+	case "local":
+		key, err := base64.StdEncoding.DecodeString(os.Getenv("PAYLOAD_ENCRYPTION_KEY"))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION_KEY must be 32 base64-encoded bytes")
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		return localKEK{aead: aead}, nil
+	case "vault":
+		v := &vaultTransit{
+			addr:   strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+			token:  os.Getenv("VAULT_TOKEN"),
+			key:    os.Getenv("PAYLOAD_ENCRYPTION_VAULT_KEY"),
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+		if v.addr == "" || v.token == "" || v.key == "" {
+			return nil, fmt.Errorf("PAYLOAD_ENCRYPTION=vault needs VAULT_ADDR, VAULT_TOKEN and PAYLOAD_ENCRYPTION_VAULT_KEY")
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYLOAD_ENCRYPTION %q (want local or vault)", mode)
+	}
+}
+
+// newGCM returns an AES-GCM AEAD for a 256-bit key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// gcmSeal encrypts plain under aead, prefixing the random nonce.
+func gcmSeal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// gcmOpen reverses gcmSeal.
+func gcmOpen(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// localKEK wraps data keys with a key held in the environment.
+type localKEK struct {
+	aead cipher.AEAD
+}
+
+func (k localKEK) Wrap(dataKey []byte) (string, error) {
+	sealed, err := gcmSeal(k.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return "local:" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (k localKEK) Unwrap(wrapped string) ([]byte, error) {
+	raw, ok := strings.CutPrefix(wrapped, "local:")
+	if !ok {
+		return nil, fmt.Errorf("data key was not wrapped locally")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return gcmOpen(k.aead, sealed)
+}
+
+// vaultTransit wraps data keys with Vault's transit secrets engine.
+type vaultTransit struct {
+	addr   string
+	token  string
+	key    string
+	client *http.Client
+}
+
+// call POSTs req to the transit endpoint op and decodes the "data" object.
+func (v *vaultTransit) call(op string, req, data interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", v.addr+"/v1/transit/"+op+"/"+v.key, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("X-Vault-Token", v.token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("vault transit %s failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault transit %s returned %d: %s", op, resp.StatusCode, string(respBody))
+	}
+	return json.Unmarshal(respBody, &struct {
+		Data interface{} `json:"data"`
+	}{data})
+}
+
+func (v *vaultTransit) Wrap(dataKey []byte) (string, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.call("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &out)
+	return out.Ciphertext, err
+}
+
+func (v *vaultTransit) Unwrap(wrapped string) ([]byte, error) {
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := v.call("decrypt", map[string]string{"ciphertext": wrapped}, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Plaintext)
+}
+
+// envelope seals records under one data key.
+type envelope struct {
+	aead    cipher.AEAD
+	wrapped string // the data key, wrapped by the key-encryption key
+}
+
+// newEnvelope generates a data key and wraps it with w.
+func newEnvelope(w keyWrapper) (*envelope, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := w.Wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, wrapped: wrapped}, nil
+}
+
+// openEnvelope unwraps a stored data key with w.
+func openEnvelope(w keyWrapper, wrapped string) (*envelope, error) {
+	dataKey, err := w.Unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, wrapped: wrapped}, nil
+}
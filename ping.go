@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Hook verification events sent by the SCMs when a webhook is created or
+// "tested" from their UI. They carry no PR data but prove the hook reaches us.
+const (
+	githubPingEvent    = "ping"
+	bitbucketPingEvent = "diagnostics:ping"
+)
+
+// isPingEvent reports whether eventType is a hook verification event.
+func isPingEvent(platform SCMPlatform, eventType string) bool {
+	switch platform {
+	case PlatformGitHub:
+		return eventType == githubPingEvent
+	case PlatformBitbucket, PlatformBitbucketServer:
+		return eventType == bitbucketPingEvent
+	case PlatformCodeCommit:
+		return eventType == snsSubscriptionConfirmation
+	}
+	return false
+}
+
+// ghPingPayload is the subset of the GitHub ping event we care about.
+type ghPingPayload struct {
+	Zen    string `json:"zen"`
+	HookID int64  `json:"hook_id"`
+	Hook   struct {
+		Type   string   `json:"type"` // "Repository", "Organization" or "App"
+		Events []string `json:"events"`
+		Config struct {
+			URL         string `json:"url"`
+			ContentType string `json:"content_type"`
+		} `json:"config"`
+	} `json:"hook"`
+	Repository *struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// handlePing records a hook verification event in the repo registry and
+// answers it with a small JSON body, so the SCM's delivery log shows that the
+// gateway understood the ping rather than skipping it as an unknown event.
+func handlePing(w http.ResponseWriter, platform SCMPlatform, payload []byte) {
+	resp := map[string]interface{}{
+		"status":   "pong",
+		"platform": platform,
+	}
+
+	fullName := appHookKey
+	var hookID int64
+
+	if platform == PlatformGitHub {
+		var p ghPingPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			log.Printf("[Ping] Warning: could not parse GitHub ping payload: %v\n", err)
+		} else {
+			if p.Repository != nil && p.Repository.FullName != "" {
+				fullName = p.Repository.FullName
+			}
+			hookID = p.HookID
+			resp["zen"] = p.Zen
+			resp["hook_id"] = p.HookID
+			resp["hook_type"] = p.Hook.Type
+			resp["events"] = p.Hook.Events
+			log.Printf("[Ping] GitHub ping — hook=%d type=%s url=%s content_type=%s events=%v zen=%q\n",
+				p.HookID, p.Hook.Type, p.Hook.Config.URL, p.Hook.Config.ContentType, p.Hook.Events, p.Zen)
+		}
+	} else if platform == PlatformCodeCommit {
+		// Unlike a ping, an SNS subscription stays pending until confirmed.
+		topic, err := confirmSNSSubscription(payload)
+		if err != nil {
+			log.Printf("[Ping] Error: could not confirm SNS subscription to %s: %v\n", topic, err)
+			http.Error(w, "subscription confirmation failed", http.StatusBadGateway)
+			return
+		}
+		resp["topic_arn"] = topic
+		log.Printf("[Ping] CodeCommit SNS subscription to %s confirmed\n", topic)
+	} else {
+		log.Printf("[Ping] %s test connection received\n", platform)
+	}
+
+	registry.MarkHookVerified(platform, fullName, hookID)
+	resp["repository"] = fullName
+	resp["hook_verified"] = true
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
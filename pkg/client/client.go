@@ -0,0 +1,253 @@
+// Package client is a Go client for the SCM gateway's HTTP API, for services
+// that read PR state from the gateway or subscribe to its normalized events.
+//
+//	c := client.New("https://scm-gateway.internal", os.Getenv("GATEWAY_ADMIN_TOKEN"))
+//	prs, err := c.PRs(ctx, client.PRFilter{Owner: "acme", Repo: "api"})
+//
+// The types mirror the gateway's JSON; they live here rather than being
+// imported so services do not depend on the gateway's main package. See
+// Handler for receiving events.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the gateway's HTTP API.
+type Client struct {
+	baseURL    string
+	adminToken string
+	httpClient *http.Client
+}
+
+// New returns a client for the gateway at baseURL. adminToken is the
+// gateway's ADMIN_TOKEN, needed only for the subscription methods and
+// ResolveReviewThread.
+func New(baseURL, adminToken string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		adminToken: adminToken,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithHTTPClient returns a copy of c that sends requests through hc.
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	cp := *c
+	cp.httpClient = hc
+	return &cp
+}
+
+// APIError is returned when the gateway answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gateway returned %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request and decodes a JSON response into out (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("client: failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("client: failed to create request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("client: %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("client: failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("client: failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
+
+// Health is the gateway's liveness report (GET /healthz).
+type Health struct {
+	Status    string                     `json:"status"`
+	Leader    bool                       `json:"leader"`
+	Role      string                     `json:"role"`
+	Consumers map[string]json.RawMessage `json:"consumers"`
+}
+
+// Health returns the liveness report. An unhealthy gateway answers 503,
+// which is returned as an *APIError.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var h Health
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// PRFilter selects pull requests for PRs. Empty fields match everything;
+// State is "open" (default), "closed" or "all".
+type PRFilter struct {
+	Platform string
+	Owner    string
+	Repo     string
+	State    string
+}
+
+// PRs returns the current state of the matching pull requests (GET /prs).
+func (c *Client) PRs(ctx context.Context, f PRFilter) ([]PRSnapshot, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"platform": f.Platform, "owner": f.Owner, "repo": f.Repo, "state": f.State} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	var resp struct {
+		PRs []PRSnapshot `json:"prs"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/prs", q, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.PRs, nil
+}
+
+// PRFiles returns the files changed in a GitHub pull request (GET /pr-files).
+func (c *Client) PRFiles(ctx context.Context, owner, repo string, number int) (*PRFiles, error) {
+	q := url.Values{"owner": {owner}, "repo": {repo}, "pr": {strconv.Itoa(number)}}
+	var files PRFiles
+	if err := c.do(ctx, http.MethodGet, "/pr-files", q, nil, &files); err != nil {
+		return nil, err
+	}
+	return &files, nil
+}
+
+// ReviewThreads returns the review threads of a pull request and how many
+// of them are unresolved (GET /pr-threads).
+func (c *Client) ReviewThreads(ctx context.Context, platform, owner, repo string, number int) ([]Thread, int, error) {
+	q := url.Values{"owner": {owner}, "repo": {repo}, "pr": {strconv.Itoa(number)}}
+	if platform != "" {
+		q.Set("platform", platform)
+	}
+	var resp struct {
+		Unresolved int      `json:"unresolved"`
+		Threads    []Thread `json:"threads"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/pr-threads", q, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Threads, resp.Unresolved, nil
+}
+
+// ResolveReviewThread resolves a review thread (POST /pr-threads/resolve).
+func (c *Client) ResolveReviewThread(ctx context.Context, platform, owner, repo string, number int, threadID string) error {
+	req := map[string]interface{}{"platform": platform, "owner": owner, "repo": repo, "pr": number, "thread_id": threadID}
+	return c.do(ctx, http.MethodPost, "/pr-threads/resolve", nil, req, nil)
+}
+
+// Repos returns the repositories the gateway has seen webhooks from
+// (GET /repos).
+func (c *Client) Repos(ctx context.Context) ([]Repo, error) {
+	var resp struct {
+		Repos []Repo `json:"repos"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/repos", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Repos, nil
+}
+
+// Subscribe registers sub and returns it as stored, with its ID.
+func (c *Client) Subscribe(ctx context.Context, sub Subscription) (*Subscription, error) {
+	var resp struct {
+		Subscription Subscription `json:"subscription"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subscriptions", nil, sub, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Subscription, nil
+}
+
+// Subscriptions lists every subscription (secrets redacted).
+func (c *Client) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	var resp struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/subscriptions", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Subscriptions, nil
+}
+
+// Subscription returns one subscription (secret redacted).
+func (c *Client) Subscription(ctx context.Context, id string) (*Subscription, error) {
+	var resp struct {
+		Subscription Subscription `json:"subscription"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Subscription, nil
+}
+
+// Unsubscribe removes a subscription.
+func (c *Client) Unsubscribe(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/subscriptions/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Deliveries returns the recent deliveries of a subscription, newest first.
+func (c *Client) Deliveries(ctx context.Context, id string) ([]Delivery, error) {
+	var resp struct {
+		Deliveries []Delivery `json:"deliveries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/subscriptions/"+url.PathEscape(id)+"/deliveries", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Deliveries, nil
+}
+
+// Redeliver resends a logged delivery and returns the new delivery.
+func (c *Client) Redeliver(ctx context.Context, id, deliveryID string) (*Delivery, error) {
+	var resp struct {
+		Delivery Delivery `json:"delivery"`
+	}
+	path := "/subscriptions/" + url.PathEscape(id) + "/deliveries/" + url.PathEscape(deliveryID) + "/redeliver"
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Delivery, nil
+}
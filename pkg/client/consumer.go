@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handler returns an http.Handler that receives the gateway's subscription
+// deliveries, verifies their X-Hub-Signature-256 against secret (skipped when
+// secret is empty) and passes each event to fn. When fn returns an error the
+// delivery is answered 500 and the gateway retries it.
+//
+// Services consume events through a subscription rather than the gateway's
+// normalized queue: the queue belongs to the gateway's own event bus and a
+// second consumer would take events away from it.
+//
+//	http.Handle("/scm-events", client.Handler(secret, func(ctx context.Context, e *client.Event) error {
+//		…
+//	}))
+//
+// The subscription must not set a template, so the body is the event JSON.
+func Handler(secret string, fn func(ctx context.Context, event *Event) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "cannot read body", http.StatusBadRequest)
+			return
+		}
+		if secret != "" && !validSignature(body, r.Header.Get("X-Hub-Signature-256"), secret) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			// Retrying will not make the body parse.
+			http.Error(w, "invalid event: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := fn(r.Context(), &event); err != nil {
+			log.Printf("[GatewayClient] Warning: handling event %s (delivery %s) failed: %v\n",
+				event.EventID, r.Header.Get("X-Gateway-Delivery"), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// validSignature checks a "sha256=<hex>" HMAC of body.
+func validSignature(body []byte, signature, secret string) bool {
+	signature = strings.TrimPrefix(signature, "sha256=")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
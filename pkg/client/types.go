@@ -0,0 +1,262 @@
+package client
+
+import "time"
+
+// Event is a normalized PR event as published by the gateway. Field names
+// follow the gateway's JSON encoding of NormalizedEvent.
+type Event struct {
+	EventID           string
+	DeliveryID        string
+	SchemaVersion     int
+	Platform          string
+	EventType         string // e.g. "pull_request.opened"
+	Action            string // e.g. "opened", "synchronize", "closed"
+	PR                PR
+	Repository        Repository
+	Files             []File
+	Commits           []Commit
+	Owners            []string
+	Tickets           []Ticket
+	Dependencies      []Dependency
+	CanonicalAuthor   *Identity      // with the "identity" enricher
+	Origin            *Origin        // events forwarded between gateways only
+	Thread            *Thread        // thread.resolved / thread.unresolved events only
+	Security          *SecurityAlert // security.alert events only
+	PolicyFindings    []PolicyFinding
+	EnrichmentSkipped string
+	StaleReason       string
+	RawPayload        []byte
+	ReceivedAt        time.Time
+}
+
+// PR is the pull request an event is about.
+type PR struct {
+	Number       int
+	Title        string
+	Description  string
+	Author       string
+	SourceBranch string
+	TargetBranch string
+	HeadSHA      string
+	BaseSHA      string
+	State        string
+	URL          string
+
+	Draft          bool
+	Mergeable      *bool // nil while unknown
+	MergeableState string
+
+	Labels             []string
+	Assignees          []string
+	RequestedReviewers []string
+	RequestedTeams     []string
+}
+
+// Repository is the repository an event belongs to.
+type Repository struct {
+	ID            string
+	Name          string
+	FullName      string
+	Owner         string
+	CloneURL      string
+	HTMLURL       string
+	DefaultBranch string
+	Visibility    string // "public", "private", "internal" or "" if unknown
+	Language      string
+}
+
+// File is a changed file. Status is "added", "modified", "removed" or
+// "renamed".
+type File struct {
+	Filename         string
+	Status           string
+	Additions        int
+	Deletions        int
+	Changes          int
+	PreviousFilename string
+	Patch            string // with the gateway's "file_patches" flag
+	PatchTruncated   bool
+	IsBinary         bool
+	SizeBytes        int64 // at the PR head; 0 when unknown
+}
+
+// Commit is one commit of a pull request.
+type Commit struct {
+	SHA       string
+	Author    string
+	Message   string
+	Timestamp time.Time
+}
+
+// Thread is a PR review thread. ID is GitHub's GraphQL node ID or
+// Bitbucket's root comment ID. On events, Unresolved is the number of
+// threads still unresolved on the PR (-1 if unknown).
+type Thread struct {
+	ID         string
+	Path       string
+	Line       int
+	Author     string
+	Body       string
+	URL        string
+	Resolved   bool
+	ResolvedBy string
+	Unresolved int
+}
+
+// SecurityAlert is a Dependabot alert, code scanning alert or published
+// security advisory. Severity is "critical", "high", "medium", "low" or
+// "unknown".
+type SecurityAlert struct {
+	Source   string // "dependabot_alert", "code_scanning_alert" or "security_advisory"
+	ID       string
+	State    string
+	Severity string
+	Summary  string
+	URL      string
+	GHSA     string
+	CVE      string
+	Packages []AffectedPackage
+	Rule     string
+	Tool     string
+	Path     string
+	Line     int
+}
+
+// AffectedPackage is a package a security alert applies to.
+type AffectedPackage struct {
+	Ecosystem          string
+	Name               string
+	VulnerableVersions string
+	PatchedVersion     string
+	Manifest           string
+}
+
+// Ticket is an issue-tracker key referenced by a pull request.
+type Ticket struct {
+	Key       string
+	Source    string // "branch", "title" or "description"
+	URL       string
+	Summary   string
+	Status    string
+	Validated bool
+}
+
+// Dependency is a PR the event's PR depends on ("depends on owner/repo#123"
+// in its description). State is the dependency's own PR state; Validated is
+// false when it could not be looked up.
+type Dependency struct {
+	Repository string
+	Number     int
+	Title      string
+	State      string
+	URL        string
+	Validated  bool
+}
+
+// Identity is the corporate identity behind a platform account. Source is
+// "file" or "scim".
+type Identity struct {
+	ID     string
+	Email  string
+	Name   string
+	Source string
+}
+
+// Origin records where an event forwarded between gateways came from:
+// the gateway that normalized it and every gateway that forwarded it.
+type Origin struct {
+	Gateway string
+	Hops    []string
+}
+
+// PolicyFinding is a violation reported by one of the gateway's policies.
+type PolicyFinding struct {
+	Policy   string
+	Rule     string
+	Path     string
+	Line     int
+	Severity string
+	Message  string
+}
+
+// PRSnapshot is the current state of a pull request (GET /prs).
+type PRSnapshot struct {
+	Platform      string    `json:"platform"`
+	Repository    string    `json:"repository"`
+	Number        int       `json:"number"`
+	Title         string    `json:"title"`
+	Author        string    `json:"author"`
+	SourceBranch  string    `json:"source_branch"`
+	TargetBranch  string    `json:"target_branch"`
+	State         string    `json:"state"`
+	URL           string    `json:"url,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	LastEventID   string    `json:"last_event_id,omitempty"`
+	LastEventType string    `json:"last_event_type,omitempty"`
+	Source        string    `json:"source"`
+}
+
+// PRFiles is the response of GET /pr-files.
+type PRFiles struct {
+	Owner          string   `json:"owner"`
+	Repo           string   `json:"repo"`
+	PRNumber       int      `json:"pr_number"`
+	TotalFiles     int      `json:"total_files"`
+	TotalAdditions int      `json:"total_additions"`
+	TotalDeletions int      `json:"total_deletions"`
+	TotalChanges   int      `json:"total_changes"`
+	Filenames      []string `json:"filenames"`
+	Files          []struct {
+		Filename         string `json:"filename"`
+		Status           string `json:"status"`
+		Additions        int    `json:"additions"`
+		Deletions        int    `json:"deletions"`
+		Changes          int    `json:"changes"`
+		PreviousFilename string `json:"previous_filename"`
+	} `json:"files"`
+}
+
+// Repo is an entry of the gateway's repo registry (GET /repos).
+type Repo struct {
+	Platform     string    `json:"platform"`
+	FullName     string    `json:"full_name"`
+	HookID       int64     `json:"hook_id,omitempty"`
+	HookVerified bool      `json:"hook_verified"`
+	VerifiedAt   time.Time `json:"verified_at,omitempty"`
+	PingCount    int       `json:"ping_count"`
+	Suspended    bool      `json:"suspended"`
+}
+
+// Subscription is an outgoing webhook subscription. Empty filters match
+// every event.
+type Subscription struct {
+	ID          string   `json:"id,omitempty"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	Platforms   []string `json:"platforms,omitempty"`
+	Repos       []string `json:"repos,omitempty"`
+	Template    string   `json:"template,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+
+	// Set by the gateway.
+	Active              bool      `json:"active"`
+	CreatedAt           time.Time `json:"created_at"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastDeliveryAt      time.Time `json:"last_delivery_at,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Delivery is one delivery of an event to a subscription.
+type Delivery struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"event_id"`
+	EventType   string    `json:"event_type"`
+	Redelivery  bool      `json:"redelivery"`
+	Success     bool      `json:"success"`
+	Attempts    int       `json:"attempts"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	Error       string    `json:"error,omitempty"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Policy severities shared by the policy stages.
+const (
+	SeverityOff     = "off"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// PolicyFinding is a single violation reported by a policy stage and attached
+// to the NormalizedEvent, so downstream consumers see the same verdict that
+// was reported on the PR.
+type PolicyFinding struct {
+	Policy   string // e.g. "conventional_commits", "files"
+	Rule     string // e.g. "disallowed_extension", "license_header"
+	Path     string // file path, or "" for PR-level findings
+	Line     int
+	Severity string // SeverityWarning or SeverityError
+	Message  string
+}
+
+// policyEnabled reports whether severity turns a policy stage on.
+func policyEnabled(severity string) bool {
+	return severity == SeverityWarning || severity == SeverityError
+}
+
+// resolveHeadSHA returns the SHA of the PR's head commit: the event's, or
+// the last of the PR's commits when the platform did not report it.
+func resolveHeadSHA(reader PRReader, event *NormalizedEvent) (string, error) {
+	if event.PR.HeadSHA != "" {
+		return event.PR.HeadSHA, nil
+	}
+	commits, err := reader.GetPRCommits(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("PR #%d has no commits", event.PR.Number)
+	}
+	return commits[len(commits)-1].SHA, nil
+}
+
+// publishPolicyCheck reports findings of one policy as a check run on the PR
+// head: failure if any finding is an error, neutral for warnings only.
+// Findings without a file path (e.g. on the PR title) are listed in the
+// summary, since annotations must point at a file. A non-nil incomplete means
+// some checks could not run: the run never succeeds then, and fails when the
+// policy's severity is "error", since a passing check would let auto-merge
+// through unchecked.
+func publishPolicyCheck(adapter SCMAdapter, event *NormalizedEvent, policy, severity string, findings []PolicyFinding, incomplete error) {
+	publisher, ok := adapter.(CheckPublisher)
+	if !ok {
+		log.Printf("[Policy] Warning: %v\n", errUnsupported(adapter, "check runs"))
+		return
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		log.Printf("[Policy] Warning: %v\n", errUnsupported(adapter, "reading PR commits"))
+		return
+	}
+	sha, err := resolveHeadSHA(reader, event)
+	if err != nil {
+		log.Printf("[Policy] Warning: could not resolve head of PR #%d: %v\n", event.PR.Number, err)
+		return
+	}
+
+	run := CheckRun{
+		Name:       "policy: " + policy,
+		HeadSHA:    sha,
+		Conclusion: CheckSuccess,
+		Title:      "No violations",
+		Summary:    fmt.Sprintf("The %s policy found no violations.", policy),
+	}
+	if len(findings) > 0 {
+		run.Conclusion = CheckNeutral
+		run.Title = fmt.Sprintf("%d violation(s)", len(findings))
+		run.Summary = fmt.Sprintf("The %s policy found %d violation(s).", policy, len(findings))
+	}
+	for _, f := range findings {
+		level := AnnotationWarning
+		if f.Severity == SeverityError {
+			level = AnnotationFailure
+			run.Conclusion = CheckFailure
+		}
+		if f.Path == "" {
+			run.Summary += fmt.Sprintf("\n- **%s**: %s", f.Rule, f.Message)
+			continue
+		}
+		line := f.Line
+		if line == 0 {
+			line = 1
+		}
+		run.Annotations = append(run.Annotations, CheckAnnotation{
+			Path:      f.Path,
+			StartLine: line,
+			EndLine:   line,
+			Level:     level,
+			Title:     f.Rule,
+			Message:   f.Message,
+		})
+	}
+
+	if incomplete != nil {
+		if run.Conclusion == CheckSuccess {
+			run.Conclusion = CheckNeutral
+			run.Title = "Check incomplete"
+		}
+		if severity == SeverityError {
+			run.Conclusion = CheckFailure
+		}
+		run.Summary += fmt.Sprintf("\n\nThe check is incomplete: %v", incomplete)
+	}
+
+	if err := publisher.PublishCheckRun(event.Repository.Owner, event.Repository.Name, run); err != nil {
+		log.Printf("[Policy] Warning: could not publish %q check run: %v\n", run.Name, err)
+	}
+}
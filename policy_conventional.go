@@ -0,0 +1,228 @@
+package main
+
+// Conventional Commits policy stage.
+//
+// Validates the PR title (and optionally every commit message) against the
+// Conventional Commits format `type(scope)!: description` and reports the
+// result as a `policy: conventional_commits` check run, failing when the
+// severity is "error", and as a single PR comment that is edited in place on
+// every run. Enabled per repo via RepoConfig:
+//
+//	"conventional_commits": {
+//	  "severity": "warning",          // "off", "warning" or "error"
+//	  "check_commits": true,          // also validate commit messages
+//	  "types": ["feat", "fix", ...]   // allowed types (default below)
+//	}
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConventionalCommitsPolicy is the per-repo configuration of the stage.
+type ConventionalCommitsPolicy struct {
+	Severity     string   `json:"severity"`
+	CheckCommits bool     `json:"check_commits"`
+	Types        []string `json:"types,omitempty"`
+}
+
+// defaultConventionalTypes are the types of the Angular convention.
+var defaultConventionalTypes = []string{
+	"build", "chore", "ci", "docs", "feat", "fix",
+	"perf", "refactor", "revert", "style", "test",
+}
+
+// conventionalHeader matches `type(scope)!: description`.
+var conventionalHeader = regexp.MustCompile(`^([a-z]+)(\([^()\s][^()]*\))?(!)?: \S.*$`)
+
+// conventionalViolation describes one title or commit that failed validation.
+type conventionalViolation struct {
+	Subject string // "PR title" or "commit abc1234"
+	Header  string
+	Reason  string
+}
+
+// validateConventionalHeader checks the first line of msg and returns an
+// empty reason when it conforms.
+func validateConventionalHeader(msg string, types []string) string {
+	header := strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
+	m := conventionalHeader.FindStringSubmatch(header)
+	if m == nil {
+		return "does not match `type(scope): description`"
+	}
+	for _, t := range types {
+		if m[1] == t {
+			return ""
+		}
+	}
+	return fmt.Sprintf("type %q is not one of %s", m[1], strings.Join(types, ", "))
+}
+
+// runConventionalCommitsPolicy validates the PR title and commit messages
+// for events that change them and reports the result on the PR.
+func runConventionalCommitsPolicy(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 {
+		return
+	}
+	switch event.Action {
+	case "opened", "reopened", "edited", "synchronize":
+	default:
+		return
+	}
+
+	policy := repoConfigFor(event.Platform, event.Repository.FullName).ConventionalCommits
+	if policy == nil || !policyEnabled(policy.Severity) {
+		return
+	}
+	types := policy.Types
+	if len(types) == 0 {
+		types = defaultConventionalTypes
+	}
+
+	var violations []conventionalViolation
+	if reason := validateConventionalHeader(event.PR.Title, types); reason != "" {
+		violations = append(violations, conventionalViolation{"PR title", event.PR.Title, reason})
+	}
+
+	if policy.CheckCommits {
+		var commits []NormalizedCommit
+		var err error
+		if reader, ok := adapter.(PRReader); ok {
+			commits, err = reader.GetPRCommits(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+		} else {
+			err = errUnsupported(adapter, "reading PR commits")
+		}
+		if err != nil {
+			log.Printf("[Policy] Warning: could not fetch commits for PR #%d: %v\n", event.PR.Number, err)
+		}
+		for _, c := range commits {
+			// Merge commits are generated by the SCM, not written by the author.
+			if strings.HasPrefix(c.Message, "Merge ") {
+				continue
+			}
+			if reason := validateConventionalHeader(c.Message, types); reason != "" {
+				header := strings.SplitN(c.Message, "\n", 2)[0]
+				violations = append(violations, conventionalViolation{"commit " + shortSHA(c.SHA), header, reason})
+			}
+		}
+	}
+
+	var findings []PolicyFinding
+	for _, v := range violations {
+		findings = append(findings, PolicyFinding{
+			Policy:   "conventional_commits",
+			Rule:     v.Subject,
+			Severity: policy.Severity,
+			Message:  fmt.Sprintf("%q %s", v.Header, v.Reason),
+		})
+	}
+	event.PolicyFindings = append(event.PolicyFindings, findings...)
+	publishPolicyCheck(adapter, event, "conventional_commits", policy.Severity, findings, nil)
+
+	if len(violations) > 0 {
+		log.Printf("[Policy] PR #%d in %s has %d conventional-commit violation(s) (severity=%s)\n",
+			event.PR.Number, event.Repository.FullName, len(violations), policy.Severity)
+	}
+	if err := upsertConventionalReport(adapter, event, violations, policy.Severity); err != nil {
+		log.Printf("[Policy] Warning: could not report violations on PR #%d: %v\n", event.PR.Number, err)
+	}
+}
+
+// conventionalReportMarker identifies the gateway's report comment on a PR.
+const conventionalReportMarker = "<!-- scm-gateway:conventional-commits -->"
+
+var (
+	conventionalReportsOnce sync.Once
+	conventionalReportsRef  *ttlCache
+)
+
+// conventionalReports remembers the last report posted per PR on adapters
+// that cannot edit comments, so an unchanged report is not posted again.
+func conventionalReports() *ttlCache {
+	conventionalReportsOnce.Do(func() {
+		conventionalReportsRef = newTTLCache("conventional_reports", 24*time.Hour)
+	})
+	return conventionalReportsRef
+}
+
+// upsertConventionalReport keeps a single report comment on the PR: the
+// marked comment written by the gateway (see isGatewayAuthor) is edited in
+// place when the adapter can find and edit it, and a comment is only added
+// while there are violations. A PR whose violations were fixed gets its
+// existing report marked as passing.
+func upsertConventionalReport(adapter SCMAdapter, event *NormalizedEvent, violations []conventionalViolation, severity string) error {
+	owner, repo, number := event.Repository.Owner, event.Repository.Name, event.PR.Number
+	body := formatConventionalReport(violations, severity)
+
+	reader, canRead := adapter.(PRCommentReader)
+	editor, canEdit := adapter.(CommentEditor)
+	if canRead && canEdit {
+		comments, err := reader.GetPRComments(owner, repo, number)
+		if err != nil {
+			return err
+		}
+		for _, c := range comments {
+			// Only the gateway's own comment: anyone can paste the marker.
+			if c.Path != "" || !strings.Contains(c.Body, conventionalReportMarker) || !isGatewayAuthor(c.Author, c.ViaApp) {
+				continue
+			}
+			if strings.TrimSpace(c.Body) == strings.TrimSpace(body) {
+				return nil
+			}
+			return editor.UpdateComment(owner, repo, number, c.ID, body)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	writer, ok := adapter.(PRWriter)
+	if !ok {
+		return errUnsupported(adapter, "PR comments")
+	}
+	key := fmt.Sprintf("%s|%s|%d", event.Platform, strings.ToLower(event.Repository.FullName), number)
+	if !canRead || !canEdit {
+		if last, ok := conventionalReports().Get(key); ok && last.(string) == body {
+			return nil
+		}
+	}
+	if err := writer.PostComment(owner, repo, number, body); err != nil {
+		return err
+	}
+	conventionalReports().Set(key, body)
+	return nil
+}
+
+// formatConventionalReport renders violations as a Markdown PR comment.
+func formatConventionalReport(violations []conventionalViolation, severity string) string {
+	var b strings.Builder
+	b.WriteString(conventionalReportMarker + "\n")
+	if len(violations) == 0 {
+		b.WriteString("### ✅ Conventional Commits check passed\n\n")
+		b.WriteString("The PR title and commits now follow the Conventional Commits format.\n")
+		return b.String()
+	}
+	if severity == SeverityError {
+		b.WriteString("### ❌ Conventional Commits check failed\n\n")
+		b.WriteString("The following must be fixed before this PR can be merged:\n\n")
+	} else {
+		b.WriteString("### ⚠️ Conventional Commits check\n\n")
+		b.WriteString("The following do not follow the Conventional Commits format:\n\n")
+	}
+	for _, v := range violations {
+		fmt.Fprintf(&b, "- **%s** `%s` — %s\n", v.Subject, v.Header, v.Reason)
+	}
+	return b.String()
+}
+
+// shortSHA abbreviates a commit SHA to 7 characters.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
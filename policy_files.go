@@ -0,0 +1,196 @@
+package main
+
+// File policy stage.
+//
+// Flags PRs whose changed files break repo rules and reports them as a
+// "policy: files" check run. Enabled per repo via RepoConfig:
+//
+//	"file_policy": {
+//	  "severity": "error",                       // "off", "warning" or "error"
+//	  "disallowed_extensions": [".exe", ".jar"],
+//	  "license_header": "Copyright \\d{4} Example Corp",  // regex
+//	  "license_header_extensions": [".go", ".ts"],
+//	  "max_binary_bytes": 1048576
+//	}
+//
+// License headers are checked on added files only, in the first
+// license_header_lines (default 20) lines of the diff.
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const defaultLicenseHeaderLines = 20
+
+// FilePolicy is the per-repo configuration of the file policy stage.
+type FilePolicy struct {
+	Severity                string   `json:"severity"`
+	DisallowedExtensions    []string `json:"disallowed_extensions,omitempty"`
+	LicenseHeader           string   `json:"license_header,omitempty"`
+	LicenseHeaderExtensions []string `json:"license_header_extensions,omitempty"`
+	LicenseHeaderLines      int      `json:"license_header_lines,omitempty"`
+	MaxBinaryBytes          int64    `json:"max_binary_bytes,omitempty"`
+}
+
+// runFilePolicy evaluates the repo's file policy against the PR's changed
+// files, attaches the findings to the event and reports them as a check run.
+func runFilePolicy(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 || len(event.Files) == 0 {
+		return
+	}
+	policy := repoConfigFor(event.Platform, event.Repository.FullName).FilePolicy
+	if policy == nil || !policyEnabled(policy.Severity) {
+		return
+	}
+
+	finding := func(rule, path, msg string) PolicyFinding {
+		return PolicyFinding{Policy: "files", Rule: rule, Path: path, Severity: policy.Severity, Message: msg}
+	}
+	var findings []PolicyFinding
+
+	for _, f := range event.Files {
+		if f.Status == "removed" {
+			continue
+		}
+		if ext := path.Ext(f.Filename); hasExtension(policy.DisallowedExtensions, ext) {
+			findings = append(findings, finding("disallowed_extension", f.Filename,
+				fmt.Sprintf("files with extension %s are not allowed in this repository", ext)))
+		}
+	}
+
+	var incomplete error
+	if policy.LicenseHeader != "" || policy.MaxBinaryBytes > 0 {
+		diffFindings, err := checkDiffFiles(adapter, event, policy, finding)
+		if err != nil {
+			log.Printf("[Policy] Warning: file policy checks on PR #%d incomplete: %v\n", event.PR.Number, err)
+			incomplete = fmt.Errorf("license header and binary size checks did not run: %w", err)
+		}
+		findings = append(findings, diffFindings...)
+	}
+
+	if len(findings) > 0 {
+		log.Printf("[Policy] PR #%d in %s has %d file policy violation(s) (severity=%s)\n",
+			event.PR.Number, event.Repository.FullName, len(findings), policy.Severity)
+	}
+	event.PolicyFindings = append(event.PolicyFindings, findings...)
+	publishPolicyCheck(adapter, event, "files", policy.Severity, findings, incomplete)
+}
+
+// checkDiffFiles runs the checks that need the diff: license headers of new
+// text files and the size of binary files.
+func checkDiffFiles(adapter SCMAdapter, event *NormalizedEvent, policy *FilePolicy,
+	finding func(rule, path, msg string) PolicyFinding) ([]PolicyFinding, error) {
+
+	var header *regexp.Regexp
+	if policy.LicenseHeader != "" {
+		re, err := regexp.Compile(policy.LicenseHeader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid license_header pattern: %w", err)
+		}
+		header = re
+	}
+	headerLines := policy.LicenseHeaderLines
+	if headerLines <= 0 {
+		headerLines = defaultLicenseHeaderLines
+	}
+
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		return nil, errUnsupported(adapter, "PR diffs")
+	}
+	owner, repo := event.Repository.Owner, event.Repository.Name
+	diff, err := reader.GetPRDiff(owner, repo, event.PR.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	repoReader, canSize := adapter.(RepoReader)
+	if !canSize && policy.MaxBinaryBytes > 0 {
+		log.Printf("[Policy] Warning: binary size check skipped: %v\n", errUnsupported(adapter, "file sizes"))
+	}
+
+	var findings []PolicyFinding
+	for _, f := range parseUnifiedDiff(diff) {
+		if strings.Contains(f.header, "deleted file mode") {
+			continue
+		}
+
+		if isBinaryDiff(f) {
+			if policy.MaxBinaryBytes <= 0 {
+				continue
+			}
+			if !canSize {
+				continue
+			}
+			size, err := repoReader.GetFileSize(owner, repo, contentsRef(event), f.path)
+			if err != nil {
+				log.Printf("[Policy] Warning: could not get size of %s: %v\n", f.path, err)
+				continue
+			}
+			if size > policy.MaxBinaryBytes {
+				findings = append(findings, finding("oversized_binary", f.path,
+					fmt.Sprintf("binary file is %d bytes, limit is %d", size, policy.MaxBinaryBytes)))
+			}
+			continue
+		}
+
+		if header == nil || !strings.Contains(f.header, "new file mode") {
+			continue
+		}
+		if len(policy.LicenseHeaderExtensions) > 0 && !hasExtension(policy.LicenseHeaderExtensions, path.Ext(f.path)) {
+			continue
+		}
+		if !header.MatchString(addedPrefix(f, headerLines)) {
+			findings = append(findings, finding("license_header", f.path, "new file is missing the required license header"))
+		}
+	}
+	return findings, nil
+}
+
+// isBinaryDiff reports whether git rendered the file as binary.
+func isBinaryDiff(f diffFile) bool {
+	return strings.Contains(f.header, "Binary files ") || strings.Contains(f.header, "GIT binary patch")
+}
+
+// addedPrefix returns the first n added lines of a file diff, without the
+// leading "+".
+func addedPrefix(f diffFile, n int) string {
+	var lines []string
+	for _, hunk := range f.hunks {
+		for _, line := range strings.Split(hunk, "\n") {
+			if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
+				lines = append(lines, line[1:])
+				if len(lines) == n {
+					return strings.Join(lines, "\n")
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contentsRef is the ref that resolves to the PR head in the contents APIs.
+func contentsRef(event *NormalizedEvent) string {
+	if event.Platform == PlatformGitHub {
+		return fmt.Sprintf("refs/pull/%d/head", event.PR.Number)
+	}
+	return event.PR.SourceBranch
+}
+
+// hasExtension reports whether ext is in list (case-insensitive, with or
+// without the leading dot).
+func hasExtension(list []string, ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, e := range list {
+		if strings.EqualFold(strings.TrimPrefix(e, "."), strings.TrimPrefix(ext, ".")) {
+			return true
+		}
+	}
+	return false
+}
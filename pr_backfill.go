@@ -0,0 +1,75 @@
+package main
+
+// Backfilling partial webhook payloads.
+//
+// Some deliveries carry an incomplete pull request — Bitbucket's
+// pullrequest:updated, for one, can omit the description and branches after
+// an edit. Rather than publish a half-empty event, NormalizeEvent asks the SCM
+// for the full PR and fills in whatever the payload left out.
+
+import (
+	"log"
+	"strings"
+)
+
+// missingPRFields lists the critical fields of pr that are empty.
+func missingPRFields(pr NormalizedPR) []string {
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"title", pr.Title},
+		{"author", pr.Author},
+		{"source_branch", pr.SourceBranch},
+		{"target_branch", pr.TargetBranch},
+		{"url", pr.URL},
+	} {
+		if f.value == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	return missing
+}
+
+// backfillPRDetails fetches the PR through reader when event is missing
+// critical fields (or descriptionMissing reports that the payload had no
+// description at all) and copies over the fields that are empty. Failures
+// are logged and leave the event as it was.
+func backfillPRDetails(reader PRReader, event *NormalizedEvent, descriptionMissing bool) {
+	missing := missingPRFields(event.PR)
+	if descriptionMissing {
+		missing = append(missing, "description")
+	}
+	if len(missing) == 0 || event.PR.Number == 0 {
+		return
+	}
+
+	log.Printf("[%s Adapter] PR #%d payload is missing %s; fetching details\n",
+		event.Platform, event.PR.Number, strings.Join(missing, ", "))
+	pr, err := reader.GetPRDetails(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		log.Printf("[%s Adapter] Warning: could not backfill PR #%d: %v\n", event.Platform, event.PR.Number, err)
+		return
+	}
+
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&event.PR.Title, pr.Title)
+	fill(&event.PR.Author, pr.Author)
+	fill(&event.PR.SourceBranch, pr.SourceBranch)
+	fill(&event.PR.TargetBranch, pr.TargetBranch)
+	fill(&event.PR.HeadSHA, pr.HeadSHA)
+	fill(&event.PR.BaseSHA, pr.BaseSHA)
+	fill(&event.PR.State, pr.State)
+	fill(&event.PR.URL, pr.URL)
+	if descriptionMissing || event.PR.Description == "" {
+		event.PR.Description = pr.Description
+	}
+	if event.PR.RequestedReviewers == nil {
+		event.PR.RequestedReviewers = pr.RequestedReviewers
+	}
+}
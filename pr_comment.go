@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PRCommentHandler lists a pull request's comments or posts a top-level
+// comment, so downstream analysis results can be written back to the PR
+// through the gateway's credentials. Comments posted this way are remembered
+// as the gateway's own, so their comment events are dropped (see
+// comment_denoise.go).
+//
+//	GET  /pr-comment?platform=github&owner=acme&repo=api&pr=12
+//	POST /pr-comment
+//	{"platform": "github", "owner": "acme", "repo": "api", "pr": 12, "body": "...", "once": true}
+//
+// With "once", the comment is not posted again when the PR already has a
+// comment with the same body.
+func PRCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		PR       int         `json:"pr"`
+		Body     string      `json:"body"`
+		Once     bool        `json:"once"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Platform, req.Owner, req.Repo = SCMPlatform(q.Get("platform")), q.Get("owner"), q.Get("repo")
+		req.PR, _ = strconv.Atoi(q.Get("pr"))
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		if isStandby() {
+			http.Error(w, "a standby deployment does not post comments", http.StatusConflict)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Body == "" {
+			http.Error(w, "owner, repo, pr and body are required", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.PR == 0 {
+		http.Error(w, "owner, repo and pr are required", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet || req.Once {
+		reader, ok := adapter.(PRCommentReader)
+		if !ok {
+			http.Error(w, errUnsupported(adapter, "reading PR comments").Error(), http.StatusNotImplemented)
+			return
+		}
+		comments, err := reader.GetPRComments(req.Owner, req.Repo, req.PR)
+		if err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"status":   "success",
+				"pr":       req.PR,
+				"comments": comments,
+			})
+			return
+		}
+		for _, c := range comments {
+			if c.Path == "" && strings.TrimSpace(c.Body) == strings.TrimSpace(req.Body) {
+				log.Printf("[PRComment] %s %s/%s#%d already has the comment (%s)\n", req.Platform, req.Owner, req.Repo, req.PR, c.ID)
+				writeJSON(w, http.StatusOK, map[string]interface{}{
+					"status": "success",
+					"pr":     req.PR,
+					"posted": false,
+				})
+				return
+			}
+		}
+	}
+
+	writer, ok := adapter.(PRWriter)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "PR comments").Error(), http.StatusNotImplemented)
+		return
+	}
+	if err := writer.PostComment(req.Owner, req.Repo, req.PR, req.Body); err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[PRComment] Posted comment on %s %s/%s#%d\n", req.Platform, req.Owner, req.Repo, req.PR)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"pr":     req.PR,
+		"posted": true,
+	})
+}
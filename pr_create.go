@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// createPRHandler opens a pull request with the gateway's credentials, so
+// downstream services can propose changes as the app. With "from" set, the
+// head branch is created from that ref first.
+//
+//	POST /prs
+//	{"platform": "github", "owner": "acme", "repo": "api", "title": "...",
+//	 "body": "...", "head": "sync/config", "base": "main", "from": "main", "draft": true}
+func createPRHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not open pull requests", http.StatusConflict)
+		return
+	}
+	var req struct {
+		NewPR
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		From     string      `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.Title == "" || req.Head == "" || req.Base == "" {
+		http.Error(w, "owner, repo, title, head and base are required", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writer, ok := adapter.(ContentWriter)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "opening pull requests").Error(), http.StatusNotImplemented)
+		return
+	}
+	if req.From != "" {
+		if err := writer.CreateBranch(req.Owner, req.Repo, req.Head, req.From); err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	number, err := writer.CreatePR(req.Owner, req.Repo, req.NewPR)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[PRs] Opened %s %s/%s#%d from %s into %s\n", req.Platform, req.Owner, req.Repo, number, req.Head, req.Base)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"pr":     number,
+	})
+}
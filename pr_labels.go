@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// titleLabelPattern matches one "[label]" prefix of a PR title.
+var titleLabelPattern = regexp.MustCompile(`^\[([^\[\]]+)\]\s*`)
+
+// titleLabels splits the "[label]" prefixes off a PR title, for platforms
+// that emulate labels in titles (Bitbucket): "[wip] [infra] Fix DNS" has the
+// labels wip and infra and the rest "Fix DNS".
+func titleLabels(title string) (labels []string, rest string) {
+	rest = title
+	for {
+		m := titleLabelPattern.FindStringSubmatch(rest)
+		if m == nil {
+			return labels, rest
+		}
+		labels = append(labels, strings.TrimSpace(m[1]))
+		rest = rest[len(m[0]):]
+	}
+}
+
+// withTitleLabels prefixes rest with labels in "[label]" form.
+func withTitleLabels(labels []string, rest string) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString("[" + l + "] ")
+	}
+	return strings.TrimRight(b.String()+rest, " ")
+}
+
+// PRLabelsHandler lists (GET), adds (POST) or removes (DELETE) the labels of
+// a pull request with the gateway's credentials.
+//
+//	GET    /pr-labels?owner=acme&repo=api&pr=12[&platform=github]
+//	POST   /pr-labels  {"platform": "github", "owner": "acme", "repo": "api", "pr": 12, "labels": ["bug"]}
+//	DELETE /pr-labels?owner=acme&repo=api&pr=12&label=bug[&platform=github]
+func PRLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		PR       int         `json:"pr"`
+		Labels   []string    `json:"labels"`
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodDelete:
+		q := r.URL.Query()
+		req.Platform, req.Owner, req.Repo = SCMPlatform(q.Get("platform")), q.Get("owner"), q.Get("repo")
+		req.PR, _ = strconv.Atoi(q.Get("pr"))
+		if label := q.Get("label"); label != "" {
+			req.Labels = []string{label}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	write := r.Method != http.MethodGet
+	if write {
+		if !requireAdmin(w, r) {
+			return
+		}
+		if isStandby() {
+			http.Error(w, "a standby deployment does not change labels", http.StatusConflict)
+			return
+		}
+	}
+	if req.Owner == "" || req.Repo == "" || req.PR == 0 || (write && len(req.Labels) == 0) {
+		http.Error(w, "owner, repo, pr and the labels to change are required", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	labeler, ok := adapter.(PRLabeler)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "PR labels").Error(), http.StatusNotImplemented)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		err = labeler.AddLabels(req.Owner, req.Repo, req.PR, req.Labels)
+	case http.MethodDelete:
+		err = labeler.RemoveLabel(req.Owner, req.Repo, req.PR, req.Labels[0])
+	}
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	labels, err := labeler.GetPRLabels(req.Owner, req.Repo, req.PR)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if write {
+		log.Printf("[PRLabels] %s %s/%s#%d now has labels %v\n", req.Platform, req.Owner, req.Repo, req.PR, labels)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"pr":     req.PR,
+		"labels": labels,
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// PRMergeHandler merges a pull request with the gateway's credentials, so
+// downstream automation can merge PRs it has approved. Without "sha" the
+// current head is merged; with it the merge fails if the head has moved.
+//
+//	POST /pr-merge
+//	{"platform": "github", "owner": "acme", "repo": "api", "pr": 12, "method": "squash", "sha": "..."}
+func PRMergeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not merge pull requests", http.StatusConflict)
+		return
+	}
+	var req struct {
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		PR       int         `json:"pr"`
+		Method   string      `json:"method"`
+		SHA      string      `json:"sha"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.PR == 0 {
+		http.Error(w, "owner, repo and pr are required", http.StatusBadRequest)
+		return
+	}
+	switch req.Method {
+	case "":
+		req.Method = "merge"
+	case "merge", "squash", "rebase":
+	default:
+		http.Error(w, "method must be merge, squash or rebase", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	merger, ok := adapter.(PRMerger)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "merging pull requests").Error(), http.StatusNotImplemented)
+		return
+	}
+	if req.SHA == "" {
+		state, err := merger.GetMergeState(req.Owner, req.Repo, req.PR)
+		if err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if !state.Open {
+			http.Error(w, "pull request is not open", http.StatusConflict)
+			return
+		}
+		req.SHA = state.HeadSHA
+	}
+	if err := merger.MergePR(req.Owner, req.Repo, req.PR, req.SHA, req.Method); err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[PRMerge] Merged %s %s/%s#%d at %s (%s)\n", req.Platform, req.Owner, req.Repo, req.PR, req.SHA, req.Method)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"pr":     req.PR,
+		"sha":    req.SHA,
+		"method": req.Method,
+	})
+}
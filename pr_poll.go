@@ -0,0 +1,43 @@
+package main
+
+// Adaptive PR polling — the "pr_snapshot_refresh" job runs every minute but
+// only lists the open PRs of repos whose poll is due, so API usage follows
+// webhook health instead of the repo count:
+//
+//	PR_POLL_MIN_SECONDS     shortest interval, for repos whose webhooks have
+//	                        gone silent or missed changes (default 300)
+//	PR_POLL_MAX_SECONDS     longest interval, for repos whose webhooks are
+//	                        flowing (default 21600)
+//	PR_POLL_QUIET_SECONDS   how long without a delivery before a repo's
+//	                        webhooks count as silent (default 3600)
+//
+// A repo with a delivery inside the quiet window is polled at the maximum
+// interval, as a safety net only. A silent repo is polled at the minimum
+// interval, doubling after every poll that finds nothing the webhooks missed,
+// since a silent repo is often just an idle one. A poll that corrects the
+// view resets the repo to the minimum, whatever its webhook state.
+
+import "time"
+
+// nextPollInterval returns how long to wait before polling rec again, given
+// how many PRs the last poll corrected.
+func nextPollInterval(rec RepoRecord, drift int) time.Duration {
+	minInterval := time.Duration(envInt("PR_POLL_MIN_SECONDS", 300)) * time.Second
+	maxInterval := time.Duration(envInt("PR_POLL_MAX_SECONDS", 21600)) * time.Second
+	quiet := time.Duration(envInt("PR_POLL_QUIET_SECONDS", 3600)) * time.Second
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+
+	switch {
+	case drift > 0:
+		return minInterval
+	case !rec.LastDeliveryAt.IsZero() && time.Since(rec.LastDeliveryAt) < quiet:
+		return maxInterval
+	}
+	interval := time.Duration(rec.PollIntervalSeconds) * time.Second * 2
+	if interval < minInterval {
+		interval = minInterval
+	}
+	return min(interval, maxInterval)
+}
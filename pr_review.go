@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// PRReviewHandler submits a review verdict on a pull request, so the Platform
+// BE can push its approval or change request back through the gateway's
+// credentials (ReviewCreator: a GitHub review event, a Bitbucket participant
+// approval or change request).
+//
+//	POST /pr-review
+//	{"platform": "github", "owner": "acme", "repo": "api", "pr": 12,
+//	 "event": "approve", "body": "LGTM", "comments": [{"path": "a.go", "line": 3, "body": "..."}]}
+//
+// event is "approve", "request_changes" or "comment". A change request needs
+// a body or comments saying what to change.
+func PRReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not review PRs", http.StatusConflict)
+		return
+	}
+	var req struct {
+		Platform SCMPlatform     `json:"platform"`
+		Owner    string          `json:"owner"`
+		Repo     string          `json:"repo"`
+		PR       int             `json:"pr"`
+		Event    string          `json:"event"`
+		Body     string          `json:"body"`
+		Comments []ReviewComment `json:"comments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.PR == 0 || req.Event == "" {
+		http.Error(w, "owner, repo, pr and event are required", http.StatusBadRequest)
+		return
+	}
+	switch req.Event {
+	case ReviewEventApprove:
+	case ReviewEventRequestChanges, ReviewEventComment:
+		if req.Body == "" && len(req.Comments) == 0 {
+			http.Error(w, req.Event+" needs a body or comments", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "event must be approve, request_changes or comment", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	creator, ok := adapter.(ReviewCreator)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "PR reviews").Error(), http.StatusNotImplemented)
+		return
+	}
+	review := PRReview{Body: req.Body, Event: req.Event, Comments: req.Comments}
+	if err := creator.CreateReview(req.Owner, req.Repo, req.PR, review); err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[PRReview] Submitted %s on %s %s/%s#%d\n", req.Event, req.Platform, req.Owner, req.Repo, req.PR)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"pr":     req.PR,
+		"event":  req.Event,
+	})
+}
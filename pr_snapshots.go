@@ -0,0 +1,290 @@
+package main
+
+// PR snapshots — a live view of each repository's pull requests, so UIs can
+// ask for the current state instead of replaying every historical event.
+//
+//	GET /prs[?platform=P][&owner=X&repo=Y][&state=open|closed|all]
+//
+// The view is updated by every normalized event and reconciled by the
+// "pr_snapshot_refresh" job (default every minute), which lists the open PRs
+// of each known repository whose poll is due (see pr_poll.go) through the
+// adapter and closes the ones the SCM no longer reports as open, catching
+// missed webhooks. Closed PRs are
+// kept for PR_SNAPSHOT_CLOSED_HOURS (default 24). The view is held in memory
+// and rebuilt by the refresh job after a restart.
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PRSnapshot is the current state of one pull request.
+type PRSnapshot struct {
+	Platform      SCMPlatform `json:"platform"`
+	Repository    string      `json:"repository"`
+	Number        int         `json:"number"`
+	Title         string      `json:"title"`
+	Author        string      `json:"author"`
+	SourceBranch  string      `json:"source_branch"`
+	TargetBranch  string      `json:"target_branch"`
+	State         string      `json:"state"`
+	URL           string      `json:"url,omitempty"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	LastActiveAt  time.Time   `json:"last_active_at"` // last event seen, or first poll (see stale_prs.go)
+	LastEventID   string      `json:"last_event_id,omitempty"`
+	LastEventType string      `json:"last_event_type,omitempty"`
+	Source        string      `json:"source"` // "event" or "poll"
+}
+
+// prSnapshotStore is a goroutine-safe map of PR snapshots.
+type prSnapshotStore struct {
+	mu  sync.RWMutex
+	prs map[string]*PRSnapshot // by prSnapshotKey
+}
+
+var (
+	prSnapshotsOnce sync.Once
+	prSnapshotView  *prSnapshotStore
+)
+
+// prSnapshots returns the package-level snapshot store.
+func prSnapshots() *prSnapshotStore {
+	prSnapshotsOnce.Do(func() {
+		prSnapshotView = &prSnapshotStore{prs: map[string]*PRSnapshot{}}
+	})
+	return prSnapshotView
+}
+
+func prSnapshotKey(platform SCMPlatform, fullName string, number int) string {
+	return fmt.Sprintf("%s#%d", registryKey(platform, strings.ToLower(fullName)), number)
+}
+
+// snapshotFromPR fills snap's PR fields from pr.
+func snapshotFromPR(snap *PRSnapshot, pr NormalizedPR) {
+	snap.Title = pr.Title
+	snap.Author = pr.Author
+	snap.SourceBranch = pr.SourceBranch
+	snap.TargetBranch = pr.TargetBranch
+	snap.URL = pr.URL
+	if pr.State != "" {
+		snap.State = pr.State
+	}
+}
+
+// Apply updates the view with a normalized event.
+func (s *prSnapshotStore) Apply(event *NormalizedEvent) {
+	if event.PR.Number == 0 || event.Repository.FullName == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := prSnapshotKey(event.Platform, event.Repository.FullName, event.PR.Number)
+	snap, ok := s.prs[key]
+	if !ok {
+		snap = &PRSnapshot{Platform: event.Platform, Repository: event.Repository.FullName, Number: event.PR.Number, State: "open"}
+		s.prs[key] = snap
+	}
+	snapshotFromPR(snap, event.PR)
+	if event.PR.State == "" && event.Action == "closed" {
+		snap.State = "closed"
+	}
+	snap.UpdatedAt = time.Now()
+	snap.LastActiveAt = snap.UpdatedAt
+	snap.LastEventID = event.EventID
+	snap.LastEventType = event.EventType
+	snap.Source = "event"
+}
+
+// Reconcile replaces the open PRs of one repository with the polled list:
+// listed PRs are upserted, and known open PRs missing from it are closed. It
+// returns how many PRs the view had wrong — opened, closed or retitled
+// without an event saying so.
+func (s *prSnapshotStore) Reconcile(platform SCMPlatform, fullName string, open []NormalizedPR) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	drift := 0
+	listed := map[string]bool{}
+	for _, pr := range open {
+		key := prSnapshotKey(platform, fullName, pr.Number)
+		listed[key] = true
+		snap, ok := s.prs[key]
+		if !ok {
+			snap = &PRSnapshot{Platform: platform, Repository: fullName, Number: pr.Number, LastActiveAt: now}
+			s.prs[key] = snap
+		}
+		if !ok || snap.State != "open" || snap.Title != pr.Title || snap.SourceBranch != pr.SourceBranch || snap.TargetBranch != pr.TargetBranch {
+			drift++
+		}
+		snapshotFromPR(snap, pr)
+		snap.State = "open"
+		snap.UpdatedAt = now
+		snap.Source = "poll"
+	}
+	prefix := registryKey(platform, strings.ToLower(fullName)) + "#"
+	for key, snap := range s.prs {
+		if strings.HasPrefix(key, prefix) && !listed[key] && snap.State == "open" {
+			snap.State = "closed"
+			snap.UpdatedAt = now
+			snap.Source = "poll"
+			drift++
+		}
+	}
+	return drift
+}
+
+// Prune drops PRs closed before cutoff.
+func (s *prSnapshotStore) Prune(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, snap := range s.prs {
+		if snap.State != "open" && snap.UpdatedAt.Before(cutoff) {
+			delete(s.prs, key)
+		}
+	}
+}
+
+// List returns copies of the matching snapshots, sorted by repository and
+// number. Empty filters match everything; state "all" matches every state
+// and "closed" every state but open.
+func (s *prSnapshotStore) List(platform SCMPlatform, fullName, state string) []PRSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := []PRSnapshot{}
+	for _, snap := range s.prs {
+		if platform != "" && snap.Platform != platform {
+			continue
+		}
+		if fullName != "" && !strings.EqualFold(snap.Repository, fullName) {
+			continue
+		}
+		if state != "all" && (state == "open") != (snap.State == "open") {
+			continue
+		}
+		out = append(out, *snap)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Repository != out[j].Repository {
+			return out[i].Repository < out[j].Repository
+		}
+		return out[i].Number < out[j].Number
+	})
+	return out
+}
+
+// repos returns the repositories that have snapshots.
+func (s *prSnapshotStore) repos() map[string]RepoRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := map[string]RepoRecord{}
+	for _, snap := range s.prs {
+		out[registryKey(snap.Platform, strings.ToLower(snap.Repository))] = RepoRecord{Platform: snap.Platform, FullName: snap.Repository}
+	}
+	return out
+}
+
+// refreshPRSnapshots is the "pr_snapshot_refresh" scheduler job: it
+// reconciles every repository known to the view or the repo registry whose
+// poll is due (see pr_poll.go) with the SCM's list of open PRs.
+func refreshPRSnapshots() error {
+	view := prSnapshots()
+	repos := view.repos()
+	for _, rec := range registry.List() {
+		if rec.FullName != appHookKey {
+			repos[registryKey(rec.Platform, strings.ToLower(rec.FullName))] = rec
+		}
+	}
+
+	now := time.Now()
+	failures, polled := 0, 0
+	for _, rec := range repos {
+		owner, name, ok := strings.Cut(rec.FullName, "/")
+		if !ok || registry.IsSuspended(rec.Platform, owner) ||
+			!featureEnabled(FlagPRSnapshotPoll, rec.Platform, rec.FullName, true) ||
+			now.Before(rec.NextPollAt) {
+			continue
+		}
+		polled++
+		adapter, err := NewSCMAdapter(rec.Platform)
+		if err != nil {
+			continue
+		}
+		lister, ok := adapter.(PRLister)
+		if !ok {
+			continue
+		}
+		open, err := lister.ListOpenPRs(owner, name)
+		if err != nil {
+			log.Printf("[PRSnapshots] Warning: could not list open PRs of %s: %v\n", rec.FullName, err)
+			failures++
+			registry.SchedulePoll(rec.Platform, rec.FullName, nextPollInterval(rec, 0))
+			continue
+		}
+		// Polled PRs are subject to the same privacy mode as events.
+		for i := range open {
+			open[i] = anonymizeEvent(&NormalizedEvent{
+				Platform:   rec.Platform,
+				Repository: NormalizedRepository{FullName: rec.FullName, Owner: owner, Name: name},
+				PR:         open[i],
+			}).PR
+		}
+		drift := view.Reconcile(rec.Platform, rec.FullName, open)
+		if drift > 0 {
+			log.Printf("[PRSnapshots] Polling %s corrected %d PR(s) its webhooks did not report\n", rec.FullName, drift)
+		}
+		registry.SchedulePoll(rec.Platform, rec.FullName, nextPollInterval(rec, drift))
+	}
+
+	view.Prune(time.Now().Add(-time.Duration(envInt("PR_SNAPSHOT_CLOSED_HOURS", 24)) * time.Hour))
+	if failures > 0 {
+		return fmt.Errorf("%d of %d polled repositories could not be listed", failures, polled)
+	}
+	return nil
+}
+
+// PRsHandler serves the PR snapshots; POST opens a pull request (see
+// pr_create.go).
+func PRsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		createPRHandler(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	fullName := ""
+	if owner, repo := q.Get("owner"), q.Get("repo"); owner != "" || repo != "" {
+		if owner == "" || repo == "" {
+			http.Error(w, "owner and repo must be given together", http.StatusBadRequest)
+			return
+		}
+		fullName = owner + "/" + repo
+	}
+	state := q.Get("state")
+	switch state {
+	case "":
+		state = "open"
+	case "open", "closed", "all":
+	default:
+		http.Error(w, "state must be open, closed or all", http.StatusBadRequest)
+		return
+	}
+
+	prs := prSnapshots().List(SCMPlatform(strings.ToLower(q.Get("platform"))), fullName, state)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"total":  len(prs),
+		"prs":    prs,
+	})
+}
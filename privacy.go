@@ -0,0 +1,227 @@
+package main
+
+// Privacy mode — for deployments bound by a data processing agreement, author
+// identities can be anonymized and email addresses stripped before an event
+// is logged, stored or delivered. It is enabled per tenant through the
+// "privacy" setting of the repo config (see repo_config.go; an "owner/*" entry
+// covers a whole organization or workspace):
+//
+//	"hash"          identities become "anon-" + SHA-256 of the login, so
+//	                consumers can still match a login they already know
+//	"pseudonymize"  identities become "anon-" + HMAC-SHA256 keyed with
+//	                PRIVACY_PSEUDONYM_KEY, stable but not reversible without
+//	                the key; without the key every identity is "anonymous"
+//
+// Anonymization covers the PR author and, in the raw payload, every account
+// object (anything with a login, nickname, username, account ID or email):
+// its names and string IDs are replaced, numeric IDs and emails removed, and
+// URLs embedding the login rewritten. Repository owners are kept, since they
+// name the tenant. Email addresses are also stripped from the PR title, description, file
+// diff hunks and every string of the raw payload, e.g. Signed-off-by trailers. Automations and
+// reviewers still see the original event; only what leaves the pipeline is
+// anonymized.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	privacyHash         = "hash"
+	privacyPseudonymize = "pseudonymize"
+
+	pseudonymPrefix = "anon-"
+	emailRemoved    = "[email removed]"
+)
+
+// emailPattern matches email addresses in free text.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// identityFields are the account-object fields that identify a person.
+var identityFields = map[string]bool{
+	"login": true, "nickname": true, "username": true, "display_name": true,
+	"name": true, "account_id": true, "uuid": true,
+}
+
+// isAccountObject reports whether m describes a person.
+func isAccountObject(m map[string]interface{}) bool {
+	for _, key := range []string{"login", "nickname", "username", "email", "account_id"} {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymizer rewrites identities for one privacy mode.
+type anonymizer struct {
+	mode string
+	key  []byte
+}
+
+// pseudonym returns the replacement for an identity. It is idempotent, so
+// re-anonymizing a stored event changes nothing.
+func (a anonymizer) pseudonym(identity string) string {
+	if identity == "" || strings.HasPrefix(identity, pseudonymPrefix) || identity == "anonymous" {
+		return identity
+	}
+	normalized := []byte(strings.ToLower(identity))
+	switch {
+	case a.mode == privacyHash:
+		sum := sha256.Sum256(normalized)
+		return pseudonymPrefix + hex.EncodeToString(sum[:8])
+	case len(a.key) > 0:
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write(normalized)
+		return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil)[:8])
+	default:
+		return "anonymous"
+	}
+}
+
+// pseudonyms returns the replacements for a list of identities.
+func (a anonymizer) pseudonyms(identities []string) []string {
+	if identities == nil {
+		return nil
+	}
+	out := make([]string, len(identities))
+	for i, identity := range identities {
+		out[i] = a.pseudonym(identity)
+	}
+	return out
+}
+
+// anonymizeEvent returns the copy of event that may be logged, stored and
+// delivered under the repo's privacy setting: event itself when privacy mode
+// is off. event is not modified, as asynchronous reviewers may still read it.
+func anonymizeEvent(event *NormalizedEvent) *NormalizedEvent {
+	mode := repoConfigFor(event.Platform, event.Repository.FullName).Privacy
+	if mode == "" {
+		return event
+	}
+	if mode != privacyHash && mode != privacyPseudonymize {
+		log.Printf("[Privacy] Warning: unknown privacy mode %q for %s, using %q\n", mode, event.Repository.FullName, privacyPseudonymize)
+		mode = privacyPseudonymize
+	}
+	a := anonymizer{mode: mode, key: []byte(os.Getenv("PRIVACY_PSEUDONYM_KEY"))}
+
+	anon := *event
+	anon.PR.Author = a.pseudonym(event.PR.Author)
+	anon.PR.Assignees = a.pseudonyms(event.PR.Assignees)
+	anon.PR.RequestedReviewers = a.pseudonyms(event.PR.RequestedReviewers)
+	anon.Owners = a.pseudonyms(event.Owners)
+	anon.PR.Title = emailPattern.ReplaceAllString(event.PR.Title, emailRemoved)
+	anon.PR.Description = emailPattern.ReplaceAllString(event.PR.Description, emailRemoved)
+	if event.Thread != nil {
+		thread := *event.Thread
+		thread.Author = a.pseudonym(thread.Author)
+		thread.ResolvedBy = a.pseudonym(thread.ResolvedBy)
+		thread.Body = emailPattern.ReplaceAllString(thread.Body, emailRemoved)
+		anon.Thread = &thread
+	}
+	if event.CanonicalAuthor != nil {
+		anon.CanonicalAuthor = &CanonicalIdentity{ID: a.pseudonym(event.CanonicalAuthor.ID), Source: event.CanonicalAuthor.Source}
+	}
+	if event.Dependencies != nil {
+		anon.Dependencies = make([]PRDependency, len(event.Dependencies))
+		for i, d := range event.Dependencies {
+			d.Title = emailPattern.ReplaceAllString(d.Title, emailRemoved)
+			anon.Dependencies[i] = d
+		}
+	}
+	if event.Files != nil {
+		// Diff hunks quote AUTHORS files, headers and trailers.
+		anon.Files = make([]NormalizedFile, len(event.Files))
+		for i, f := range event.Files {
+			f.Patch = emailPattern.ReplaceAllString(f.Patch, emailRemoved)
+			anon.Files[i] = f
+		}
+	}
+	if event.Commits != nil {
+		anon.Commits = make([]NormalizedCommit, len(event.Commits))
+		for i, c := range event.Commits {
+			c.Author = a.pseudonym(c.Author)
+			c.Message = emailPattern.ReplaceAllString(c.Message, emailRemoved)
+			anon.Commits[i] = c
+		}
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(event.RawPayload, &payload); err != nil {
+		// Never pass on a payload that could not be checked.
+		anon.RawPayload = nil
+		return &anon
+	}
+	if raw, err := json.Marshal(a.payload(payload, "", "")); err == nil {
+		anon.RawPayload = raw
+	} else {
+		anon.RawPayload = nil
+	}
+	return &anon
+}
+
+// payload anonymizes a decoded JSON value; key is the field it was found
+// under and parent the field holding that object.
+func (a anonymizer) payload(v interface{}, parent, key string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if isAccountObject(t) && !(parent == "repository" && key == "owner") {
+			return a.account(t)
+		}
+		for k, child := range t {
+			t[k] = a.payload(child, key, k)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = a.payload(child, parent, key)
+		}
+	case string:
+		return emailPattern.ReplaceAllString(t, emailRemoved)
+	}
+	return v
+}
+
+// account anonymizes an account object: identities are replaced, emails
+// dropped and every other string rewritten to drop the original identities.
+func (a anonymizer) account(m map[string]interface{}) interface{} {
+	replacements := map[string]string{}
+	for k, v := range m {
+		if s, ok := v.(string); ok && identityFields[k] && s != "" {
+			replacements[s] = a.pseudonym(s)
+		}
+		if k == "email" || strings.HasSuffix(k, "_email") || k == "id" || k == "node_id" {
+			delete(m, k)
+		}
+	}
+	var rewrite func(v interface{}) interface{}
+	rewrite = func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, child := range t {
+				t[k] = rewrite(child)
+			}
+		case []interface{}:
+			for i, child := range t {
+				t[i] = rewrite(child)
+			}
+		case string:
+			if r, ok := replacements[t]; ok {
+				return r
+			}
+			for orig, r := range replacements {
+				if len(orig) > 2 {
+					t = strings.ReplaceAll(t, orig, r)
+				}
+			}
+			return emailPattern.ReplaceAllString(t, emailRemoved)
+		}
+		return v
+	}
+	return rewrite(m)
+}
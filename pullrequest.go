@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,31 +17,27 @@ type PRFile struct {
 	Deletions   int    `json:"deletions"`
 	Changes     int    `json:"changes"`
 	PreviousFilename string `json:"previous_filename"` // only set when status = "renamed"
+	Patch       string `json:"patch,omitempty"` // unified diff hunks; absent for binary or very large files
 }
 
 // getPRChangedFiles fetches the list of files changed in a pull request
-func getPRChangedFiles(token string, owner string, repo string, prNumber int) ([]PRFile, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files", owner, repo, prNumber)
+func getPRChangedFiles(ctx context.Context, token string, owner string, repo string, prNumber int) ([]PRFile, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files?per_page=100", owner, repo, prNumber)
 	log.Printf("Fetching PR files from: %s\n", url)
 
-	body, err := makeAuthenticatedRequest(token, "GET", url, nil)
+	var files []PRFile
+	err := paginate(url, githubPages(ctx, token), func(body []byte) (bool, error) {
+		var page []PRFile
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("failed to parse PR files: %w", err)
+		}
+		files = append(files, page...)
+		return true, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch PR files: %w", err)
 	}
 
-	// Check for API error response
-	var apiErr map[string]interface{}
-	if err := json.Unmarshal(body, &apiErr); err == nil {
-		if msg, ok := apiErr["message"]; ok {
-			return nil, fmt.Errorf("GitHub API error: %v", msg)
-		}
-	}
-
-	var files []PRFile
-	if err := json.Unmarshal(body, &files); err != nil {
-		return nil, fmt.Errorf("failed to parse PR files: %w", err)
-	}
-
 	return files, nil
 }
 
@@ -109,7 +106,7 @@ func GetPRFilesHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Step 3: Fetch changed files
 	log.Println("Step 3: Fetching changed files in PR...")
-	files, err := getPRChangedFiles(installationToken, owner, repo, prNumber)
+	files, err := getPRChangedFiles(r.Context(), installationToken, owner, repo, prNumber)
 	if err != nil {
 		log.Println("Error:", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
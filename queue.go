@@ -14,15 +14,28 @@ import (
 const (
 	rawEventsQueue        = "raw_webhook_events"
 	normalizedEventsQueue = "normalized_pr_events"
+	deadLetterQueue       = "dead_letter_events"
 )
 
 // RawWebhookMessage is the message published to the raw events queue by the
 // Webhook Gateway. It carries everything the SCM Adapter needs to process the
 // event without access to the original HTTP request.
 type RawWebhookMessage struct {
-	Platform  SCMPlatform `json:"platform"`
-	EventType string      `json:"event_type"`
-	Payload   []byte      `json:"payload"`
+	EventID    string      `json:"event_id"`
+	DeliveryID string      `json:"delivery_id"`
+	Platform   SCMPlatform `json:"platform"`
+	EventType  string      `json:"event_type"`
+	Payload    []byte      `json:"payload"`
+	Repo       string      `json:"repo,omitempty"` // repository full name, routes the message to its shard
+}
+
+// DeadLetterMessage records a message the pipeline gave up on: an
+// undecodable delivery, or a normalized event a sink refused.
+type DeadLetterMessage struct {
+	Queue    string    `json:"queue"`          // queue (or "sink:<name>") the message failed on
+	Reason   string    `json:"reason"`
+	Body     []byte    `json:"body"`
+	FailedAt time.Time `json:"failed_at"`
 }
 
 // RabbitMQ wraps an AMQP connection and a dedicated publish channel.
@@ -35,12 +48,19 @@ type RabbitMQ struct {
 	pubCh     *amqp.Channel // used exclusively for publishing
 }
 
-// NewRabbitMQ dials the broker at url, opens a dedicated publish channel, and
-// declares the two durable queues the application uses.
+// NewRabbitMQ dials the broker at url (with the TLS, credential and
+// connection options of amqp_config.go), opens a dedicated publish channel,
+// and declares the durable queues the application uses (every shard of the
+// raw and normalized queues, see queue_shards.go, plus the security alert and
+// dead-letter queues).
 func NewRabbitMQ(url string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(url)
+	cfg, err := rabbitmqConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := amqp.DialConfig(url, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("rabbitmq: failed to connect to %s: %w", url, err)
+		return nil, fmt.Errorf("rabbitmq: failed to connect to %s: %w", redactURL(url), err)
 	}
 
 	pubCh, err := conn.Channel()
@@ -58,18 +78,29 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 	return mq, nil
 }
 
-// declareQueues ensures both application queues exist on the broker.
+// declareQueues ensures the application queues exist on the broker.
 // Durable queues survive a broker restart; messages marked Persistent also
-// survive if they were written to disk before the restart.
+// survive if they were written to disk before the restart. Quorum queues
+// (QUEUE_TYPE=quorum) also survive the loss of a cluster node.
 func (mq *RabbitMQ) declareQueues(ch *amqp.Channel) error {
-	for _, name := range []string{rawEventsQueue, normalizedEventsQueue} {
+	var eventArgs amqp.Table
+	if orderedProcessing() {
+		eventArgs = amqp.Table{"x-single-active-consumer": true}
+	}
+	names := append(shardQueues(rawEventsQueue), shardQueues(normalizedEventsQueue)...)
+	for _, name := range append(names, securityAlertsQueue, deadLetterQueue) {
+		args := eventArgs
+		if name == deadLetterQueue {
+			args = nil
+		}
+		args = queueTypeArgs(args) // QUEUE_TYPE, see queue_types.go
 		if _, err := ch.QueueDeclare(
 			name,  // queue name
 			true,  // durable
 			false, // auto-delete when unused
 			false, // exclusive
 			false, // no-wait
-			nil,   // additional arguments
+			args,  // additional arguments
 		); err != nil {
 			return fmt.Errorf("rabbitmq: failed to declare queue %q: %w", name, err)
 		}
@@ -78,7 +109,8 @@ func (mq *RabbitMQ) declareQueues(ch *amqp.Channel) error {
 	return nil
 }
 
-// PublishRawEvent serialises msg as JSON and sends it to the raw events queue.
+// PublishRawEvent serialises msg as JSON and sends it to the raw events queue
+// shard of its repository.
 // Called by the Webhook Gateway immediately after signature verification.
 // The mutex ensures safe concurrent calls from multiple HTTP handler goroutines.
 func (mq *RabbitMQ) PublishRawEvent(msg RawWebhookMessage) error {
@@ -87,6 +119,8 @@ func (mq *RabbitMQ) PublishRawEvent(msg RawWebhookMessage) error {
 		return fmt.Errorf("rabbitmq: failed to marshal raw event: %w", err)
 	}
 
+	queue := shardQueue(rawEventsQueue, shardFor(msg.Platform, msg.Repo))
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -94,13 +128,16 @@ func (mq *RabbitMQ) PublishRawEvent(msg RawWebhookMessage) error {
 	defer mq.publishMu.Unlock()
 
 	if err := mq.pubCh.PublishWithContext(ctx,
-		"",             // default exchange
-		rawEventsQueue, // routing key = queue name
+		"",    // default exchange
+		queue, // routing key = queue name
 		false,          // mandatory
 		false,          // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
 			DeliveryMode: amqp.Persistent, // survive broker restart
+			MessageId:    msg.EventID,
+			Timestamp:    time.Now(),
+			Headers:      rawMessageHeaders(msg), // see queue_headers.go
 			Body:         body,
 		},
 	); err != nil {
@@ -108,12 +145,13 @@ func (mq *RabbitMQ) PublishRawEvent(msg RawWebhookMessage) error {
 	}
 
 	log.Printf("[RabbitMQ] Published raw event (platform=%s, type=%s) to %q\n",
-		msg.Platform, msg.EventType, rawEventsQueue)
+		msg.Platform, msg.EventType, queue)
 	return nil
 }
 
 // PublishNormalizedEvent serialises event as JSON and sends it to the
-// normalized events queue (the "Unified Event Bus" in the sequence diagram).
+// normalized events queue shard of its repository (the "Unified Event Bus" in
+// the sequence diagram).
 // Called by the SCM Adapter consumer after normalization.
 func (mq *RabbitMQ) PublishNormalizedEvent(event *NormalizedEvent) error {
 	body, err := json.Marshal(event)
@@ -121,6 +159,8 @@ func (mq *RabbitMQ) PublishNormalizedEvent(event *NormalizedEvent) error {
 		return fmt.Errorf("rabbitmq: failed to marshal normalized event: %w", err)
 	}
 
+	queue := shardQueue(normalizedEventsQueue, shardFor(event.Platform, event.Repository.FullName))
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -128,13 +168,16 @@ func (mq *RabbitMQ) PublishNormalizedEvent(event *NormalizedEvent) error {
 	defer mq.publishMu.Unlock()
 
 	if err := mq.pubCh.PublishWithContext(ctx,
-		"",                    // default exchange
-		normalizedEventsQueue, // routing key = queue name
+		"",    // default exchange
+		queue, // routing key = queue name
 		false,
 		false,
 		amqp.Publishing{
 			ContentType:  "application/json",
 			DeliveryMode: amqp.Persistent,
+			MessageId:    event.EventID,
+			Timestamp:    time.Now(),
+			Headers:      normalizedMessageHeaders(event),
 			Body:         body,
 		},
 	); err != nil {
@@ -142,42 +185,135 @@ func (mq *RabbitMQ) PublishNormalizedEvent(event *NormalizedEvent) error {
 	}
 
 	log.Printf("[RabbitMQ] Published normalized event (PR #%d) to %q\n",
-		event.PR.Number, normalizedEventsQueue)
+		event.PR.Number, queue)
 	return nil
 }
 
-// ConsumeRawEvents opens a dedicated channel, registers a consumer on the raw
-// events queue, and calls handler for every delivery. Each consumer goroutine
+// PublishSecurityAlert serialises a security.alert event as JSON and sends it
+// to the security alerts queue (see security_alerts.go).
+func (mq *RabbitMQ) PublishSecurityAlert(event *NormalizedEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: failed to marshal security alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mq.publishMu.Lock()
+	defer mq.publishMu.Unlock()
+
+	if err := mq.pubCh.PublishWithContext(ctx,
+		"",
+		securityAlertsQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.EventID,
+			Timestamp:    time.Now(),
+			Headers:      normalizedMessageHeaders(event),
+			Body:         body,
+		},
+	); err != nil {
+		return fmt.Errorf("rabbitmq: failed to publish security alert: %w", err)
+	}
+
+	log.Printf("[RabbitMQ] Published security alert (%s) to %q\n", event.Security.Source, securityAlertsQueue)
+	return nil
+}
+
+// PublishDeadLetter sends msg to the dead-letter queue, where it stays for
+// inspection; nothing consumes it automatically.
+func (mq *RabbitMQ) PublishDeadLetter(msg DeadLetterMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: failed to marshal dead letter: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mq.publishMu.Lock()
+	defer mq.publishMu.Unlock()
+
+	if err := mq.pubCh.PublishWithContext(ctx,
+		"",
+		deadLetterQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	); err != nil {
+		return fmt.Errorf("rabbitmq: failed to publish dead letter: %w", err)
+	}
+
+	log.Printf("[RabbitMQ] Dead-lettered message from %s: %s\n", msg.Queue, msg.Reason)
+	return nil
+}
+
+// deadLetter is PublishDeadLetter for callers that can only log a failure.
+func (mq *RabbitMQ) deadLetter(queue string, reason error, body []byte) {
+	msg := DeadLetterMessage{Queue: queue, Reason: reason.Error(), Body: body, FailedAt: time.Now()}
+	if err := mq.PublishDeadLetter(msg); err != nil {
+		log.Printf("[RabbitMQ] Warning: %v\n", err)
+	}
+}
+
+// QueueDepth returns the number of ready messages in the named queue.
+// It uses a short-lived channel because a passive declare on a missing
+// queue closes the channel it was issued on.
+func (mq *RabbitMQ) QueueDepth(name string) (int, error) {
+	ch, err := mq.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("rabbitmq: failed to open inspect channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(name, true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("rabbitmq: failed to inspect queue %q: %w", name, err)
+	}
+	return q.Messages, nil
+}
+
+// ConsumeRawEvents opens a dedicated channel, registers a consumer on queue
+// (one shard of the raw events queue), and calls handler for every delivery. Each consumer goroutine
 // gets its own channel so it never races with the publish channel or the other
 // consumer goroutine.
 //
 // This method blocks until the channel is closed; run it in a goroutine.
-func (mq *RabbitMQ) ConsumeRawEvents(handler func(RawWebhookMessage)) error {
+func (mq *RabbitMQ) ConsumeRawEvents(queue string, handler func(RawWebhookMessage)) error {
 	ch, err := mq.conn.Channel()
 	if err != nil {
-		return fmt.Errorf("rabbitmq: failed to open consumer channel for %q: %w", rawEventsQueue, err)
+		return fmt.Errorf("rabbitmq: failed to open consumer channel for %q: %w", queue, err)
 	}
 	defer ch.Close()
 
 	deliveries, err := ch.Consume(
-		rawEventsQueue, // queue
-		"",             // consumer tag (auto-generated)
-		false,          // auto-ack disabled — we ack manually
-		false,          // exclusive
-		false,          // no-local
-		false,          // no-wait
-		nil,            // arguments
+		queue, // queue
+		"",    // consumer tag (auto-generated)
+		false, // auto-ack disabled — we ack manually
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("rabbitmq: failed to register consumer on %q: %w", rawEventsQueue, err)
+		return fmt.Errorf("rabbitmq: failed to register consumer on %q: %w", queue, err)
 	}
 
-	log.Printf("[RabbitMQ] Consumer started, listening on queue %q\n", rawEventsQueue)
+	log.Printf("[RabbitMQ] Consumer started, listening on queue %q\n", queue)
 
 	for d := range deliveries {
 		var msg RawWebhookMessage
 		if err := json.Unmarshal(d.Body, &msg); err != nil {
 			log.Printf("[RabbitMQ] Warning: could not decode delivery, discarding: %v\n", err)
+			mq.deadLetter(queue, err, d.Body)
 			d.Nack(false, false) // discard; requeue=false avoids poison-message loop
 			continue
 		}
@@ -189,36 +325,37 @@ func (mq *RabbitMQ) ConsumeRawEvents(handler func(RawWebhookMessage)) error {
 }
 
 // ConsumeNormalizedEvents opens a dedicated channel, registers a consumer on
-// the normalized events queue, and calls handler for every delivery. Mirrors
-// ConsumeRawEvents but operates on the normalizedEventsQueue.
+// queue (one shard of the normalized events queue), and calls handler for
+// every delivery. Mirrors ConsumeRawEvents.
 //
 // This method blocks until the channel is closed; run it in a goroutine.
-func (mq *RabbitMQ) ConsumeNormalizedEvents(handler func(*NormalizedEvent)) error {
+func (mq *RabbitMQ) ConsumeNormalizedEvents(queue string, handler func(*NormalizedEvent)) error {
 	ch, err := mq.conn.Channel()
 	if err != nil {
-		return fmt.Errorf("rabbitmq: failed to open consumer channel for %q: %w", normalizedEventsQueue, err)
+		return fmt.Errorf("rabbitmq: failed to open consumer channel for %q: %w", queue, err)
 	}
 	defer ch.Close()
 
 	deliveries, err := ch.Consume(
-		normalizedEventsQueue, // queue
-		"",                    // consumer tag (auto-generated)
-		false,                 // auto-ack disabled — we ack manually
-		false,                 // exclusive
-		false,                 // no-local
-		false,                 // no-wait
-		nil,                   // arguments
+		queue, // queue
+		"",    // consumer tag (auto-generated)
+		false, // auto-ack disabled — we ack manually
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("rabbitmq: failed to register consumer on %q: %w", normalizedEventsQueue, err)
+		return fmt.Errorf("rabbitmq: failed to register consumer on %q: %w", queue, err)
 	}
 
-	log.Printf("[RabbitMQ] Consumer started, listening on queue %q\n", normalizedEventsQueue)
+	log.Printf("[RabbitMQ] Consumer started, listening on queue %q\n", queue)
 
 	for d := range deliveries {
 		var event NormalizedEvent
 		if err := json.Unmarshal(d.Body, &event); err != nil {
 			log.Printf("[RabbitMQ] Warning: could not decode normalized event, discarding: %v\n", err)
+			mq.deadLetter(queue, err, d.Body)
 			d.Nack(false, false) // discard; requeue=false avoids poison-message loop
 			continue
 		}
@@ -0,0 +1,72 @@
+package main
+
+// AMQP message headers — published messages carry their routing metadata as
+// headers, so operators can bind header exchanges or inspect messages in the
+// management UI without decoding bodies:
+//
+//	x-platform        github, bitbucket, gerrit
+//	x-event-type      raw: the SCM's event type; normalized: e.g. pull_request.opened
+//	x-action          normalized only, e.g. synchronize
+//	x-repo            repository full name
+//	x-pr-number       normalized only
+//	x-delivery-id     the SCM's delivery ID
+//	x-event-id        the gateway's event ID (also the AMQP message ID)
+//	x-schema-version  normalized only
+//	traceparent       W3C trace context; the trace ID is the event ID
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// traceparent returns a W3C traceparent whose trace ID is eventID, or "" if
+// eventID is not a 32-digit hex ID.
+func traceparent(eventID string) string {
+	if len(eventID) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(eventID); err != nil {
+		return ""
+	}
+	span := make([]byte, 8)
+	rand.Read(span)
+	return "00-" + eventID + "-" + hex.EncodeToString(span) + "-01"
+}
+
+// setHeader adds a string header unless value is empty.
+func setHeader(h amqp.Table, key, value string) {
+	if value != "" {
+		h[key] = value
+	}
+}
+
+// rawMessageHeaders returns the headers of a raw webhook message.
+func rawMessageHeaders(msg RawWebhookMessage) amqp.Table {
+	h := amqp.Table{}
+	setHeader(h, "x-platform", string(msg.Platform))
+	setHeader(h, "x-event-type", msg.EventType)
+	setHeader(h, "x-repo", msg.Repo)
+	setHeader(h, "x-delivery-id", msg.DeliveryID)
+	setHeader(h, "x-event-id", msg.EventID)
+	setHeader(h, "traceparent", traceparent(msg.EventID))
+	return h
+}
+
+// normalizedMessageHeaders returns the headers of a normalized event message.
+func normalizedMessageHeaders(event *NormalizedEvent) amqp.Table {
+	h := amqp.Table{}
+	setHeader(h, "x-platform", string(event.Platform))
+	setHeader(h, "x-event-type", event.EventType)
+	setHeader(h, "x-action", event.Action)
+	setHeader(h, "x-repo", event.Repository.FullName)
+	if event.PR.Number != 0 {
+		h["x-pr-number"] = int64(event.PR.Number)
+	}
+	setHeader(h, "x-delivery-id", event.DeliveryID)
+	setHeader(h, "x-event-id", event.EventID)
+	h["x-schema-version"] = int64(event.SchemaVersion)
+	setHeader(h, "traceparent", traceparent(event.EventID))
+	return h
+}
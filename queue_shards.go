@@ -0,0 +1,135 @@
+package main
+
+// Queue sharding — with QUEUE_SHARDS=N (N > 1) the raw and normalized event
+// queues are split into N queues each ("raw_webhook_events.0" …), every shard
+// with its own consumer. Events are routed by a hash of the repository, so a
+// burst from one hot repository only backs up its own shard instead of
+// head-of-line blocking every other repository.
+//
+// Changing the shard count re-routes repositories: drain the queues (or
+// accept that events already queued under the old layout are processed by
+// nobody until the count is changed back) before resizing.
+//
+// Ordering — events of one repository (and so of one PR) always take the same
+// path: the same ingest worker, the same shard and that shard's consumer,
+// which handles one message at a time. What is left is several replicas
+// consuming the same shard concurrently; ORDERED_PROCESSING=true declares the
+// queues with RabbitMQ's single-active-consumer flag, so only one replica
+// consumes each shard at a time (the others take over if it goes away) and
+// downstream never sees, say, closed before synchronize. Shard to keep the
+// parallelism. Queue arguments cannot change on an existing queue, so drain
+// and delete the queues before switching the flag either way.
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+)
+
+// queueShards returns the configured number of shards per queue.
+func queueShards() int {
+	if n := envInt("QUEUE_SHARDS", 1); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// shardQueue returns the name of one shard of base. Without sharding it is
+// base itself, so unsharded deployments keep their queue names.
+func shardQueue(base string, shard int) string {
+	if queueShards() == 1 {
+		return base
+	}
+	return fmt.Sprintf("%s.%d", base, shard)
+}
+
+// shardQueues returns every shard of base.
+func shardQueues(base string) []string {
+	names := make([]string, queueShards())
+	for i := range names {
+		names[i] = shardQueue(base, i)
+	}
+	return names
+}
+
+// orderedProcessing reports whether the event queues are declared with a
+// single active consumer (ORDERED_PROCESSING=true).
+func orderedProcessing() bool {
+	return os.Getenv("ORDERED_PROCESSING") == "true"
+}
+
+// repoHash is the routing hash of a repository.
+func repoHash(platform SCMPlatform, repo string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(string(platform) + ":" + strings.ToLower(repo)))
+	return h.Sum32()
+}
+
+// shardFor returns the shard events of repo are routed to.
+func shardFor(platform SCMPlatform, repo string) int {
+	n := queueShards()
+	if n == 1 {
+		return 0
+	}
+	return int(repoHash(platform, repo) % uint32(n))
+}
+
+// payloadRepoFullName returns the repository a raw webhook belongs to, or ""
+// if the payload does not name one.
+func payloadRepoFullName(platform SCMPlatform, payload []byte) string {
+	var p struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		Change struct {
+			Project string `json:"project"`
+		} `json:"change"`
+		PullRequest struct {
+			ToRef bbsRef `json:"toRef"`
+		} `json:"pullRequest"`
+		Account string `json:"account"`
+		Detail  struct {
+			RepositoryNames []string `json:"repositoryNames"`
+		} `json:"detail"`
+	}
+	if json.Unmarshal(payload, &p) != nil {
+		return ""
+	}
+	if platform == PlatformBitbucketServer {
+		if repo := p.PullRequest.ToRef.Repository; repo.Slug != "" {
+			return repo.Project.Key + "/" + repo.Slug
+		}
+		return ""
+	}
+	if platform == PlatformGerrit {
+		return p.Change.Project
+	}
+	if platform == PlatformCodeCommit {
+		if len(p.Detail.RepositoryNames) > 0 {
+			return p.Account + "/" + p.Detail.RepositoryNames[0]
+		}
+		return ""
+	}
+	return p.Repository.FullName
+}
+
+// superviseShards runs a supervised consumer (see superviseConsumer) for
+// every shard of base, named name or "name[i]" when sharded. It blocks
+// forever; call it in a goroutine.
+func superviseShards(name, base string, consume func(queue string) error) {
+	queues := shardQueues(base)
+	for i, queue := range queues {
+		label := name
+		if len(queues) > 1 {
+			label = fmt.Sprintf("%s[%d]", name, i)
+		}
+		run := func() error { return consume(queue) }
+		if i < len(queues)-1 {
+			go superviseConsumer(label, run)
+		} else {
+			superviseConsumer(label, run)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+// Queue types — QUEUE_TYPE picks how the application queues are declared:
+//
+//	classic   (default) classic durable queues
+//	quorum    replicated quorum queues, which survive the loss of a node in a
+//	          clustered broker
+//	lazy      classic queues that keep messages on disk rather than in memory,
+//	          for long backlogs
+//
+// The type of an existing queue cannot change: a redeclare with different
+// arguments fails with PRECONDITION_FAILED. Drain and delete the queues (or
+// migrate them with the broker's tooling) before switching.
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// queueTypeArgs returns the declare arguments for QUEUE_TYPE, merged into
+// base (which is not modified).
+func queueTypeArgs(base amqp.Table) amqp.Table {
+	args := amqp.Table{}
+	for k, v := range base {
+		args[k] = v
+	}
+	switch queueType := strings.ToLower(os.Getenv("QUEUE_TYPE")); queueType {
+	case "", "classic":
+	case "quorum":
+		args["x-queue-type"] = "quorum"
+	case "lazy":
+		args["x-queue-mode"] = "lazy"
+	default:
+		log.Printf("[RabbitMQ] Warning: unknown QUEUE_TYPE %q, declaring classic queues\n", queueType)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
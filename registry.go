@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRegistryMaxRepos bounds the registry unless REGISTRY_MAX_REPOS is
+// set.
+const defaultRegistryMaxRepos = 50000
+
+// appHookKey is the registry key used for hooks that are not tied to a single
+// repository (GitHub App / organization hooks, Bitbucket diagnostics pings).
+const appHookKey = "*"
+
+// RepoRecord is what the gateway knows about a single repository's webhook.
+type RepoRecord struct {
+	Platform     SCMPlatform `json:"platform"`
+	FullName     string      `json:"full_name"`
+	HookID       int64       `json:"hook_id,omitempty"`
+	HookVerified bool        `json:"hook_verified"`
+	VerifiedAt   time.Time   `json:"verified_at,omitempty"`
+	PingCount    int         `json:"ping_count"`
+
+	// Suspended is set while the app installation covering the repo's
+	// owner is suspended (see installations.go).
+	Suspended bool `json:"suspended"`
+
+	// LastDeliveryAt is when the repo's last webhook delivery arrived;
+	// NextPollAt and PollIntervalSeconds are the adaptive PR polling
+	// schedule derived from it (see pr_poll.go).
+	LastDeliveryAt      time.Time `json:"last_delivery_at,omitempty"`
+	NextPollAt          time.Time `json:"next_poll_at,omitempty"`
+	PollIntervalSeconds int       `json:"poll_interval_seconds,omitempty"`
+}
+
+// InstallationSuspension is a suspended app installation, by account.
+type InstallationSuspension struct {
+	Platform       SCMPlatform `json:"platform"`
+	Account        string      `json:"account"`
+	InstallationID int64       `json:"installation_id,omitempty"`
+	SuspendedAt    time.Time   `json:"suspended_at"`
+	SuspendedBy    string      `json:"suspended_by,omitempty"`
+}
+
+// RepoRegistry is an in-memory, goroutine-safe registry of repositories seen
+// by the gateway, keyed by platform and full name.
+type RepoRegistry struct {
+	mu        sync.RWMutex
+	repos     map[string]*RepoRecord
+	suspended map[string]InstallationSuspension // by registryKey(platform, account)
+}
+
+// registry is the package-level repo registry shared by the webhook handler
+// and the admin endpoints.
+var registry = NewRepoRegistry()
+
+// NewRepoRegistry returns an empty RepoRegistry.
+func NewRepoRegistry() *RepoRegistry {
+	return &RepoRegistry{repos: make(map[string]*RepoRecord), suspended: make(map[string]InstallationSuspension)}
+}
+
+func registryKey(platform SCMPlatform, fullName string) string {
+	return string(platform) + ":" + fullName
+}
+
+// record returns the record for (platform, fullName), creating it if needed.
+// Callers must hold r.mu for writing.
+func (r *RepoRegistry) record(platform SCMPlatform, fullName string) *RepoRecord {
+	key := registryKey(platform, fullName)
+	rec, ok := r.repos[key]
+	if !ok {
+		if len(r.repos) >= envInt("REGISTRY_MAX_REPOS", defaultRegistryMaxRepos) {
+			r.evictIdlest()
+		}
+		rec = &RepoRecord{Platform: platform, FullName: fullName}
+		r.repos[key] = rec
+	}
+	return rec
+}
+
+// evictIdlest drops the record that has gone longest without a delivery or
+// ping, keeping app-wide hooks. Callers must hold r.mu for writing.
+func (r *RepoRegistry) evictIdlest() {
+	var idlest string
+	var idlestAt time.Time
+	for key, rec := range r.repos {
+		if rec.FullName == appHookKey {
+			continue
+		}
+		at := rec.LastDeliveryAt
+		if rec.VerifiedAt.After(at) {
+			at = rec.VerifiedAt
+		}
+		if idlest == "" || at.Before(idlestAt) {
+			idlest, idlestAt = key, at
+		}
+	}
+	if idlest != "" {
+		delete(r.repos, idlest)
+		metrics.registryEvictions.Add(1)
+	}
+}
+
+// Len returns the number of registered repos.
+func (r *RepoRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.repos)
+}
+
+// Register adds the repo to the registry, e.g. before its first webhook.
+func (r *RepoRegistry) Register(platform SCMPlatform, fullName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record(platform, fullName)
+}
+
+// MarkHookVerified records a successful ping / test delivery for the repo's
+// webhook.
+func (r *RepoRegistry) MarkHookVerified(platform SCMPlatform, fullName string, hookID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec := r.record(platform, fullName)
+	if hookID != 0 {
+		rec.HookID = hookID
+	}
+	rec.HookVerified = true
+	rec.VerifiedAt = time.Now()
+	rec.PingCount++
+}
+
+// MarkDelivery records that a webhook delivery for the repo arrived.
+func (r *RepoRegistry) MarkDelivery(platform SCMPlatform, fullName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record(platform, fullName).LastDeliveryAt = time.Now()
+}
+
+// SchedulePoll sets when the repo's open PRs are polled next.
+func (r *RepoRegistry) SchedulePoll(platform SCMPlatform, fullName string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.record(platform, fullName)
+	rec.PollIntervalSeconds = int(interval / time.Second)
+	rec.NextPollAt = time.Now().Add(interval)
+}
+
+// SetSuspended records that the installation for account was suspended.
+func (r *RepoRegistry) SetSuspended(s InstallationSuspension) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.suspended[registryKey(s.Platform, strings.ToLower(s.Account))] = s
+}
+
+// ClearSuspended records that the installation for account was resumed and
+// reports whether it had been suspended.
+func (r *RepoRegistry) ClearSuspended(platform SCMPlatform, account string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(platform, strings.ToLower(account))
+	_, ok := r.suspended[key]
+	delete(r.suspended, key)
+	return ok
+}
+
+// IsSuspended reports whether the installation covering owner is suspended.
+func (r *RepoRegistry) IsSuspended(platform SCMPlatform, owner string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.suspended[registryKey(platform, strings.ToLower(owner))]
+	return ok
+}
+
+// Suspensions returns the suspended installations, sorted by account.
+func (r *RepoRegistry) Suspensions() []InstallationSuspension {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]InstallationSuspension, 0, len(r.suspended))
+	for _, s := range r.suspended {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Account < out[j].Account })
+	return out
+}
+
+// Get returns a copy of the record for (platform, fullName).
+func (r *RepoRegistry) Get(platform SCMPlatform, fullName string) (RepoRecord, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rec, ok := r.repos[registryKey(platform, fullName)]
+	if !ok {
+		return RepoRecord{}, false
+	}
+	return *rec, true
+}
+
+// List returns a copy of every record, sorted by platform and full name.
+func (r *RepoRegistry) List() []RepoRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]RepoRecord, 0, len(r.repos))
+	for _, rec := range r.repos {
+		c := *rec
+		owner, _, _ := strings.Cut(rec.FullName, "/")
+		_, c.Suspended = r.suspended[registryKey(rec.Platform, strings.ToLower(owner))]
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Platform != out[j].Platform {
+			return out[i].Platform < out[j].Platform
+		}
+		return out[i].FullName < out[j].FullName
+	})
+	return out
+}
+
+// ReposHandler exposes the repo registry as JSON.
+func ReposHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repos := registry.List()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                  "success",
+		"total":                   len(repos),
+		"repos":                   repos,
+		"suspended_installations": registry.Suspensions(),
+	})
+}
@@ -0,0 +1,420 @@
+package main
+
+// Release notes drafts.
+//
+// A draft lists the PRs merged since the previous release, taken from the
+// event store, grouped into sections by the Conventional Commit type of
+// their title (`feat: …` → Features) or, failing that, by their labels
+// (bug → Bug Fixes). It is served at
+//
+//	GET /release-notes?owner=X&repo=Y[&tag=T][&since=RFC3339][&platform=github]
+//
+// and, for repos with "release_notes": {"post": true} in REPO_CONFIG_FILE,
+// written to the release through the adapter (ReleaseWriter): a GitHub tag
+// push gets a draft release carrying the notes, and a published release
+// without notes gets them filled in. Notes already written by someone are
+// never replaced.
+//
+// The event store is bounded (EVENT_STORE_MAX), so a release that spans
+// more merged PRs than it keeps gets a partial draft.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReleaseNotesPolicy is the per-repo configuration of release notes.
+type ReleaseNotesPolicy struct {
+	// Post writes drafts to the release on tag pushes and release.published.
+	Post bool `json:"post"`
+	// Sections maps Conventional Commit types and labels to section
+	// titles, on top of defaultReleaseSections.
+	Sections map[string]string `json:"sections,omitempty"`
+}
+
+const (
+	breakingChangesSection = "Breaking Changes"
+	otherChangesSection    = "Other Changes"
+)
+
+// defaultReleaseSections maps Conventional Commit types and common labels to
+// section titles.
+var defaultReleaseSections = map[string]string{
+	"feat":            "Features",
+	"fix":             "Bug Fixes",
+	"perf":            "Performance",
+	"refactor":        "Refactoring",
+	"docs":            "Documentation",
+	"build":           "Build and CI",
+	"ci":              "Build and CI",
+	"test":            "Tests",
+	"chore":           "Maintenance",
+	"style":           "Maintenance",
+	"revert":          "Reverts",
+	"enhancement":     "Features",
+	"feature":         "Features",
+	"bug":             "Bug Fixes",
+	"documentation":   "Documentation",
+	"dependencies":    "Dependencies",
+	"breaking-change": breakingChangesSection,
+}
+
+// releaseSectionOrder is the order sections are listed in; other sections
+// follow alphabetically, then otherChangesSection.
+var releaseSectionOrder = []string{
+	breakingChangesSection, "Features", "Bug Fixes", "Performance", "Refactoring",
+	"Documentation", "Dependencies", "Build and CI", "Tests", "Maintenance", "Reverts",
+}
+
+// ReleaseNotesEntry is one merged PR of a draft.
+type ReleaseNotesEntry struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	URL    string `json:"url"`
+}
+
+// ReleaseNotesSection is a group of merged PRs.
+type ReleaseNotesSection struct {
+	Title        string              `json:"title"`
+	PullRequests []ReleaseNotesEntry `json:"pull_requests"`
+}
+
+// ReleaseNotesDraft is the draft notes of a release.
+type ReleaseNotesDraft struct {
+	Repository  string                `json:"repository"`
+	Tag         string                `json:"tag,omitempty"`
+	PreviousTag string                `json:"previous_tag,omitempty"`
+	Since       time.Time             `json:"since"`
+	Count       int                   `json:"count"`
+	Sections    []ReleaseNotesSection `json:"sections"`
+	Markdown    string                `json:"markdown"`
+}
+
+// mergedPR is a PR merged according to the event store.
+type mergedPR struct {
+	pr       NormalizedPR
+	labels   []string
+	mergedAt time.Time
+}
+
+// prMerged reports whether event records the merge of its PR. GitHub
+// reports merged PRs as closed, so its payload's "merged" flag is checked.
+func prMerged(event *NormalizedEvent) bool {
+	if event.PR.State == "merged" || event.EventType == EventTypeAutoMerged {
+		return true
+	}
+	if event.Action != "closed" {
+		return false
+	}
+	var p struct {
+		PullRequest struct {
+			Merged bool `json:"merged"`
+		} `json:"pull_request"`
+	}
+	return json.Unmarshal(event.RawPayload, &p) == nil && p.PullRequest.Merged
+}
+
+// payloadLabels returns the label names of the PR in a GitHub or Gitea
+// payload.
+func payloadLabels(payload []byte) []string {
+	var p struct {
+		PullRequest struct {
+			Labels []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"pull_request"`
+	}
+	if json.Unmarshal(payload, &p) != nil {
+		return nil
+	}
+	labels := make([]string, 0, len(p.PullRequest.Labels))
+	for _, l := range p.PullRequest.Labels {
+		labels = append(labels, l.Name)
+	}
+	return labels
+}
+
+// mergedPRsSince returns the PRs of a repository merged at or after since,
+// oldest first.
+func mergedPRsSince(platform SCMPlatform, fullName string, since time.Time) []mergedPR {
+	byNumber := map[int]mergedPR{}
+	for _, stored := range store().Since(since) {
+		event := stored.Event
+		if event == nil || event.Platform != platform || !strings.EqualFold(event.Repository.FullName, fullName) || !prMerged(event) {
+			continue
+		}
+		merged := mergedPR{pr: event.PR, labels: payloadLabels(event.RawPayload), mergedAt: stored.StoredAt}
+		if prev, ok := byNumber[event.PR.Number]; ok {
+			// Auto-merges are recorded twice (auto_merged, then the closed
+			// webhook); keep the first time and the payload's labels.
+			merged.mergedAt = prev.mergedAt
+			if merged.labels == nil {
+				merged.labels = prev.labels
+			}
+		}
+		byNumber[event.PR.Number] = merged
+	}
+	prs := make([]mergedPR, 0, len(byNumber))
+	for _, pr := range byNumber {
+		prs = append(prs, pr)
+	}
+	sort.Slice(prs, func(i, j int) bool { return prs[i].mergedAt.Before(prs[j].mergedAt) })
+	return prs
+}
+
+// releaseSection returns the section of a merged PR and its title without
+// the Conventional Commit prefix.
+func releaseSection(pr mergedPR, sections map[string]string) (section, title string) {
+	title = pr.pr.Title
+	if m := conventionalHeader.FindStringSubmatch(strings.TrimSpace(title)); m != nil {
+		_, title, _ = strings.Cut(title, ": ")
+		if m[3] == "!" {
+			return breakingChangesSection, title
+		}
+		if s, ok := sections[m[1]]; ok {
+			return s, title
+		}
+	}
+	for _, label := range pr.labels {
+		if s, ok := sections[strings.ToLower(label)]; ok {
+			return s, title
+		}
+	}
+	return otherChangesSection, title
+}
+
+// releaseSections returns the type and label to section mapping of a repo.
+func releaseSections(platform SCMPlatform, fullName string) map[string]string {
+	sections := map[string]string{}
+	for k, v := range defaultReleaseSections {
+		sections[k] = v
+	}
+	if policy := repoConfigFor(platform, fullName).ReleaseNotes; policy != nil {
+		for k, v := range policy.Sections {
+			sections[strings.ToLower(k)] = v
+		}
+	}
+	return sections
+}
+
+// buildReleaseNotes drafts the notes of tag from the PRs merged since.
+func buildReleaseNotes(platform SCMPlatform, fullName, tag, previousTag string, since time.Time) *ReleaseNotesDraft {
+	sections := releaseSections(platform, fullName)
+	prs := mergedPRsSince(platform, fullName, since)
+	grouped := map[string][]ReleaseNotesEntry{}
+	for _, pr := range prs {
+		section, title := releaseSection(pr, sections)
+		grouped[section] = append(grouped[section], ReleaseNotesEntry{
+			Number: pr.pr.Number,
+			Title:  title,
+			Author: pr.pr.Author,
+			URL:    pr.pr.URL,
+		})
+	}
+
+	order := append([]string(nil), releaseSectionOrder...)
+	var extra []string
+	for title := range grouped {
+		known := title == otherChangesSection
+		for _, o := range releaseSectionOrder {
+			known = known || o == title
+		}
+		if !known {
+			extra = append(extra, title)
+		}
+	}
+	sort.Strings(extra)
+	order = append(append(order, extra...), otherChangesSection)
+
+	draft := &ReleaseNotesDraft{
+		Repository:  fullName,
+		Tag:         tag,
+		PreviousTag: previousTag,
+		Since:       since,
+		Count:       len(prs),
+		Sections:    []ReleaseNotesSection{},
+	}
+	var b strings.Builder
+	for _, title := range order {
+		entries, ok := grouped[title]
+		if !ok {
+			continue
+		}
+		draft.Sections = append(draft.Sections, ReleaseNotesSection{Title: title, PullRequests: entries})
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(&b, "- %s (#%d)", e.Title, e.Number)
+			if e.Author != "" {
+				fmt.Fprintf(&b, " @%s", e.Author)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(prs) == 0 {
+		b.WriteString("No pull requests merged since the previous release.\n")
+	}
+	draft.Markdown = b.String()
+	return draft
+}
+
+// releaseNotesSince resolves the start of a draft: the publication of the
+// release before tag, if the adapter can tell, else the oldest stored event.
+func releaseNotesSince(adapter SCMAdapter, owner, repo, tag string) (previousTag string, since time.Time, err error) {
+	writer, ok := adapter.(ReleaseWriter)
+	if !ok {
+		return "", time.Time{}, nil
+	}
+	return writer.PreviousRelease(owner, repo, tag)
+}
+
+// ReleaseNotesHandler returns the draft release notes of a repository.
+//
+//	GET /release-notes?owner=X&repo=Y[&tag=T][&since=RFC3339][&platform=github]
+func ReleaseNotesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	owner, repo, tag := q.Get("owner"), q.Get("repo"), q.Get("tag")
+	if owner == "" || repo == "" {
+		http.Error(w, "owner and repo parameters are required", http.StatusBadRequest)
+		return
+	}
+	platform := SCMPlatform(q.Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+
+	var previousTag string
+	var since time.Time
+	if raw := q.Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	} else {
+		adapter, err := NewSCMAdapter(platform)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		previousTag, since, err = releaseNotesSince(adapter, owner, repo, tag)
+		if err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"draft":  buildReleaseNotes(platform, owner+"/"+repo, tag, previousTag, since),
+	})
+}
+
+// ghReleaseTriggerPayload is the subset of GitHub push and release events
+// that trigger release notes.
+type ghReleaseTriggerPayload struct {
+	Action  string `json:"action"`
+	Ref     string `json:"ref"`
+	Created bool   `json:"created"`
+	Release struct {
+		TagName string `json:"tag_name"`
+		Body    string `json:"body"`
+	} `json:"release"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+		Owner    struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// releaseTrigger returns the payload of a delivery that calls for release
+// notes: a GitHub tag being pushed, or a release being published.
+func releaseTrigger(platform SCMPlatform, eventType string, payload []byte) (*ghReleaseTriggerPayload, bool) {
+	if platform != PlatformGitHub || (eventType != "push" && eventType != "release") {
+		return nil, false
+	}
+	var p ghReleaseTriggerPayload
+	if json.Unmarshal(payload, &p) != nil {
+		return nil, false
+	}
+	if eventType == "push" && p.Created && strings.HasPrefix(p.Ref, "refs/tags/") {
+		p.Release.TagName = strings.TrimPrefix(p.Ref, "refs/tags/")
+		return &p, true
+	}
+	if eventType == "release" && p.Action == "published" {
+		return &p, true
+	}
+	return nil, false
+}
+
+// handleReleaseTrigger acknowledges a release trigger and, when the repo
+// posts release notes, writes the draft to the release in the background.
+// Release triggers are never queued: they carry no PR.
+func handleReleaseTrigger(w http.ResponseWriter, platform SCMPlatform, p *ghReleaseTriggerPayload) {
+	owner, repo, tag := p.Repository.Owner.Login, p.Repository.Name, p.Release.TagName
+	policy := repoConfigFor(platform, p.Repository.FullName).ReleaseNotes
+	post := policy != nil && policy.Post
+	switch {
+	case !post:
+	case isStandby():
+		// Write-backs to the SCM belong to the active region.
+		post = false
+	case registry.IsSuspended(platform, owner):
+		log.Printf("[ReleaseNotes] Skipping %s of %s: installation is suspended\n", tag, p.Repository.FullName)
+		post = false
+	case strings.TrimSpace(p.Release.Body) != "":
+		log.Printf("[ReleaseNotes] Release %s of %s already has notes\n", tag, p.Repository.FullName)
+		post = false
+	}
+	if post {
+		go postReleaseNotes(platform, owner, repo, tag)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "received",
+		"tag":           tag,
+		"release_notes": post,
+	})
+}
+
+// postReleaseNotes drafts the notes of tag and writes them to its release.
+func postReleaseNotes(platform SCMPlatform, owner, repo, tag string) {
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		log.Printf("[ReleaseNotes] Warning: %v\n", err)
+		return
+	}
+	writer, ok := adapter.(ReleaseWriter)
+	if !ok {
+		log.Printf("[ReleaseNotes] Warning: %v\n", errUnsupported(adapter, "writing release notes"))
+		return
+	}
+	previousTag, since, err := writer.PreviousRelease(owner, repo, tag)
+	if err != nil {
+		log.Printf("[ReleaseNotes] Warning: could not find the release before %s of %s/%s: %v\n", tag, owner, repo, err)
+		return
+	}
+	draft := buildReleaseNotes(platform, owner+"/"+repo, tag, previousTag, since)
+	written, err := writer.SetReleaseNotes(owner, repo, tag, draft.Markdown)
+	switch {
+	case err != nil:
+		log.Printf("[ReleaseNotes] Warning: could not write the notes of %s of %s/%s: %v\n", tag, owner, repo, err)
+	case written:
+		log.Printf("[ReleaseNotes] Wrote notes of %s of %s/%s: %d PR(s) since %s\n", tag, owner, repo, draft.Count, previousTag)
+	default:
+		log.Printf("[ReleaseNotes] Release %s of %s/%s already has notes\n", tag, owner, repo)
+	}
+}
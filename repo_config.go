@@ -0,0 +1,135 @@
+package main
+
+// Per-repository configuration.
+//
+// Automations that can be enabled per repo read their settings from the JSON
+// file named by REPO_CONFIG_FILE:
+//
+//	{
+//	  "default": { ... },
+//	  "repos": {
+//	    "octo-org/api": { ... },
+//	    "bitbucket:workspace/legacy": { ... }
+//	  }
+//	}
+//
+// Repo keys are matched as "platform:owner/repo" first, then "owner/repo",
+// then the tenant-wide "platform:owner/*" and "owner/*". A matching entry
+// replaces "default" entirely. If the file is unset or cannot
+// be read every repo gets the zero RepoConfig, i.e. all automations disabled.
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RepoConfig holds the per-repository automation settings.
+type RepoConfig struct {
+	// DescriptionTemplate is a text/template appended to the PR description
+	// on pull_request.opened. Empty disables the automation.
+	DescriptionTemplate string `json:"description_template,omitempty"`
+
+	// ConventionalCommits enables PR title / commit message validation.
+	ConventionalCommits *ConventionalCommitsPolicy `json:"conventional_commits,omitempty"`
+
+	// LLMReview opts the repo in to automated reviews (see reviewer.go).
+	LLMReview bool `json:"llm_review,omitempty"`
+
+	// Analyzers are run against the PR head and reported as check runs
+	// (see analysis.go).
+	Analyzers []AnalyzerConfig `json:"analyzers,omitempty"`
+
+	// FilePolicy flags disallowed, unlicensed or oversized changed files.
+	FilePolicy *FilePolicy `json:"file_policy,omitempty"`
+
+	// PathRules limit enrichment to relevant changed paths.
+	PathRules *PathRules `json:"path_rules,omitempty"`
+
+	// Enrichers overrides the ENRICHERS chain (see enrichers.go).
+	Enrichers []string `json:"enrichers,omitempty"`
+
+	// Owners map changed paths to their owners for the "owners" enricher.
+	Owners []OwnerRule `json:"owners,omitempty"`
+
+	// AutoMerge merges PRs that meet its conditions (see auto_merge.go).
+	AutoMerge *AutoMergePolicy `json:"auto_merge,omitempty"`
+
+	// RequiredContexts is the desired set of status checks each protected
+	// branch requires, by branch (see required_contexts.go).
+	RequiredContexts map[string][]string `json:"required_contexts,omitempty"`
+
+	// Labels and Milestones are the standard set the repo should have (see
+	// label_sync.go).
+	Labels     []RepoLabel     `json:"labels,omitempty"`
+	Milestones []RepoMilestone `json:"milestones,omitempty"`
+
+	// Changelog adds merged PRs to the changelog file (see
+	// automation_changelog.go).
+	Changelog *ChangelogPolicy `json:"changelog,omitempty"`
+
+	// ReleaseNotes configures release notes drafts (see release_notes.go).
+	ReleaseNotes *ReleaseNotesPolicy `json:"release_notes,omitempty"`
+
+	// Scaffold is applied to repositories created by /admin/scaffold (see
+	// repo_scaffold.go).
+	Scaffold *ScaffoldPolicy `json:"scaffold,omitempty"`
+
+	// Privacy anonymizes authors before events are stored or delivered:
+	// "hash" or "pseudonymize" (see privacy.go). Empty disables it.
+	Privacy string `json:"privacy,omitempty"`
+}
+
+type repoConfigFile struct {
+	Default RepoConfig            `json:"default"`
+	Repos   map[string]RepoConfig `json:"repos"`
+}
+
+var (
+	repoConfigOnce   sync.Once
+	loadedRepoConfig repoConfigFile
+)
+
+// loadRepoConfig reads REPO_CONFIG_FILE once; later calls reuse the result.
+func loadRepoConfig() repoConfigFile {
+	repoConfigOnce.Do(func() {
+		path := os.Getenv("REPO_CONFIG_FILE")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[RepoConfig] Warning: could not read %s: %v\n", path, err)
+			return
+		}
+		if err := json.Unmarshal(data, &loadedRepoConfig); err != nil {
+			log.Printf("[RepoConfig] Warning: could not parse %s: %v\n", path, err)
+			loadedRepoConfig = repoConfigFile{}
+			return
+		}
+		log.Printf("[RepoConfig] Loaded %s (%d repo overrides)\n", path, len(loadedRepoConfig.Repos))
+	})
+	return loadedRepoConfig
+}
+
+// repoConfigFor returns the effective configuration for a repository.
+func repoConfigFor(platform SCMPlatform, fullName string) RepoConfig {
+	cfg := loadRepoConfig()
+	if rc, ok := cfg.Repos[string(platform)+":"+fullName]; ok {
+		return rc
+	}
+	if rc, ok := cfg.Repos[fullName]; ok {
+		return rc
+	}
+	if owner, _, ok := strings.Cut(fullName, "/"); ok {
+		if rc, ok := cfg.Repos[string(platform)+":"+owner+"/*"]; ok {
+			return rc
+		}
+		if rc, ok := cfg.Repos[owner+"/*"]; ok {
+			return rc
+		}
+	}
+	return cfg.Default
+}
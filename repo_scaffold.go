@@ -0,0 +1,150 @@
+package main
+
+// Repository scaffolding — creates a repository from a template and applies
+// the standard setup in one call:
+//
+//	POST /admin/scaffold
+//	{"platform": "github", "template": "acme/service-template",
+//	 "owner": "acme", "name": "billing", "description": "...", "private": true}
+//
+// The setup is the new repo's entry in REPO_CONFIG_FILE, usually the
+// tenant-wide "acme/*" or "default" one:
+//
+//	"required_contexts": {"main": ["ci/build"]},   // branch protection
+//	"labels": [{"name": "bug", "color": "d73a4a"}], // and "milestones", see label_sync.go
+//	"scaffold": {
+//	  "webhooks": [{"url": "https://ci.example.com/hook", "secret": "...", "events": ["push"]}]
+//	}
+//
+// Each step is reported on its own; a failed step does not undo the ones
+// before it, and the repository is registered in the repo registry once it
+// exists.
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ScaffoldPolicy is the per-repo setup applied to scaffolded repositories.
+type ScaffoldPolicy struct {
+	Webhooks []NewWebhook `json:"webhooks,omitempty"`
+}
+
+// ScaffoldStep is the outcome of one setup step.
+type ScaffoldStep struct {
+	Step   string `json:"step"`
+	Target string `json:"target,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// scaffoldRepo applies the configured setup to a freshly created repo.
+func scaffoldRepo(adapter SCMAdapter, provisioner RepoProvisioner, platform SCMPlatform, repo *NormalizedRepository) []ScaffoldStep {
+	steps := []ScaffoldStep{}
+	step := func(name, target string, err error) {
+		s := ScaffoldStep{Step: name, Target: target}
+		if err != nil {
+			s.Error = err.Error()
+			log.Printf("[Scaffold] Warning: %s %s of %s failed: %v\n", name, target, repo.FullName, err)
+		}
+		steps = append(steps, s)
+	}
+
+	config := repoConfigFor(platform, repo.FullName)
+	if len(config.Labels) > 0 || len(config.Milestones) > 0 {
+		var err error
+		if d := reconcileRepoLabels(adapter, platform, repo.FullName, config, true); d.Error != "" {
+			err = errors.New(d.Error)
+		}
+		step("labels", "", err)
+	}
+	if policy := config.Scaffold; policy != nil {
+		for _, hook := range policy.Webhooks {
+			_, err := provisioner.CreateWebhook(repo.Owner, repo.Name, hook)
+			step("webhook", hook.URL, err)
+		}
+	}
+
+	branches := make([]string, 0, len(config.RequiredContexts))
+	for branch := range config.RequiredContexts {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	for _, branch := range branches {
+		d := reconcileBranch(adapter, platform, repo.FullName, branch, config.RequiredContexts[branch], true)
+		var err error
+		if d.Error != "" {
+			err = errors.New(d.Error)
+		}
+		step("branch_protection", branch, err)
+	}
+	return steps
+}
+
+// AdminScaffoldHandler creates a repository from a template and sets it up.
+func AdminScaffoldHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if isStandby() {
+		http.Error(w, "a standby deployment does not create repositories", http.StatusConflict)
+		return
+	}
+	var req struct {
+		NewRepo
+		Platform SCMPlatform `json:"platform"`
+		Template string      `json:"template"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	templateOwner, templateRepo, ok := strings.Cut(req.Template, "/")
+	if !ok || templateOwner == "" || templateRepo == "" || req.Owner == "" || req.Name == "" {
+		http.Error(w, "template (owner/repo), owner and name are required", http.StatusBadRequest)
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformGitHub
+	}
+
+	adapter, err := NewSCMAdapter(req.Platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	provisioner, ok := adapter.(RepoProvisioner)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "creating repositories").Error(), http.StatusNotImplemented)
+		return
+	}
+	repo, err := provisioner.CreateRepoFromTemplate(templateOwner, templateRepo, req.NewRepo)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	registry.Register(req.Platform, repo.FullName)
+	log.Printf("[Scaffold] ✓ Created %s %s from %s\n", req.Platform, repo.FullName, req.Template)
+
+	steps := scaffoldRepo(adapter, provisioner, req.Platform, repo)
+	failed := 0
+	for _, s := range steps {
+		if s.Error != "" {
+			failed++
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status":     "success",
+		"repository": repo,
+		"failed":     failed,
+		"steps":      steps,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// repoStatsWeeks is how many weeks of commit activity are reported.
+const repoStatsWeeks = 12
+
+// RepoStats summarizes a repository for dashboards.
+type RepoStats struct {
+	Languages         map[string]float64 `json:"languages"` // percent of code
+	PrimaryLanguage   string             `json:"primary_language"`
+	Contributors      int                `json:"contributors"`
+	WeeklyCommits     []int              `json:"weekly_commits"` // oldest week first
+	CommitsLast4Weeks int                `json:"commits_last_4_weeks"`
+	Pending           bool               `json:"pending"` // SCM still computing statistics
+}
+
+// setLanguageBytes converts a bytes-per-language map into percentages and
+// picks the primary language.
+func (s *RepoStats) setLanguageBytes(languages map[string]int64) {
+	var total int64
+	for _, n := range languages {
+		total += n
+	}
+	s.Languages = make(map[string]float64, len(languages))
+	if total == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(languages))
+	for name, n := range languages {
+		s.Languages[name] = float64(n*10000/total) / 100
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return languages[names[i]] > languages[names[j]] })
+	s.PrimaryLanguage = names[0]
+}
+
+// summarizeActivity fills CommitsLast4Weeks from WeeklyCommits.
+func (s *RepoStats) summarizeActivity() {
+	s.CommitsLast4Weeks = 0
+	for i := len(s.WeeklyCommits) - 1; i >= 0 && i >= len(s.WeeklyCommits)-4; i-- {
+		s.CommitsLast4Weeks += s.WeeklyCommits[i]
+	}
+}
+
+var (
+	repoStatsCacheOnce sync.Once
+	repoStatsCacheRef  *ttlCache
+)
+
+// repoStatsCache holds computed statistics; they change slowly and the
+// GitHub statistics endpoints are expensive.
+func repoStatsCache() *ttlCache {
+	repoStatsCacheOnce.Do(func() {
+		repoStatsCacheRef = newTTLCache("repo_stats", time.Hour)
+	})
+	return repoStatsCacheRef
+}
+
+// RepoStatsHandler returns cached repository statistics.
+//
+//	GET /repo-stats?owner=X&repo=Y[&platform=github|bitbucket]
+func RepoStatsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	if owner == "" || repo == "" {
+		http.Error(w, "owner and repo parameters are required", http.StatusBadRequest)
+		return
+	}
+	platform := SCMPlatform(q.Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+
+	key := registryKey(platform, owner+"/"+repo)
+	cached := true
+	value, ok := repoStatsCache().Get(key)
+	if !ok {
+		cached = false
+		adapter, err := NewSCMAdapter(platform)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader, ok := adapter.(RepoReader)
+		if !ok {
+			http.Error(w, errUnsupported(adapter, "repository statistics").Error(), http.StatusNotImplemented)
+			return
+		}
+		stats, err := reader.GetRepoStats(owner, repo)
+		if err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		// Pending statistics are incomplete; let the next request retry.
+		if !stats.Pending {
+			repoStatsCache().Set(key, stats)
+		}
+		value = stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"owner":    owner,
+		"repo":     repo,
+		"platform": platform,
+		"cached":   cached,
+		"stats":    value,
+	})
+}
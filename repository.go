@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -35,7 +36,7 @@ func getRepositoryFileTree(token string, owner string, repo string, path string,
 	log.Printf("Fetching from: %s\n", url)
 
 	// Make authenticated request
-	body, err := makeAuthenticatedRequest(token, "GET", url, nil)
+	body, err := makeAuthenticatedRequest(context.Background(), token, "GET", url, nil)
 	if err != nil {
 		log.Println("Error: Failed to get repository contents:", err)
 		return err
@@ -0,0 +1,55 @@
+package main
+
+// Request scope — the context the outbound requests made on behalf of one
+// event are created with. The SCM Adapter consumer binds it to the adapter it
+// builds for the event; the adapter, the enrichers and the reviewers and
+// analyzers running on that adapter make their requests with it, and the
+// tracing transport reads the event's trace (trace.go) and tenant (usage.go)
+// from req.Context(). Consumers of different shards run concurrently, so the
+// attribution travels with each request rather than in shared state.
+
+import "context"
+
+// requestScope is embedded by the adapters to carry their request context.
+type requestScope struct {
+	ctx context.Context
+}
+
+// bindContext makes the adapter's subsequent requests in ctx.
+func (s *requestScope) bindContext(ctx context.Context) { s.ctx = ctx }
+
+// requestContext returns the context to create outbound requests with.
+func (s *requestScope) requestContext() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// scopedAdapter is implemented by adapters embedding requestScope.
+type scopedAdapter interface {
+	bindContext(ctx context.Context)
+	requestContext() context.Context
+}
+
+// NewScopedSCMAdapter is NewSCMAdapter with the adapter's requests made in
+// ctx.
+func NewScopedSCMAdapter(ctx context.Context, platform SCMPlatform) (SCMAdapter, error) {
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		return nil, err
+	}
+	if scoped, ok := adapter.(scopedAdapter); ok {
+		scoped.bindContext(ctx)
+	}
+	return adapter, nil
+}
+
+// adapterContext returns the context adapter makes its requests in, for
+// callers making related requests of their own.
+func adapterContext(adapter SCMAdapter) context.Context {
+	if scoped, ok := adapter.(scopedAdapter); ok {
+		return scoped.requestContext()
+	}
+	return context.Background()
+}
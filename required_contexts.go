@@ -0,0 +1,173 @@
+package main
+
+// Required status checks — keeps the status checks each protected branch
+// requires in line with a declarative desired state, across every repository
+// in the registry. The desired state is the repo's "required_contexts" in
+// REPO_CONFIG_FILE, so a "default" entry enforces one set org-wide:
+//
+//	"required_contexts": {
+//	  "main":    ["ci/build", "ci/test"],
+//	  "release": ["ci/build"]
+//	}
+//
+// Only the listed branches are compared; an empty list declares that the
+// branch requires no checks. Contexts are compared as sets.
+//
+//	GET  /admin/required-contexts  → drift report, changes nothing
+//	POST /admin/required-contexts  → also applies the desired state
+//
+// Adapters without BranchProtector (Bitbucket, Gerrit, Gitea) are reported as
+// unsupported.
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ContextDrift is the comparison of one branch with its desired state.
+type ContextDrift struct {
+	Platform   SCMPlatform `json:"platform"`
+	Repository string      `json:"repository"`
+	Branch     string      `json:"branch"`
+	Protected  bool        `json:"protected"`
+	Have       []string    `json:"have"`
+	Want       []string    `json:"want"`
+	Missing    []string    `json:"missing,omitempty"` // wanted but not required
+	Extra      []string    `json:"extra,omitempty"`   // required but not wanted
+	Drifted    bool        `json:"drifted"`
+	Fixed      bool        `json:"fixed,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// reconcileRequiredContexts compares the required status checks of every
+// registered repo's declared branches with its config and, with fix, updates
+// the ones that drifted.
+func reconcileRequiredContexts(fix bool) []ContextDrift {
+	report := []ContextDrift{}
+	adapters := map[SCMPlatform]SCMAdapter{}
+	for _, rec := range registry.List() {
+		if rec.FullName == appHookKey || rec.Suspended {
+			continue
+		}
+		desired := repoConfigFor(rec.Platform, rec.FullName).RequiredContexts
+		if len(desired) == 0 {
+			continue
+		}
+		adapter, ok := adapters[rec.Platform]
+		if !ok {
+			var err error
+			if adapter, err = NewSCMAdapter(rec.Platform); err != nil {
+				log.Printf("[RequiredContexts] Warning: %v\n", err)
+				adapter = nil
+			}
+			adapters[rec.Platform] = adapter
+		}
+
+		branches := make([]string, 0, len(desired))
+		for branch := range desired {
+			branches = append(branches, branch)
+		}
+		sort.Strings(branches)
+		for _, branch := range branches {
+			report = append(report, reconcileBranch(adapter, rec.Platform, rec.FullName, branch, desired[branch], fix))
+		}
+	}
+	return report
+}
+
+// reconcileBranch compares one branch and, with fix, updates it.
+func reconcileBranch(adapter SCMAdapter, platform SCMPlatform, fullName, branch string, want []string, fix bool) ContextDrift {
+	d := ContextDrift{Platform: platform, Repository: fullName, Branch: branch, Have: []string{}, Want: sortedSet(want)}
+	if adapter == nil {
+		d.Error = "adapter not configured"
+		return d
+	}
+	protector, ok := adapter.(BranchProtector)
+	if !ok {
+		d.Error = errUnsupported(adapter, "branch protection").Error()
+		return d
+	}
+	owner, repo, _ := strings.Cut(fullName, "/")
+
+	have, protected, err := protector.GetRequiredContexts(owner, repo, branch)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Protected = protected
+	d.Have = sortedSet(have)
+	d.Missing = missingEvents(d.Have, d.Want)
+	d.Extra = missingEvents(d.Want, d.Have)
+	// An unprotected branch that should require nothing is in line.
+	d.Drifted = len(d.Missing) > 0 || len(d.Extra) > 0
+	if !d.Drifted {
+		return d
+	}
+	log.Printf("[RequiredContexts] %s %s@%s drifted: missing %v, extra %v\n", platform, fullName, branch, d.Missing, d.Extra)
+
+	if !fix {
+		return d
+	}
+	if err := protector.SetRequiredContexts(owner, repo, branch, d.Want); err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Fixed = true
+	log.Printf("[RequiredContexts] ✓ %s %s@%s now requires %v\n", platform, fullName, branch, d.Want)
+	return d
+}
+
+// sortedSet returns the distinct entries of list, sorted.
+func sortedSet(list []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AdminRequiredContextsHandler reports (GET) or fixes (POST) drift of the
+// branches' required status checks.
+func AdminRequiredContextsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	fix := r.Method == http.MethodPost
+	if fix && isStandby() {
+		http.Error(w, "a standby deployment does not change branch protection", http.StatusConflict)
+		return
+	}
+
+	report := reconcileRequiredContexts(fix)
+	drifted, fixed, failed := 0, 0, 0
+	for _, d := range report {
+		if d.Drifted {
+			drifted++
+		}
+		if d.Fixed {
+			fixed++
+		}
+		if d.Error != "" {
+			failed++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"checked":  len(report),
+		"drifted":  drifted,
+		"fixed":    fixed,
+		"failed":   failed,
+		"branches": report,
+	})
+}
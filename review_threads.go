@@ -0,0 +1,164 @@
+package main
+
+// Review threads — resolving and reopening a PR review thread produces a
+// normalized thread.resolved / thread.unresolved event, whose Thread carries
+// the thread and the number of threads still unresolved on the PR, so a
+// downstream "all conversations resolved" merge gate needs no API calls of
+// its own:
+//
+//	GitHub     pull_request_review_thread (resolved, unresolved)
+//	Bitbucket  pullrequest:comment_resolved, pullrequest:comment_reopened
+//
+// Threads can also be listed and resolved through the adapters
+// (ThreadResolver):
+//
+//	GET  /pr-threads?platform=&owner=&repo=&pr=[&state=unresolved]
+//	POST /pr-threads/resolve   {"platform", "owner", "repo", "pr", "thread_id"}  (admin)
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// githubReviewThreadEvent is the X-GitHub-Event of thread resolution.
+	githubReviewThreadEvent = "pull_request_review_thread"
+
+	EventTypeThreadResolved   = "thread.resolved"
+	EventTypeThreadUnresolved = "thread.unresolved"
+)
+
+// countUnresolvedThreads sets event.Thread.Unresolved from the PR's current
+// threads; it stays -1 if they cannot be listed.
+func countUnresolvedThreads(resolver ThreadResolver, event *NormalizedEvent) {
+	if event.Thread == nil || event.PR.Number == 0 {
+		return
+	}
+	threads, err := resolver.ListReviewThreads(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		log.Printf("[%s Adapter] Warning: could not list review threads of PR #%d: %v\n", event.Platform, event.PR.Number, err)
+		return
+	}
+	unresolved := 0
+	for _, t := range threads {
+		if !t.Resolved {
+			unresolved++
+		}
+	}
+	event.Thread.Unresolved = unresolved
+}
+
+// threadResolverFor returns the adapter of platform as a ThreadResolver,
+// writing the error response if there is none.
+func threadResolverFor(w http.ResponseWriter, platform SCMPlatform) (ThreadResolver, bool) {
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+	adapter, err := NewSCMAdapter(platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	resolver, ok := adapter.(ThreadResolver)
+	if !ok {
+		http.Error(w, errUnsupported(adapter, "review threads").Error(), http.StatusNotImplemented)
+		return nil, false
+	}
+	return resolver, true
+}
+
+// ReviewThreadsHandler lists a PR's review threads.
+//
+//	GET /pr-threads?platform=github&owner=o&repo=r&pr=12[&state=unresolved]
+func ReviewThreadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	prNumber, err := strconv.Atoi(q.Get("pr"))
+	if owner == "" || repo == "" || err != nil {
+		http.Error(w, "owner, repo and pr parameters are required", http.StatusBadRequest)
+		return
+	}
+	state := q.Get("state")
+	if state != "" && state != "unresolved" && state != "resolved" {
+		http.Error(w, "state must be resolved or unresolved", http.StatusBadRequest)
+		return
+	}
+
+	resolver, ok := threadResolverFor(w, SCMPlatform(q.Get("platform")))
+	if !ok {
+		return
+	}
+	threads, err := resolver.ListReviewThreads(owner, repo, prNumber)
+	if err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	unresolved := 0
+	filtered := []NormalizedThread{}
+	for _, t := range threads {
+		if !t.Resolved {
+			unresolved++
+		}
+		if state == "" || (state == "resolved") == t.Resolved {
+			filtered = append(filtered, t)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "success",
+		"total":      len(threads),
+		"unresolved": unresolved,
+		"threads":    filtered,
+	})
+}
+
+// ResolveReviewThreadHandler resolves a review thread.
+//
+//	POST /pr-threads/resolve
+//	{"platform": "github", "owner": "o", "repo": "r", "pr": 12, "thread_id": "PRRT_…"}
+func ResolveReviewThreadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		Platform SCMPlatform `json:"platform"`
+		Owner    string      `json:"owner"`
+		Repo     string      `json:"repo"`
+		PR       int         `json:"pr"`
+		ThreadID string      `json:"thread_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Owner == "" || req.Repo == "" || req.PR == 0 || req.ThreadID == "" {
+		http.Error(w, "owner, repo, pr and thread_id are required", http.StatusBadRequest)
+		return
+	}
+
+	resolver, ok := threadResolverFor(w, req.Platform)
+	if !ok {
+		return
+	}
+	if err := resolver.ResolveReviewThread(req.Owner, req.Repo, req.PR, req.ThreadID); err != nil {
+		log.Println("Error:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "success",
+		"thread_id": req.ThreadID,
+		"resolved":  true,
+	})
+}
@@ -0,0 +1,335 @@
+package main
+
+// Automated PR review hook.
+//
+// Reviewers are invoked on enriched PR events (opened / synchronize /
+// reopened) for repos that opt in with "llm_review": true in RepoConfig. The
+// PR diff is split into chunks bounded by a token budget, each chunk is sent
+// to every configured Reviewer, and the resulting comments are posted back
+// to the PR through the adapter — so the external review service never needs
+// SCM credentials of its own.
+//
+// The built-in LLMReviewer is enabled by LLM_REVIEW_URL and calls an external
+// HTTP service:
+//
+//	POST LLM_REVIEW_URL
+//	{"repository": "...", "pr_number": 1, "title": "...", "description": "...",
+//	 "chunk_id": "...", "files": [...], "diff": "..."}
+//	→ {"comments": [{"path": "...", "line": 12, "body": "..."}]}
+//
+// Budgets: LLM_REVIEW_CHUNK_TOKENS (default 3000) caps a single chunk,
+// LLM_REVIEW_MAX_TOKENS (default 20000) caps the whole PR; chunks beyond the
+// total budget are skipped and mentioned in the posted review.
+//
+// Reviews run on LLM_REVIEW_WORKERS goroutines (default 2) fed by a queue of
+// LLM_REVIEW_QUEUE PRs (default 100); a PR that finds the queue full during
+// a burst (e.g. a backfill) is not reviewed.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReviewRequest is one unit of work handed to a Reviewer.
+type ReviewRequest struct {
+	Event *NormalizedEvent
+	Chunk DiffChunk
+}
+
+// ReviewComment is a single finding produced by a Reviewer, and an inline
+// comment of a PRReview. Line is a line number in the file — of the new
+// version, or of the old one when Side is "LEFT" (a removed line) — not a
+// position in the diff; it is 0 for file-level comments.
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side,omitempty"` // "RIGHT" (default) or "LEFT"
+	Body string `json:"body"`
+}
+
+// Review verdicts.
+const (
+	ReviewEventComment        = "comment"
+	ReviewEventApprove        = "approve"
+	ReviewEventRequestChanges = "request_changes"
+)
+
+// PRReview is a review submitted through ReviewCreator.
+type PRReview struct {
+	Body     string
+	Event    string // ReviewEventComment (default), ReviewEventApprove or ReviewEventRequestChanges
+	Comments []ReviewComment
+}
+
+// Reviewer reviews one diff chunk of a pull request.
+type Reviewer interface {
+	Name() string
+	Review(ctx context.Context, req ReviewRequest) ([]ReviewComment, error)
+}
+
+// LLMReviewer calls an external LLM review service over HTTP.
+type LLMReviewer struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewLLMReviewer returns an LLMReviewer from LLM_REVIEW_URL / LLM_REVIEW_API_KEY,
+// or nil when the URL is not configured.
+func NewLLMReviewer() *LLMReviewer {
+	url := os.Getenv("LLM_REVIEW_URL")
+	if url == "" {
+		return nil
+	}
+	return &LLMReviewer{
+		url:    url,
+		apiKey: os.Getenv("LLM_REVIEW_API_KEY"),
+		client: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (l *LLMReviewer) Name() string {
+	return "llm"
+}
+
+func (l *LLMReviewer) Review(ctx context.Context, req ReviewRequest) ([]ReviewComment, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"repository":  req.Event.Repository.FullName,
+		"pr_number":   req.Event.PR.Number,
+		"title":       req.Event.PR.Title,
+		"description": req.Event.PR.Description,
+		"chunk_id":    req.Chunk.ID,
+		"files":       req.Chunk.Files,
+		"diff":        req.Chunk.Diff,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", l.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if l.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+
+	resp, err := l.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("reviewer: failed to reach %s: %w", l.url, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("reviewer: service returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Comments []ReviewComment `json:"comments"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("reviewer: failed to parse response: %w", err)
+	}
+	return result.Comments, nil
+}
+
+var (
+	reviewersOnce sync.Once
+	reviewers     []Reviewer
+
+	reviewWorkersOnce sync.Once
+	reviewQueue       chan reviewJob
+)
+
+// reviewJob is a PR review waiting for a worker.
+type reviewJob struct {
+	ctx    context.Context
+	reader PRReader
+	writer PRWriter
+	event  *NormalizedEvent
+	active []Reviewer
+}
+
+// enqueueReview hands job to the review workers, starting them on first use.
+// It reports false when the queue is full.
+func enqueueReview(job reviewJob) bool {
+	reviewWorkersOnce.Do(func() {
+		reviewQueue = make(chan reviewJob, envInt("LLM_REVIEW_QUEUE", 100))
+		workers := envInt("LLM_REVIEW_WORKERS", 2)
+		for i := 0; i < workers; i++ {
+			go reviewWorker()
+		}
+		log.Printf("[Reviewer] %d worker(s) reviewing from a queue of %d\n", workers, cap(reviewQueue))
+	})
+	select {
+	case reviewQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// reviewWorker runs queued reviews until the process exits.
+func reviewWorker() {
+	for job := range reviewQueue {
+		reviewPR(job.ctx, job.reader, job.writer, job.event, job.active)
+	}
+}
+
+// configuredReviewers returns the reviewers enabled by the environment.
+func configuredReviewers() []Reviewer {
+	reviewersOnce.Do(func() {
+		if l := NewLLMReviewer(); l != nil {
+			reviewers = append(reviewers, l)
+			log.Printf("[Reviewer] LLM reviewer enabled (%s)\n", l.url)
+		}
+	})
+	return reviewers
+}
+
+// envInt reads a positive integer environment variable, returning def when
+// it is unset or invalid.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// runReviewers queues an asynchronous review of the PR when the repo has
+// opted in. Reviews run on the review workers, off the consumer goroutine, so
+// a slow review service never delays normalization of other events.
+func runReviewers(adapter SCMAdapter, event *NormalizedEvent) {
+	if event.PR.Number == 0 || !isFileEnrichableAction(event.Action) {
+		return
+	}
+	optedIn := repoConfigFor(event.Platform, event.Repository.FullName).LLMReview
+	if !featureEnabled(FlagLLMReview, event.Platform, event.Repository.FullName, optedIn) {
+		return
+	}
+	active := configuredReviewers()
+	if len(active) == 0 {
+		return
+	}
+	reader, canRead := adapter.(PRReader)
+	writer, canWrite := adapter.(PRWriter)
+	if !canRead || !canWrite {
+		log.Printf("[Reviewer] Warning: %v\n", errUnsupported(adapter, "reading diffs and posting comments"))
+		return
+	}
+
+	job := reviewJob{ctx: adapterContext(adapter), reader: reader, writer: writer, event: event, active: active}
+	if !enqueueReview(job) {
+		log.Printf("[Reviewer] Warning: review queue full, skipping PR #%d in %s\n", event.PR.Number, event.Repository.FullName)
+	}
+}
+
+// reviewPR fetches the diff, runs every reviewer over each chunk within the
+// token budget, and posts the combined findings (see postReview). Reviewer
+// calls are made in ctx, the adapter's request context.
+func reviewPR(ctx context.Context, reader PRReader, writer PRWriter, event *NormalizedEvent, active []Reviewer) {
+	owner, repo, number := event.Repository.Owner, event.Repository.Name, event.PR.Number
+
+	diff, err := reader.GetPRDiff(owner, repo, number)
+	if err != nil {
+		log.Printf("[Reviewer] Warning: could not fetch diff for PR #%d: %v\n", number, err)
+		return
+	}
+
+	chunks := chunkDiff(diff, envInt("LLM_REVIEW_CHUNK_TOKENS", 3000))
+	budget := envInt("LLM_REVIEW_MAX_TOKENS", 20000)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	var comments []ReviewComment
+	var skipped []string
+	used := 0
+	for _, chunk := range chunks {
+		if used+chunk.Tokens > budget {
+			skipped = append(skipped, chunk.Files...)
+			continue
+		}
+		used += chunk.Tokens
+		for _, r := range active {
+			found, err := r.Review(ctx, ReviewRequest{Event: event, Chunk: chunk})
+			if err != nil {
+				log.Printf("[Reviewer] Warning: %s reviewer failed on %s of PR #%d: %v\n", r.Name(), chunk.ID, number, err)
+				continue
+			}
+			comments = append(comments, found...)
+		}
+	}
+
+	log.Printf("[Reviewer] PR #%d in %s: %d chunk(s), ~%d tokens, %d comment(s), %d file(s) over budget\n",
+		number, event.Repository.FullName, len(chunks), used, len(comments), len(skipped))
+
+	if len(comments) == 0 && len(skipped) == 0 {
+		return
+	}
+	postReview(writer, event, comments, skipped)
+}
+
+// postReview submits the findings as a review with inline comments when the
+// adapter is a ReviewCreator, and as one PR comment otherwise — or when the
+// SCM rejects the review, e.g. because a line is outside the diff.
+func postReview(writer PRWriter, event *NormalizedEvent, comments []ReviewComment, skipped []string) {
+	owner, repo, number := event.Repository.Owner, event.Repository.Name, event.PR.Number
+	if creator, ok := writer.(ReviewCreator); ok && len(comments) > 0 {
+		review := PRReview{Body: formatReviewSummary(len(comments), skipped), Event: ReviewEventComment, Comments: comments}
+		err := creator.CreateReview(owner, repo, number, review)
+		if err == nil {
+			return
+		}
+		log.Printf("[Reviewer] Warning: could not submit inline review on PR #%d, posting a comment instead: %v\n", number, err)
+	}
+	if err := writer.PostComment(owner, repo, number, formatReview(comments, skipped)); err != nil {
+		log.Printf("[Reviewer] Warning: could not post review on PR #%d: %v\n", number, err)
+	}
+}
+
+// formatReviewSummary renders the body of an inline review.
+func formatReviewSummary(inline int, skipped []string) string {
+	var b strings.Builder
+	b.WriteString("### 🤖 Automated review\n\n")
+	fmt.Fprintf(&b, "%d finding(s), see the inline comments.\n", inline)
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\n_Not reviewed (over token budget): %s_\n", strings.Join(skipped, ", "))
+	}
+	return b.String()
+}
+
+// formatReview renders reviewer findings as a Markdown PR comment.
+func formatReview(comments []ReviewComment, skipped []string) string {
+	var b strings.Builder
+	b.WriteString("### 🤖 Automated review\n\n")
+	if len(comments) == 0 {
+		b.WriteString("No issues found.\n")
+	}
+	for _, c := range comments {
+		switch {
+		case c.Path != "" && c.Line > 0:
+			fmt.Fprintf(&b, "- `%s:%d` — %s\n", c.Path, c.Line, c.Body)
+		case c.Path != "":
+			fmt.Fprintf(&b, "- `%s` — %s\n", c.Path, c.Body)
+		default:
+			fmt.Fprintf(&b, "- %s\n", c.Body)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\n_Not reviewed (over token budget): %s_\n", strings.Join(skipped, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+// SBOM export — serves a repository's software bill of materials (SPDX JSON,
+// from GitHub's dependency graph) with the gateway's installation auth, so
+// supply-chain tooling needs no SCM credentials of its own:
+//
+//	GET /sbom?owner=X&repo=Y[&platform=github]
+//
+// Documents are cached for SBOM_CACHE_SECONDS (default one hour); the
+// dependency graph is only rebuilt on pushes anyway.
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	sbomCacheOnce sync.Once
+	sbomCacheRef  *ttlCache
+)
+
+// sbomCache returns the SBOM cache, sized from SBOM_CACHE_SECONDS.
+func sbomCache() *ttlCache {
+	sbomCacheOnce.Do(func() {
+		sbomCacheRef = newTTLCache("sbom", time.Duration(envInt("SBOM_CACHE_SECONDS", 3600))*time.Second)
+	})
+	return sbomCacheRef
+}
+
+// SBOMHandler returns the SPDX document of a repository as is. Whether it
+// came from the cache is reported in the X-Gateway-Cache header.
+//
+//	GET /sbom?owner=X&repo=Y[&platform=github]
+func SBOMHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	owner, repo := q.Get("owner"), q.Get("repo")
+	if owner == "" || repo == "" {
+		http.Error(w, "owner and repo parameters are required", http.StatusBadRequest)
+		return
+	}
+	platform := SCMPlatform(q.Get("platform"))
+	if platform == "" {
+		platform = PlatformGitHub
+	}
+
+	key := registryKey(platform, owner+"/"+repo)
+	cache := "hit"
+	value, ok := sbomCache().Get(key)
+	if !ok {
+		cache = "miss"
+		adapter, err := NewSCMAdapter(platform)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader, ok := adapter.(SBOMReader)
+		if !ok {
+			http.Error(w, errUnsupported(adapter, "SBOM export").Error(), http.StatusNotImplemented)
+			return
+		}
+		sbom, err := reader.GetSBOM(owner, repo)
+		if err != nil {
+			log.Println("Error:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		sbomCache().Set(key, []byte(sbom))
+		value = []byte(sbom)
+	}
+
+	w.Header().Set("Content-Type", "application/spdx+json")
+	w.Header().Set("X-Gateway-Cache", cache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(value.([]byte))
+}
@@ -0,0 +1,193 @@
+package main
+
+// Scheduler — runs periodic jobs on cron schedules.
+//
+// Every job has a default schedule that can be overridden with
+// SCHEDULE_<JOB> (upper-case job name), e.g. SCHEDULE_WEBHOOK_SYNC="*/30 * * * *".
+// Setting it to "off" disables the job. Each run is delayed by a random
+// jitter of up to SCHEDULER_JITTER_SECONDS (default 30) so replicas and jobs
+// don't hit the SCM APIs in lockstep. Jobs that write to the SCMs only run
+// on the leader replica (see leader.go); local housekeeping of a replica's
+// own state (event store, PR snapshots, usage) and the digests of it run on
+// every replica. Status
+// is served at GET /admin/jobs.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduledJob is a unit of periodic work.
+type ScheduledJob struct {
+	Name        string
+	DefaultSpec string // cron expression used when SCHEDULE_<NAME> is unset
+	Run         func() error
+	// Local jobs maintain the replica's own state and run on every
+	// replica; the others run on the leader only.
+	Local bool
+}
+
+// JobStatus is the last-run state of a job, as reported by the admin API.
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	Enabled      bool      `json:"enabled"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"next_run,omitempty"`
+	LastRun      time.Time `json:"last_run,omitempty"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	Local        bool      `json:"local"` // runs on every replica, not only the leader
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+}
+
+var (
+	jobsMu    sync.Mutex
+	jobStatus = map[string]*JobStatus{}
+)
+
+// scheduledJobs is the list of jobs the scheduler runs.
+func scheduledJobs() []ScheduledJob {
+	return []ScheduledJob{
+		{Name: "webhook_sync", DefaultSpec: "0 * * * *", Run: syncWebhooks},
+		{Name: "event_retention", DefaultSpec: "15 3 * * *", Run: pruneEventStore, Local: true},
+		{Name: "pr_snapshot_refresh", DefaultSpec: "* * * * *", Run: refreshPRSnapshots, Local: true},
+		{Name: "auto_merge", DefaultSpec: "*/5 * * * *", Run: sweepAutoMerge},
+		{Name: "label_sync", DefaultSpec: "45 * * * *", Run: syncLabels},
+		{Name: "usage_flush", DefaultSpec: "*/5 * * * *", Run: flushUsage, Local: true},
+		{Name: "stale_pr_scan", DefaultSpec: "0 9 * * *", Run: scanStalePRs},
+		{Name: "digest", DefaultSpec: "0 8 * * *", Run: sendDigests, Local: true},
+	}
+}
+
+// StartScheduler launches one goroutine per enabled job and returns.
+func StartScheduler() {
+	jitter := envInt("SCHEDULER_JITTER_SECONDS", 30)
+
+	for _, job := range scheduledJobs() {
+		spec := job.DefaultSpec
+		if v := os.Getenv("SCHEDULE_" + strings.ToUpper(job.Name)); v != "" {
+			spec = v
+		}
+		status := &JobStatus{Name: job.Name, Schedule: spec, Local: job.Local}
+		jobsMu.Lock()
+		jobStatus[job.Name] = status
+		jobsMu.Unlock()
+
+		if strings.EqualFold(spec, "off") {
+			log.Printf("[Scheduler] Job %s disabled\n", job.Name)
+			continue
+		}
+		sched, err := parseCron(spec)
+		if err != nil {
+			log.Printf("[Scheduler] Warning: job %s disabled: %v\n", job.Name, err)
+			status.LastError = err.Error()
+			continue
+		}
+		status.Enabled = true
+		log.Printf("[Scheduler] Job %s scheduled %q\n", job.Name, spec)
+		go runJob(job, sched, status, jitter)
+	}
+}
+
+// runJob runs job at every tick of sched until the process exits.
+func runJob(job ScheduledJob, sched *cronSchedule, status *JobStatus, jitterSeconds int) {
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("[Scheduler] Warning: job %s never fires again, stopping\n", job.Name)
+			return
+		}
+		jobsMu.Lock()
+		status.NextRun = next
+		jobsMu.Unlock()
+
+		delay := time.Until(next)
+		if jitterSeconds > 0 {
+			delay += time.Duration(rand.Intn(jitterSeconds*1000)) * time.Millisecond
+		}
+		time.Sleep(delay)
+
+		if !job.Local && !isLeader() {
+			continue
+		}
+		runJobOnce(job, status)
+	}
+}
+
+// runJobOnce executes the job and records the outcome. A panic is reported
+// as a failure instead of killing the process.
+func runJobOnce(job ScheduledJob, status *JobStatus) {
+	jobsMu.Lock()
+	if status.Running {
+		jobsMu.Unlock()
+		log.Printf("[Scheduler] Warning: job %s still running, skipping this run\n", job.Name)
+		return
+	}
+	status.Running = true
+	jobsMu.Unlock()
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.Run()
+	}()
+	elapsed := time.Since(start)
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	status.Running = false
+	status.LastRun = start
+	status.LastDuration = elapsed.Round(time.Millisecond).String()
+	status.Runs++
+	status.LastError = ""
+	if err != nil {
+		status.Failures++
+		status.LastError = err.Error()
+		log.Printf("[Scheduler] Warning: job %s failed after %s: %v\n", job.Name, status.LastDuration, err)
+		return
+	}
+	log.Printf("[Scheduler] Job %s finished in %s\n", job.Name, status.LastDuration)
+}
+
+// AdminJobsHandler reports scheduler job status.
+//
+//	GET /admin/jobs
+func AdminJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	jobsMu.Lock()
+	jobs := make([]JobStatus, 0, len(jobStatus))
+	for _, s := range jobStatus {
+		jobs = append(jobs, *s)
+	}
+	jobsMu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"leader": isLeader(),
+		"jobs":   jobs,
+	})
+}
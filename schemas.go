@@ -0,0 +1,456 @@
+package main
+
+// Event schema registry — JSON Schemas for every normalized event type and
+// schema version, served to consumers:
+//
+//	GET /schemas                          versions and their event types
+//	GET /schemas/{version}                every schema of a version
+//	GET /schemas/{version}/{event_type}   one schema, e.g. /schemas/1/pull_request.opened
+//
+// The schemas are written out by hand rather than derived from
+// NormalizedEvent, so a change to the struct shows up as a mismatch instead
+// of silently changing the contract. With SCHEMA_VALIDATION=warn every
+// outgoing event is validated against its schema and violations are logged;
+// with SCHEMA_VALIDATION=strict violating events are also not delivered and
+// go to the dead-letter queue. Event types without a schema of their own
+// (GitHub's legacy "pull_request.<action>") use "pull_request.*".
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schema is a JSON Schema document.
+type schema = map[string]interface{}
+
+// genericEventSchema is the registry key of the fallback schema.
+const genericEventSchema = "pull_request.*"
+
+// schemaEventActions lists, per normalized event type, the actions it
+// carries (nil: any action).
+var schemaEventActions = map[string][]string{
+	"pull_request.opened":      {"opened"},
+	"pull_request.synchronize": {"synchronize"},
+	"pull_request.updated":     {"synchronize"},
+	"pull_request.reopened":    {"reopened"},
+	"pull_request.closed":      {"closed"},
+	"pull_request.unknown":     {"unknown"},
+	EventTypeOther:             nil,
+	genericEventSchema:         nil,
+	EventTypeThreadResolved:    {"resolved"},
+	EventTypeThreadUnresolved:  {"unresolved"},
+	EventTypeAutoMerged:        {"auto_merged"},
+	EventTypeDependencyBlocked: {"dependency_blocked"},
+	EventTypeStale:             {"stale"},
+	EventTypeSecurityAlert:     nil,
+}
+
+// eventSchemas holds the schemas by version and event type.
+var eventSchemas = map[int]map[string]schema{
+	1: eventSchemasV1(),
+}
+
+func objectSchema(props schema) schema {
+	required := make([]string, 0, len(props))
+	for name := range props {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return schema{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func nullableArray(items schema) schema {
+	return schema{"type": []string{"array", "null"}, "items": items}
+}
+
+func nullableObject(props schema) schema {
+	s := objectSchema(props)
+	s["type"] = []string{"object", "null"}
+	return s
+}
+
+var (
+	stringSchema  = schema{"type": "string"}
+	integerSchema = schema{"type": "integer"}
+)
+
+// eventSchemasV1 returns the schemas of NormalizedSchemaVersion 1.
+func eventSchemasV1() map[string]schema {
+	pr := objectSchema(schema{
+		"Number":             integerSchema,
+		"Title":              stringSchema,
+		"Description":        stringSchema,
+		"Author":             stringSchema,
+		"SourceBranch":       stringSchema,
+		"TargetBranch":       stringSchema,
+		"HeadSHA":            stringSchema,
+		"BaseSHA":            stringSchema,
+		"State":              stringSchema,
+		"URL":                stringSchema,
+		"Draft":              schema{"type": "boolean"},
+		"Mergeable":          schema{"type": []string{"boolean", "null"}},
+		"Labels":             nullableArray(stringSchema),
+		"Assignees":          nullableArray(stringSchema),
+		"RequestedReviewers": nullableArray(stringSchema),
+		"RequestedTeams":     nullableArray(stringSchema),
+		"MergeableState": schema{"enum": []string{
+			"clean", "dirty", "blocked", "behind", "unstable", "draft", "unknown", "has_hooks", "",
+		}},
+	})
+	repository := objectSchema(schema{
+		"ID":            stringSchema,
+		"Name":          stringSchema,
+		"FullName":      stringSchema,
+		"Owner":         stringSchema,
+		"CloneURL":      stringSchema,
+		"HTMLURL":       stringSchema,
+		"DefaultBranch": stringSchema,
+		"Visibility":    schema{"enum": []string{"public", "private", "internal", ""}},
+		"Language":      stringSchema,
+	})
+	file := objectSchema(schema{
+		"Filename":         stringSchema,
+		"Status":           schema{"enum": []string{"added", "modified", "removed", "renamed"}},
+		"Additions":        integerSchema,
+		"Deletions":        integerSchema,
+		"Changes":          integerSchema,
+		"PreviousFilename": stringSchema,
+		"Patch":            stringSchema,
+		"PatchTruncated":   schema{"type": "boolean"},
+		"IsBinary":         schema{"type": "boolean"},
+		"SizeBytes":        integerSchema,
+	})
+	commit := objectSchema(schema{
+		"SHA":       stringSchema,
+		"Author":    stringSchema,
+		"Message":   stringSchema,
+		"Timestamp": schema{"type": "string", "format": "date-time"},
+	})
+	ticket := objectSchema(schema{
+		"Key":       stringSchema,
+		"Source":    schema{"enum": []string{"branch", "title", "description"}},
+		"URL":       stringSchema,
+		"Summary":   stringSchema,
+		"Status":    stringSchema,
+		"Validated": schema{"type": "boolean"},
+	})
+	dependency := objectSchema(schema{
+		"Repository": stringSchema,
+		"Number":     integerSchema,
+		"Title":      stringSchema,
+		"State":      stringSchema,
+		"URL":        stringSchema,
+		"Validated":  schema{"type": "boolean"},
+	})
+	identity := nullableObject(schema{
+		"ID":     stringSchema,
+		"Email":  stringSchema,
+		"Name":   stringSchema,
+		"Source": schema{"enum": []string{"file", "scim"}},
+	})
+	origin := nullableObject(schema{
+		"Gateway": stringSchema,
+		"Hops":    schema{"type": "array", "items": stringSchema},
+	})
+	finding := objectSchema(schema{
+		"Policy":   stringSchema,
+		"Rule":     stringSchema,
+		"Path":     stringSchema,
+		"Line":     integerSchema,
+		"Severity": schema{"enum": []string{SeverityWarning, SeverityError}},
+		"Message":  stringSchema,
+	})
+	thread := objectSchema(schema{
+		"ID":         stringSchema,
+		"Path":       stringSchema,
+		"Line":       integerSchema,
+		"Author":     stringSchema,
+		"Body":       stringSchema,
+		"URL":        stringSchema,
+		"Resolved":   schema{"type": "boolean"},
+		"ResolvedBy": stringSchema,
+		"Unresolved": integerSchema,
+	})
+	pkg := objectSchema(schema{
+		"Ecosystem":          stringSchema,
+		"Name":               stringSchema,
+		"VulnerableVersions": stringSchema,
+		"PatchedVersion":     stringSchema,
+		"Manifest":           stringSchema,
+	})
+	security := objectSchema(schema{
+		"Source":   schema{"enum": []string{githubDependabotAlertEvent, githubCodeScanningAlertEvent, githubSecurityAdvisoryEvent}},
+		"ID":       stringSchema,
+		"State":    stringSchema,
+		"Severity": schema{"enum": []string{"critical", "high", "medium", "low", "unknown"}},
+		"Summary":  stringSchema,
+		"URL":      stringSchema,
+		"GHSA":     stringSchema,
+		"CVE":      stringSchema,
+		"Packages": nullableArray(pkg),
+		"Rule":     stringSchema,
+		"Tool":     stringSchema,
+		"Path":     stringSchema,
+		"Line":     integerSchema,
+	})
+
+	out := map[string]schema{}
+	for eventType, actions := range schemaEventActions {
+		eventTypeSchema := schema{"const": eventType}
+		if eventType == genericEventSchema {
+			eventTypeSchema = schema{"type": "string", "pattern": `^pull_request\.[a-z_]+$`}
+		}
+		actionSchema := stringSchema
+		if actions != nil {
+			actionSchema = schema{"enum": actions}
+		}
+		threadSchema := schema{"type": "null"}
+		if strings.HasPrefix(eventType, "thread.") {
+			threadSchema = thread
+		}
+		securitySchema := schema{"type": "null"}
+		if eventType == EventTypeSecurityAlert {
+			securitySchema = security
+		}
+		s := objectSchema(schema{
+			"EventID":           stringSchema,
+			"DeliveryID":        stringSchema,
+			"SchemaVersion":     schema{"const": 1},
+			"Platform":          schema{"enum": []string{string(PlatformGitHub), string(PlatformBitbucket), string(PlatformGerrit), string(PlatformGitea), string(PlatformBitbucketServer), string(PlatformCodeCommit)}},
+			"EventType":         eventTypeSchema,
+			"Action":            actionSchema,
+			"PR":                pr,
+			"Repository":        repository,
+			"Files":             nullableArray(file),
+			"Commits":           nullableArray(commit),
+			"Owners":            nullableArray(stringSchema),
+			"Tickets":           nullableArray(ticket),
+			"Dependencies":      nullableArray(dependency),
+			"CanonicalAuthor":   identity,
+			"Origin":            origin,
+			"Thread":            threadSchema,
+			"Security":          securitySchema,
+			"PolicyFindings":    nullableArray(finding),
+			"EnrichmentSkipped": stringSchema,
+			"StaleReason":       stringSchema,
+			"RawPayload":        schema{"type": []string{"string", "null"}, "contentEncoding": "base64"},
+			"ReceivedAt":        schema{"type": "string", "format": "date-time"},
+		})
+		s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		s["$id"] = fmt.Sprintf("/schemas/1/%s", eventType)
+		s["title"] = eventType
+		out[eventType] = s
+	}
+	return out
+}
+
+// eventSchemaFor returns the schema an event of eventType and version is
+// validated against.
+func eventSchemaFor(version int, eventType string) (schema, bool) {
+	schemas, ok := eventSchemas[version]
+	if !ok {
+		return nil, false
+	}
+	if s, ok := schemas[eventType]; ok {
+		return s, true
+	}
+	s, ok := schemas[genericEventSchema]
+	return s, ok
+}
+
+// validateEvent checks the JSON encoding of event against its schema and
+// returns the violations.
+func validateEvent(event *NormalizedEvent) []string {
+	s, ok := eventSchemaFor(event.SchemaVersion, event.EventType)
+	if !ok {
+		return []string{fmt.Sprintf("no schema for version %d", event.SchemaVersion)}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []string{err.Error()}
+	}
+	var violations []string
+	validateValue(s, doc, "$", &violations)
+	return violations
+}
+
+// validateValue checks v against the subset of JSON Schema the registry
+// uses: type, const, enum, pattern, properties, required,
+// additionalProperties and items.
+func validateValue(s schema, v interface{}, path string, violations *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []string:
+			types = t
+		}
+		matched := false
+		for _, name := range types {
+			if jsonTypeMatches(name, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), jsonTypeOf(v))
+			return
+		}
+	}
+	if c, ok := s["const"]; ok && fmt.Sprint(c) != fmt.Sprint(v) {
+		fail("expected %v, got %v", c, v)
+	}
+	if enum, ok := s["enum"].([]string); ok {
+		str, _ := v.(string)
+		found := false
+		for _, e := range enum {
+			if e == str {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%v is not one of %s", v, strings.Join(enum, ", "))
+		}
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if str, _ := v.(string); !regexp.MustCompile(pattern).MatchString(str) {
+			fail("%q does not match %s", str, pattern)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := s["properties"].(schema)
+		if required, ok := s["required"].([]string); ok {
+			for _, name := range required {
+				if _, ok := v[name]; !ok {
+					fail("missing property %s", name)
+				}
+			}
+		}
+		for name, child := range v {
+			if ps, ok := props[name].(schema); ok {
+				validateValue(ps, child, path+"."+name, violations)
+			} else if s["additionalProperties"] == false {
+				fail("unexpected property %s", name)
+			}
+		}
+	case []interface{}:
+		if items, ok := s["items"].(schema); ok {
+			for i, item := range v {
+				validateValue(items, item, path+"["+strconv.Itoa(i)+"]", violations)
+			}
+		}
+	}
+}
+
+func jsonTypeMatches(name string, v interface{}) bool {
+	if name == "integer" {
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	}
+	return jsonTypeOf(v) == name
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// schemaValidationMode returns "warn", "strict" or "" (off).
+func schemaValidationMode() string {
+	switch mode := os.Getenv("SCHEMA_VALIDATION"); mode {
+	case "warn", "strict":
+		return mode
+	case "":
+		return ""
+	default:
+		log.Printf("[Schemas] Warning: unknown SCHEMA_VALIDATION %q, validation disabled\n", mode)
+		return ""
+	}
+}
+
+// SchemasHandler serves the schema registry.
+func SchemasHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/schemas"), "/")
+	if rest == "" {
+		versions := map[string][]string{}
+		for version, schemas := range eventSchemas {
+			var types []string
+			for eventType := range schemas {
+				types = append(types, eventType)
+			}
+			sort.Strings(types)
+			versions[strconv.Itoa(version)] = types
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"current":  NormalizedSchemaVersion,
+			"versions": versions,
+		})
+		return
+	}
+
+	versionStr, eventType, _ := strings.Cut(rest, "/")
+	version, err := strconv.Atoi(strings.TrimPrefix(versionStr, "v"))
+	schemas, ok := eventSchemas[version]
+	if err != nil || !ok {
+		http.Error(w, "unknown schema version: "+versionStr, http.StatusNotFound)
+		return
+	}
+	if eventType == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"version": version,
+			"schemas": schemas,
+		})
+		return
+	}
+	s, ok := schemas[eventType]
+	if !ok {
+		http.Error(w, "unknown event type: "+eventType, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s)
+}
@@ -1,36 +1,85 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // BitbucketAdapter implements SCMAdapter for Bitbucket Cloud.
 //
 // Authentication uses Bitbucket App Passwords (HTTP Basic Auth).
-// Required env vars: BITBUCKET_USERNAME, BITBUCKET_APP_PASSWORD.
+// Required env vars: BITBUCKET_USERNAME, BITBUCKET_APP_PASSWORD, unless every
+// workspace has its own entry in BITBUCKET_CREDENTIALS_FILE
+// (see bitbucket_credentials.go).
+// Alternatively, with BITBUCKET_CONNECT_KEY set the adapter authenticates as
+// a Bitbucket Connect app, using the JWT of the workspace's installation
+// (see bitbucket_connect.go).
 //
 // Relevant Bitbucket API v2 endpoints used:
 //   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}
+//   PUT  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}
 //   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/diffstat
+//   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/commits
+//   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/diff
+//   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/comments
+//   POST /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/comments
+//   POST /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/comments/{comment_id}/resolve
+//   GET  /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/statuses
+//   POST /2.0/repositories/{workspace}/{repo}/pullrequests/{id}/merge
+//   GET  /2.0/repositories/{workspace}/{repo}/src/{ref}/{path}?format=meta
+//   GET  /2.0/repositories/{workspace}/{repo}
+//   GET  /2.0/repositories/{workspace}/{repo}/commits
+//   PUT  /2.0/repositories/{workspace}/{repo}/commit/{sha}/reports/{id}
+//   POST /2.0/repositories/{workspace}/{repo}/commit/{sha}/reports/{id}/annotations
+//   POST /2.0/repositories/{workspace}/{repo}/pipelines/
 type BitbucketAdapter struct {
+	requestScope
 	username    string
 	appPassword string
+	connect     bool // authenticate as the Connect app instead
 	baseURL     string
 }
 
+// BitbucketAdapter implements every adapter capability.
+var (
+	_ SCMAdapter      = (*BitbucketAdapter)(nil)
+	_ PRReader        = (*BitbucketAdapter)(nil)
+	_ PRWriter        = (*BitbucketAdapter)(nil)
+	_ PRCommentReader = (*BitbucketAdapter)(nil)
+	_ ReviewCreator   = (*BitbucketAdapter)(nil)
+	_ CheckPublisher  = (*BitbucketAdapter)(nil)
+	_ StatusPublisher = (*BitbucketAdapter)(nil)
+	_ PRLabeler       = (*BitbucketAdapter)(nil)
+	_ ThreadResolver  = (*BitbucketAdapter)(nil)
+	_ PRMerger        = (*BitbucketAdapter)(nil)
+	_ RepoReader      = (*BitbucketAdapter)(nil)
+	_ FileReader      = (*BitbucketAdapter)(nil)
+	_ ContentWriter   = (*BitbucketAdapter)(nil)
+	_ CITrigger       = (*BitbucketAdapter)(nil)
+)
+
 // NewBitbucketAdapter creates a BitbucketAdapter from environment credentials.
 func NewBitbucketAdapter() (*BitbucketAdapter, error) {
+	if connectAppKey() != "" {
+		return &BitbucketAdapter{connect: true, baseURL: "https://api.bitbucket.org/2.0"}, nil
+	}
 	username := os.Getenv("BITBUCKET_USERNAME")
 	appPassword := os.Getenv("BITBUCKET_APP_PASSWORD")
-	if username == "" || appPassword == "" {
-		return nil, fmt.Errorf("Bitbucket adapter: BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD must be set")
+	if (username == "" || appPassword == "") && len(loadBitbucketCredentials()) == 0 {
+		return nil, fmt.Errorf("Bitbucket adapter: BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD (or BITBUCKET_CREDENTIALS_FILE) must be set")
 	}
 	return &BitbucketAdapter{
 		username:    username,
@@ -45,12 +94,68 @@ func (b *BitbucketAdapter) Platform() SCMPlatform {
 
 // request makes an authenticated GET request to the Bitbucket API.
 func (b *BitbucketAdapter) request(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return b.do("GET", url, nil)
+}
+
+// do makes an authenticated request to the Bitbucket API, JSON-encoding body
+// when it is non-nil.
+func (b *BitbucketAdapter) do(method, url string, body interface{}) ([]byte, error) {
+	return b.doAccept(method, url, body, "application/json")
+}
+
+// doAccept is do with an explicit Accept header, for the endpoints that
+// return plain text (e.g. /diff).
+func (b *BitbucketAdapter) doAccept(method, url string, body interface{}, accept string) ([]byte, error) {
+	var reqBody io.Reader
+	contentType := ""
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+		contentType = "application/json"
+	}
+	return b.send(method, url, reqBody, contentType, accept)
+}
+
+// doForm POSTs form-encoded fields, for the endpoints that take no JSON
+// (e.g. /src).
+func (b *BitbucketAdapter) doForm(endpoint string, form url.Values) ([]byte, error) {
+	return b.send("POST", endpoint, strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", "application/json")
+}
+
+// bitbucketAPIError is a 4xx/5xx response of the Bitbucket API.
+type bitbucketAPIError struct {
+	Status int
+	Body   string
+}
+
+func (e *bitbucketAPIError) Error() string {
+	return fmt.Sprintf("Bitbucket API %d: %s", e.Status, e.Body)
+}
+
+// send makes an authenticated request to the Bitbucket API.
+func (b *BitbucketAdapter) send(method, url string, reqBody io.Reader, contentType, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(b.requestContext(), method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(b.username, b.appPassword)
-	req.Header.Set("Accept", "application/json")
+	if b.connect {
+		if err := connectAuthorize(req); err != nil {
+			return nil, err
+		}
+	} else {
+		username, appPassword := b.credentialsFor(bitbucketWorkspace(req.URL))
+		if username == "" || appPassword == "" {
+			return nil, fmt.Errorf("Bitbucket adapter: no credentials for workspace %q", bitbucketWorkspace(req.URL))
+		}
+		req.SetBasicAuth(username, appPassword)
+	}
+	setAPIHeaders(req, accept)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	resp, err := (&http.Client{}).Do(req)
 	if err != nil {
@@ -58,14 +163,14 @@ func (b *BitbucketAdapter) request(url string) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("Bitbucket API %d: %s", resp.StatusCode, string(body))
+		return nil, &bitbucketAPIError{Status: resp.StatusCode, Body: string(respBody)}
 	}
-	return body, nil
+	return respBody, nil
 }
 
 // bbPRResponse is the subset of the Bitbucket PR API response we care about.
@@ -82,17 +187,39 @@ type bbPRResponse struct {
 		Branch struct {
 			Name string `json:"name"`
 		} `json:"branch"`
+		Commit struct {
+			Hash string `json:"hash"` // abbreviated to 12 characters
+		} `json:"commit"`
 	} `json:"source"`
 	Destination struct {
 		Branch struct {
 			Name string `json:"name"`
 		} `json:"branch"`
+		Commit struct {
+			Hash string `json:"hash"` // abbreviated to 12 characters
+		} `json:"commit"`
 	} `json:"destination"`
 	Links struct {
 		HTML struct {
 			Href string `json:"href"`
 		} `json:"html"`
 	} `json:"links"`
+	Draft     bool        `json:"draft"`
+	Reviewers bbReviewers `json:"reviewers"` // only in single-PR responses
+}
+
+// bbReviewers decodes a PR's reviewers, keeping their nicknames. Bitbucket
+// Cloud PRs have no labels or assignees.
+type bbReviewers []struct {
+	Nickname string `json:"nickname"`
+}
+
+func (r bbReviewers) nicknames() []string {
+	out := make([]string, len(r))
+	for i, reviewer := range r {
+		out[i] = reviewer.Nickname
+	}
+	return out
 }
 
 func (b *BitbucketAdapter) GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error) {
@@ -114,11 +241,350 @@ func (b *BitbucketAdapter) GetPRDetails(owner, repo string, prNumber int) (*Norm
 		Author:       pr.Author.Nickname,
 		SourceBranch: pr.Source.Branch.Name,
 		TargetBranch: pr.Destination.Branch.Name,
+		HeadSHA:      pr.Source.Commit.Hash,
+		BaseSHA:      pr.Destination.Commit.Hash,
 		State:        strings.ToLower(pr.State),
 		URL:          pr.Links.HTML.Href,
+		Draft:        pr.Draft, // Bitbucket Cloud does not report mergeability
+
+		RequestedReviewers: pr.Reviewers.nicknames(),
 	}, nil
 }
 
+func (b *BitbucketAdapter) ListOpenPRs(owner, repo string) ([]NormalizedPR, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests?state=OPEN&pagelen=50", b.baseURL, owner, repo)
+
+	var prs []NormalizedPR
+	err := paginate(url, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []bbPRResponse `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse pull requests response: %w", err)
+		}
+		for _, pr := range page.Values {
+			prs = append(prs, NormalizedPR{
+				Number:       pr.ID,
+				Title:        pr.Title,
+				Description:  pr.Description,
+				Author:       pr.Author.Nickname,
+				SourceBranch: pr.Source.Branch.Name,
+				TargetBranch: pr.Destination.Branch.Name,
+				HeadSHA:      pr.Source.Commit.Hash,
+				BaseSHA:      pr.Destination.Commit.Hash,
+				State:        strings.ToLower(pr.State),
+				URL:          pr.Links.HTML.Href,
+				Draft:        pr.Draft,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: ListOpenPRs failed: %w", err)
+	}
+	return prs, nil
+}
+
+func (b *BitbucketAdapter) UpdatePRDescription(owner, repo string, prNumber int, description string) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", b.baseURL, owner, repo, prNumber)
+	if _, err := b.do("PUT", url, map[string]string{"description": description}); err != nil {
+		return fmt.Errorf("Bitbucket adapter: UpdatePRDescription failed: %w", err)
+	}
+	return nil
+}
+
+// GetPRLabels reads the labels emulated as "[label]" title prefixes, since
+// Bitbucket pull requests have no labels.
+func (b *BitbucketAdapter) GetPRLabels(owner, repo string, prNumber int) ([]string, error) {
+	pr, err := b.GetPRDetails(owner, repo, prNumber)
+	if err != nil {
+		return nil, err
+	}
+	labels, _ := titleLabels(pr.Title)
+	if labels == nil {
+		labels = []string{}
+	}
+	return labels, nil
+}
+
+// setTitleLabels rewrites the PR title with labels as its prefixes.
+func (b *BitbucketAdapter) setTitleLabels(owner, repo string, prNumber int, change func(labels []string) []string) error {
+	pr, err := b.GetPRDetails(owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+	labels, rest := titleLabels(pr.Title)
+	title := withTitleLabels(change(labels), rest)
+	if title == pr.Title {
+		return nil
+	}
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", b.baseURL, owner, repo, prNumber)
+	if _, err := b.do("PUT", url, map[string]string{"title": title}); err != nil {
+		return fmt.Errorf("Bitbucket adapter: updating the PR title failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketAdapter) AddLabels(owner, repo string, prNumber int, labels []string) error {
+	return b.setTitleLabels(owner, repo, prNumber, func(have []string) []string {
+		for _, l := range labels {
+			if !containsFold(have, l) {
+				have = append(have, l)
+			}
+		}
+		return have
+	})
+}
+
+func (b *BitbucketAdapter) RemoveLabel(owner, repo string, prNumber int, label string) error {
+	return b.setTitleLabels(owner, repo, prNumber, func(have []string) []string {
+		kept := have[:0]
+		for _, l := range have {
+			if !strings.EqualFold(l, label) {
+				kept = append(kept, l)
+			}
+		}
+		return kept
+	})
+}
+
+// bbCommitsResponse is one page of the Bitbucket PR commits API response.
+type bbCommitsResponse struct {
+	Values []struct {
+		Hash    string    `json:"hash"`
+		Message string    `json:"message"`
+		Date    time.Time `json:"date"`
+		Author  struct {
+			Raw  string `json:"raw"` // "Name <email>"
+			User *struct {
+				Nickname string `json:"nickname"`
+			} `json:"user"`
+		} `json:"author"`
+	} `json:"values"`
+	Next string `json:"next"`
+}
+
+func (b *BitbucketAdapter) GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/commits", b.baseURL, owner, repo, prNumber)
+
+	var commits []NormalizedCommit
+	err := paginate(url, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var resp bbCommitsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse commits response: %w", err)
+		}
+		for _, c := range resp.Values {
+			author := c.Author.Raw
+			if c.Author.User != nil && c.Author.User.Nickname != "" {
+				author = c.Author.User.Nickname
+			}
+			commits = append(commits, NormalizedCommit{
+				SHA:       c.Hash,
+				Author:    author,
+				Message:   c.Message,
+				Timestamp: c.Date,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetPRCommits failed: %w", err)
+	}
+
+	// Bitbucket lists newest first; normalize to oldest first like GitHub.
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+func (b *BitbucketAdapter) GetPRDiff(owner, repo string, prNumber int) (string, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/diff", b.baseURL, owner, repo, prNumber)
+	body, err := b.doAccept("GET", url, nil, "text/plain")
+	if err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: GetPRDiff failed: %w", err)
+	}
+	return string(body), nil
+}
+
+func (b *BitbucketAdapter) PostComment(owner, repo string, prNumber int, body string) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments", b.baseURL, owner, repo, prNumber)
+	comment := map[string]interface{}{
+		"content": map[string]string{"raw": body},
+	}
+	rememberSelfComment(PlatformBitbucket, owner+"/"+repo, prNumber, body)
+	if _, err := b.do("POST", url, comment); err != nil {
+		return fmt.Errorf("Bitbucket adapter: PostComment failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketAdapter) UpdateComment(owner, repo string, prNumber int, commentID, body string) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments/%s", b.baseURL, owner, repo, prNumber, commentID)
+	comment := map[string]interface{}{
+		"content": map[string]string{"raw": body},
+	}
+	rememberSelfComment(PlatformBitbucket, owner+"/"+repo, prNumber, body)
+	if _, err := b.do("PUT", url, comment); err != nil {
+		return fmt.Errorf("Bitbucket adapter: UpdateComment failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketAdapter) GetFileSize(owner, repo, ref, path string) (int64, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/src/%s/%s?format=meta", b.baseURL, owner, repo, ref, path)
+	body, err := b.request(url)
+	if err != nil {
+		return 0, fmt.Errorf("Bitbucket adapter: GetFileSize failed: %w", err)
+	}
+
+	var meta struct {
+		Size int64 `json:"size"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return 0, fmt.Errorf("Bitbucket adapter: failed to parse src meta response: %w", err)
+	}
+	return meta.Size, nil
+}
+
+// GetRepoStats derives statistics from the repository and its recent
+// commits: Bitbucket reports a single language and has no contributor API,
+// so Contributors counts distinct authors of the last repoStatsWeeks weeks.
+func (b *BitbucketAdapter) GetRepoStats(owner, repo string) (*RepoStats, error) {
+	repoURL := fmt.Sprintf("%s/repositories/%s/%s", b.baseURL, owner, repo)
+	body, err := b.request(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetRepoStats failed: %w", err)
+	}
+	var meta struct {
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: failed to parse repository response: %w", err)
+	}
+
+	stats := &RepoStats{WeeklyCommits: make([]int, repoStatsWeeks)}
+	if meta.Language != "" {
+		stats.setLanguageBytes(map[string]int64{meta.Language: 1})
+	}
+
+	// Weeks are counted from a fixed now: time passes while paging, and a
+	// commit right at since must still land in the oldest week.
+	now := time.Now()
+	since := now.AddDate(0, 0, -7*repoStatsWeeks)
+	authors := map[string]bool{}
+	err = paginate(repoURL+"/commits?pagelen=100", b.bitbucketPages(), func(body []byte) (bool, error) {
+		var resp bbCommitsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse commits response: %w", err)
+		}
+		for _, c := range resp.Values {
+			if c.Date.Before(since) {
+				return false, nil
+			}
+			authors[c.Author.Raw] = true
+			week := int(now.Sub(c.Date).Hours() / (24 * 7))
+			if week < 0 {
+				week = 0 // committer clock ahead of ours
+			}
+			if week > repoStatsWeeks-1 {
+				week = repoStatsWeeks - 1
+			}
+			stats.WeeklyCommits[repoStatsWeeks-1-week]++
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetRepoStats commits failed: %w", err)
+	}
+	stats.Contributors = len(authors)
+	stats.summarizeActivity()
+
+	return stats, nil
+}
+
+// PublishCheckRun maps a check run onto a Code Insights report plus its
+// annotations, keyed by the check name so re-runs replace the old report.
+func (b *BitbucketAdapter) PublishCheckRun(owner, repo string, run CheckRun) error {
+	reportID := strings.ReplaceAll(strings.ToLower(run.Name), " ", "-")
+	reportURL := fmt.Sprintf("%s/repositories/%s/%s/commit/%s/reports/%s", b.baseURL, owner, repo, run.HeadSHA, reportID)
+
+	result := "PASSED"
+	if run.Conclusion == CheckFailure {
+		result = "FAILED"
+	}
+	report := map[string]interface{}{
+		"title":       run.Name,
+		"details":     run.Summary,
+		"report_type": "BUG",
+		"reporter":    "scm-gateway",
+		"result":      result,
+	}
+	if _, err := b.do("PUT", reportURL, report); err != nil {
+		return fmt.Errorf("Bitbucket adapter: PublishCheckRun failed: %w", err)
+	}
+	if len(run.Annotations) == 0 {
+		return nil
+	}
+
+	annotations := make([]map[string]interface{}, 0, len(run.Annotations))
+	for i, a := range run.Annotations {
+		if i == maxCheckAnnotations {
+			break
+		}
+		annotations = append(annotations, map[string]interface{}{
+			"external_id":     fmt.Sprintf("%s-%d", reportID, i+1),
+			"annotation_type": "CODE_SMELL",
+			"path":            a.Path,
+			"line":            a.StartLine,
+			"summary":         a.Message,
+			"severity":        bitbucketSeverity(a.Level),
+		})
+	}
+	if _, err := b.do("POST", reportURL+"/annotations", annotations); err != nil {
+		return fmt.Errorf("Bitbucket adapter: PublishCheckRun annotations failed: %w", err)
+	}
+	return nil
+}
+
+// bitbucketSeverity maps an annotation level to a Code Insights severity.
+func bitbucketSeverity(level string) string {
+	switch level {
+	case AnnotationFailure:
+		return "HIGH"
+	case AnnotationWarning:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// authenticatedCloneURL returns an HTTPS clone URL carrying the workspace's
+// app password (Connect: an access token for the workspace's installation).
+func (b *BitbucketAdapter) authenticatedCloneURL(owner, repo string) (string, error) {
+	if b.connect {
+		token, err := connectAccessToken(owner)
+		if err != nil {
+			return "", err
+		}
+		u := &url.URL{
+			Scheme: "https",
+			User:   url.UserPassword("x-token-auth", token),
+			Host:   "bitbucket.org",
+			Path:   fmt.Sprintf("/%s/%s.git", owner, repo),
+		}
+		return u.String(), nil
+	}
+	username, appPassword := b.credentialsFor(owner)
+	u := &url.URL{
+		Scheme: "https",
+		User:   url.UserPassword(username, appPassword),
+		Host:   "bitbucket.org",
+		Path:   fmt.Sprintf("/%s/%s.git", owner, repo),
+	}
+	return u.String(), nil
+}
+
 // bbDiffstatResponse is the Bitbucket diffstat API response structure.
 type bbDiffstatResponse struct {
 	Values []struct {
@@ -135,17 +601,20 @@ type bbDiffstatResponse struct {
 }
 
 func (b *BitbucketAdapter) GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error) {
-	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/diffstat", b.baseURL, owner, repo, prNumber)
-	body, err := b.request(url)
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/diffstat?pagelen=100", b.baseURL, owner, repo, prNumber)
+	var diffstat bbDiffstatResponse
+	err := paginate(url, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page bbDiffstatResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse diffstat response: %w", err)
+		}
+		diffstat.Values = append(diffstat.Values, page.Values...)
+		return true, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Bitbucket adapter: GetPRFiles failed: %w", err)
 	}
 
-	var diffstat bbDiffstatResponse
-	if err := json.Unmarshal(body, &diffstat); err != nil {
-		return nil, fmt.Errorf("Bitbucket adapter: failed to parse diffstat response: %w", err)
-	}
-
 	files := make([]NormalizedFile, 0, len(diffstat.Values))
 	for _, v := range diffstat.Values {
 		f := NormalizedFile{
@@ -162,6 +631,25 @@ func (b *BitbucketAdapter) GetPRFiles(owner, repo string, prNumber int) ([]Norma
 		}
 		files = append(files, f)
 	}
+
+	if filePatchesEnabled(PlatformBitbucket, owner+"/"+repo) {
+		diff, err := b.GetPRDiff(owner, repo, prNumber)
+		if err != nil {
+			// The file list is still worth delivering without patches.
+			log.Printf("[Bitbucket Adapter] Warning: could not fetch patches of PR #%d: %v\n", prNumber, err)
+			return files, nil
+		}
+		patches := diffPatches(diff)
+		for i := range files {
+			path := files[i].Filename
+			if path == "" {
+				path = files[i].PreviousFilename
+			}
+			if patch, ok := patches[path]; ok {
+				setPatch(&files[i], patch)
+			}
+		}
+	}
 	return files, nil
 }
 
@@ -199,23 +687,33 @@ type bbWebhookPayload struct {
 			Branch struct {
 				Name string `json:"name"`
 			} `json:"branch"`
+			Commit struct {
+				Hash string `json:"hash"` // abbreviated to 12 characters
+			} `json:"commit"`
 		} `json:"source"`
 		Destination struct {
 			Branch struct {
 				Name string `json:"name"`
 			} `json:"branch"`
+			Commit struct {
+				Hash string `json:"hash"` // abbreviated to 12 characters
+			} `json:"commit"`
 		} `json:"destination"`
 		Links struct {
 			HTML struct {
 				Href string `json:"href"`
 			} `json:"html"`
 		} `json:"links"`
+		Draft     bool        `json:"draft"`
+		Reviewers bbReviewers `json:"reviewers"`
 	} `json:"pullrequest"`
 
 	Repository struct {
-		Name     string `json:"name"`
-		FullName string `json:"full_name"` // "workspace/repo-slug"
-		Owner    struct {
+		UUID      string `json:"uuid"`
+		Name      string `json:"name"`
+		FullName  string `json:"full_name"` // "workspace/repo-slug"
+		IsPrivate bool   `json:"is_private"`
+		Owner     struct {
 			Nickname    string `json:"nickname"`
 			DisplayName string `json:"display_name"`
 		} `json:"owner"`
@@ -243,7 +741,14 @@ func mapBitbucketEventKey(key string) (eventType, action string) {
 		return "pull_request.closed", "closed"
 	case "pullrequest:rejected":
 		return "pull_request.closed", "closed"
+	case "pullrequest:comment_resolved":
+		return EventTypeThreadResolved, "resolved"
+	case "pullrequest:comment_reopened":
+		return EventTypeThreadUnresolved, "unresolved"
 	default:
+		if prActionPassthrough() {
+			return EventTypeOther, strings.TrimPrefix(key, "pullrequest:") // e.g. "approved"
+		}
 		return "pull_request.unknown", "unknown"
 	}
 }
@@ -288,30 +793,538 @@ func (b *BitbucketAdapter) NormalizeEvent(eventType string, payload []byte) (*No
 			Author:       pr.Author.Nickname,
 			SourceBranch: pr.Source.Branch.Name,
 			TargetBranch: pr.Destination.Branch.Name,
+			HeadSHA:      pr.Source.Commit.Hash,
+			BaseSHA:      pr.Destination.Commit.Hash,
 			State:        strings.ToLower(pr.State),
 			URL:          pr.Links.HTML.Href,
+			Draft:        pr.Draft,
+
+			RequestedReviewers: pr.Reviewers.nicknames(),
 		},
 		Repository: NormalizedRepository{
-			Name:     repoName,
-			FullName: repo.FullName,
-			Owner:    owner,
-			CloneURL: cloneURL,
-			HTMLURL:  repo.Links.HTML.Href,
+			ID:         repo.UUID,
+			Name:       repoName,
+			FullName:   repo.FullName,
+			Owner:      owner,
+			CloneURL:   cloneURL,
+			HTMLURL:    repo.Links.HTML.Href,
+			Visibility: "public",
 		},
 		RawPayload: payload,
 		ReceivedAt: time.Now(),
 	}
-
-	// Fetch changed files for opened / updated events.
-	if pr.ID != 0 && (action == "opened" || action == "synchronize") {
-		log.Printf("[Bitbucket Adapter] Fetching files for PR #%d in %s\n", pr.ID, repo.FullName)
-		files, err := b.GetPRFiles(owner, repoName, pr.ID)
+	if repo.IsPrivate {
+		event.Repository.Visibility = "private"
+	}
+	// Webhook payloads carry no main branch or language.
+	if repo.FullName != "" {
+		details, err := b.repoDetails(repo.FullName)
 		if err != nil {
-			log.Printf("[Bitbucket Adapter] Warning: could not fetch PR files: %v\n", err)
+			log.Printf("[Bitbucket Adapter] Warning: could not fetch details of %s: %v\n", repo.FullName, err)
 		} else {
-			event.Files = files
+			event.Repository.DefaultBranch, event.Repository.Language = details.MainBranch.Name, details.Language
 		}
 	}
+	if normalizedType == EventTypeThreadResolved || normalizedType == EventTypeThreadUnresolved {
+		event.Thread = bitbucketThread(normalizedType == EventTypeThreadResolved, payload)
+	}
+
+	// pullrequest:updated may drop the description key after an edit;
+	// an empty description is kept as is.
+	var raw struct {
+		PullRequest map[string]json.RawMessage `json:"pullrequest"`
+	}
+	json.Unmarshal(payload, &raw)
+	_, hasDescription := raw.PullRequest["description"]
+	backfillPRDetails(b, event, !hasDescription)
+	countUnresolvedThreads(b, event)
 
 	return event, nil
 }
+
+// bbRepoDetails are the repository fields webhook payloads leave out.
+type bbRepoDetails struct {
+	Language   string `json:"language"`
+	MainBranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+}
+
+var (
+	bitbucketRepoCacheOnce sync.Once
+	bitbucketRepoCacheRef  *ttlCache
+)
+
+// bitbucketRepoCache holds bbRepoDetails by full name; they rarely change.
+func bitbucketRepoCache() *ttlCache {
+	bitbucketRepoCacheOnce.Do(func() {
+		bitbucketRepoCacheRef = newTTLCache("bitbucket_repos", time.Hour)
+	})
+	return bitbucketRepoCacheRef
+}
+
+// repoDetails returns the main branch and language of a repository.
+func (b *BitbucketAdapter) repoDetails(fullName string) (*bbRepoDetails, error) {
+	if cached, ok := bitbucketRepoCache().Get(fullName); ok {
+		return cached.(*bbRepoDetails), nil
+	}
+	body, err := b.request(fmt.Sprintf("%s/repositories/%s", b.baseURL, fullName))
+	if err != nil {
+		return nil, err
+	}
+	var details bbRepoDetails
+	if err := json.Unmarshal(body, &details); err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: failed to parse repository response: %w", err)
+	}
+	bitbucketRepoCache().Set(fullName, &details)
+	return &details, nil
+}
+
+// bbComment is a PR comment of the Bitbucket API and comment webhooks.
+type bbComment struct {
+	ID        int       `json:"id"`
+	Deleted   bool      `json:"deleted"`
+	CreatedOn time.Time `json:"created_on"`
+	Parent    *struct {
+		ID int `json:"id"`
+	} `json:"parent"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	User struct {
+		Nickname string `json:"nickname"`
+	} `json:"user"`
+	Inline *struct {
+		Path string `json:"path"`
+		To   *int   `json:"to"`
+		From *int   `json:"from"`
+	} `json:"inline"`
+	Resolution *struct {
+		User struct {
+			Nickname string `json:"nickname"`
+		} `json:"user"`
+	} `json:"resolution"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// thread returns the review thread rooted at c.
+func (c bbComment) thread() NormalizedThread {
+	thread := NormalizedThread{
+		ID:       strconv.Itoa(c.ID),
+		Author:   c.User.Nickname,
+		Body:     c.Content.Raw,
+		URL:      c.Links.HTML.Href,
+		Resolved: c.Resolution != nil,
+	}
+	if c.Resolution != nil {
+		thread.ResolvedBy = c.Resolution.User.Nickname
+	}
+	if c.Inline != nil {
+		thread.Path = c.Inline.Path
+		if c.Inline.To != nil {
+			thread.Line = *c.Inline.To
+		} else if c.Inline.From != nil {
+			thread.Line = *c.Inline.From
+		}
+	}
+	return thread
+}
+
+// GetPRComments returns every comment on the PR, replies included; the API
+// lists them oldest first.
+func (b *BitbucketAdapter) GetPRComments(owner, repo string, prNumber int) ([]NormalizedComment, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments?pagelen=100", b.baseURL, owner, repo, prNumber)
+
+	var comments []NormalizedComment
+	err := paginate(url, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []bbComment `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse comments response: %w", err)
+		}
+		for _, c := range page.Values {
+			if c.Deleted {
+				continue
+			}
+			t := c.thread()
+			comments = append(comments, NormalizedComment{
+				ID:        t.ID,
+				Author:    t.Author,
+				Body:      t.Body,
+				CreatedAt: c.CreatedOn,
+				Path:      t.Path,
+				Line:      t.Line,
+				URL:       t.URL,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetPRComments failed: %w", err)
+	}
+	return comments, nil
+}
+
+// ListReviewThreads returns the PR's top-level comments, each the root of a
+// thread that can be resolved.
+func (b *BitbucketAdapter) ListReviewThreads(owner, repo string, prNumber int) ([]NormalizedThread, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments?pagelen=100", b.baseURL, owner, repo, prNumber)
+
+	var threads []NormalizedThread
+	err := paginate(url, b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []bbComment `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse comments response: %w", err)
+		}
+		for _, c := range page.Values {
+			if c.Parent == nil && !c.Deleted {
+				threads = append(threads, c.thread())
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: ListReviewThreads failed: %w", err)
+	}
+	return threads, nil
+}
+
+func (b *BitbucketAdapter) ResolveReviewThread(owner, repo string, prNumber int, threadID string) error {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments/%s/resolve", b.baseURL, owner, repo, prNumber, threadID)
+	if _, err := b.do("POST", url, nil); err != nil {
+		return fmt.Errorf("Bitbucket adapter: ResolveReviewThread failed: %w", err)
+	}
+	return nil
+}
+
+// bitbucketThread returns the thread of a pullrequest:comment_resolved or
+// pullrequest:comment_reopened payload.
+func bitbucketThread(resolved bool, payload []byte) *NormalizedThread {
+	var p struct {
+		Comment bbComment `json:"comment"`
+		Actor   struct {
+			Nickname string `json:"nickname"`
+		} `json:"actor"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil
+	}
+	thread := p.Comment.thread()
+	// The event key, not the comment, is authoritative about the state.
+	thread.Resolved, thread.ResolvedBy, thread.Unresolved = resolved, "", -1
+	if resolved {
+		thread.ResolvedBy = p.Actor.Nickname
+	}
+	return &thread
+}
+
+// GetMergeState reads approvals and build statuses. Bitbucket PRs have no
+// labels, and conflicts only surface when the merge is attempted.
+func (b *BitbucketAdapter) GetMergeState(owner, repo string, prNumber int) (*MergeState, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", b.baseURL, owner, repo, prNumber)
+	body, err := b.request(url)
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetMergeState failed: %w", err)
+	}
+	var pr struct {
+		Title  string `json:"title"`
+		State  string `json:"state"`
+		Draft  bool   `json:"draft"`
+		Source struct {
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+		Participants []struct {
+			Approved bool   `json:"approved"`
+			State    string `json:"state"` // "approved", "changes_requested" or null
+		} `json:"participants"`
+	}
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: failed to parse PR response: %w", err)
+	}
+	state := &MergeState{
+		HeadSHA: pr.Source.Commit.Hash,
+		Open:    pr.State == "OPEN",
+		Draft:   pr.Draft,
+		Checks:  map[string]string{},
+	}
+	state.Labels, _ = titleLabels(pr.Title)
+	for _, p := range pr.Participants {
+		if p.Approved {
+			state.Approvals++
+		}
+		if p.State == "changes_requested" {
+			state.ChangesRequested = true
+		}
+	}
+
+	err = paginate(url+"/statuses?pagelen=100", b.bitbucketPages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []struct {
+				Key   string `json:"key"`
+				Name  string `json:"name"`
+				State string `json:"state"` // SUCCESSFUL, INPROGRESS, FAILED, STOPPED
+			} `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket adapter: failed to parse statuses response: %w", err)
+		}
+		for _, st := range page.Values {
+			name := st.Name
+			if name == "" {
+				name = st.Key
+			}
+			switch st.State {
+			case "SUCCESSFUL":
+				state.Checks[name] = "success"
+			case "INPROGRESS":
+				state.Checks[name] = "pending"
+			default:
+				state.Checks[name] = "failure"
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetMergeState failed: %w", err)
+	}
+	return state, nil
+}
+
+// bitbucketMergeStrategies maps merge methods to Bitbucket merge strategies.
+var bitbucketMergeStrategies = map[string]string{
+	"merge":  "merge_commit",
+	"squash": "squash",
+	"rebase": "fast_forward",
+}
+
+// MergePR merges the PR. Bitbucket cannot pin the merge to a head commit, so
+// headSHA is compared with the PR's current head first.
+func (b *BitbucketAdapter) MergePR(owner, repo string, prNumber int, headSHA, method string) error {
+	strategy, ok := bitbucketMergeStrategies[method]
+	if !ok {
+		return fmt.Errorf("Bitbucket adapter: unknown merge method %q", method)
+	}
+	state, err := b.GetMergeState(owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+	if state.HeadSHA != headSHA {
+		return fmt.Errorf("Bitbucket adapter: MergePR: head moved from %s to %s", headSHA, state.HeadSHA)
+	}
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/merge", b.baseURL, owner, repo, prNumber)
+	if _, err := b.do("POST", url, map[string]string{"merge_strategy": strategy}); err != nil {
+		return fmt.Errorf("Bitbucket adapter: MergePR failed: %w", err)
+	}
+	return nil
+}
+
+// TriggerCI runs a Bitbucket Pipelines pipeline on a branch: the custom
+// pipeline req.Workflow, or the branch's default pipeline. Inputs become
+// pipeline variables. It returns the pipeline's build number.
+func (b *BitbucketAdapter) TriggerCI(owner, repo string, req DispatchRequest) (string, error) {
+	if req.Kind != dispatchPipeline {
+		return "", fmt.Errorf("Bitbucket adapter: unsupported dispatch kind %q", req.Kind)
+	}
+	if req.Ref == "" {
+		return "", fmt.Errorf("Bitbucket adapter: pipeline dispatch requires ref")
+	}
+	target := map[string]interface{}{
+		"type":     "pipeline_ref_target",
+		"ref_type": "branch",
+		"ref_name": req.Ref,
+	}
+	if req.Workflow != "" {
+		target["selector"] = map[string]string{"type": "custom", "pattern": req.Workflow}
+	}
+	pipeline := map[string]interface{}{"target": target}
+	if len(req.Inputs) > 0 {
+		variables := make([]map[string]string, 0, len(req.Inputs))
+		for key, value := range req.Inputs {
+			variables = append(variables, map[string]string{"key": key, "value": fmt.Sprint(value)})
+		}
+		pipeline["variables"] = variables
+	}
+
+	url := fmt.Sprintf("%s/repositories/%s/%s/pipelines/", b.baseURL, owner, repo)
+	body, err := b.do("POST", url, pipeline)
+	if err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: TriggerCI failed: %w", err)
+	}
+	var resp struct {
+		BuildNumber int `json:"build_number"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: failed to parse pipeline response: %w", err)
+	}
+	return strconv.Itoa(resp.BuildNumber), nil
+}
+
+// CreateReview posts the review the way Bitbucket models one: every inline
+// comment as a PR comment anchored with "inline" — "to" is a line of the new
+// file, "from" one of the old file (Side "LEFT"), neither a file-level
+// comment — then the body as a top-level comment, then the approval or
+// change request. Bitbucket has no atomic review, so a failure part-way
+// leaves the comments posted before it.
+func (b *BitbucketAdapter) CreateReview(owner, repo string, prNumber int, review PRReview) error {
+	prURL := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", b.baseURL, owner, repo, prNumber)
+	var verdict string
+	switch review.Event {
+	case "", ReviewEventComment:
+	case ReviewEventApprove:
+		verdict = "/approve"
+	case ReviewEventRequestChanges:
+		verdict = "/request-changes"
+	default:
+		return fmt.Errorf("Bitbucket adapter: unknown review event %q", review.Event)
+	}
+
+	for _, c := range review.Comments {
+		inline := map[string]interface{}{"path": c.Path}
+		if c.Line > 0 {
+			if c.Side == "LEFT" {
+				inline["from"] = c.Line
+			} else {
+				inline["to"] = c.Line
+			}
+		}
+		rememberSelfComment(PlatformBitbucket, owner+"/"+repo, prNumber, c.Body)
+		if _, err := b.do("POST", prURL+"/comments", map[string]interface{}{
+			"content": map[string]string{"raw": c.Body},
+			"inline":  inline,
+		}); err != nil {
+			return fmt.Errorf("Bitbucket adapter: CreateReview comment on %s failed: %w", c.Path, err)
+		}
+	}
+	if review.Body != "" {
+		if err := b.PostComment(owner, repo, prNumber, review.Body); err != nil {
+			return err
+		}
+	}
+	if verdict != "" {
+		if _, err := b.do("POST", prURL+verdict, nil); err != nil {
+			return fmt.Errorf("Bitbucket adapter: CreateReview %s failed: %w", review.Event, err)
+		}
+	}
+	return nil
+}
+
+// bitbucketBuildStates maps commit status states to build status states.
+var bitbucketBuildStates = map[string]string{
+	StatusPending: "INPROGRESS",
+	StatusSuccess: "SUCCESSFUL",
+	StatusFailure: "FAILED",
+	StatusError:   "FAILED",
+}
+
+// SetCommitStatus reports a build status keyed by context. Bitbucket keys
+// are at most 40 characters, so longer contexts are keyed by their SHA-1,
+// and a build status needs a URL, so the commit's page stands in when
+// targetURL is empty.
+func (b *BitbucketAdapter) SetCommitStatus(owner, repo, sha, state, context, description, targetURL string) error {
+	buildState, ok := bitbucketBuildStates[state]
+	if !ok {
+		return fmt.Errorf("Bitbucket adapter: unknown commit status state %q", state)
+	}
+	key := context
+	if len(key) > 40 {
+		sum := sha1.Sum([]byte(context))
+		key = hex.EncodeToString(sum[:])
+	}
+	if targetURL == "" {
+		targetURL = fmt.Sprintf("https://bitbucket.org/%s/%s/commits/%s", owner, repo, sha)
+	}
+
+	url := fmt.Sprintf("%s/repositories/%s/%s/commit/%s/statuses/build", b.baseURL, owner, repo, sha)
+	if _, err := b.do("POST", url, map[string]string{
+		"key":         key,
+		"state":       buildState,
+		"name":        context,
+		"description": description,
+		"url":         targetURL,
+	}); err != nil {
+		return fmt.Errorf("Bitbucket adapter: SetCommitStatus failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketAdapter) GetFileContent(owner, repo, ref, path string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/repositories/%s/%s/src/%s/%s", b.baseURL, owner, repo, url.PathEscape(ref), strings.TrimLeft(path, "/"))
+	body, err := b.doAccept("GET", endpoint, nil, "*/*")
+	var apiErr *bitbucketAPIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil, errFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket adapter: GetFileContent failed: %w", err)
+	}
+	return body, nil
+}
+
+func (b *BitbucketAdapter) CreateBranch(owner, repo, branch, from string) error {
+	endpoint := fmt.Sprintf("%s/repositories/%s/%s/refs/branches", b.baseURL, owner, repo)
+	body, err := b.request(endpoint + "/" + url.PathEscape(from))
+	if err != nil {
+		return fmt.Errorf("Bitbucket adapter: could not resolve %s: %w", from, err)
+	}
+	var ref struct {
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+	if err := json.Unmarshal(body, &ref); err != nil {
+		return fmt.Errorf("Bitbucket adapter: failed to parse branch response: %w", err)
+	}
+	if _, err := b.do("POST", endpoint, map[string]interface{}{
+		"name":   branch,
+		"target": map[string]string{"hash": ref.Target.Hash},
+	}); err != nil {
+		return fmt.Errorf("Bitbucket adapter: CreateBranch failed: %w", err)
+	}
+	return nil
+}
+
+// CreateCommit commits through the form-encoded /src endpoint, which
+// answers with no body: the commit SHA is not reported.
+func (b *BitbucketAdapter) CreateCommit(owner, repo string, commit FileCommit) (string, error) {
+	endpoint := fmt.Sprintf("%s/repositories/%s/%s/src", b.baseURL, owner, repo)
+	form := url.Values{}
+	form.Set(strings.TrimLeft(commit.Path, "/"), string(commit.Content))
+	form.Set("message", commit.Message)
+	form.Set("branch", commit.Branch)
+	if commit.Author != nil {
+		form.Set("author", fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email))
+	}
+	if _, err := b.doForm(endpoint, form); err != nil {
+		return "", fmt.Errorf("Bitbucket adapter: CreateCommit failed: %w", err)
+	}
+	return "", nil
+}
+
+func (b *BitbucketAdapter) CreatePR(owner, repo string, pr NewPR) (int, error) {
+	endpoint := fmt.Sprintf("%s/repositories/%s/%s/pullrequests", b.baseURL, owner, repo)
+	resp, err := b.do("POST", endpoint, map[string]interface{}{
+		"title":               pr.Title,
+		"description":         pr.Body,
+		"source":              map[string]interface{}{"branch": map[string]string{"name": pr.Head}},
+		"destination":         map[string]interface{}{"branch": map[string]string{"name": pr.Base}},
+		"close_source_branch": true,
+		"draft":               pr.Draft,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Bitbucket adapter: CreatePR failed: %w", err)
+	}
+	var created bbPRResponse
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return 0, fmt.Errorf("Bitbucket adapter: failed to parse PR response: %w", err)
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BitbucketServerAdapter implements SCMAdapter for self-hosted Bitbucket
+// Server and Data Center, whose REST 1.0 API and webhooks differ from
+// Bitbucket Cloud's: repositories belong to a project (the owner is the
+// project key, the repo its slug) and PR webhooks use "pr:*" event keys.
+//
+// Authentication uses an HTTP access token (personal, project or repository
+// token) sent as a bearer token.
+// Required env vars: BITBUCKET_SERVER_URL (e.g. https://bitbucket.example.com),
+// BITBUCKET_SERVER_TOKEN.
+//
+// Relevant Bitbucket Server REST 1.0 endpoints used:
+//
+//	GET  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}
+//	PUT  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}
+//	GET  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}/changes
+//	GET  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}/commits
+//	GET  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}.diff
+//	GET  /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests?state=OPEN
+//	POST /rest/api/1.0/projects/{key}/repos/{slug}/pull-requests/{id}/comments
+type BitbucketServerAdapter struct {
+	requestScope
+	baseURL string // API root, ending in /rest/api/1.0
+	token   string
+}
+
+// BitbucketServerAdapter reads, lists and writes pull requests and reads
+// files.
+var (
+	_ SCMAdapter = (*BitbucketServerAdapter)(nil)
+	_ PRReader   = (*BitbucketServerAdapter)(nil)
+	_ PRLister   = (*BitbucketServerAdapter)(nil)
+	_ PRWriter   = (*BitbucketServerAdapter)(nil)
+	_ FileReader = (*BitbucketServerAdapter)(nil)
+)
+
+// NewBitbucketServerAdapter creates a BitbucketServerAdapter from
+// environment configuration.
+func NewBitbucketServerAdapter() (*BitbucketServerAdapter, error) {
+	baseURL := strings.TrimRight(os.Getenv("BITBUCKET_SERVER_URL"), "/")
+	token := os.Getenv("BITBUCKET_SERVER_TOKEN")
+	if baseURL == "" || token == "" {
+		return nil, fmt.Errorf("Bitbucket Server adapter: BITBUCKET_SERVER_URL and BITBUCKET_SERVER_TOKEN must be set")
+	}
+	return &BitbucketServerAdapter{baseURL: baseURL + "/rest/api/1.0", token: token}, nil
+}
+
+func (b *BitbucketServerAdapter) Platform() SCMPlatform {
+	return PlatformBitbucketServer
+}
+
+// prURL returns the API URL of a pull request, followed by suffix.
+func (b *BitbucketServerAdapter) prURL(project, slug string, prID int, suffix string) string {
+	return fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests/%d%s", b.baseURL, project, slug, prID, suffix)
+}
+
+// do makes an authenticated request to the Bitbucket Server API,
+// JSON-encoding body when it is non-nil.
+func (b *BitbucketServerAdapter) do(method, url string, body interface{}, accept string) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(b.requestContext(), method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	setAPIHeaders(req, accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Bitbucket Server API %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// pages fetches Bitbucket Server paged endpoints, which report the start of
+// the next page in the body ("isLastPage", "nextPageStart").
+func (b *BitbucketServerAdapter) pages() pageFetcher {
+	return func(pageURL string) ([]byte, string, error) {
+		body, err := b.do("GET", pageURL, nil, "application/json")
+		if err != nil {
+			return nil, "", err
+		}
+		var page struct {
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart *int `json:"nextPageStart"`
+		}
+		if json.Unmarshal(body, &page) != nil || page.IsLastPage || page.NextPageStart == nil {
+			return body, "", nil
+		}
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return body, "", nil
+		}
+		q := u.Query()
+		q.Set("start", strconv.Itoa(*page.NextPageStart))
+		u.RawQuery = q.Encode()
+		return body, u.String(), nil
+	}
+}
+
+// bbsRef is the source or target of a Bitbucket Server pull request.
+type bbsRef struct {
+	DisplayID    string `json:"displayId"` // branch name
+	LatestCommit string `json:"latestCommit"`
+	Repository   struct {
+		ID      int    `json:"id"`
+		Slug    string `json:"slug"`
+		Name    string `json:"name"`
+		Public  bool   `json:"public"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Links struct {
+			Clone []struct {
+				Href string `json:"href"`
+				Name string `json:"name"`
+			} `json:"clone"`
+			Self []struct {
+				Href string `json:"href"`
+			} `json:"self"`
+		} `json:"links"`
+	} `json:"repository"`
+}
+
+// bbsPR is the subset of a Bitbucket Server pull request we care about, in
+// both API responses and webhooks.
+type bbsPR struct {
+	ID          int    `json:"id"`
+	Version     int    `json:"version"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"` // OPEN, MERGED or DECLINED
+	Author      struct {
+		User struct {
+			Name string `json:"name"`
+			Slug string `json:"slug"`
+		} `json:"user"`
+	} `json:"author"`
+	FromRef bbsRef `json:"fromRef"`
+	ToRef   bbsRef `json:"toRef"`
+	Draft   bool   `json:"draft"` // Bitbucket Server 8.18+
+	Links   struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+	Reviewers []struct {
+		User struct {
+			Slug string `json:"slug"`
+		} `json:"user"`
+	} `json:"reviewers"`
+}
+
+func (pr bbsPR) normalize() NormalizedPR {
+	link := ""
+	if len(pr.Links.Self) > 0 {
+		link = pr.Links.Self[0].Href
+	}
+	reviewers := make([]string, len(pr.Reviewers))
+	for i, r := range pr.Reviewers {
+		reviewers[i] = r.User.Slug
+	}
+	return NormalizedPR{
+		Number:       pr.ID,
+		Title:        pr.Title,
+		Description:  pr.Description,
+		Author:       pr.Author.User.Slug,
+		SourceBranch: pr.FromRef.DisplayID,
+		TargetBranch: pr.ToRef.DisplayID,
+		HeadSHA:      pr.FromRef.LatestCommit,
+		BaseSHA:      pr.ToRef.LatestCommit,
+		State:        strings.ToLower(pr.State),
+		URL:          link,
+		Draft:        pr.Draft,
+
+		RequestedReviewers: reviewers,
+	}
+}
+
+func (b *BitbucketServerAdapter) getPR(project, slug string, prID int) (*bbsPR, error) {
+	body, err := b.do("GET", b.prURL(project, slug, prID, ""), nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	var pr bbsPR
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse PR response: %w", err)
+	}
+	return &pr, nil
+}
+
+func (b *BitbucketServerAdapter) GetPRDetails(project, slug string, prID int) (*NormalizedPR, error) {
+	pr, err := b.getPR(project, slug, prID)
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: GetPRDetails failed: %w", err)
+	}
+	normalized := pr.normalize()
+	if normalized.State == "open" {
+		if err := b.mergeStatus(project, slug, prID, &normalized); err != nil {
+			log.Printf("[Bitbucket Server Adapter] Warning: could not check mergeability of PR #%d: %v\n", prID, err)
+		}
+	}
+	return &normalized, nil
+}
+
+// mergeStatus fills pr's Mergeable and MergeableState from the PR's merge
+// preconditions: "dirty" for conflicts, "blocked" when a merge check vetoes
+// it, "clean" otherwise.
+func (b *BitbucketServerAdapter) mergeStatus(project, slug string, prID int, pr *NormalizedPR) error {
+	body, err := b.do("GET", b.prURL(project, slug, prID, "/merge"), nil, "application/json")
+	if err != nil {
+		return err
+	}
+	var status struct {
+		CanMerge   bool   `json:"canMerge"`
+		Conflicted bool   `json:"conflicted"`
+		Outcome    string `json:"outcome"` // CLEAN, CONFLICTED or UNKNOWN
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("failed to parse merge status: %w", err)
+	}
+	if status.Outcome == "UNKNOWN" {
+		pr.MergeableState = "unknown"
+		return nil
+	}
+	mergeable := !status.Conflicted
+	pr.Mergeable = &mergeable
+	switch {
+	case status.Conflicted:
+		pr.MergeableState = "dirty"
+	case !status.CanMerge:
+		pr.MergeableState = "blocked"
+	default:
+		pr.MergeableState = "clean"
+	}
+	return nil
+}
+
+func (b *BitbucketServerAdapter) ListOpenPRs(project, slug string) ([]NormalizedPR, error) {
+	listURL := fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests?state=OPEN&limit=50", b.baseURL, project, slug)
+	var prs []NormalizedPR
+	err := paginate(listURL, b.pages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []bbsPR `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket Server adapter: failed to parse pull requests response: %w", err)
+		}
+		for _, pr := range page.Values {
+			prs = append(prs, pr.normalize())
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: ListOpenPRs failed: %w", err)
+	}
+	return prs, nil
+}
+
+// mapBitbucketServerChangeType normalises Bitbucket Server change types to
+// the common vocabulary shared across all adapters.
+func mapBitbucketServerChangeType(changeType string) string {
+	switch changeType {
+	case "ADD", "COPY":
+		return "added"
+	case "DELETE":
+		return "removed"
+	case "MOVE":
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
+
+// GetPRFiles lists the changed files. The changes API carries no line
+// counts, so Additions, Deletions and Changes stay 0.
+func (b *BitbucketServerAdapter) GetPRFiles(project, slug string, prID int) ([]NormalizedFile, error) {
+	var files []NormalizedFile
+	err := paginate(b.prURL(project, slug, prID, "/changes?limit=100"), b.pages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []struct {
+				Type string `json:"type"`
+				Path struct {
+					ToString string `json:"toString"`
+				} `json:"path"`
+				SrcPath *struct {
+					ToString string `json:"toString"`
+				} `json:"srcPath"`
+			} `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket Server adapter: failed to parse changes response: %w", err)
+		}
+		for _, c := range page.Values {
+			nf := NormalizedFile{
+				Filename: c.Path.ToString,
+				Status:   mapBitbucketServerChangeType(c.Type),
+			}
+			if nf.Status == "renamed" && c.SrcPath != nil {
+				nf.PreviousFilename = c.SrcPath.ToString
+			}
+			files = append(files, nf)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: GetPRFiles failed: %w", err)
+	}
+	return files, nil
+}
+
+func (b *BitbucketServerAdapter) GetPRCommits(project, slug string, prID int) ([]NormalizedCommit, error) {
+	var commits []NormalizedCommit
+	err := paginate(b.prURL(project, slug, prID, "/commits?limit=100"), b.pages(), func(body []byte) (bool, error) {
+		var page struct {
+			Values []struct {
+				ID     string `json:"id"`
+				Author struct {
+					Name string `json:"name"`
+					Slug string `json:"slug"`
+				} `json:"author"`
+				AuthorTimestamp int64  `json:"authorTimestamp"` // milliseconds
+				Message         string `json:"message"`
+			} `json:"values"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Bitbucket Server adapter: failed to parse commits response: %w", err)
+		}
+		for _, c := range page.Values {
+			author := c.Author.Slug
+			if author == "" {
+				author = c.Author.Name
+			}
+			commits = append(commits, NormalizedCommit{
+				SHA:       c.ID,
+				Author:    author,
+				Message:   c.Message,
+				Timestamp: time.UnixMilli(c.AuthorTimestamp).UTC(),
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: GetPRCommits failed: %w", err)
+	}
+	return commits, nil
+}
+
+func (b *BitbucketServerAdapter) GetPRDiff(project, slug string, prID int) (string, error) {
+	body, err := b.do("GET", b.prURL(project, slug, prID, ".diff"), nil, "text/plain")
+	if err != nil {
+		return "", fmt.Errorf("Bitbucket Server adapter: GetPRDiff failed: %w", err)
+	}
+	return string(body), nil
+}
+
+// UpdatePRDescription replaces the PR description. Bitbucket Server
+// requires the PR's current version (optimistic locking) and its title.
+func (b *BitbucketServerAdapter) UpdatePRDescription(project, slug string, prID int, description string) error {
+	pr, err := b.getPR(project, slug, prID)
+	if err != nil {
+		return fmt.Errorf("Bitbucket Server adapter: UpdatePRDescription failed: %w", err)
+	}
+	update := map[string]interface{}{
+		"version":     pr.Version,
+		"title":       pr.Title,
+		"description": description,
+	}
+	if _, err := b.do("PUT", b.prURL(project, slug, prID, ""), update, "application/json"); err != nil {
+		return fmt.Errorf("Bitbucket Server adapter: UpdatePRDescription failed: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketServerAdapter) PostComment(project, slug string, prID int, body string) error {
+	rememberSelfComment(PlatformBitbucketServer, project+"/"+slug, prID, body)
+	if _, err := b.do("POST", b.prURL(project, slug, prID, "/comments"), map[string]string{"text": body}, "application/json"); err != nil {
+		return fmt.Errorf("Bitbucket Server adapter: PostComment failed: %w", err)
+	}
+	return nil
+}
+
+// bbsWebhookPayload is the Bitbucket Server pull request webhook structure.
+type bbsWebhookPayload struct {
+	EventKey    string `json:"eventKey"`
+	PullRequest bbsPR  `json:"pullRequest"`
+}
+
+// GetFileContent streams the file through the raw endpoint.
+func (b *BitbucketServerAdapter) GetFileContent(project, slug, ref, path string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/projects/%s/repos/%s/raw/%s?at=%s", b.baseURL, project, slug, strings.TrimLeft(path, "/"), url.QueryEscape(ref))
+	body, err := b.do("GET", endpoint, nil, "*/*")
+	if err != nil && strings.HasPrefix(err.Error(), "Bitbucket Server API 404:") {
+		return nil, errFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: GetFileContent failed: %w", err)
+	}
+	return body, nil
+}
+
+// mapBitbucketServerEventKey converts a Bitbucket Server X-Event-Key to the
+// normalized event type and action, like mapBitbucketEventKey for Cloud.
+func mapBitbucketServerEventKey(key string) (eventType, action string) {
+	switch key {
+	case "pr:opened":
+		return "pull_request.opened", "opened"
+	case "pr:from_ref_updated", "pr:modified":
+		// New commits, or an edited title, description or target branch.
+		return "pull_request.updated", "synchronize"
+	case "pr:merged", "pr:declined", "pr:deleted":
+		return "pull_request.closed", "closed"
+	default:
+		if prActionPassthrough() {
+			return EventTypeOther, strings.TrimPrefix(key, "pr:") // e.g. "reviewer:approved"
+		}
+		return "pull_request.unknown", "unknown"
+	}
+}
+
+// isBitbucketServerPREvent reports whether key is a pull request event key.
+func isBitbucketServerPREvent(key string) bool {
+	return strings.HasPrefix(key, "pr:")
+}
+
+// NormalizeEvent parses a Bitbucket Server webhook payload and maps it to a
+// NormalizedEvent. The repository is the PR's target repository.
+func (b *BitbucketServerAdapter) NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	var p bbsWebhookPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("Bitbucket Server adapter: failed to parse webhook payload: %w", err)
+	}
+	if eventType == "" {
+		eventType = p.EventKey
+	}
+	normalizedType, action := mapBitbucketServerEventKey(eventType)
+
+	repo := p.PullRequest.ToRef.Repository
+	cloneURL, htmlURL := "", ""
+	for _, link := range repo.Links.Clone {
+		if link.Name == "http" || link.Name == "https" {
+			cloneURL = link.Href
+			break
+		}
+	}
+	if len(repo.Links.Self) > 0 {
+		htmlURL = repo.Links.Self[0].Href
+	}
+
+	event := &NormalizedEvent{
+		Platform:  PlatformBitbucketServer,
+		EventType: normalizedType,
+		Action:    action,
+		PR:        p.PullRequest.normalize(),
+		Repository: NormalizedRepository{
+			ID:         strconv.Itoa(repo.ID),
+			Name:       repo.Slug,
+			FullName:   repo.Project.Key + "/" + repo.Slug,
+			Owner:      repo.Project.Key,
+			CloneURL:   cloneURL,
+			HTMLURL:    htmlURL,
+			Visibility: "private",
+		},
+		RawPayload: payload,
+		ReceivedAt: time.Now(),
+	}
+
+	if repo.Public {
+		event.Repository.Visibility = "public"
+	}
+
+	backfillPRDetails(b, event, false)
+
+	return event, nil
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CodeCommitAdapter implements SCMAdapter and PRReader for AWS CodeCommit.
+// It is read-only. Pull request events reach the gateway as EventBridge
+// "CodeCommit Pull Request State Change" events delivered by an SNS topic
+// with an HTTPS subscription to /webhook/codecommit (see aws.go for the SNS
+// envelope).
+//
+// A repository is identified as {account}/{repositoryName}; the account is
+// only informational, the API is called in the configured region with the
+// configured credentials. Requests are signed with SigV4.
+// Required env vars: CODECOMMIT_REGION (or AWS_REGION), AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY. Optional: AWS_SESSION_TOKEN.
+//
+// Relevant CodeCommit API actions used (JSON 1.1 protocol):
+//
+//	GetPullRequest     pull request metadata and its target
+//	GetDifferences     files changed between the merge base and the source
+//	GetCommit          commits, walked from the source tip to the merge base
+//	ListPullRequests   open pull requests of a repository
+type CodeCommitAdapter struct {
+	requestScope
+	region   string
+	endpoint string
+	creds    awsCredentials
+}
+
+// CodeCommitAdapter reads and lists pull requests and reads files.
+var (
+	_ SCMAdapter = (*CodeCommitAdapter)(nil)
+	_ PRReader   = (*CodeCommitAdapter)(nil)
+	_ PRLister   = (*CodeCommitAdapter)(nil)
+	_ FileReader = (*CodeCommitAdapter)(nil)
+)
+
+// codeCommitRegion returns the region of the CodeCommit repositories.
+func codeCommitRegion() string {
+	for _, env := range []string{"CODECOMMIT_REGION", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+	return ""
+}
+
+// NewCodeCommitAdapter creates a CodeCommitAdapter from environment
+// configuration.
+func NewCodeCommitAdapter() (*CodeCommitAdapter, error) {
+	region := codeCommitRegion()
+	creds := awsCredentialsFromEnv()
+	if region == "" || creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, fmt.Errorf("CodeCommit adapter: CODECOMMIT_REGION (or AWS_REGION), AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return &CodeCommitAdapter{
+		region:   region,
+		endpoint: "https://codecommit." + region + ".amazonaws.com/",
+		creds:    creds,
+	}, nil
+}
+
+func (c *CodeCommitAdapter) Platform() SCMPlatform {
+	return PlatformCodeCommit
+}
+
+// call invokes a CodeCommit API action and returns the response body.
+func (c *CodeCommitAdapter) call(action string, input interface{}) ([]byte, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(c.requestContext(), "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "CodeCommit_20150413."+action)
+	setAPIHeaders(req, "application/json")
+	signAWSv4(req, body, c.creds, c.region, "codecommit", time.Now())
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Type != "" {
+			return nil, fmt.Errorf("CodeCommit API %d: %s: %s", resp.StatusCode, apiErr.Type, apiErr.Message)
+		}
+		return nil, fmt.Errorf("CodeCommit API %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// pages returns a fetcher for paged CodeCommit actions. CodeCommit pages by
+// a token in the request rather than by URL, so the "URL" handed between
+// pages is the JSON request itself, with tokenField set for the next page.
+func (c *CodeCommitAdapter) pages(action, tokenField string) pageFetcher {
+	return func(request string) ([]byte, string, error) {
+		body, err := c.call(action, json.RawMessage(request))
+		if err != nil {
+			return nil, "", err
+		}
+		var page struct {
+			NextToken string `json:"nextToken"` // matches NextToken too
+		}
+		if json.Unmarshal(body, &page) != nil || page.NextToken == "" {
+			return body, "", nil
+		}
+		var input map[string]interface{}
+		json.Unmarshal([]byte(request), &input)
+		input[tokenField] = page.NextToken
+		next, err := json.Marshal(input)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, string(next), nil
+	}
+}
+
+// firstPage returns the JSON request of the first page of a paged action.
+func firstPage(input map[string]interface{}) string {
+	data, _ := json.Marshal(input)
+	return string(data)
+}
+
+// ccPullRequest is the subset of a CodeCommit PullRequest we care about.
+type ccPullRequest struct {
+	PullRequestID     string `json:"pullRequestId"`
+	Title             string `json:"title"`
+	Description       string `json:"description"`
+	PullRequestStatus string `json:"pullRequestStatus"` // OPEN, CLOSED
+	AuthorArn         string `json:"authorArn"`
+	Targets           []struct {
+		RepositoryName       string `json:"repositoryName"`
+		SourceReference      string `json:"sourceReference"`
+		DestinationReference string `json:"destinationReference"`
+		SourceCommit         string `json:"sourceCommit"`
+		DestinationCommit    string `json:"destinationCommit"`
+		MergeBase            string `json:"mergeBase"`
+		MergeMetadata        struct {
+			IsMerged bool `json:"isMerged"`
+		} `json:"mergeMetadata"`
+	} `json:"pullRequestTargets"`
+}
+
+// codeCommitState converts a pull request status to the PR state vocabulary.
+func codeCommitState(status string, merged bool) string {
+	if merged {
+		return "merged"
+	}
+	if strings.EqualFold(status, "CLOSED") {
+		return "closed"
+	}
+	return "open"
+}
+
+// arnName returns the user or role session name at the end of an IAM ARN.
+func arnName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// prURL returns the console URL of a pull request.
+func (c *CodeCommitAdapter) prURL(region, repo string, number int) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/codesuite/codecommit/repositories/%s/pull-requests/%d?region=%s",
+		region, repo, number, region)
+}
+
+func (c *CodeCommitAdapter) normalize(pr ccPullRequest) NormalizedPR {
+	number, _ := strconv.Atoi(pr.PullRequestID)
+	n := NormalizedPR{
+		Number:      number,
+		Title:       pr.Title,
+		Description: pr.Description,
+		Author:      arnName(pr.AuthorArn),
+		State:       codeCommitState(pr.PullRequestStatus, false),
+	}
+	if len(pr.Targets) > 0 {
+		t := pr.Targets[0]
+		n.SourceBranch = strings.TrimPrefix(t.SourceReference, "refs/heads/")
+		n.TargetBranch = strings.TrimPrefix(t.DestinationReference, "refs/heads/")
+		n.HeadSHA, n.BaseSHA = t.SourceCommit, t.DestinationCommit
+		n.State = codeCommitState(pr.PullRequestStatus, t.MergeMetadata.IsMerged)
+		n.URL = c.prURL(c.region, t.RepositoryName, number)
+	}
+	return n
+}
+
+// getPullRequest fetches a pull request.
+func (c *CodeCommitAdapter) getPullRequest(prNumber int) (*ccPullRequest, error) {
+	body, err := c.call("GetPullRequest", map[string]string{"pullRequestId": strconv.Itoa(prNumber)})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		PullRequest ccPullRequest `json:"pullRequest"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request response: %w", err)
+	}
+	if len(resp.PullRequest.Targets) == 0 {
+		return nil, fmt.Errorf("pull request %d has no target", prNumber)
+	}
+	return &resp.PullRequest, nil
+}
+
+// GetPRDetails fetches pull request metadata. Pull request IDs are unique
+// per region, so repo is not needed to find it.
+func (c *CodeCommitAdapter) GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error) {
+	pr, err := c.getPullRequest(prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: GetPRDetails failed: %w", err)
+	}
+	n := c.normalize(*pr)
+	return &n, nil
+}
+
+// ListOpenPRs returns the repository's open pull requests.
+func (c *CodeCommitAdapter) ListOpenPRs(owner, repo string) ([]NormalizedPR, error) {
+	var ids []string
+	request := firstPage(map[string]interface{}{"repositoryName": repo, "pullRequestStatus": "OPEN"})
+	err := paginate(request, c.pages("ListPullRequests", "nextToken"), func(body []byte) (bool, error) {
+		var page struct {
+			PullRequestIDs []string `json:"pullRequestIds"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("CodeCommit adapter: failed to parse pull requests response: %w", err)
+		}
+		ids = append(ids, page.PullRequestIDs...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: ListOpenPRs failed: %w", err)
+	}
+
+	prs := make([]NormalizedPR, 0, len(ids))
+	for _, id := range ids {
+		number, _ := strconv.Atoi(id)
+		pr, err := c.getPullRequest(number)
+		if err != nil {
+			return nil, fmt.Errorf("CodeCommit adapter: ListOpenPRs failed: %w", err)
+		}
+		prs = append(prs, c.normalize(*pr))
+	}
+	return prs, nil
+}
+
+// mapCodeCommitChangeType normalises CodeCommit change types to the common
+// file status vocabulary.
+func mapCodeCommitChangeType(changeType string) string {
+	switch changeType {
+	case "A":
+		return "added"
+	case "D":
+		return "removed"
+	default:
+		return "modified"
+	}
+}
+
+// GetPRFiles lists the files changed between the merge base and the source
+// commit. CodeCommit reports no line counts, so Additions and Deletions are
+// zero.
+func (c *CodeCommitAdapter) GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error) {
+	pr, err := c.getPullRequest(prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: GetPRFiles failed: %w", err)
+	}
+	t := pr.Targets[0]
+	before := t.MergeBase
+	if before == "" {
+		before = t.DestinationCommit
+	}
+
+	files := []NormalizedFile{}
+	request := firstPage(map[string]interface{}{
+		"repositoryName":        t.RepositoryName,
+		"beforeCommitSpecifier": before,
+		"afterCommitSpecifier":  t.SourceCommit,
+	})
+	err = paginate(request, c.pages("GetDifferences", "NextToken"), func(body []byte) (bool, error) {
+		var page struct {
+			Differences []struct {
+				BeforeBlob *struct {
+					Path string `json:"path"`
+				} `json:"beforeBlob"`
+				AfterBlob *struct {
+					Path string `json:"path"`
+				} `json:"afterBlob"`
+				ChangeType string `json:"changeType"` // A, M, D
+			} `json:"differences"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("CodeCommit adapter: failed to parse differences response: %w", err)
+		}
+		for _, d := range page.Differences {
+			f := NormalizedFile{Status: mapCodeCommitChangeType(d.ChangeType)}
+			if d.AfterBlob != nil {
+				f.Filename = d.AfterBlob.Path
+			} else if d.BeforeBlob != nil {
+				f.Filename = d.BeforeBlob.Path
+			}
+			if d.BeforeBlob != nil && d.AfterBlob != nil && d.BeforeBlob.Path != d.AfterBlob.Path {
+				f.Status = "renamed"
+				f.PreviousFilename = d.BeforeBlob.Path
+			}
+			files = append(files, f)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: GetPRFiles failed: %w", err)
+	}
+	return files, nil
+}
+
+// codeCommitMaxCommits bounds the commit walk of GetPRCommits.
+const codeCommitMaxCommits = 250
+
+// GetPRCommits returns the commits from the merge base (exclusive) to the
+// source commit, oldest first. CodeCommit has no pull request commit
+// listing, so the first-parent history is walked with GetCommit.
+func (c *CodeCommitAdapter) GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error) {
+	pr, err := c.getPullRequest(prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: GetPRCommits failed: %w", err)
+	}
+	t := pr.Targets[0]
+
+	var commits []NormalizedCommit
+	for id := t.SourceCommit; id != "" && id != t.MergeBase && len(commits) < codeCommitMaxCommits; {
+		body, err := c.call("GetCommit", map[string]string{"repositoryName": t.RepositoryName, "commitId": id})
+		if err != nil {
+			return nil, fmt.Errorf("CodeCommit adapter: GetPRCommits failed: %w", err)
+		}
+		var resp struct {
+			Commit struct {
+				CommitID string   `json:"commitId"`
+				Parents  []string `json:"parents"`
+				Message  string   `json:"message"`
+				Author   struct {
+					Name  string `json:"name"`
+					Email string `json:"email"`
+					Date  string `json:"date"` // "1484167798 -0800"
+				} `json:"author"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("CodeCommit adapter: failed to parse commit response: %w", err)
+		}
+		cm := resp.Commit
+		author := cm.Author.Email
+		if author == "" {
+			author = cm.Author.Name
+		}
+		var ts time.Time
+		if secs, err := strconv.ParseInt(strings.Fields(cm.Author.Date + " ")[0], 10, 64); err == nil {
+			ts = time.Unix(secs, 0).UTC()
+		}
+		commits = append(commits, NormalizedCommit{SHA: cm.CommitID, Author: author, Message: cm.Message, Timestamp: ts})
+
+		id = ""
+		if len(cm.Parents) > 0 {
+			id = cm.Parents[0]
+		}
+	}
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+// GetPRDiff is not available: the CodeCommit API has no unified diff, only
+// blob-level differences (see GetPRFiles).
+func (c *CodeCommitAdapter) GetPRDiff(owner, repo string, prNumber int) (string, error) {
+	return "", fmt.Errorf("CodeCommit adapter: the CodeCommit API provides no unified diff")
+}
+
+// GetFileContent reads a file with GetFile; ref may be a branch, tag or
+// commit ID. CodeCommit repositories have no owner.
+func (c *CodeCommitAdapter) GetFileContent(owner, repo, ref, path string) ([]byte, error) {
+	body, err := c.call("GetFile", map[string]string{
+		"repositoryName":  repo,
+		"commitSpecifier": ref,
+		"filePath":        path,
+	})
+	if err != nil && strings.Contains(err.Error(), "FileDoesNotExistException") {
+		return nil, errFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: GetFileContent failed: %w", err)
+	}
+	var resp struct {
+		FileContent []byte `json:"fileContent"` // base64 in the JSON
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: failed to parse GetFile response: %w", err)
+	}
+	return resp.FileContent, nil
+}
+
+// codeCommitEvent is an EventBridge CodeCommit event, as carried by an SNS
+// notification.
+type codeCommitEvent struct {
+	DetailType string `json:"detail-type"`
+	Source     string `json:"source"`
+	Account    string `json:"account"`
+	Region     string `json:"region"`
+	Detail     struct {
+		Event                string   `json:"event"`
+		PullRequestID        string   `json:"pullRequestId"`
+		RepositoryNames      []string `json:"repositoryNames"`
+		Title                string   `json:"title"`
+		Description          string   `json:"description"`
+		Author               string   `json:"author"`
+		SourceReference      string   `json:"sourceReference"`
+		DestinationReference string   `json:"destinationReference"`
+		PullRequestStatus    string   `json:"pullRequestStatus"` // Open, Closed
+		IsMerged             string   `json:"isMerged"`          // "True", "False"
+	} `json:"detail"`
+}
+
+// codeCommitEventType reads the event type ("pullRequestCreated", …) of an
+// EventBridge CodeCommit event.
+func codeCommitEventType(payload []byte) string {
+	var e codeCommitEvent
+	if json.Unmarshal(payload, &e) != nil {
+		return ""
+	}
+	return e.Detail.Event
+}
+
+// isCodeCommitPREvent reports whether eventType is about a pull request.
+func isCodeCommitPREvent(eventType string) bool {
+	return strings.HasPrefix(eventType, "pullRequest")
+}
+
+// NormalizeEvent parses an EventBridge CodeCommit pull request event and maps
+// it to a NormalizedEvent. Changed files are attached by the enricher chain
+// (see enrichers.go).
+//
+//	pullRequestCreated                → opened
+//	pullRequestSourceBranchUpdated    → synchronize
+//	pullRequestStatusChanged          → closed / reopened
+//	pullRequestMergeStatusUpdated     → closed (merged)
+func (c *CodeCommitAdapter) NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	var e codeCommitEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("CodeCommit adapter: failed to parse webhook payload: %w", err)
+	}
+	d := e.Detail
+	merged := strings.EqualFold(d.IsMerged, "true")
+
+	normalizedType, action := "pull_request.unknown", "unknown"
+	if prActionPassthrough() {
+		normalizedType, action = EventTypeOther, d.Event
+	}
+	switch d.Event {
+	case "pullRequestCreated":
+		normalizedType, action = "pull_request.opened", "opened"
+	case "pullRequestSourceBranchUpdated":
+		normalizedType, action = "pull_request.updated", "synchronize"
+	case "pullRequestStatusChanged":
+		if strings.EqualFold(d.PullRequestStatus, "Closed") {
+			normalizedType, action = "pull_request.closed", "closed"
+		} else {
+			normalizedType, action = "pull_request.reopened", "reopened"
+		}
+	case "pullRequestMergeStatusUpdated":
+		if merged {
+			normalizedType, action = "pull_request.closed", "closed"
+		}
+	}
+
+	region := e.Region
+	if region == "" {
+		region = c.region
+	}
+	repoName := ""
+	if len(d.RepositoryNames) > 0 {
+		repoName = d.RepositoryNames[0]
+	}
+	number, _ := strconv.Atoi(d.PullRequestID)
+
+	event := &NormalizedEvent{
+		Platform:  PlatformCodeCommit,
+		EventType: normalizedType,
+		Action:    action,
+		PR: NormalizedPR{
+			Number:       number,
+			Title:        d.Title,
+			Description:  d.Description,
+			Author:       arnName(d.Author),
+			SourceBranch: strings.TrimPrefix(d.SourceReference, "refs/heads/"),
+			TargetBranch: strings.TrimPrefix(d.DestinationReference, "refs/heads/"),
+			State:        codeCommitState(d.PullRequestStatus, merged),
+			URL:          c.prURL(region, repoName, number),
+		},
+		Repository: NormalizedRepository{
+			Name:     repoName,
+			FullName: e.Account + "/" + repoName,
+			Owner:    e.Account,
+			CloneURL: fmt.Sprintf("https://git-codecommit.%s.amazonaws.com/v1/repos/%s", region, repoName),
+			HTMLURL:  fmt.Sprintf("https://%s.console.aws.amazon.com/codesuite/codecommit/repositories/%s/browse?region=%s", region, repoName, region),
+		},
+		RawPayload: payload,
+		ReceivedAt: time.Now(),
+	}
+
+	// EventBridge events carry no description.
+	backfillPRDetails(c, event, d.Description == "")
+
+	return event, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 )
@@ -9,17 +10,167 @@ import (
 // provider sent the request.
 //
 //   - GitHub sends:    X-GitHub-Event
-//   - Bitbucket sends: X-Event-Key  (e.g. "pullrequest:created")
+//   - Bitbucket sends: X-Event-Key  (e.g. "pullrequest:created"). Cloud also
+//     sends X-Request-UUID / X-Hook-UUID; Server and Data Center send
+//     X-Request-Id and "pr:*" keys (e.g. "pr:opened")
+//   - Gitea sends:     X-Gitea-Event (Forgejo: X-Forgejo-Event), plus
+//     X-GitHub-Event for compatibility, so it is checked first
+//   - CodeCommit (SNS) sends: X-Amz-Sns-Message-Type
 func DetectPlatform(headers http.Header) SCMPlatform {
+	if headers.Get("X-Amz-Sns-Message-Type") != "" {
+		return PlatformCodeCommit
+	}
+	if headers.Get("X-Gitea-Event") != "" || headers.Get("X-Forgejo-Event") != "" {
+		return PlatformGitea
+	}
 	if headers.Get("X-GitHub-Event") != "" {
 		return PlatformGitHub
 	}
-	if headers.Get("X-Event-Key") != "" {
+	if key := headers.Get("X-Event-Key"); key != "" {
+		if headers.Get("X-Request-UUID") == "" && headers.Get("X-Hook-UUID") == "" &&
+			(isBitbucketServerPREvent(key) || headers.Get("X-Request-Id") != "") {
+			return PlatformBitbucketServer
+		}
 		return PlatformBitbucket
 	}
 	return PlatformUnknown
 }
 
+// DetectPlatformFromPayload identifies the SCM by the shape of the webhook
+// body. It is the fallback for deliveries forwarded through proxies that
+// strip the platform headers.
+//
+//   - GitHub:    top-level "pull_request" (or "zen" for pings) plus "repository"
+//   - Bitbucket: top-level "pullrequest"
+//   - Bitbucket Server: top-level "eventKey" or "pullRequest"
+//   - GitLab:    top-level "object_kind"
+//   - Gerrit:    top-level "type" plus "change" or "refUpdate"
+//   - CodeCommit: an SNS envelope ("Type" plus "TopicArn") or the
+//     EventBridge event it carries ("detail-type" plus "detail")
+func DetectPlatformFromPayload(payload []byte) SCMPlatform {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &keys); err != nil {
+		return PlatformUnknown
+	}
+	has := func(k string) bool { _, ok := keys[k]; return ok }
+
+	switch {
+	case has("pullrequest"):
+		return PlatformBitbucket
+	case has("eventKey") || has("pullRequest"):
+		return PlatformBitbucketServer
+	case has("object_kind"):
+		return PlatformGitLab
+	case has("type") && (has("change") || has("refUpdate")):
+		return PlatformGerrit
+	case (has("Type") && has("TopicArn")) || (has("detail-type") && has("detail")):
+		return PlatformCodeCommit
+	case has("repository") && (has("pull_request") || has("zen")):
+		return PlatformGitHub
+	}
+	return PlatformUnknown
+}
+
+// inferEventType reconstructs the event-type header value from the payload
+// when the header was stripped in transit. Bitbucket's created/updated
+// distinction is a best effort based on the PR timestamps.
+func inferEventType(platform SCMPlatform, payload []byte) string {
+	switch platform {
+	case PlatformGitHub:
+		var p struct {
+			Zen         string          `json:"zen"`
+			PullRequest json.RawMessage `json:"pull_request"`
+			Thread      json.RawMessage `json:"thread"`
+			Alert       *struct {
+				Rule json.RawMessage `json:"rule"`
+			} `json:"alert"`
+		}
+		if json.Unmarshal(payload, &p) == nil {
+			if p.Zen != "" {
+				return "ping"
+			}
+			if p.Alert != nil && p.Alert.Rule != nil {
+				return githubCodeScanningAlertEvent
+			}
+			if p.Alert != nil {
+				return githubDependabotAlertEvent
+			}
+			if p.PullRequest != nil && p.Thread != nil {
+				return githubReviewThreadEvent
+			}
+			if p.PullRequest != nil {
+				return "pull_request"
+			}
+		}
+	case PlatformBitbucket:
+		var p struct {
+			PullRequest struct {
+				State     string `json:"state"`
+				CreatedOn string `json:"created_on"`
+				UpdatedOn string `json:"updated_on"`
+			} `json:"pullrequest"`
+		}
+		if json.Unmarshal(payload, &p) == nil {
+			switch p.PullRequest.State {
+			case "MERGED":
+				return "pullrequest:fulfilled"
+			case "DECLINED":
+				return "pullrequest:rejected"
+			case "OPEN":
+				if p.PullRequest.CreatedOn == p.PullRequest.UpdatedOn {
+					return "pullrequest:created"
+				}
+				return "pullrequest:updated"
+			}
+		}
+	case PlatformGerrit:
+		return gerritEventType(payload)
+	case PlatformBitbucketServer:
+		var p struct {
+			EventKey string `json:"eventKey"`
+		}
+		if json.Unmarshal(payload, &p) == nil {
+			return p.EventKey
+		}
+	case PlatformGitea:
+		var p struct {
+			PullRequest json.RawMessage `json:"pull_request"`
+		}
+		if json.Unmarshal(payload, &p) == nil && p.PullRequest != nil {
+			return "pull_request"
+		}
+	case PlatformCodeCommit:
+		return codeCommitEventType(payload)
+	}
+	return ""
+}
+
+// DeliveryID returns the SCM's unique ID for this webhook delivery, used to
+// correlate gateway events with the SCM's own delivery log.
+//
+//   - GitHub sends:    X-GitHub-Delivery
+//   - Bitbucket sends: X-Request-UUID (Server and Data Center: X-Request-Id)
+//   - Gitea sends:     X-Gitea-Delivery (Forgejo: X-Forgejo-Delivery)
+//   - SNS sends:       X-Amz-Sns-Message-Id
+func DeliveryID(headers http.Header) string {
+	for _, h := range []string{"X-Gitea-Delivery", "X-Forgejo-Delivery", "X-GitHub-Delivery", "X-Request-UUID", "X-Amz-Sns-Message-Id"} {
+		if id := headers.Get(h); id != "" {
+			return id
+		}
+	}
+	return headers.Get("X-Request-Id")
+}
+
+// isSupportedPlatform reports whether NewSCMAdapter has an adapter for
+// platform.
+func isSupportedPlatform(platform SCMPlatform) bool {
+	switch platform {
+	case PlatformGitHub, PlatformBitbucket, PlatformGerrit, PlatformGitea, PlatformBitbucketServer, PlatformCodeCommit:
+		return true
+	}
+	return false
+}
+
 // NewSCMAdapter returns the SCMAdapter implementation for the detected platform.
 // Returns an error if the platform is unsupported or the adapter cannot be
 // initialised (e.g. missing credentials).
@@ -29,6 +180,14 @@ func NewSCMAdapter(platform SCMPlatform) (SCMAdapter, error) {
 		return NewGitHubAdapter()
 	case PlatformBitbucket:
 		return NewBitbucketAdapter()
+	case PlatformGerrit:
+		return NewGerritAdapter()
+	case PlatformGitea:
+		return NewGiteaAdapter()
+	case PlatformBitbucketServer:
+		return NewBitbucketServerAdapter()
+	case PlatformCodeCommit:
+		return NewCodeCommitAdapter()
 	default:
 		return nil, fmt.Errorf("unsupported SCM platform: %q", platform)
 	}
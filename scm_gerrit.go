@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GerritAdapter implements SCMAdapter, PRReader, PRLister and FileReader for
+// Gerrit. It is read-only: a change maps onto a pull request (change number
+// as PR number, each new patch set as "synchronize"), but nothing is written
+// back.
+//
+// Authentication uses a Gerrit HTTP password (HTTP Basic Auth against the
+// /a/ endpoints); without credentials the anonymous endpoints are used.
+// Required env vars: GERRIT_BASE_URL. Optional: GERRIT_USERNAME,
+// GERRIT_HTTP_PASSWORD.
+//
+// Relevant Gerrit REST endpoints used:
+//
+//	GET /changes/{project}~{number}?o=CURRENT_REVISION&o=CURRENT_COMMIT&o=DETAILED_ACCOUNTS
+//	GET /changes/{project}~{number}/revisions/current/files
+//	GET /changes/{project}~{number}/revisions/current/commit
+//	GET /changes/{project}~{number}/revisions/current/patch
+//	GET /changes/?q=change:{Change-Id} project:{project} branch:{branch}
+//	GET /changes/?q=project:{project} status:open
+//	GET /projects/{project}/branches/{branch}/files/{path}/content
+type GerritAdapter struct {
+	requestScope
+	baseURL  string
+	username string
+	password string
+}
+
+// GerritAdapter is read-only.
+var (
+	_ SCMAdapter = (*GerritAdapter)(nil)
+	_ PRReader   = (*GerritAdapter)(nil)
+	_ PRLister   = (*GerritAdapter)(nil)
+	_ FileReader = (*GerritAdapter)(nil)
+)
+
+// NewGerritAdapter creates a GerritAdapter from environment configuration.
+func NewGerritAdapter() (*GerritAdapter, error) {
+	baseURL := strings.TrimRight(os.Getenv("GERRIT_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("Gerrit adapter: GERRIT_BASE_URL must be set")
+	}
+	return &GerritAdapter{
+		baseURL:  baseURL,
+		username: os.Getenv("GERRIT_USERNAME"),
+		password: os.Getenv("GERRIT_HTTP_PASSWORD"),
+	}, nil
+}
+
+func (g *GerritAdapter) Platform() SCMPlatform {
+	return PlatformGerrit
+}
+
+// gerritXSSIPrefix guards every Gerrit JSON response and must be stripped.
+const gerritXSSIPrefix = ")]}'"
+
+// gerritProject returns the project name of owner and repo. Gerrit projects
+// may contain slashes, so they are re-joined.
+func gerritProject(owner, repo string) string {
+	if owner != "" {
+		return owner + "/" + repo
+	}
+	return repo
+}
+
+// apiRoot returns the REST API root, under /a when authenticating.
+func (g *GerritAdapter) apiRoot() string {
+	if g.username != "" {
+		return g.baseURL + "/a"
+	}
+	return g.baseURL
+}
+
+// changeURL returns the REST URL of a change, followed by suffix.
+func (g *GerritAdapter) changeURL(owner, repo string, number int, suffix string) string {
+	return fmt.Sprintf("%s/changes/%s~%d%s", g.apiRoot(), url.PathEscape(gerritProject(owner, repo)), number, suffix)
+}
+
+// request makes a GET request to the Gerrit REST API and strips the XSSI
+// prefix from the response.
+func (g *GerritAdapter) request(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(g.requestContext(), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if g.username != "" {
+		req.SetBasicAuth(g.username, g.password)
+	}
+	setAPIHeaders(req, "application/json")
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Gerrit API %d: %s", resp.StatusCode, string(body))
+	}
+	return bytes.TrimPrefix(body, []byte(gerritXSSIPrefix)), nil
+}
+
+// gerritAccount is a Gerrit AccountInfo.
+type gerritAccount struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// login returns the most stable identifier of the account.
+func (a gerritAccount) login() string {
+	if a.Username != "" {
+		return a.Username
+	}
+	if a.Email != "" {
+		return a.Email
+	}
+	return a.Name
+}
+
+// gerritCommit is a Gerrit CommitInfo.
+type gerritCommit struct {
+	Commit  string `json:"commit"`
+	Message string `json:"message"`
+	Author  struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Date  string `json:"date"` // "2006-01-02 15:04:05.000000000", UTC
+	} `json:"author"`
+	Parents []struct {
+		Commit string `json:"commit"`
+	} `json:"parents"`
+}
+
+// gerritChange is the subset of a Gerrit ChangeInfo we care about.
+type gerritChange struct {
+	Number          int           `json:"_number"`
+	Project         string        `json:"project"`
+	Branch          string        `json:"branch"`
+	Subject         string        `json:"subject"`
+	Status          string        `json:"status"` // NEW, MERGED, ABANDONED
+	Owner           gerritAccount `json:"owner"`
+	CurrentRevision string        `json:"current_revision"`
+	Revisions       map[string]struct {
+		Number int          `json:"_number"`
+		Ref    string       `json:"ref"`
+		Commit gerritCommit `json:"commit"`
+	} `json:"revisions"`
+}
+
+// mapGerritStatus converts a Gerrit change status to the PR state vocabulary.
+func mapGerritStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "MERGED":
+		return "merged"
+	case "ABANDONED":
+		return "abandoned"
+	default:
+		return "open"
+	}
+}
+
+// GetPRDetails fetches change metadata and its current revision.
+func (g *GerritAdapter) GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error) {
+	body, err := g.request(g.changeURL(owner, repo, prNumber,
+		"?o=CURRENT_REVISION&o=CURRENT_COMMIT&o=DETAILED_ACCOUNTS"))
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: GetPRDetails failed: %w", err)
+	}
+
+	var c gerritChange
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: failed to parse change response: %w", err)
+	}
+
+	pr := g.normalize(c)
+	return &pr, nil
+}
+
+// normalize converts a change with its current revision to a NormalizedPR.
+func (g *GerritAdapter) normalize(c gerritChange) NormalizedPR {
+	pr := NormalizedPR{
+		Number:       c.Number,
+		Title:        c.Subject,
+		Author:       c.Owner.login(),
+		TargetBranch: c.Branch,
+		State:        mapGerritStatus(c.Status),
+		URL:          fmt.Sprintf("%s/c/%s/+/%d", g.baseURL, c.Project, c.Number),
+	}
+	if rev, ok := c.Revisions[c.CurrentRevision]; ok {
+		pr.Description = rev.Commit.Message
+		pr.SourceBranch = rev.Ref
+		pr.HeadSHA = c.CurrentRevision
+		if len(rev.Commit.Parents) > 0 {
+			pr.BaseSHA = rev.Commit.Parents[0].Commit
+		}
+	}
+	return pr
+}
+
+// pages fetches change queries, which mark a truncated page with
+// "_more_changes" on its last change; the next page starts at S.
+func (g *GerritAdapter) pages() pageFetcher {
+	return func(pageURL string) ([]byte, string, error) {
+		body, err := g.request(pageURL)
+		if err != nil {
+			return nil, "", err
+		}
+		var page []struct {
+			MoreChanges bool `json:"_more_changes"`
+		}
+		if json.Unmarshal(body, &page) != nil || len(page) == 0 || !page[len(page)-1].MoreChanges {
+			return body, "", nil
+		}
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return body, "", nil
+		}
+		q := u.Query()
+		start, _ := strconv.Atoi(q.Get("S"))
+		q.Set("S", strconv.Itoa(start+len(page)))
+		u.RawQuery = q.Encode()
+		return body, u.String(), nil
+	}
+}
+
+// ListOpenPRs queries the project's open changes.
+func (g *GerritAdapter) ListOpenPRs(owner, repo string) ([]NormalizedPR, error) {
+	query := url.Values{}
+	query.Set("q", fmt.Sprintf("project:%s status:open", gerritProject(owner, repo)))
+	query.Set("n", "100")
+	query["o"] = []string{"CURRENT_REVISION", "CURRENT_COMMIT", "DETAILED_ACCOUNTS"}
+	listURL := g.apiRoot() + "/changes/?" + query.Encode()
+
+	prs := []NormalizedPR{}
+	err := paginate(listURL, g.pages(), func(body []byte) (bool, error) {
+		var page []gerritChange
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gerrit adapter: failed to parse changes response: %w", err)
+		}
+		for _, c := range page {
+			prs = append(prs, g.normalize(c))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: ListOpenPRs failed: %w", err)
+	}
+	return prs, nil
+}
+
+// gerritFile is a Gerrit FileInfo. Status is absent for modified files.
+type gerritFile struct {
+	Status        string `json:"status"` // A, D, R, C, W
+	OldPath       string `json:"old_path"`
+	LinesInserted int    `json:"lines_inserted"`
+	LinesDeleted  int    `json:"lines_deleted"`
+	Binary        bool   `json:"binary"`
+	Size          int64  `json:"size"` // after the change
+}
+
+// mapGerritFileStatus normalises Gerrit file-change status letters to the
+// common vocabulary shared across all adapters.
+func mapGerritFileStatus(status string) string {
+	switch status {
+	case "A", "C":
+		return "added"
+	case "D":
+		return "removed"
+	case "R":
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
+
+// GetPRFiles fetches the files changed by the current patch set.
+func (g *GerritAdapter) GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error) {
+	body, err := g.request(g.changeURL(owner, repo, prNumber, "/revisions/current/files"))
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: GetPRFiles failed: %w", err)
+	}
+
+	var fileMap map[string]gerritFile
+	if err := json.Unmarshal(body, &fileMap); err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: failed to parse files response: %w", err)
+	}
+
+	files := make([]NormalizedFile, 0, len(fileMap))
+	for path, f := range fileMap {
+		// Magic entries for the commit message and merge parents.
+		if strings.HasPrefix(path, "/") {
+			continue
+		}
+		nf := NormalizedFile{
+			Filename:  path,
+			Status:    mapGerritFileStatus(f.Status),
+			Additions: f.LinesInserted,
+			Deletions: f.LinesDeleted,
+			Changes:   f.LinesInserted + f.LinesDeleted,
+			IsBinary:  f.Binary,
+		}
+		if nf.Status != "removed" {
+			nf.SizeBytes = f.Size
+		}
+		if nf.Status == "renamed" {
+			nf.PreviousFilename = f.OldPath
+		}
+		files = append(files, nf)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	return files, nil
+}
+
+// GetPRCommits returns the commit of the current patch set. A Gerrit change
+// is always exactly one commit.
+func (g *GerritAdapter) GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error) {
+	body, err := g.request(g.changeURL(owner, repo, prNumber, "/revisions/current/commit"))
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: GetPRCommits failed: %w", err)
+	}
+
+	var c gerritCommit
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: failed to parse commit response: %w", err)
+	}
+	ts, _ := time.Parse("2006-01-02 15:04:05.000000000", c.Author.Date)
+	author := c.Author.Email
+	if author == "" {
+		author = c.Author.Name
+	}
+	return []NormalizedCommit{{SHA: c.Commit, Author: author, Message: c.Message, Timestamp: ts}}, nil
+}
+
+// GetPRDiff returns the current patch set as a unified diff.
+func (g *GerritAdapter) GetPRDiff(owner, repo string, prNumber int) (string, error) {
+	body, err := g.request(g.changeURL(owner, repo, prNumber, "/revisions/current/patch"))
+	if err != nil {
+		return "", fmt.Errorf("Gerrit adapter: GetPRDiff failed: %w", err)
+	}
+	// The patch endpoint returns the format-patch output base64-encoded.
+	patch, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("Gerrit adapter: failed to decode patch: %w", err)
+	}
+	return string(patch), nil
+}
+
+// isCommitSHA reports whether ref is a full hexadecimal commit SHA.
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetFileContent reads a file at the head of a branch, or at a commit when
+// ref is a full SHA. Gerrit returns the content base64-encoded.
+func (g *GerritAdapter) GetFileContent(owner, repo, ref, path string) ([]byte, error) {
+	kind := "branches"
+	if isCommitSHA(ref) {
+		kind = "commits"
+	}
+	endpoint := fmt.Sprintf("%s/projects/%s/%s/%s/files/%s/content", g.apiRoot(), url.PathEscape(gerritProject(owner, repo)), kind,
+		url.PathEscape(ref), url.PathEscape(strings.TrimLeft(path, "/")))
+	body, err := g.request(endpoint)
+	if err != nil && strings.HasPrefix(err.Error(), "Gerrit API 404:") {
+		return nil, errFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: GetFileContent failed: %w", err)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: failed to decode file content: %w", err)
+	}
+	return content, nil
+}
+
+// gerritWebhookPayload is a Gerrit stream event as delivered by the webhooks
+// plugin.
+type gerritWebhookPayload struct {
+	Type   string `json:"type"`
+	Change struct {
+		Project       string        `json:"project"`
+		Branch        string        `json:"branch"`
+		ID            string        `json:"id"` // Change-Id
+		Number        gerritNumber  `json:"number"`
+		Subject       string        `json:"subject"`
+		CommitMessage string        `json:"commitMessage"`
+		Status        string        `json:"status"`
+		URL           string        `json:"url"`
+		Owner         gerritAccount `json:"owner"`
+	} `json:"change"`
+	PatchSet struct {
+		Number   int    `json:"number"`
+		Revision string `json:"revision"`
+		Ref      string `json:"ref"`
+	} `json:"patchSet"`
+}
+
+// gerritNumber is a change number in a stream event. Gerrit releases before
+// 2.14 send it as a string, later ones as a number.
+type gerritNumber int
+
+func (n *gerritNumber) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid change number %s", data)
+	}
+	*n = gerritNumber(v)
+	return nil
+}
+
+// resolveChangeNumber looks up the number of the change with the given
+// Change-Id. A Change-Id is only unique per project and branch (cherry-picks
+// keep it), so the lookup is scoped to both.
+func (g *GerritAdapter) resolveChangeNumber(project, branch, changeID string) (int, error) {
+	query := "change:" + changeID + " project:" + project
+	if branch != "" {
+		query += " branch:" + branch
+	}
+	body, err := g.request(g.apiRoot() + "/changes/?n=2&q=" + url.QueryEscape(query))
+	if err != nil {
+		return 0, fmt.Errorf("Gerrit adapter: Change-Id lookup failed: %w", err)
+	}
+	var changes []gerritChange
+	if err := json.Unmarshal(body, &changes); err != nil {
+		return 0, fmt.Errorf("Gerrit adapter: failed to parse changes response: %w", err)
+	}
+	switch len(changes) {
+	case 0:
+		return 0, fmt.Errorf("Gerrit adapter: no change with Change-Id %s in %s", changeID, project)
+	case 1:
+		return changes[0].Number, nil
+	}
+	return 0, fmt.Errorf("Gerrit adapter: Change-Id %s is ambiguous in %s", changeID, project)
+}
+
+// gerritChangeEvents maps the Gerrit event types the pipeline handles to the
+// normalised (eventType, action) pair. patchset-created is resolved
+// separately: the first patch set opens the change.
+var gerritChangeEvents = map[string][2]string{
+	"change-merged":    {"pull_request.closed", "closed"},
+	"change-abandoned": {"pull_request.closed", "closed"},
+	"change-restored":  {"pull_request.reopened", "reopened"},
+}
+
+// gerritOtherChangeEvents are change-scoped events the pipeline only
+// forwards in passthrough mode, as EventTypeOther.
+var gerritOtherChangeEvents = map[string]bool{
+	"comment-added":         true,
+	"reviewer-added":        true,
+	"reviewer-deleted":      true,
+	"vote-deleted":          true,
+	"topic-changed":         true,
+	"hashtags-changed":      true,
+	"wip-state-changed":     true,
+	"private-state-changed": true,
+}
+
+// isGerritChangeEvent reports whether eventType is a change event the
+// pipeline processes.
+func isGerritChangeEvent(eventType string) bool {
+	_, ok := gerritChangeEvents[eventType]
+	return ok || eventType == "patchset-created" ||
+		(gerritOtherChangeEvents[eventType] && prActionPassthrough())
+}
+
+// gerritEventType reads the event type from a Gerrit webhook payload, which
+// (unlike GitHub and Bitbucket) carries no identifying headers.
+func gerritEventType(payload []byte) string {
+	var p struct {
+		Type   string          `json:"type"`
+		Change json.RawMessage `json:"change"`
+		Ref    json.RawMessage `json:"refUpdate"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil || (p.Change == nil && p.Ref == nil) {
+		return ""
+	}
+	return p.Type
+}
+
+// NormalizeEvent parses a Gerrit stream event, maps it to a NormalizedEvent,
+// and enriches it with changed files for new patch sets.
+func (g *GerritAdapter) NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	var p gerritWebhookPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("Gerrit adapter: failed to parse webhook payload: %w", err)
+	}
+
+	normalizedType, action := "pull_request.unknown", "unknown"
+	if prActionPassthrough() {
+		normalizedType, action = EventTypeOther, p.Type
+	}
+	if m, ok := gerritChangeEvents[p.Type]; ok {
+		normalizedType, action = m[0], m[1]
+	} else if p.Type == "patchset-created" {
+		normalizedType, action = "pull_request.updated", "synchronize"
+		if p.PatchSet.Number == 1 {
+			normalizedType, action = "pull_request.opened", "opened"
+		}
+	}
+
+	c := p.Change
+	number := int(c.Number)
+	if number == 0 && c.ID != "" {
+		n, err := g.resolveChangeNumber(c.Project, c.Branch, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		number = n
+	}
+	owner, repoName := "", c.Project
+	if i := strings.LastIndex(c.Project, "/"); i >= 0 {
+		owner, repoName = c.Project[:i], c.Project[i+1:]
+	}
+
+	event := &NormalizedEvent{
+		Platform:  PlatformGerrit,
+		EventType: normalizedType,
+		Action:    action,
+		PR: NormalizedPR{
+			Number:       number,
+			Title:        c.Subject,
+			Description:  c.CommitMessage,
+			Author:       c.Owner.login(),
+			SourceBranch: p.PatchSet.Ref,
+			TargetBranch: c.Branch,
+			State:        mapGerritStatus(c.Status),
+			URL:          c.URL,
+		},
+		Repository: NormalizedRepository{
+			Name:     repoName,
+			FullName: c.Project,
+			Owner:    owner,
+			CloneURL: g.baseURL + "/" + c.Project,
+			HTMLURL:  g.baseURL + "/admin/repos/" + c.Project,
+		},
+		RawPayload: payload,
+		ReceivedAt: time.Now(),
+	}
+
+	backfillPRDetails(g, event, false)
+
+	return event, nil
+}
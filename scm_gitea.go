@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GiteaAdapter implements SCMAdapter for self-hosted Gitea and Forgejo.
+// Their webhooks resemble GitHub's, but the API lives under /api/v1 on the
+// instance's own host.
+//
+// Authentication uses an access token ("Authorization: token …").
+// Required env vars: GITEA_BASE_URL (e.g. https://gitea.example.com),
+// GITEA_TOKEN.
+//
+// Relevant Gitea API v1 endpoints used:
+//
+//	GET   /api/v1/repos/{owner}/{repo}/pulls/{index}
+//	PATCH /api/v1/repos/{owner}/{repo}/pulls/{index}
+//	GET   /api/v1/repos/{owner}/{repo}/pulls/{index}/files
+//	GET   /api/v1/repos/{owner}/{repo}/pulls/{index}/commits
+//	GET   /api/v1/repos/{owner}/{repo}/pulls/{index}.diff
+//	GET   /api/v1/repos/{owner}/{repo}/pulls?state=open
+//	POST  /api/v1/repos/{owner}/{repo}/issues/{index}/comments
+type GiteaAdapter struct {
+	requestScope
+	baseURL string // API root, ending in /api/v1
+	token   string
+}
+
+// GiteaAdapter reads, lists, writes and labels pull requests, reads files
+// and manages labels and milestones.
+var (
+	_ SCMAdapter   = (*GiteaAdapter)(nil)
+	_ PRReader     = (*GiteaAdapter)(nil)
+	_ PRLister     = (*GiteaAdapter)(nil)
+	_ PRWriter     = (*GiteaAdapter)(nil)
+	_ PRLabeler    = (*GiteaAdapter)(nil)
+	_ FileReader   = (*GiteaAdapter)(nil)
+	_ LabelManager = (*GiteaAdapter)(nil)
+)
+
+// NewGiteaAdapter creates a GiteaAdapter from environment configuration.
+func NewGiteaAdapter() (*GiteaAdapter, error) {
+	baseURL := strings.TrimRight(os.Getenv("GITEA_BASE_URL"), "/")
+	token := os.Getenv("GITEA_TOKEN")
+	if baseURL == "" || token == "" {
+		return nil, fmt.Errorf("Gitea adapter: GITEA_BASE_URL and GITEA_TOKEN must be set")
+	}
+	return &GiteaAdapter{baseURL: baseURL + "/api/v1", token: token}, nil
+}
+
+func (g *GiteaAdapter) Platform() SCMPlatform {
+	return PlatformGitea
+}
+
+// repoURL returns the API URL of a repository, followed by suffix.
+func (g *GiteaAdapter) repoURL(owner, repo, suffix string) string {
+	return fmt.Sprintf("%s/repos/%s/%s%s", g.baseURL, owner, repo, suffix)
+}
+
+// do makes an authenticated request to the Gitea API and returns the body
+// and the Link header's next page.
+func (g *GiteaAdapter) do(method, url string, body interface{}, accept string) ([]byte, string, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(g.requestContext(), method, url, reqBody)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "token "+g.token)
+	setAPIHeaders(req, accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, "", fmt.Errorf("Gitea API %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, linkHeaderNext(resp.Header), nil
+}
+
+// pages fetches Gitea list pages, which link the next page like GitHub.
+func (g *GiteaAdapter) pages() pageFetcher {
+	return func(url string) ([]byte, string, error) {
+		return g.do("GET", url, nil, "application/json")
+	}
+}
+
+// giteaPR is the subset of a Gitea pull request we care about, in both API
+// responses and webhooks.
+type giteaPR struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"` // "open" or "closed"
+	Merged  bool   `json:"merged"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"base"`
+}
+
+func (pr giteaPR) normalize() NormalizedPR {
+	state := pr.State
+	if pr.Merged {
+		state = "merged"
+	}
+	return NormalizedPR{
+		Number:       pr.Number,
+		Title:        pr.Title,
+		Description:  pr.Body,
+		Author:       pr.User.Login,
+		SourceBranch: pr.Head.Ref,
+		TargetBranch: pr.Base.Ref,
+		HeadSHA:      pr.Head.SHA,
+		BaseSHA:      pr.Base.SHA,
+		State:        state,
+		URL:          pr.HTMLURL,
+	}
+}
+
+func (g *GiteaAdapter) GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error) {
+	body, _, err := g.do("GET", g.repoURL(owner, repo, fmt.Sprintf("/pulls/%d", prNumber)), nil, "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: GetPRDetails failed: %w", err)
+	}
+	var pr giteaPR
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("Gitea adapter: failed to parse PR response: %w", err)
+	}
+	normalized := pr.normalize()
+	return &normalized, nil
+}
+
+func (g *GiteaAdapter) ListOpenPRs(owner, repo string) ([]NormalizedPR, error) {
+	var prs []NormalizedPR
+	err := paginate(g.repoURL(owner, repo, "/pulls?state=open&limit=50"), g.pages(), func(body []byte) (bool, error) {
+		var page []giteaPR
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gitea adapter: failed to parse pulls response: %w", err)
+		}
+		for _, pr := range page {
+			prs = append(prs, pr.normalize())
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: ListOpenPRs failed: %w", err)
+	}
+	return prs, nil
+}
+
+// mapGiteaFileStatus normalises Gitea file statuses to the common
+// vocabulary shared across all adapters.
+func mapGiteaFileStatus(status string) string {
+	switch status {
+	case "added":
+		return "added"
+	case "deleted":
+		return "removed"
+	case "renamed":
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
+
+func (g *GiteaAdapter) GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error) {
+	var files []NormalizedFile
+	err := paginate(g.repoURL(owner, repo, fmt.Sprintf("/pulls/%d/files?limit=50", prNumber)), g.pages(), func(body []byte) (bool, error) {
+		var page []struct {
+			Filename         string `json:"filename"`
+			Status           string `json:"status"`
+			Additions        int    `json:"additions"`
+			Deletions        int    `json:"deletions"`
+			Changes          int    `json:"changes"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gitea adapter: failed to parse files response: %w", err)
+		}
+		for _, f := range page {
+			nf := NormalizedFile{
+				Filename:  f.Filename,
+				Status:    mapGiteaFileStatus(f.Status),
+				Additions: f.Additions,
+				Deletions: f.Deletions,
+				Changes:   f.Changes,
+			}
+			if nf.Status == "renamed" {
+				nf.PreviousFilename = f.PreviousFilename
+			}
+			files = append(files, nf)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: GetPRFiles failed: %w", err)
+	}
+	return files, nil
+}
+
+func (g *GiteaAdapter) GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error) {
+	var commits []NormalizedCommit
+	err := paginate(g.repoURL(owner, repo, fmt.Sprintf("/pulls/%d/commits?limit=50", prNumber)), g.pages(), func(body []byte) (bool, error) {
+		var page []ghCommit // same shape as GitHub's
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gitea adapter: failed to parse commits response: %w", err)
+		}
+		for _, c := range page {
+			author := c.Commit.Author.Name
+			if c.Author != nil && c.Author.Login != "" {
+				author = c.Author.Login
+			}
+			commits = append(commits, NormalizedCommit{
+				SHA:       c.SHA,
+				Author:    author,
+				Message:   c.Commit.Message,
+				Timestamp: c.Commit.Author.Date,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: GetPRCommits failed: %w", err)
+	}
+	return commits, nil
+}
+
+func (g *GiteaAdapter) GetPRDiff(owner, repo string, prNumber int) (string, error) {
+	body, _, err := g.do("GET", g.repoURL(owner, repo, fmt.Sprintf("/pulls/%d.diff", prNumber)), nil, "text/plain")
+	if err != nil {
+		return "", fmt.Errorf("Gitea adapter: GetPRDiff failed: %w", err)
+	}
+	return string(body), nil
+}
+
+func (g *GiteaAdapter) UpdatePRDescription(owner, repo string, prNumber int, description string) error {
+	url := g.repoURL(owner, repo, fmt.Sprintf("/pulls/%d", prNumber))
+	if _, _, err := g.do("PATCH", url, map[string]string{"body": description}, "application/json"); err != nil {
+		return fmt.Errorf("Gitea adapter: UpdatePRDescription failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaAdapter) PostComment(owner, repo string, prNumber int, body string) error {
+	// As on GitHub, PR conversation comments live on the issues API.
+	url := g.repoURL(owner, repo, fmt.Sprintf("/issues/%d/comments", prNumber))
+	rememberSelfComment(PlatformGitea, owner+"/"+repo, prNumber, body)
+	if _, _, err := g.do("POST", url, map[string]string{"body": body}, "application/json"); err != nil {
+		return fmt.Errorf("Gitea adapter: PostComment failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaAdapter) GetFileContent(owner, repo, ref, path string) ([]byte, error) {
+	endpoint := g.repoURL(owner, repo, "/raw/"+strings.TrimLeft(path, "/")+"?ref="+url.QueryEscape(ref))
+	body, _, err := g.do("GET", endpoint, nil, "*/*")
+	if err != nil && strings.HasPrefix(err.Error(), "Gitea API 404:") {
+		return nil, errFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: GetFileContent failed: %w", err)
+	}
+	return body, nil
+}
+
+// giteaLabel is a Gitea label. Older Gitea releases prefix the color with
+// "#".
+type giteaLabel struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+func (g *GiteaAdapter) listLabels(owner, repo string) ([]giteaLabel, error) {
+	labels := []giteaLabel{}
+	err := paginate(g.repoURL(owner, repo, "/labels?limit=50"), g.pages(), func(body []byte) (bool, error) {
+		var page []giteaLabel
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gitea adapter: failed to parse labels response: %w", err)
+		}
+		labels = append(labels, page...)
+		return true, nil
+	})
+	return labels, err
+}
+
+func (g *GiteaAdapter) ListLabels(owner, repo string) ([]RepoLabel, error) {
+	raw, err := g.listLabels(owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: ListLabels failed: %w", err)
+	}
+	labels := make([]RepoLabel, len(raw))
+	for i, l := range raw {
+		labels[i] = RepoLabel{Name: l.Name, Color: strings.TrimPrefix(l.Color, "#"), Description: l.Description}
+	}
+	return labels, nil
+}
+
+// SetLabel looks the label up by name, since Gitea edits labels by ID.
+func (g *GiteaAdapter) SetLabel(owner, repo string, label RepoLabel) error {
+	raw, err := g.listLabels(owner, repo)
+	if err != nil {
+		return fmt.Errorf("Gitea adapter: SetLabel failed: %w", err)
+	}
+	fields := map[string]string{"name": label.Name, "color": "#" + label.Color, "description": label.Description}
+	method, endpoint := "POST", g.repoURL(owner, repo, "/labels")
+	for _, l := range raw {
+		if strings.EqualFold(l.Name, label.Name) {
+			method, endpoint = "PATCH", g.repoURL(owner, repo, fmt.Sprintf("/labels/%d", l.ID))
+			break
+		}
+	}
+	if _, _, err := g.do(method, endpoint, fields, "application/json"); err != nil {
+		return fmt.Errorf("Gitea adapter: SetLabel failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaAdapter) GetPRLabels(owner, repo string, prNumber int) ([]string, error) {
+	body, _, err := g.do("GET", g.repoURL(owner, repo, fmt.Sprintf("/issues/%d/labels", prNumber)), nil, "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: GetPRLabels failed: %w", err)
+	}
+	var raw []giteaLabel
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("Gitea adapter: failed to parse labels response: %w", err)
+	}
+	labels := make([]string, len(raw))
+	for i, l := range raw {
+		labels[i] = l.Name
+	}
+	return labels, nil
+}
+
+// AddLabels resolves the names to label IDs; unknown labels are an error,
+// create them with SetLabel first.
+func (g *GiteaAdapter) AddLabels(owner, repo string, prNumber int, labels []string) error {
+	raw, err := g.listLabels(owner, repo)
+	if err != nil {
+		return fmt.Errorf("Gitea adapter: AddLabels failed: %w", err)
+	}
+	ids := make([]int64, 0, len(labels))
+	for _, name := range labels {
+		id := int64(0)
+		for _, l := range raw {
+			if strings.EqualFold(l.Name, name) {
+				id = l.ID
+				break
+			}
+		}
+		if id == 0 {
+			return fmt.Errorf("Gitea adapter: AddLabels: %s/%s has no label %q", owner, repo, name)
+		}
+		ids = append(ids, id)
+	}
+	endpoint := g.repoURL(owner, repo, fmt.Sprintf("/issues/%d/labels", prNumber))
+	if _, _, err := g.do("POST", endpoint, map[string][]int64{"labels": ids}, "application/json"); err != nil {
+		return fmt.Errorf("Gitea adapter: AddLabels failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaAdapter) RemoveLabel(owner, repo string, prNumber int, label string) error {
+	raw, err := g.listLabels(owner, repo)
+	if err != nil {
+		return fmt.Errorf("Gitea adapter: RemoveLabel failed: %w", err)
+	}
+	for _, l := range raw {
+		if !strings.EqualFold(l.Name, label) {
+			continue
+		}
+		endpoint := g.repoURL(owner, repo, fmt.Sprintf("/issues/%d/labels/%d", prNumber, l.ID))
+		if _, _, err := g.do("DELETE", endpoint, nil, "application/json"); err != nil {
+			return fmt.Errorf("Gitea adapter: RemoveLabel failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// giteaMilestone is a Gitea milestone; its number is its ID.
+type giteaMilestone struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	DueOn       string `json:"due_on"`
+}
+
+func (g *GiteaAdapter) ListMilestones(owner, repo string) ([]RepoMilestone, error) {
+	milestones := []RepoMilestone{}
+	err := paginate(g.repoURL(owner, repo, "/milestones?state=all&limit=50"), g.pages(), func(body []byte) (bool, error) {
+		var page []giteaMilestone
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("Gitea adapter: failed to parse milestones response: %w", err)
+		}
+		for _, m := range page {
+			due := m.DueOn
+			if len(due) > 10 {
+				due = due[:10]
+			}
+			milestones = append(milestones, RepoMilestone{Number: m.ID, Title: m.Title, Description: m.Description, State: m.State, DueOn: due})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gitea adapter: ListMilestones failed: %w", err)
+	}
+	return milestones, nil
+}
+
+func (g *GiteaAdapter) SetMilestone(owner, repo string, milestone RepoMilestone) error {
+	fields := map[string]string{"title": milestone.Title, "description": milestone.Description}
+	if milestone.State != "" {
+		fields["state"] = milestone.State
+	}
+	if milestone.DueOn != "" {
+		fields["due_on"] = milestone.DueOn + "T00:00:00Z"
+	}
+	method, endpoint := "POST", g.repoURL(owner, repo, "/milestones")
+	if milestone.Number != 0 {
+		method, endpoint = "PATCH", g.repoURL(owner, repo, fmt.Sprintf("/milestones/%d", milestone.Number))
+	}
+	if _, _, err := g.do(method, endpoint, fields, "application/json"); err != nil {
+		return fmt.Errorf("Gitea adapter: SetMilestone failed: %w", err)
+	}
+	return nil
+}
+
+// giteaWebhookPayload is the Gitea/Forgejo pull request webhook structure.
+type giteaWebhookPayload struct {
+	Action      string  `json:"action"`
+	PullRequest giteaPR `json:"pull_request"`
+	Repository  struct {
+		ID            int64  `json:"id"`
+		Name          string `json:"name"`
+		FullName      string `json:"full_name"`
+		HTMLURL       string `json:"html_url"`
+		CloneURL      string `json:"clone_url"`
+		DefaultBranch string `json:"default_branch"`
+		Private       bool   `json:"private"`
+		Internal      bool   `json:"internal"`
+		Language      string `json:"language"`
+		Owner         struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// giteaActions maps the pull_request actions the pipeline handles to the
+// normalised actions. Gitea says "synchronized" where GitHub says
+// "synchronize".
+var giteaActions = map[string]string{
+	"opened":       "opened",
+	"synchronized": "synchronize",
+	"reopened":     "reopened",
+	"closed":       "closed",
+}
+
+// giteaEventType returns the event type of a Gitea or Forgejo delivery. The
+// X-*-Event-Type header distinguishes sub-events ("pull_request_sync",
+// "pull_request_label") that X-*-Event reports as plain "pull_request".
+func giteaEventType(headers http.Header) string {
+	for _, h := range []string{"X-Gitea-Event-Type", "X-Forgejo-Event-Type", "X-Gitea-Event", "X-Forgejo-Event", "X-GitHub-Event"} {
+		if v := headers.Get(h); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isGiteaPREvent reports whether eventType (X-Gitea-Event-Type, e.g.
+// "pull_request", "pull_request_sync", "pull_request_label") is about a
+// pull request.
+func isGiteaPREvent(eventType string) bool {
+	return strings.HasPrefix(eventType, "pull_request")
+}
+
+// NormalizeEvent parses a Gitea/Forgejo webhook payload and maps it to a
+// NormalizedEvent. Changed files are attached by the enricher chain (see
+// enrichers.go).
+func (g *GiteaAdapter) NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	var p giteaWebhookPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("Gitea adapter: failed to parse webhook payload: %w", err)
+	}
+
+	// Sub-events (labels, reviews, assignments) arrive as their own event
+	// types with the PR attached, e.g. "pull_request_review_approved".
+	rawAction := p.Action
+	if sub := strings.TrimPrefix(eventType, "pull_request_"); sub != eventType && sub != "sync" {
+		rawAction = sub
+	}
+	normalizedType, action := "pull_request.unknown", "unknown"
+	if prActionPassthrough() {
+		normalizedType, action = EventTypeOther, rawAction
+	}
+	if mapped, ok := giteaActions[rawAction]; ok {
+		normalizedType, action = "pull_request."+mapped, mapped
+	}
+
+	repo := p.Repository
+	event := &NormalizedEvent{
+		Platform:  PlatformGitea,
+		EventType: normalizedType,
+		Action:    action,
+		PR:        p.PullRequest.normalize(),
+		Repository: NormalizedRepository{
+			ID:            strconv.FormatInt(repo.ID, 10),
+			Name:          repo.Name,
+			FullName:      repo.FullName,
+			Owner:         repo.Owner.Login,
+			CloneURL:      repo.CloneURL,
+			HTMLURL:       repo.HTMLURL,
+			DefaultBranch: repo.DefaultBranch,
+			Visibility:    "public",
+			Language:      repo.Language,
+		},
+		RawPayload: payload,
+		ReceivedAt: time.Now(),
+	}
+	switch {
+	case repo.Private:
+		event.Repository.Visibility = "private"
+	case repo.Internal:
+		event.Repository.Visibility = "internal"
+	}
+
+	backfillPRDetails(g, event, false)
+
+	return event, nil
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,10 +18,36 @@ import (
 // It reuses the existing JWT / installation-token auth layer in auth.go and
 // the PR file fetching logic in pullrequest.go.
 type GitHubAdapter struct {
+	requestScope
 	appID      string
 	privateKey string
 }
 
+// GitHubAdapter implements every adapter capability.
+var (
+	_ SCMAdapter       = (*GitHubAdapter)(nil)
+	_ PRReader         = (*GitHubAdapter)(nil)
+	_ PRWriter         = (*GitHubAdapter)(nil)
+	_ PRCommentReader  = (*GitHubAdapter)(nil)
+	_ ReviewCreator    = (*GitHubAdapter)(nil)
+	_ CheckPublisher   = (*GitHubAdapter)(nil)
+	_ StatusPublisher  = (*GitHubAdapter)(nil)
+	_ PRLabeler        = (*GitHubAdapter)(nil)
+	_ ThreadResolver   = (*GitHubAdapter)(nil)
+	_ PRMerger         = (*GitHubAdapter)(nil)
+	_ RepoReader       = (*GitHubAdapter)(nil)
+	_ FileReader       = (*GitHubAdapter)(nil)
+	_ ContentWriter    = (*GitHubAdapter)(nil)
+	_ RepoProvisioner  = (*GitHubAdapter)(nil)
+	_ LabelManager     = (*GitHubAdapter)(nil)
+	_ SBOMReader       = (*GitHubAdapter)(nil)
+	_ BranchProtector  = (*GitHubAdapter)(nil)
+	_ CIArtifactReader = (*GitHubAdapter)(nil)
+	_ CITrigger        = (*GitHubAdapter)(nil)
+	_ Deployer         = (*GitHubAdapter)(nil)
+	_ ReleaseWriter    = (*GitHubAdapter)(nil)
+)
+
 // NewGitHubAdapter creates a GitHubAdapter from environment credentials.
 // Required env vars: GITHUB_APP_ID, GITHUB_PRIVATE_KEY.
 func NewGitHubAdapter() (*GitHubAdapter, error) {
@@ -31,12 +64,30 @@ func (g *GitHubAdapter) Platform() SCMPlatform {
 }
 
 // token generates a short-lived installation access token for the given repo.
-func (g *GitHubAdapter) token(owner, repo string) (string, error) {
+// Token scopes shared by several operations; see token.
+var (
+	scopePRRead           = tokenScope{"pull_requests": "read"}
+	scopePRWrite          = tokenScope{"pull_requests": "write"}
+	scopeContentsRead     = tokenScope{"contents": "read"}
+	scopeContentsWrite    = tokenScope{"contents": "write"}
+	scopeIssuesRead       = tokenScope{"issues": "read"}
+	scopeIssuesWrite      = tokenScope{"issues": "write"}
+	scopeActionsRead      = tokenScope{"actions": "read"}
+	scopeDeploymentsWrite = tokenScope{"deployments": "write"}
+)
+
+// token mints an installation token limited to repo and the permissions of
+// the operation at hand (see getScopedInstallationToken). An empty repo
+// leaves the token valid for every repository of the installation.
+func (g *GitHubAdapter) token(owner, repo string, scope tokenScope) (string, error) {
+	if registry.IsSuspended(PlatformGitHub, owner) {
+		return "", fmt.Errorf("GitHub adapter: %w", errInstallationSuspended(PlatformGitHub, owner))
+	}
 	jwtToken, err := generateJWT(g.appID, g.privateKey)
 	if err != nil {
 		return "", fmt.Errorf("GitHub adapter: failed to generate JWT: %w", err)
 	}
-	tok, err := getInstallationToken(jwtToken, owner, repo)
+	tok, err := getScopedInstallationToken(jwtToken, owner, repo, scope)
 	if err != nil {
 		return "", fmt.Errorf("GitHub adapter: failed to get installation token: %w", err)
 	}
@@ -49,26 +100,75 @@ type ghPRResponse struct {
 	Title   string `json:"title"`
 	Body    string `json:"body"`
 	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
 	HTMLURL string `json:"html_url"`
 	User    struct {
 		Login string `json:"login"`
 	} `json:"user"`
 	Head struct {
 		Ref string `json:"ref"`
+		SHA string `json:"sha"`
 	} `json:"head"`
 	Base struct {
 		Ref string `json:"ref"`
+		SHA string `json:"sha"`
 	} `json:"base"`
+
+	Draft          bool   `json:"draft"`
+	Mergeable      *bool  `json:"mergeable"` // null while GitHub computes it
+	MergeableState string `json:"mergeable_state"`
+
+	Labels             ghLabels `json:"labels"`
+	Assignees          ghUsers  `json:"assignees"`
+	RequestedReviewers ghUsers  `json:"requested_reviewers"`
+	RequestedTeams     ghTeams  `json:"requested_teams"`
+}
+
+// ghLabels, ghUsers and ghTeams decode the lists of a PR, keeping the names.
+type (
+	ghLabels []struct {
+		Name string `json:"name"`
+	}
+	ghUsers []struct {
+		Login string `json:"login"`
+	}
+	ghTeams []struct {
+		Slug string `json:"slug"`
+	}
+)
+
+func (l ghLabels) names() []string {
+	out := make([]string, len(l))
+	for i, label := range l {
+		out[i] = label.Name
+	}
+	return out
+}
+
+func (u ghUsers) logins() []string {
+	out := make([]string, len(u))
+	for i, user := range u {
+		out[i] = user.Login
+	}
+	return out
+}
+
+func (t ghTeams) slugs() []string {
+	out := make([]string, len(t))
+	for i, team := range t {
+		out[i] = team.Slug
+	}
+	return out
 }
 
 func (g *GitHubAdapter) GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error) {
-	tok, err := g.token(owner, repo)
+	tok, err := g.token(owner, repo, scopePRRead)
 	if err != nil {
 		return nil, err
 	}
 
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
-	body, err := makeAuthenticatedRequest(tok, "GET", url, nil)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("GitHub adapter: GetPRDetails request failed: %w", err)
 	}
@@ -77,6 +177,10 @@ func (g *GitHubAdapter) GetPRDetails(owner, repo string, prNumber int) (*Normali
 	if err := json.Unmarshal(body, &pr); err != nil {
 		return nil, fmt.Errorf("GitHub adapter: failed to parse PR response: %w", err)
 	}
+	if pr.Merged {
+		// GitHub reports merged PRs as "closed".
+		pr.State = "merged"
+	}
 
 	return &NormalizedPR{
 		Number:       pr.Number,
@@ -85,23 +189,76 @@ func (g *GitHubAdapter) GetPRDetails(owner, repo string, prNumber int) (*Normali
 		Author:       pr.User.Login,
 		SourceBranch: pr.Head.Ref,
 		TargetBranch: pr.Base.Ref,
+		HeadSHA:      pr.Head.SHA,
+		BaseSHA:      pr.Base.SHA,
 		State:        pr.State,
 		URL:          pr.HTMLURL,
+
+		Draft:          pr.Draft,
+		Mergeable:      pr.Mergeable,
+		MergeableState: pr.MergeableState,
+
+		Labels:             pr.Labels.names(),
+		Assignees:          pr.Assignees.logins(),
+		RequestedReviewers: pr.RequestedReviewers.logins(),
+		RequestedTeams:     pr.RequestedTeams.slugs(),
 	}, nil
 }
 
+func (g *GitHubAdapter) ListOpenPRs(owner, repo string) ([]NormalizedPR, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls?state=open&per_page=100", owner, repo)
+	var prs []NormalizedPR
+	err = paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []ghPRResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse pulls response: %w", err)
+		}
+		for _, pr := range page {
+			prs = append(prs, NormalizedPR{
+				Number:       pr.Number,
+				Title:        pr.Title,
+				Description:  pr.Body,
+				Author:       pr.User.Login,
+				SourceBranch: pr.Head.Ref,
+				TargetBranch: pr.Base.Ref,
+				HeadSHA:      pr.Head.SHA,
+				BaseSHA:      pr.Base.SHA,
+				State:        pr.State,
+				URL:          pr.HTMLURL,
+				Draft:        pr.Draft, // the list omits mergeability
+
+				Labels:             pr.Labels.names(),
+				Assignees:          pr.Assignees.logins(),
+				RequestedReviewers: pr.RequestedReviewers.logins(),
+				RequestedTeams:     pr.RequestedTeams.slugs(),
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: ListOpenPRs failed: %w", err)
+	}
+	return prs, nil
+}
+
 func (g *GitHubAdapter) GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error) {
-	tok, err := g.token(owner, repo)
+	tok, err := g.token(owner, repo, scopePRRead)
 	if err != nil {
 		return nil, err
 	}
 
 	// Reuse the existing GitHub-specific fetcher from pullrequest.go.
-	rawFiles, err := getPRChangedFiles(tok, owner, repo, prNumber)
+	rawFiles, err := getPRChangedFiles(g.requestContext(), tok, owner, repo, prNumber)
 	if err != nil {
 		return nil, fmt.Errorf("GitHub adapter: GetPRFiles failed: %w", err)
 	}
 
+	patches := filePatchesEnabled(PlatformGitHub, owner+"/"+repo)
 	files := make([]NormalizedFile, len(rawFiles))
 	for i, f := range rawFiles {
 		files[i] = NormalizedFile{
@@ -111,11 +268,367 @@ func (g *GitHubAdapter) GetPRFiles(owner, repo string, prNumber int) ([]Normaliz
 			Deletions:        f.Deletions,
 			Changes:          f.Changes,
 			PreviousFilename: f.PreviousFilename,
+			// GitHub gives binary files neither a patch nor line counts.
+			IsBinary: f.Patch == "" && f.Changes == 0 && f.Status != "renamed",
+		}
+		if patches {
+			setPatch(&files[i], f.Patch)
 		}
 	}
 	return files, nil
 }
 
+func (g *GitHubAdapter) UpdatePRDescription(owner, repo string, prNumber int, description string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PATCH", url, map[string]string{"body": description})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: UpdatePRDescription request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: UpdatePRDescription failed: %w", err)
+	}
+	return nil
+}
+
+// ghCommit is the subset of the GitHub PR commits API response we care about.
+type ghCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+func (g *GitHubAdapter) GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/commits?per_page=100", owner, repo, prNumber)
+	var raw []ghCommit
+	err = paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []ghCommit
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse commits response: %w", err)
+		}
+		raw = append(raw, page...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetPRCommits failed: %w", err)
+	}
+
+	commits := make([]NormalizedCommit, len(raw))
+	for i, c := range raw {
+		author := c.Commit.Author.Name
+		if c.Author != nil && c.Author.Login != "" {
+			author = c.Author.Login
+		}
+		commits[i] = NormalizedCommit{
+			SHA:       c.SHA,
+			Author:    author,
+			Message:   c.Commit.Message,
+			Timestamp: c.Commit.Author.Date,
+		}
+	}
+	return commits, nil
+}
+
+func (g *GitHubAdapter) GetPRDiff(owner, repo string, prNumber int) (string, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, prNumber)
+	body, err := makeAuthenticatedRawRequest(g.requestContext(), tok, "GET", url, "application/vnd.github.diff")
+	if err != nil {
+		return "", fmt.Errorf("GitHub adapter: GetPRDiff failed: %w", err)
+	}
+	return string(body), nil
+}
+
+func (g *GitHubAdapter) PostComment(owner, repo string, prNumber int, body string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	// PR conversation comments live on the issues API.
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments", owner, repo, prNumber)
+	rememberSelfComment(PlatformGitHub, owner+"/"+repo, prNumber, body)
+	resp, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: PostComment request failed: %w", err)
+	}
+	if err := githubAPIError(resp); err != nil {
+		return fmt.Errorf("GitHub adapter: PostComment failed: %w", err)
+	}
+	return nil
+}
+
+// UpdateComment edits a PR conversation comment; the PR number only scopes
+// self-comment de-noising, GitHub addresses comments by ID.
+func (g *GitHubAdapter) UpdateComment(owner, repo string, prNumber int, commentID, body string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/comments/%s", owner, repo, commentID)
+	rememberSelfComment(PlatformGitHub, owner+"/"+repo, prNumber, body)
+	resp, err := makeAuthenticatedRequest(g.requestContext(), tok, "PATCH", url, map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: UpdateComment request failed: %w", err)
+	}
+	if err := githubAPIError(resp); err != nil {
+		return fmt.Errorf("GitHub adapter: UpdateComment failed: %w", err)
+	}
+	return nil
+}
+
+// ghComment is a PR conversation (issue) comment or review comment.
+type ghComment struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	HTMLURL   string    `json:"html_url"`
+	Path      string    `json:"path"`
+	Line      *int      `json:"line"`
+	OrigLine  *int      `json:"original_line"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	ViaApp *struct {
+		ID int64 `json:"id"`
+	} `json:"performed_via_github_app"`
+}
+
+// GetPRComments merges the PR's conversation comments (issues API) with its
+// review comments (pulls API). Review comments on outdated lines keep their
+// original line.
+func (g *GitHubAdapter) GetPRComments(owner, repo string, prNumber int) ([]NormalizedComment, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+	var comments []NormalizedComment
+	for _, endpoint := range []string{
+		fmt.Sprintf("%s/issues/%d/comments?per_page=100", base, prNumber),
+		fmt.Sprintf("%s/pulls/%d/comments?per_page=100", base, prNumber),
+	} {
+		err := paginate(endpoint, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+			var page []ghComment
+			if err := json.Unmarshal(body, &page); err != nil {
+				return false, fmt.Errorf("GitHub adapter: failed to parse comments response: %w", err)
+			}
+			for _, c := range page {
+				comment := NormalizedComment{
+					ID:        strconv.FormatInt(c.ID, 10),
+					Author:    c.User.Login,
+					Body:      c.Body,
+					CreatedAt: c.CreatedAt,
+					Path:      c.Path,
+					URL:       c.HTMLURL,
+				}
+				if c.Line != nil {
+					comment.Line = *c.Line
+				} else if c.OrigLine != nil {
+					comment.Line = *c.OrigLine
+				}
+				if c.ViaApp != nil {
+					comment.ViaApp = c.ViaApp.ID
+				}
+				comments = append(comments, comment)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GitHub adapter: GetPRComments failed: %w", err)
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return comments, nil
+}
+
+func (g *GitHubAdapter) PublishCheckRun(owner, repo string, run CheckRun) error {
+	tok, err := g.token(owner, repo, tokenScope{"checks": "write"})
+	if err != nil {
+		return err
+	}
+
+	annotations := make([]map[string]interface{}, 0, len(run.Annotations))
+	for i, a := range run.Annotations {
+		if i == maxCheckAnnotations {
+			break
+		}
+		endLine := a.EndLine
+		if endLine < a.StartLine {
+			endLine = a.StartLine
+		}
+		annotations = append(annotations, map[string]interface{}{
+			"path":             a.Path,
+			"start_line":       a.StartLine,
+			"end_line":         endLine,
+			"annotation_level": a.Level,
+			"title":            a.Title,
+			"message":          a.Message,
+		})
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/check-runs", owner, repo)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, map[string]interface{}{
+		"name":       run.Name,
+		"head_sha":   run.HeadSHA,
+		"status":     "completed",
+		"conclusion": run.Conclusion,
+		"output": map[string]interface{}{
+			"title":       run.Title,
+			"summary":     run.Summary,
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: PublishCheckRun request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: PublishCheckRun failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubAdapter) GetFileSize(owner, repo, ref, path string) (int64, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", owner, repo, path, ref)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("GitHub adapter: GetFileSize request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: GetFileSize failed: %w", err)
+	}
+
+	var meta struct {
+		Size int64 `json:"size"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: failed to parse contents response: %w", err)
+	}
+	return meta.Size, nil
+}
+
+// githubStatsAttempts bounds how often a 202 "computing" statistics response
+// is retried before the request is reported as pending.
+const githubStatsAttempts = 4
+
+// getStats fetches a GitHub statistics endpoint into out, retrying with
+// exponential backoff while GitHub answers 202 Accepted. It returns
+// pending=true if the statistics were still being computed.
+func (g *GitHubAdapter) getStats(tok, url string, out interface{}) (pending bool, err error) {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		body, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "GET", url)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case status == 202:
+			if attempt == githubStatsAttempts {
+				return true, nil
+			}
+			time.Sleep(delay)
+			delay *= 2
+			continue
+		case status == 204:
+			return false, nil // empty repository
+		case status >= 400:
+			return false, fmt.Errorf("GitHub API %d: %s", status, string(body))
+		}
+		return false, json.Unmarshal(body, out)
+	}
+}
+
+func (g *GitHubAdapter) GetRepoStats(owner, repo string) (*RepoStats, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+	stats := &RepoStats{}
+
+	var languages map[string]int64
+	if _, err := g.getStats(tok, base+"/languages", &languages); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetRepoStats languages failed: %w", err)
+	}
+	stats.setLanguageBytes(languages)
+
+	var contributors []json.RawMessage
+	pending, err := g.getStats(tok, base+"/stats/contributors", &contributors)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetRepoStats contributors failed: %w", err)
+	}
+	stats.Pending = stats.Pending || pending
+	stats.Contributors = len(contributors)
+
+	var activity []struct {
+		Total int `json:"total"`
+	}
+	pending, err = g.getStats(tok, base+"/stats/commit_activity", &activity)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetRepoStats activity failed: %w", err)
+	}
+	stats.Pending = stats.Pending || pending
+	if len(activity) > repoStatsWeeks {
+		activity = activity[len(activity)-repoStatsWeeks:]
+	}
+	for _, week := range activity {
+		stats.WeeklyCommits = append(stats.WeeklyCommits, week.Total)
+	}
+	stats.summarizeActivity()
+
+	return stats, nil
+}
+
+// authenticatedCloneURL returns an HTTPS clone URL carrying an installation
+// token, valid for about an hour.
+func (g *GitHubAdapter) authenticatedCloneURL(owner, repo string) (string, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://x-access-token:%s@github.com/%s/%s.git", tok, owner, repo), nil
+}
+
+// githubAPIError returns an error if body is a GitHub API error object
+// ({"message": "...", "documentation_url": "..."}).
+func githubAPIError(body []byte) error {
+	var apiErr map[string]interface{}
+	if err := json.Unmarshal(body, &apiErr); err == nil {
+		if msg, ok := apiErr["message"]; ok {
+			return fmt.Errorf("GitHub API error: %v", msg)
+		}
+	}
+	return nil
+}
+
 // ghWebhookPayload is the GitHub-specific webhook JSON structure.
 type ghWebhookPayload struct {
 	Action string `json:"action"`
@@ -130,24 +643,36 @@ type ghWebhookPayload struct {
 		User    struct {
 			Login string `json:"login"`
 		} `json:"user"`
-		Head struct{ Ref string `json:"ref"` } `json:"head"`
-		Base struct{ Ref string `json:"ref"` } `json:"base"`
+		Head struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"base"`
+
+		Draft          bool   `json:"draft"`
+		Mergeable      *bool  `json:"mergeable"`
+		MergeableState string `json:"mergeable_state"`
+
+		Labels             ghLabels `json:"labels"`
+		Assignees          ghUsers  `json:"assignees"`
+		RequestedReviewers ghUsers  `json:"requested_reviewers"`
+		RequestedTeams     ghTeams  `json:"requested_teams"`
 	} `json:"pull_request"`
 
-	Repository struct {
-		Name     string `json:"name"`
-		FullName string `json:"full_name"`
-		HTMLURL  string `json:"html_url"`
-		CloneURL string `json:"clone_url"`
-		Owner    struct {
-			Login string `json:"login"`
-		} `json:"owner"`
-	} `json:"repository"`
+	Repository ghRepository `json:"repository"`
 }
 
 // NormalizeEvent parses the raw GitHub webhook payload, maps it to a
-// NormalizedEvent, and enriches it with changed files for actionable PR events.
+// NormalizedEvent, backfilling PR details the payload left out. Changed files
+// are attached by the enricher chain (see enrichers.go).
 func (g *GitHubAdapter) NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	if isGitHubSecurityEvent(eventType) {
+		return normalizeGitHubSecurityEvent(eventType, payload)
+	}
+
 	var p ghWebhookPayload
 	if err := json.Unmarshal(payload, &p); err != nil {
 		return nil, fmt.Errorf("GitHub adapter: failed to parse webhook payload: %w", err)
@@ -156,9 +681,19 @@ func (g *GitHubAdapter) NormalizeEvent(eventType string, payload []byte) (*Norma
 	pr := p.PullRequest
 	repo := p.Repository
 
+	normalizedType := fmt.Sprintf("pull_request.%s", p.Action) // e.g. "pull_request.opened"
+	if !githubCuratedActions[p.Action] && prActionPassthrough() {
+		normalizedType = EventTypeOther
+	}
+	var thread *NormalizedThread
+	if eventType == githubReviewThreadEvent {
+		normalizedType = "thread." + p.Action // thread.resolved, thread.unresolved
+		thread = githubThread(p.Action, payload)
+	}
+
 	event := &NormalizedEvent{
 		Platform:  PlatformGitHub,
-		EventType: fmt.Sprintf("pull_request.%s", p.Action), // e.g. "pull_request.opened"
+		EventType: normalizedType,
 		Action:    p.Action,
 		PR: NormalizedPR{
 			Number:       pr.Number,
@@ -167,34 +702,42 @@ func (g *GitHubAdapter) NormalizeEvent(eventType string, payload []byte) (*Norma
 			Author:       pr.User.Login,
 			SourceBranch: pr.Head.Ref,
 			TargetBranch: pr.Base.Ref,
+			HeadSHA:      pr.Head.SHA,
+			BaseSHA:      pr.Base.SHA,
 			State:        pr.State,
 			URL:          pr.HTMLURL,
+
+			Draft:          pr.Draft,
+			Mergeable:      pr.Mergeable,
+			MergeableState: pr.MergeableState,
+
+			Labels:             pr.Labels.names(),
+			Assignees:          pr.Assignees.logins(),
+			RequestedReviewers: pr.RequestedReviewers.logins(),
+			RequestedTeams:     pr.RequestedTeams.slugs(),
 		},
-		Repository: NormalizedRepository{
-			Name:     repo.Name,
-			FullName: repo.FullName,
-			Owner:    repo.Owner.Login,
-			CloneURL: repo.CloneURL,
-			HTMLURL:  repo.HTMLURL,
-		},
+		Repository: repo.normalize(),
+		Thread:     thread,
 		RawPayload: payload,
 		ReceivedAt: time.Now(),
 	}
 
-	// Fetch changed files for events that mutate the PR's commit set.
-	if pr.Number != 0 && isFileEnrichableAction(p.Action) {
-		log.Printf("[GitHub Adapter] Fetching files for PR #%d in %s\n", pr.Number, repo.FullName)
-		files, err := g.GetPRFiles(repo.Owner.Login, repo.Name, pr.Number)
-		if err != nil {
-			log.Printf("[GitHub Adapter] Warning: could not fetch PR files: %v\n", err)
-		} else {
-			event.Files = files
-		}
-	}
+	backfillPRDetails(g, event, false)
+	countUnresolvedThreads(g, event)
 
 	return event, nil
 }
 
+// githubCuratedActions are the pull_request actions the pipeline handles;
+// others (edited, labeled, converted_to_draft, …) become EventTypeOther in
+// passthrough mode.
+var githubCuratedActions = map[string]bool{
+	"opened":      true,
+	"synchronize": true,
+	"reopened":    true,
+	"closed":      true,
+}
+
 // isFileEnrichableAction returns true for PR actions where fetching changed
 // files makes sense (opened, synchronize, reopened).
 func isFileEnrichableAction(action string) bool {
@@ -204,3 +747,1374 @@ func isFileEnrichableAction(action string) bool {
 	}
 	return false
 }
+
+// githubGraphQL runs a GraphQL query and decodes its "data" into out.
+func githubGraphQL(ctx context.Context, tok, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := makeAuthenticatedRequest(ctx, tok, "POST", "https://api.github.com/graphql", map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Message string `json:"message"` // REST-style error, e.g. bad credentials
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse GraphQL response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL error: %s", resp.Errors[0].Message)
+	}
+	if resp.Message != "" {
+		return fmt.Errorf("GitHub API error: %s", resp.Message)
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+const ghReviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        nodes {
+          id
+          isResolved
+          path
+          line
+          resolvedBy { login }
+          comments(first: 1) { nodes { body url author { login } } }
+        }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}`
+
+// ghReviewThread is a review thread node of the GraphQL API.
+type ghReviewThread struct {
+	ID         string `json:"id"`
+	IsResolved bool   `json:"isResolved"`
+	Path       string `json:"path"`
+	Line       int    `json:"line"`
+	ResolvedBy *struct {
+		Login string `json:"login"`
+	} `json:"resolvedBy"`
+	Comments struct {
+		Nodes []struct {
+			Body   string `json:"body"`
+			URL    string `json:"url"`
+			Author *struct {
+				Login string `json:"login"`
+			} `json:"author"`
+		} `json:"nodes"`
+	} `json:"comments"`
+}
+
+func (t ghReviewThread) normalize() NormalizedThread {
+	thread := NormalizedThread{ID: t.ID, Path: t.Path, Line: t.Line, Resolved: t.IsResolved}
+	if t.ResolvedBy != nil {
+		thread.ResolvedBy = t.ResolvedBy.Login
+	}
+	if len(t.Comments.Nodes) > 0 {
+		c := t.Comments.Nodes[0]
+		thread.Body, thread.URL = c.Body, c.URL
+		if c.Author != nil {
+			thread.Author = c.Author.Login
+		}
+	}
+	return thread
+}
+
+func (g *GitHubAdapter) ListReviewThreads(owner, repo string, prNumber int) ([]NormalizedThread, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return nil, err
+	}
+
+	// The GraphQL API pages by cursor rather than Link header, so paginate
+	// does not apply; the same page cap does.
+	var threads []NormalizedThread
+	var cursor interface{}
+	maxPages := envInt("PAGINATION_MAX_PAGES", defaultPaginationMaxPages)
+	for page := 0; ; page++ {
+		if page == maxPages {
+			metrics.paginationTruncated.Add(1)
+			break
+		}
+		var data struct {
+			Repository struct {
+				PullRequest *struct {
+					ReviewThreads struct {
+						Nodes    []ghReviewThread `json:"nodes"`
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		err := githubGraphQL(g.requestContext(), tok, ghReviewThreadsQuery, map[string]interface{}{
+			"owner": owner, "repo": repo, "number": prNumber, "cursor": cursor,
+		}, &data)
+		if err != nil {
+			return nil, fmt.Errorf("GitHub adapter: ListReviewThreads failed: %w", err)
+		}
+		metrics.pagesFetched.Add(1)
+		pr := data.Repository.PullRequest
+		if pr == nil {
+			return nil, fmt.Errorf("GitHub adapter: ListReviewThreads: PR #%d not found", prNumber)
+		}
+		for _, t := range pr.ReviewThreads.Nodes {
+			threads = append(threads, t.normalize())
+		}
+		if !pr.ReviewThreads.PageInfo.HasNextPage {
+			break
+		}
+		cursor = pr.ReviewThreads.PageInfo.EndCursor
+	}
+	return threads, nil
+}
+
+const ghResolveReviewThreadMutation = `mutation($thread: ID!) {
+  resolveReviewThread(input: {threadId: $thread}) { thread { id isResolved } }
+}`
+
+func (g *GitHubAdapter) ResolveReviewThread(owner, repo string, prNumber int, threadID string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+	var data struct{}
+	if err := githubGraphQL(g.requestContext(), tok, ghResolveReviewThreadMutation, map[string]interface{}{"thread": threadID}, &data); err != nil {
+		return fmt.Errorf("GitHub adapter: ResolveReviewThread failed: %w", err)
+	}
+	return nil
+}
+
+// ghReviewThreadPayload is the thread of a pull_request_review_thread webhook.
+type ghReviewThreadPayload struct {
+	Thread struct {
+		NodeID   string `json:"node_id"`
+		Comments []struct {
+			Path    string `json:"path"`
+			Line    *int   `json:"line"`
+			Body    string `json:"body"`
+			HTMLURL string `json:"html_url"`
+			User    struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		} `json:"comments"`
+	} `json:"thread"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// githubThread returns the thread of a pull_request_review_thread payload.
+func githubThread(action string, payload []byte) *NormalizedThread {
+	var p ghReviewThreadPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil
+	}
+	thread := &NormalizedThread{ID: p.Thread.NodeID, Resolved: action == "resolved", Unresolved: -1}
+	if thread.Resolved {
+		thread.ResolvedBy = p.Sender.Login
+	}
+	if len(p.Thread.Comments) > 0 {
+		c := p.Thread.Comments[0]
+		thread.Path, thread.Body, thread.URL, thread.Author = c.Path, c.Body, c.HTMLURL, c.User.Login
+		if c.Line != nil {
+			thread.Line = *c.Line
+		}
+	}
+	return thread
+}
+
+// ghMergePR is the subset of the GitHub PR API response auto-merge needs.
+type ghMergePR struct {
+	State     string `json:"state"`
+	Draft     bool   `json:"draft"`
+	Mergeable *bool  `json:"mergeable"` // null while GitHub computes it
+	Head      struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+func (g *GitHubAdapter) GetMergeState(owner, repo string, prNumber int) (*MergeState, error) {
+	tok, err := g.token(owner, repo, tokenScope{"pull_requests": "read", "checks": "read", "statuses": "read"})
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "GET", fmt.Sprintf("%s/pulls/%d", base, prNumber), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetMergeState request failed: %w", err)
+	}
+	var pr ghMergePR
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse PR response: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetMergeState failed: %w", err)
+	}
+	state := &MergeState{
+		HeadSHA:   pr.Head.SHA,
+		Open:      pr.State == "open",
+		Draft:     pr.Draft,
+		Conflicts: pr.Mergeable != nil && !*pr.Mergeable,
+		Checks:    map[string]string{},
+	}
+	for _, l := range pr.Labels {
+		state.Labels = append(state.Labels, l.Name)
+	}
+
+	// A reviewer's latest approval or change request counts; comments don't.
+	latest := map[string]string{}
+	err = paginate(fmt.Sprintf("%s/pulls/%d/reviews?per_page=100", base, prNumber), githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []struct {
+			State string `json:"state"`
+			User  struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse reviews response: %w", err)
+		}
+		for _, r := range page {
+			if r.State != "COMMENTED" && r.State != "PENDING" {
+				latest[r.User.Login] = r.State
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetMergeState failed: %w", err)
+	}
+	for _, review := range latest {
+		switch review {
+		case "APPROVED":
+			state.Approvals++
+		case "CHANGES_REQUESTED":
+			state.ChangesRequested = true
+		}
+	}
+
+	err = paginate(fmt.Sprintf("%s/commits/%s/check-runs?per_page=100", base, pr.Head.SHA), githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page struct {
+			CheckRuns []struct {
+				Name       string `json:"name"`
+				Status     string `json:"status"`
+				Conclusion string `json:"conclusion"`
+			} `json:"check_runs"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse check runs response: %w", err)
+		}
+		for _, run := range page.CheckRuns {
+			switch {
+			case run.Status != "completed":
+				state.Checks[run.Name] = "pending"
+			case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+				state.Checks[run.Name] = "success"
+			default:
+				state.Checks[run.Name] = "failure"
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetMergeState failed: %w", err)
+	}
+
+	// Legacy commit statuses, reported by CI systems that predate checks.
+	body, err = makeAuthenticatedRequest(g.requestContext(), tok, "GET", fmt.Sprintf("%s/commits/%s/status", base, pr.Head.SHA), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetMergeState request failed: %w", err)
+	}
+	var combined struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := json.Unmarshal(body, &combined); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse status response: %w", err)
+	}
+	for _, st := range combined.Statuses {
+		switch st.State {
+		case "success", "pending":
+			state.Checks[st.Context] = st.State
+		default:
+			state.Checks[st.Context] = "failure"
+		}
+	}
+	return state, nil
+}
+
+func (g *GitHubAdapter) MergePR(owner, repo string, prNumber int, headSHA, method string) error {
+	tok, err := g.token(owner, repo, tokenScope{"pull_requests": "write", "contents": "write"})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/merge", owner, repo, prNumber)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PUT", url, map[string]string{"sha": headSHA, "merge_method": method})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: MergePR request failed: %w", err)
+	}
+	// A successful merge also carries a "message", so check "merged".
+	var resp struct {
+		Merged  bool   `json:"merged"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("GitHub adapter: failed to parse merge response: %w", err)
+	}
+	if !resp.Merged {
+		return fmt.Errorf("GitHub adapter: MergePR failed: GitHub API error: %s", resp.Message)
+	}
+	return nil
+}
+
+// githubProtectionURL returns the branch protection URL of branch, followed by
+// suffix.
+func githubProtectionURL(owner, repo, branch, suffix string) string {
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/branches/%s/protection%s", owner, repo, url.PathEscape(branch), suffix)
+}
+
+// GetRequiredContexts reads the required status checks of branch. GitHub
+// answers 404 both for unprotected branches and for protections without
+// required checks.
+func (g *GitHubAdapter) GetRequiredContexts(owner, repo, branch string) ([]string, bool, error) {
+	tok, err := g.token(owner, repo, tokenScope{"administration": "read"})
+	if err != nil {
+		return nil, false, err
+	}
+
+	body, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "GET", githubProtectionURL(owner, repo, branch, "/required_status_checks"))
+	if err != nil {
+		return nil, false, fmt.Errorf("GitHub adapter: GetRequiredContexts request failed: %w", err)
+	}
+	if status == 404 {
+		return nil, false, nil
+	}
+	if status >= 400 {
+		return nil, false, fmt.Errorf("GitHub adapter: GetRequiredContexts failed: %w", githubAPIError(body))
+	}
+	var checks struct {
+		Contexts []string `json:"contexts"`
+	}
+	if err := json.Unmarshal(body, &checks); err != nil {
+		return nil, false, fmt.Errorf("GitHub adapter: failed to parse required status checks: %w", err)
+	}
+	return checks.Contexts, true, nil
+}
+
+// SetRequiredContexts updates the required status checks of branch. An
+// unprotected branch gets a protection that requires only those checks; a
+// protection without required checks is left for an admin to extend, since
+// adding them would mean rewriting its other rules.
+func (g *GitHubAdapter) SetRequiredContexts(owner, repo, branch string, contexts []string) error {
+	tok, err := g.token(owner, repo, tokenScope{"administration": "write"})
+	if err != nil {
+		return err
+	}
+	if contexts == nil {
+		contexts = []string{}
+	}
+
+	_, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "GET", githubProtectionURL(owner, repo, branch, ""))
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetRequiredContexts request failed: %w", err)
+	}
+	if status == 404 {
+		protection := map[string]interface{}{
+			"required_status_checks":        map[string]interface{}{"strict": false, "contexts": contexts},
+			"enforce_admins":                nil,
+			"required_pull_request_reviews": nil,
+			"restrictions":                  nil,
+		}
+		body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PUT", githubProtectionURL(owner, repo, branch, ""), protection)
+		if err != nil {
+			return fmt.Errorf("GitHub adapter: SetRequiredContexts request failed: %w", err)
+		}
+		if err := githubAPIError(body); err != nil {
+			return fmt.Errorf("GitHub adapter: SetRequiredContexts failed: %w", err)
+		}
+		return nil
+	}
+
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PATCH", githubProtectionURL(owner, repo, branch, "/required_status_checks"),
+		map[string]interface{}{"contexts": contexts})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetRequiredContexts request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: SetRequiredContexts failed: %w", err)
+	}
+	return nil
+}
+
+// GetSBOM exports the repository's dependency graph as SPDX JSON. GitHub
+// wraps the document in {"sbom": …}; the document itself is returned.
+func (g *GitHubAdapter) GetSBOM(owner, repo string) (json.RawMessage, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/dependency-graph/sbom", owner, repo)
+	body, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetSBOM request failed: %w", err)
+	}
+	if status >= 400 {
+		apiErr := githubAPIError(body)
+		if apiErr == nil {
+			apiErr = fmt.Errorf("GitHub API %d", status)
+		}
+		return nil, fmt.Errorf("GitHub adapter: GetSBOM failed: %w", apiErr)
+	}
+	var resp struct {
+		SBOM json.RawMessage `json:"sbom"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse SBOM response: %w", err)
+	}
+	if resp.SBOM == nil {
+		return nil, fmt.Errorf("GitHub adapter: SBOM response carries no document")
+	}
+	return resp.SBOM, nil
+}
+
+// ghWorkflowRun is the subset of a GitHub Actions workflow run we care about.
+type ghWorkflowRun struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Event      string    `json:"event"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HeadSHA    string    `json:"head_sha"`
+	HeadBranch string    `json:"head_branch"`
+	RunAttempt int       `json:"run_attempt"`
+	HTMLURL    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListWorkflowRuns returns the Actions runs of headSHA with the jobs of their
+// latest attempt and their artifacts.
+func (g *GitHubAdapter) ListWorkflowRuns(owner, repo, headSHA string) ([]WorkflowRun, error) {
+	tok, err := g.token(owner, repo, scopeActionsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("https://api.github.com/repos/%s/%s/actions", owner, repo)
+	runs := []WorkflowRun{}
+	err = paginate(fmt.Sprintf("%s/runs?head_sha=%s&per_page=100", base, url.QueryEscape(headSHA)), githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page struct {
+			WorkflowRuns []ghWorkflowRun `json:"workflow_runs"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse workflow runs response: %w", err)
+		}
+		for _, r := range page.WorkflowRuns {
+			runs = append(runs, WorkflowRun{
+				ID:         r.ID,
+				Name:       r.Name,
+				Event:      r.Event,
+				Status:     r.Status,
+				Conclusion: r.Conclusion,
+				HeadSHA:    r.HeadSHA,
+				HeadBranch: r.HeadBranch,
+				Attempt:    r.RunAttempt,
+				URL:        r.HTMLURL,
+				CreatedAt:  r.CreatedAt,
+				Jobs:       []WorkflowJob{},
+				Artifacts:  []WorkflowArtifact{},
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: ListWorkflowRuns failed: %w", err)
+	}
+
+	for i := range runs {
+		run := &runs[i]
+		err := paginate(fmt.Sprintf("%s/runs/%d/jobs?filter=latest&per_page=100", base, run.ID), githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+			var page struct {
+				Jobs []WorkflowJob `json:"jobs"`
+			}
+			if err := json.Unmarshal(body, &page); err != nil {
+				return false, fmt.Errorf("GitHub adapter: failed to parse jobs response: %w", err)
+			}
+			run.Jobs = append(run.Jobs, page.Jobs...)
+			return true, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GitHub adapter: ListWorkflowRuns failed: %w", err)
+		}
+		err = paginate(fmt.Sprintf("%s/runs/%d/artifacts?per_page=100", base, run.ID), githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+			var page struct {
+				Artifacts []struct {
+					ID          int64     `json:"id"`
+					Name        string    `json:"name"`
+					SizeInBytes int64     `json:"size_in_bytes"`
+					Expired     bool      `json:"expired"`
+					ExpiresAt   time.Time `json:"expires_at"`
+				} `json:"artifacts"`
+			}
+			if err := json.Unmarshal(body, &page); err != nil {
+				return false, fmt.Errorf("GitHub adapter: failed to parse artifacts response: %w", err)
+			}
+			for _, a := range page.Artifacts {
+				run.Artifacts = append(run.Artifacts, WorkflowArtifact{
+					ID:        a.ID,
+					Name:      a.Name,
+					SizeBytes: a.SizeInBytes,
+					Expired:   a.Expired,
+					ExpiresAt: a.ExpiresAt,
+				})
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GitHub adapter: ListWorkflowRuns failed: %w", err)
+		}
+	}
+	return runs, nil
+}
+
+// OpenArtifact streams an Actions artifact as a zip archive.
+func (g *GitHubAdapter) OpenArtifact(owner, repo string, artifactID int64) (io.ReadCloser, int64, error) {
+	tok, err := g.token(owner, repo, scopeActionsRead)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, size, err := openGitHubDownload(g.requestContext(), tok, fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/artifacts/%d/zip", owner, repo, artifactID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("GitHub adapter: OpenArtifact failed: %w", err)
+	}
+	return body, size, nil
+}
+
+// OpenLogs streams the logs of an Actions run (zip) or of one of its jobs
+// (plain text).
+func (g *GitHubAdapter) OpenLogs(owner, repo string, runID, jobID int64) (io.ReadCloser, int64, error) {
+	tok, err := g.token(owner, repo, scopeActionsRead)
+	if err != nil {
+		return nil, 0, err
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/runs/%d/logs", owner, repo, runID)
+	if jobID > 0 {
+		url = fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/jobs/%d/logs", owner, repo, jobID)
+	}
+	body, size, err := openGitHubDownload(g.requestContext(), tok, url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GitHub adapter: OpenLogs failed: %w", err)
+	}
+	return body, size, nil
+}
+
+// openGitHubDownload starts a download from an API endpoint that redirects
+// to a short-lived storage URL. The client drops the Authorization header on
+// the cross-host redirect, which the pre-signed URL does not need.
+func openGitHubDownload(ctx context.Context, token, url string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	setGitHubHeaders(req, "")
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := githubAPIError(body)
+		if apiErr == nil {
+			apiErr = fmt.Errorf("GitHub API %d", resp.StatusCode)
+		}
+		return nil, 0, apiErr
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// TriggerCI sends a repository_dispatch or workflow_dispatch event. GitHub
+// answers 204 without identifying the runs it starts.
+func (g *GitHubAdapter) TriggerCI(owner, repo string, req DispatchRequest) (string, error) {
+	var endpoint string
+	var payload map[string]interface{}
+	switch req.Kind {
+	case dispatchRepository:
+		if req.EventType == "" {
+			return "", fmt.Errorf("GitHub adapter: repository_dispatch requires event_type")
+		}
+		endpoint = fmt.Sprintf("https://api.github.com/repos/%s/%s/dispatches", owner, repo)
+		payload = map[string]interface{}{"event_type": req.EventType}
+		if len(req.Inputs) > 0 {
+			payload["client_payload"] = req.Inputs
+		}
+	case dispatchWorkflow:
+		if req.Workflow == "" || req.Ref == "" {
+			return "", fmt.Errorf("GitHub adapter: workflow_dispatch requires workflow and ref")
+		}
+		endpoint = fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/workflows/%s/dispatches", owner, repo, url.PathEscape(req.Workflow))
+		payload = map[string]interface{}{"ref": req.Ref}
+		if len(req.Inputs) > 0 {
+			payload["inputs"] = req.Inputs
+		}
+	default:
+		return "", fmt.Errorf("GitHub adapter: unsupported dispatch kind %q", req.Kind)
+	}
+
+	tok, err := g.token(owner, repo, tokenScope{"actions": "write", "contents": "write"})
+	if err != nil {
+		return "", err
+	}
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, payload)
+	if err != nil {
+		return "", fmt.Errorf("GitHub adapter: TriggerCI request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return "", fmt.Errorf("GitHub adapter: TriggerCI failed: %w", err)
+	}
+	return "", nil
+}
+
+// CreateDeployment creates a deployment. auto_merge is off: the CD system
+// deploys exactly the ref it was given.
+func (g *GitHubAdapter) CreateDeployment(owner, repo string, d Deployment) (int64, error) {
+	tok, err := g.token(owner, repo, scopeDeploymentsWrite)
+	if err != nil {
+		return 0, err
+	}
+
+	contexts := d.RequiredContexts
+	if contexts == nil {
+		contexts = []string{}
+	}
+	payload := map[string]interface{}{
+		"ref":               d.Ref,
+		"environment":       d.Environment,
+		"description":       d.Description,
+		"auto_merge":        false,
+		"required_contexts": contexts,
+	}
+	if d.Payload != nil {
+		payload["payload"] = d.Payload
+	}
+	if d.Production != nil {
+		payload["production_environment"] = *d.Production
+	}
+	if d.Transient != nil {
+		payload["transient_environment"] = *d.Transient
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/deployments", owner, repo)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, payload)
+	if err != nil {
+		return 0, fmt.Errorf("GitHub adapter: CreateDeployment request failed: %w", err)
+	}
+	var resp struct {
+		ID      int64  `json:"id"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: failed to parse deployment response: %w", err)
+	}
+	if resp.ID == 0 {
+		return 0, fmt.Errorf("GitHub adapter: CreateDeployment failed: GitHub API error: %s", resp.Message)
+	}
+	return resp.ID, nil
+}
+
+// SetDeploymentStatus creates a deployment status. GitHub marks earlier
+// deployments to the same environment inactive on success.
+func (g *GitHubAdapter) SetDeploymentStatus(owner, repo string, id int64, s DeploymentStatus) error {
+	tok, err := g.token(owner, repo, scopeDeploymentsWrite)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{"state": s.State}
+	if s.Description != "" {
+		payload["description"] = s.Description
+	}
+	if s.EnvironmentURL != "" {
+		payload["environment_url"] = s.EnvironmentURL
+	}
+	if s.LogURL != "" {
+		payload["log_url"] = s.LogURL
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/deployments/%d/statuses", owner, repo, id)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, payload)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetDeploymentStatus request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: SetDeploymentStatus failed: %w", err)
+	}
+	return nil
+}
+
+// githubReviewEvents maps review verdicts to the reviews API's events.
+var githubReviewEvents = map[string]string{
+	"":                        "COMMENT",
+	ReviewEventComment:        "COMMENT",
+	ReviewEventApprove:        "APPROVE",
+	ReviewEventRequestChanges: "REQUEST_CHANGES",
+}
+
+// CreateReview submits the review in one call to the reviews API. Inline
+// comments are anchored with line and side, which take file lines like
+// ReviewComment; the API has no file-level review comments, so those are
+// appended to the review body.
+func (g *GitHubAdapter) CreateReview(owner, repo string, prNumber int, review PRReview) error {
+	event, ok := githubReviewEvents[review.Event]
+	if !ok {
+		return fmt.Errorf("GitHub adapter: unknown review event %q", review.Event)
+	}
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	body := review.Body
+	comments := make([]map[string]interface{}, 0, len(review.Comments))
+	for _, c := range review.Comments {
+		if c.Line <= 0 {
+			body += fmt.Sprintf("\n- `%s` — %s", c.Path, c.Body)
+			continue
+		}
+		side := c.Side
+		if side == "" {
+			side = "RIGHT"
+		}
+		comments = append(comments, map[string]interface{}{
+			"path": c.Path,
+			"line": c.Line,
+			"side": side,
+			"body": c.Body,
+		})
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/reviews", owner, repo, prNumber)
+	rememberSelfComment(PlatformGitHub, owner+"/"+repo, prNumber, body)
+	resp, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, map[string]interface{}{
+		"body":     body,
+		"event":    event,
+		"comments": comments,
+	})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: CreateReview request failed: %w", err)
+	}
+	if err := githubAPIError(resp); err != nil {
+		return fmt.Errorf("GitHub adapter: CreateReview failed: %w", err)
+	}
+	return nil
+}
+
+// ghRelease is the subset of a GitHub release we care about.
+type ghRelease struct {
+	ID          int64      `json:"id"`
+	TagName     string     `json:"tag_name"`
+	Body        string     `json:"body"`
+	Draft       bool       `json:"draft"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// listReleases returns the repository's releases, drafts included, newest
+// first.
+func (g *GitHubAdapter) listReleases(tok, owner, repo string) ([]ghRelease, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases?per_page=100", owner, repo)
+	var releases []ghRelease
+	err := paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []ghRelease
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse releases response: %w", err)
+		}
+		releases = append(releases, page...)
+		return true, nil
+	})
+	return releases, err
+}
+
+func (g *GitHubAdapter) PreviousRelease(owner, repo, tag string) (string, time.Time, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	releases, err := g.listReleases(tok, owner, repo)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	before := time.Now()
+	for _, r := range releases {
+		if r.TagName == tag && r.PublishedAt != nil {
+			before = *r.PublishedAt
+		}
+	}
+	var prevTag string
+	var prevAt time.Time
+	for _, r := range releases {
+		if r.Draft || r.PublishedAt == nil || r.TagName == tag || !r.PublishedAt.Before(before) {
+			continue
+		}
+		if r.PublishedAt.After(prevAt) {
+			prevTag, prevAt = r.TagName, *r.PublishedAt
+		}
+	}
+	return prevTag, prevAt, nil
+}
+
+func (g *GitHubAdapter) SetReleaseNotes(owner, repo, tag, notes string) (bool, error) {
+	tok, err := g.token(owner, repo, scopeContentsWrite)
+	if err != nil {
+		return false, err
+	}
+	// The tags endpoint skips drafts, so look the release up in the list.
+	releases, err := g.listReleases(tok, owner, repo)
+	if err != nil {
+		return false, err
+	}
+
+	method := "POST"
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
+	payload := map[string]interface{}{"tag_name": tag, "name": tag, "body": notes, "draft": true}
+	for _, r := range releases {
+		if r.TagName != tag {
+			continue
+		}
+		if strings.TrimSpace(r.Body) != "" {
+			return false, nil
+		}
+		method = "PATCH"
+		url = fmt.Sprintf("%s/%d", url, r.ID)
+		payload = map[string]interface{}{"body": notes}
+		break
+	}
+
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, method, url, payload)
+	if err != nil {
+		return false, fmt.Errorf("GitHub adapter: SetReleaseNotes request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return false, fmt.Errorf("GitHub adapter: SetReleaseNotes failed: %w", err)
+	}
+	return true, nil
+}
+
+// maxStatusDescription is the longest description GitHub accepts on a
+// commit status.
+const maxStatusDescription = 140
+
+func (g *GitHubAdapter) SetCommitStatus(owner, repo, sha, state, context, description, targetURL string) error {
+	tok, err := g.token(owner, repo, tokenScope{"statuses": "write"})
+	if err != nil {
+		return err
+	}
+	if len(description) > maxStatusDescription {
+		description = description[:maxStatusDescription-3] + "..."
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/statuses/%s", owner, repo, sha)
+	status := map[string]string{
+		"state":       state,
+		"context":     context,
+		"description": description,
+	}
+	if targetURL != "" {
+		status["target_url"] = targetURL
+	}
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, status)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetCommitStatus request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: SetCommitStatus failed: %w", err)
+	}
+	return nil
+}
+
+// githubContentPath escapes each segment of a repository path for the
+// contents API.
+func githubContentPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// getContent returns the content and blob SHA of path at ref, or
+// errFileNotFound.
+func (g *GitHubAdapter) getContent(tok, owner, repo, ref, path string) ([]byte, string, error) {
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", owner, repo, githubContentPath(path), url.QueryEscape(ref))
+	body, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "GET", endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("GitHub adapter: contents request failed: %w", err)
+	}
+	if status == http.StatusNotFound {
+		return nil, "", errFileNotFound
+	}
+	if err := githubAPIError(body); err != nil {
+		return nil, "", fmt.Errorf("GitHub adapter: contents request failed: %w", err)
+	}
+	var file struct {
+		Type     string `json:"type"`
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+		SHA      string `json:"sha"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, "", fmt.Errorf("GitHub adapter: failed to parse contents response: %w", err)
+	}
+	if file.Type != "file" || file.Encoding != "base64" {
+		return nil, "", fmt.Errorf("GitHub adapter: %s is not a file GitHub returns inline", path)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("GitHub adapter: failed to decode %s: %w", path, err)
+	}
+	return content, file.SHA, nil
+}
+
+func (g *GitHubAdapter) GetFileContent(owner, repo, ref, path string) ([]byte, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return nil, err
+	}
+	content, _, err := g.getContent(tok, owner, repo, ref, path)
+	return content, err
+}
+
+// GetFileSizes lists the blobs of ref's tree. A tree too large for one
+// response is truncated by GitHub; files past the cut are left out.
+func (g *GitHubAdapter) GetFileSizes(owner, repo, ref string) (map[string]int64, error) {
+	tok, err := g.token(owner, repo, scopeContentsRead)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/trees/%s?recursive=1", owner, repo, url.PathEscape(ref))
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetFileSizes request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetFileSizes failed: %w", err)
+	}
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+			Size int64  `json:"size"`
+		} `json:"tree"`
+	}
+	if err := json.Unmarshal(body, &tree); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse tree response: %w", err)
+	}
+	sizes := make(map[string]int64, len(tree.Tree))
+	for _, e := range tree.Tree {
+		if e.Type == "blob" {
+			sizes[e.Path] = e.Size
+		}
+	}
+	return sizes, nil
+}
+
+func (g *GitHubAdapter) CreateBranch(owner, repo, branch, from string) error {
+	tok, err := g.token(owner, repo, scopeContentsWrite)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/ref/heads/%s", owner, repo, from)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: CreateBranch request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: could not resolve %s: %w", from, err)
+	}
+	var ref struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(body, &ref); err != nil {
+		return fmt.Errorf("GitHub adapter: failed to parse ref response: %w", err)
+	}
+
+	endpoint = fmt.Sprintf("https://api.github.com/repos/%s/%s/git/refs", owner, repo)
+	body, err = makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, map[string]string{
+		"ref": "refs/heads/" + branch,
+		"sha": ref.Object.SHA,
+	})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: CreateBranch request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: CreateBranch failed: %w", err)
+	}
+	return nil
+}
+
+// CreateCommit writes path through the contents API, which needs the SHA of
+// the blob being replaced; a concurrent change to the file makes it fail.
+func (g *GitHubAdapter) CreateCommit(owner, repo string, commit FileCommit) (string, error) {
+	tok, err := g.token(owner, repo, scopeContentsWrite)
+	if err != nil {
+		return "", err
+	}
+	_, blobSHA, err := g.getContent(tok, owner, repo, commit.Branch, commit.Path)
+	if err != nil && err != errFileNotFound {
+		return "", err
+	}
+
+	payload := map[string]interface{}{
+		"message": commit.Message,
+		"content": base64.StdEncoding.EncodeToString(commit.Content),
+		"branch":  commit.Branch,
+	}
+	if blobSHA != "" {
+		payload["sha"] = blobSHA
+	}
+	if commit.Author != nil {
+		payload["author"] = commit.Author
+	}
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", owner, repo, githubContentPath(commit.Path))
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PUT", endpoint, payload)
+	if err != nil {
+		return "", fmt.Errorf("GitHub adapter: CreateCommit request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return "", fmt.Errorf("GitHub adapter: CreateCommit failed: %w", err)
+	}
+	var resp struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("GitHub adapter: failed to parse contents response: %w", err)
+	}
+	return resp.Commit.SHA, nil
+}
+
+func (g *GitHubAdapter) CreatePR(owner, repo string, pr NewPR) (int, error) {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", owner, repo)
+	resp, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, map[string]interface{}{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.Head,
+		"base":  pr.Base,
+		"draft": pr.Draft,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GitHub adapter: CreatePR request failed: %w", err)
+	}
+	if err := githubAPIError(resp); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: CreatePR failed: %w", err)
+	}
+	var created ghPRResponse
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: failed to parse PR response: %w", err)
+	}
+	return created.Number, nil
+}
+
+// ghRepository is the subset of a GitHub repository, of the API and of
+// webhook payloads, we care about.
+type ghRepository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Visibility    string `json:"visibility"` // absent on older GHES versions
+	Language      string `json:"language"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+func (r ghRepository) normalize() NormalizedRepository {
+	repo := NormalizedRepository{
+		Name:          r.Name,
+		FullName:      r.FullName,
+		Owner:         r.Owner.Login,
+		CloneURL:      r.CloneURL,
+		HTMLURL:       r.HTMLURL,
+		DefaultBranch: r.DefaultBranch,
+		Visibility:    r.Visibility,
+		Language:      r.Language,
+	}
+	if r.ID != 0 {
+		repo.ID = strconv.FormatInt(r.ID, 10)
+	}
+	if repo.Visibility == "" && r.FullName != "" {
+		repo.Visibility = "public"
+		if r.Private {
+			repo.Visibility = "private"
+		}
+	}
+	return repo
+}
+
+// CreateRepoFromTemplate uses the generate API. GitHub copies the template's
+// content asynchronously, so the default branch may appear a moment later.
+func (g *GitHubAdapter) CreateRepoFromTemplate(templateOwner, templateRepo string, repo NewRepo) (*NormalizedRepository, error) {
+	tok, err := g.token(repo.Owner, "", tokenScope{"administration": "write", "contents": "read"})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/generate", templateOwner, templateRepo)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, map[string]interface{}{
+		"owner":       repo.Owner,
+		"name":        repo.Name,
+		"description": repo.Description,
+		"private":     repo.Private,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: CreateRepoFromTemplate request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: CreateRepoFromTemplate failed: %w", err)
+	}
+	var created ghRepository
+	if err := json.Unmarshal(body, &created); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse repository response: %w", err)
+	}
+	normalized := created.normalize()
+	return &normalized, nil
+}
+
+// SetLabel updates the label and creates it if the update finds none.
+func (g *GitHubAdapter) SetLabel(owner, repo string, label RepoLabel) error {
+	tok, err := g.token(owner, repo, scopeIssuesWrite)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]string{"color": label.Color, "description": label.Description}
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/labels/%s", owner, repo, url.PathEscape(label.Name))
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "PATCH", endpoint, fields)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetLabel request failed: %w", err)
+	}
+	if githubAPIError(body) == nil {
+		return nil
+	}
+
+	fields["name"] = label.Name
+	endpoint = fmt.Sprintf("https://api.github.com/repos/%s/%s/labels", owner, repo)
+	body, err = makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, fields)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetLabel request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: SetLabel failed: %w", err)
+	}
+	return nil
+}
+
+// GetPRLabels reads the labels of the PR's issue.
+func (g *GitHubAdapter) GetPRLabels(owner, repo string, prNumber int) ([]string, error) {
+	tok, err := g.token(owner, repo, scopePRRead)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []string{}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/labels?per_page=100", owner, repo, prNumber)
+	err = paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []RepoLabel
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse labels response: %w", err)
+		}
+		for _, l := range page {
+			labels = append(labels, l.Name)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: GetPRLabels failed: %w", err)
+	}
+	return labels, nil
+}
+
+// AddLabels adds labels to the PR's issue, creating labels the repository
+// does not have yet.
+func (g *GitHubAdapter) AddLabels(owner, repo string, prNumber int, labels []string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/labels", owner, repo, prNumber)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", url, map[string][]string{"labels": labels})
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: AddLabels request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: AddLabels failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubAdapter) RemoveLabel(owner, repo string, prNumber int, label string) error {
+	tok, err := g.token(owner, repo, scopePRWrite)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/labels/%s", owner, repo, prNumber, url.PathEscape(label))
+	body, status, err := makeAuthenticatedRequestWithStatus(g.requestContext(), tok, "DELETE", endpoint)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: RemoveLabel request failed: %w", err)
+	}
+	if status == http.StatusNotFound {
+		return nil
+	}
+	if status >= 400 {
+		apiErr := githubAPIError(body)
+		if apiErr == nil {
+			apiErr = fmt.Errorf("GitHub API %d", status)
+		}
+		return fmt.Errorf("GitHub adapter: RemoveLabel failed: %w", apiErr)
+	}
+	return nil
+}
+
+func (g *GitHubAdapter) ListLabels(owner, repo string) ([]RepoLabel, error) {
+	tok, err := g.token(owner, repo, scopeIssuesRead)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []RepoLabel{}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/labels?per_page=100", owner, repo)
+	err = paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []RepoLabel
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse labels response: %w", err)
+		}
+		labels = append(labels, page...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: ListLabels failed: %w", err)
+	}
+	return labels, nil
+}
+
+// ListMilestones reads due dates as their YYYY-MM-DD prefix.
+func (g *GitHubAdapter) ListMilestones(owner, repo string) ([]RepoMilestone, error) {
+	tok, err := g.token(owner, repo, scopeIssuesRead)
+	if err != nil {
+		return nil, err
+	}
+
+	milestones := []RepoMilestone{}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/milestones?state=all&per_page=100", owner, repo)
+	err = paginate(url, githubPages(g.requestContext(), tok), func(body []byte) (bool, error) {
+		var page []RepoMilestone
+		if err := json.Unmarshal(body, &page); err != nil {
+			return false, fmt.Errorf("GitHub adapter: failed to parse milestones response: %w", err)
+		}
+		for _, m := range page {
+			if len(m.DueOn) > 10 {
+				m.DueOn = m.DueOn[:10]
+			}
+			milestones = append(milestones, m)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: ListMilestones failed: %w", err)
+	}
+	return milestones, nil
+}
+
+func (g *GitHubAdapter) SetMilestone(owner, repo string, milestone RepoMilestone) error {
+	tok, err := g.token(owner, repo, scopeIssuesWrite)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]string{"title": milestone.Title, "description": milestone.Description}
+	if milestone.State != "" {
+		fields["state"] = milestone.State
+	}
+	if milestone.DueOn != "" {
+		fields["due_on"] = milestone.DueOn + "T00:00:00Z"
+	}
+	method, endpoint := "POST", fmt.Sprintf("https://api.github.com/repos/%s/%s/milestones", owner, repo)
+	if milestone.Number != 0 {
+		method, endpoint = "PATCH", fmt.Sprintf("%s/%d", endpoint, milestone.Number)
+	}
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, method, endpoint, fields)
+	if err != nil {
+		return fmt.Errorf("GitHub adapter: SetMilestone request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return fmt.Errorf("GitHub adapter: SetMilestone failed: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubAdapter) CreateWebhook(owner, repo string, hook NewWebhook) (int64, error) {
+	tok, err := g.token(owner, repo, tokenScope{"repository_hooks": "write"})
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/hooks", owner, repo)
+	body, err := makeAuthenticatedRequest(g.requestContext(), tok, "POST", endpoint, map[string]interface{}{
+		"name":   "web",
+		"active": true,
+		"events": hook.Events,
+		"config": map[string]string{
+			"url":          hook.URL,
+			"content_type": "json",
+			"secret":       hook.Secret,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GitHub adapter: CreateWebhook request failed: %w", err)
+	}
+	if err := githubAPIError(body); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: CreateWebhook failed: %w", err)
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return 0, fmt.Errorf("GitHub adapter: failed to parse webhook response: %w", err)
+	}
+	return created.ID, nil
+}
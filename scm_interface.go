@@ -1,7 +1,12 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
 	"time"
 )
 
@@ -9,9 +14,14 @@ import (
 type SCMPlatform string
 
 const (
-	PlatformGitHub    SCMPlatform = "github"
-	PlatformBitbucket SCMPlatform = "bitbucket"
-	PlatformUnknown   SCMPlatform = "unknown"
+	PlatformGitHub          SCMPlatform = "github"
+	PlatformBitbucket       SCMPlatform = "bitbucket" // Bitbucket Cloud
+	PlatformGerrit          SCMPlatform = "gerrit"
+	PlatformGitea           SCMPlatform = "gitea"            // Gitea and Forgejo
+	PlatformBitbucketServer SCMPlatform = "bitbucket_server" // Bitbucket Server and Data Center
+	PlatformCodeCommit      SCMPlatform = "codecommit"       // AWS CodeCommit, via SNS
+	PlatformGitLab          SCMPlatform = "gitlab"           // recognised, but no adapter yet
+	PlatformUnknown         SCMPlatform = "unknown"
 )
 
 // NormalizedPR is a platform-agnostic pull request representation.
@@ -22,17 +32,38 @@ type NormalizedPR struct {
 	Author       string
 	SourceBranch string
 	TargetBranch string
+	HeadSHA      string // the source branch's commit
+	BaseSHA      string // the target branch's commit
 	State        string
 	URL          string
+
+	// Draft PRs are still being worked on; consumers may skip analysing them.
+	Draft bool
+	// Mergeable reports whether the PR merges without conflicts; nil while
+	// the SCM is still computing it or when the platform does not say.
+	Mergeable *bool
+	// MergeableState is the SCM's finer-grained merge status: "clean",
+	// "dirty" (conflicts), "blocked", "behind", "unstable", "draft" or
+	// "unknown" on GitHub; "" where the platform does not report one.
+	MergeableState string
+
+	Labels             []string
+	Assignees          []string // account logins
+	RequestedReviewers []string // account logins whose review is requested
+	RequestedTeams     []string // team slugs whose review is requested (GitHub)
 }
 
 // NormalizedRepository is a platform-agnostic repository representation.
 type NormalizedRepository struct {
-	Name     string
-	FullName string
-	Owner    string
-	CloneURL string
-	HTMLURL  string
+	ID            string // the platform's repository ID (GitHub numeric ID, Bitbucket UUID, …)
+	Name          string
+	FullName      string
+	Owner         string
+	CloneURL      string
+	HTMLURL       string
+	DefaultBranch string
+	Visibility    string // "public", "private" or "internal"; "" if the platform does not say
+	Language      string // primary language, where the platform detects one
 }
 
 // NormalizedFile is a platform-agnostic changed-file representation.
@@ -44,28 +75,159 @@ type NormalizedFile struct {
 	Deletions        int
 	Changes          int
 	PreviousFilename string // only set when Status == "renamed"
+	// Patch is the file's unified diff hunks, with the "file_patches"
+	// feature flag; PatchTruncated is set when hunks were cut off at
+	// FILE_PATCH_MAX_BYTES (see file_patch.go).
+	Patch          string
+	PatchTruncated bool
+	// IsBinary and SizeBytes (at the PR head; 0 when unknown or removed)
+	// let analyzers skip files without fetching them (see file_stats.go).
+	IsBinary  bool
+	SizeBytes int64
+}
+
+// NormalizedCommit is a platform-agnostic commit representation.
+type NormalizedCommit struct {
+	SHA       string
+	Author    string
+	Message   string
+	Timestamp time.Time
+}
+
+// NormalizedThread is a platform-agnostic PR review thread: an inline
+// comment and its replies, which can be resolved as a whole.
+type NormalizedThread struct {
+	ID         string // GitHub: the thread's GraphQL node ID; Bitbucket: the root comment ID
+	Path       string
+	Line       int
+	Author     string // author of the first comment
+	Body       string // first comment
+	URL        string
+	Resolved   bool
+	ResolvedBy string
+	// Unresolved is the number of threads still unresolved on the PR after
+	// this event, or -1 if they could not be listed (thread events only).
+	Unresolved int
+}
+
+// NormalizedComment is a comment on a pull request. Path and Line anchor an
+// inline (review) comment; both are empty for conversation comments.
+type NormalizedComment struct {
+	ID        string
+	Author    string
+	Body      string
+	CreatedAt time.Time
+	Path      string
+	Line      int
+	URL       string
+	ViaApp    int64 // GitHub App that posted it, if any
+}
+
+// NormalizedSecurityAlert is a platform-agnostic security alert: a
+// vulnerable dependency, a code scanning finding or a published advisory.
+type NormalizedSecurityAlert struct {
+	Source   string // "dependabot_alert", "code_scanning_alert" or "security_advisory"
+	ID       string // the alert number, or the advisory's GHSA ID
+	State    string // e.g. "open", "dismissed", "fixed", "published"
+	Severity string // "critical", "high", "medium", "low" or "unknown"
+	Summary  string
+	URL      string
+	GHSA     string
+	CVE      string
+	Packages []AffectedPackage
+	Rule     string // code scanning only: the rule and tool that raised it
+	Tool     string
+	Path     string // code scanning only: where the finding is
+	Line     int
 }
 
+// AffectedPackage is a package a security alert applies to.
+type AffectedPackage struct {
+	Ecosystem          string // e.g. "npm", "pip", "maven"
+	Name               string
+	VulnerableVersions string // e.g. "< 4.17.21"
+	PatchedVersion     string // empty if no fix is released
+	Manifest           string // dependabot_alert only, e.g. "package-lock.json"
+}
+
+// NormalizedSchemaVersion is the version of the NormalizedEvent shape
+// produced by this build. Bump it when fields change meaning or are removed.
+const NormalizedSchemaVersion = 1
+
 // NormalizedEvent is the unified event the SCM Adapter emits after consuming a
 // raw webhook, enriching it with PR metadata and changed files.
 type NormalizedEvent struct {
-	Platform   SCMPlatform
-	EventType  string // e.g. "pull_request.opened", "pull_request.closed"
-	Action     string // e.g. "opened", "synchronize", "closed"
-	PR         NormalizedPR
-	Repository NormalizedRepository
-	Files      []NormalizedFile
-	RawPayload []byte
-	ReceivedAt time.Time
-}
-
-// SCMAdapter is the interface every SCM provider must implement.
-// Adding support for a new SCM (GitLab, Azure DevOps, …) means creating a
-// struct that satisfies this interface — no other code needs to change.
+	EventID       string // assigned by the gateway when the webhook is received
+	DeliveryID    string // the SCM's delivery ID (X-GitHub-Delivery / X-Request-UUID)
+	SchemaVersion int
+	Platform      SCMPlatform
+	EventType     string // e.g. "pull_request.opened", "pull_request.closed"
+	Action        string // e.g. "opened", "synchronize", "closed"
+	PR            NormalizedPR
+	Repository    NormalizedRepository
+	Files         []NormalizedFile
+	Commits       []NormalizedCommit       // with the "commits" enricher
+	Owners        []string                 // owners of the changed files, with the "owners" enricher
+	Tickets       []TicketRef              // issue-tracker keys referenced by the PR
+	Dependencies  []PRDependency           // PRs it depends on, with the "dependencies" enricher
+	Thread        *NormalizedThread        // thread.resolved / thread.unresolved events only
+	Security      *NormalizedSecurityAlert // security.alert events only
+	// CanonicalAuthor is the corporate identity of the PR author, with the
+	// "identity" enricher (see identity.go).
+	CanonicalAuthor *CanonicalIdentity
+	// Origin is set on events forwarded between gateways (see
+	// federation.go); nil for events normalized here.
+	Origin *EventOrigin
+	// PolicyFindings are violations reported by the policy stages.
+	PolicyFindings []PolicyFinding
+	// EnrichmentSkipped is the reason enrichment stopped after the file
+	// list (see enrichers.go, path_rules.go); empty when the event was
+	// fully enriched.
+	EnrichmentSkipped string
+	// StaleReason is set when the PR moved on before the event was
+	// delivered (see staleness.go, STALE_EVENTS=flag).
+	StaleReason string
+	RawPayload  []byte
+	ReceivedAt  time.Time
+}
+
+// EventTypeOther is the EventType of PR actions the gateway does not curate,
+// emitted in passthrough mode with the SCM's raw action kept in Action
+// (e.g. "labeled", "approved").
+const EventTypeOther = "pull_request.other"
+
+// prActionPassthrough reports whether uncurated PR actions are forwarded as
+// EventTypeOther (PR_ACTION_PASSTHROUGH=true) instead of the legacy mapping:
+// "pull_request.<action>" on GitHub, "pull_request.unknown" elsewhere.
+func prActionPassthrough() bool {
+	return os.Getenv("PR_ACTION_PASSTHROUGH") == "true"
+}
+
+// SCMAdapter is the core every SCM provider must implement: identifying the
+// platform and normalizing its webhooks. Adding support for a new SCM
+// (GitLab, Azure DevOps, …) means creating a struct that satisfies this
+// interface — no other code needs to change.
+//
+// Everything beyond the core is an optional capability — PRReader, PRLister,
+// PRWriter, PRCommentReader, ReviewCreator, PRMerger, CheckPublisher,
+// StatusPublisher, PRLabeler, ThreadResolver, RepoReader, FileReader,
+// ContentWriter, RepoProvisioner, LabelManager, SBOMReader, BranchProtector,
+// CIArtifactReader, CITrigger, Deployer, ReleaseWriter — detected at runtime
+// with a type assertion, so a partial adapter (e.g. a read-only Gerrit
+// adapter) implements only what it supports and the stages that need the
+// rest skip themselves.
 type SCMAdapter interface {
 	// Platform returns the identifier of the SCM this adapter handles.
 	Platform() SCMPlatform
 
+	// NormalizeEvent converts a raw webhook payload into a NormalizedEvent,
+	// fetching PR details the payload is missing. Files, commits and other
+	// additions are left to the enricher chain (see enrichers.go).
+	NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error)
+}
+
+// PRReader reads pull request data from the SCM.
+type PRReader interface {
 	// GetPRDetails fetches pull-request metadata from the SCM API and returns
 	// it in the normalized format.
 	GetPRDetails(owner, repo string, prNumber int) (*NormalizedPR, error)
@@ -74,14 +236,305 @@ type SCMAdapter interface {
 	// returns them in the normalized format.
 	GetPRFiles(owner, repo string, prNumber int) ([]NormalizedFile, error)
 
-	// NormalizeEvent converts a raw webhook payload into a NormalizedEvent,
-	// fetching additional PR details and file lists as needed.
-	NormalizeEvent(eventType string, payload []byte) (*NormalizedEvent, error)
+	// GetPRCommits fetches the commits of a pull request, oldest first.
+	GetPRCommits(owner, repo string, prNumber int) ([]NormalizedCommit, error)
+
+	// GetPRDiff fetches the pull request's full unified diff.
+	GetPRDiff(owner, repo string, prNumber int) (string, error)
+}
+
+// PRLister lists a repository's pull requests.
+type PRLister interface {
+	// ListOpenPRs returns the repository's open pull requests.
+	ListOpenPRs(owner, repo string) ([]NormalizedPR, error)
+}
+
+// PRWriter modifies pull requests.
+type PRWriter interface {
+	// UpdatePRDescription replaces the pull request's description (body).
+	UpdatePRDescription(owner, repo string, prNumber int, description string) error
+
+	// PostComment adds a top-level comment to the pull request.
+	PostComment(owner, repo string, prNumber int, body string) error
+}
+
+// PRCommentReader reads the discussion of a pull request.
+type PRCommentReader interface {
+	// GetPRComments returns the PR's conversation and inline comments,
+	// oldest first.
+	GetPRComments(owner, repo string, prNumber int) ([]NormalizedComment, error)
+}
+
+// CommentEditor edits comments the gateway posted, so reports that are
+// refreshed on every push stay a single comment.
+type CommentEditor interface {
+	// UpdateComment replaces the body of a top-level PR comment, identified
+	// by the NormalizedComment ID returned by GetPRComments.
+	UpdateComment(owner, repo string, prNumber int, commentID, body string) error
+}
+
+// ReviewCreator submits pull request reviews with inline comments.
+type ReviewCreator interface {
+	// CreateReview submits review on the pull request. Comment lines are
+	// file lines (see ReviewComment); each adapter translates them to its
+	// platform's anchoring.
+	CreateReview(owner, repo string, prNumber int, review PRReview) error
+}
+
+// MergeState is what auto-merge conditions are checked against.
+type MergeState struct {
+	HeadSHA          string
+	Open             bool
+	Draft            bool
+	Conflicts        bool // the SCM reports merge conflicts
+	Approvals        int
+	ChangesRequested bool
+	Labels           []string
+	Checks           map[string]string // check or status name → "success", "pending" or "failure"
+}
+
+// PRMerger merges pull requests.
+type PRMerger interface {
+	// GetMergeState returns the PR's reviews, checks and labels.
+	GetMergeState(owner, repo string, prNumber int) (*MergeState, error)
+
+	// MergePR merges the pull request if its head is still headSHA. method
+	// is "merge", "squash" or "rebase".
+	MergePR(owner, repo string, prNumber int, headSHA, method string) error
+}
+
+// CheckPublisher reports check results on commits.
+type CheckPublisher interface {
+	// PublishCheckRun reports a completed check (with annotations) on a commit.
+	PublishCheckRun(owner, repo string, run CheckRun) error
+}
+
+// StatusPublisher sets commit statuses.
+type StatusPublisher interface {
+	// SetCommitStatus sets the status of context on commit sha. state is
+	// StatusPending, StatusSuccess, StatusFailure or StatusError; targetURL
+	// may be empty.
+	SetCommitStatus(owner, repo, sha, state, context, description, targetURL string) error
+}
+
+// PRLabeler reads and changes the labels of a pull request, by name.
+// Platforms without labels may emulate them (Bitbucket: "[label]" title
+// prefixes, see titleLabels).
+type PRLabeler interface {
+	// GetPRLabels returns the names of the PR's labels.
+	GetPRLabels(owner, repo string, prNumber int) ([]string, error)
+
+	// AddLabels adds labels to the PR; labels it already has are kept.
+	AddLabels(owner, repo string, prNumber int, labels []string) error
+
+	// RemoveLabel removes a label from the PR; a missing label is not an
+	// error.
+	RemoveLabel(owner, repo string, prNumber int, label string) error
+}
+
+// ThreadResolver reads and resolves PR review threads.
+type ThreadResolver interface {
+	// ListReviewThreads returns the pull request's review threads.
+	ListReviewThreads(owner, repo string, prNumber int) ([]NormalizedThread, error)
+
+	// ResolveReviewThread marks a review thread as resolved.
+	ResolveReviewThread(owner, repo string, prNumber int, threadID string) error
+}
+
+// RepoReader reads repository-level data.
+type RepoReader interface {
+	// GetFileSize returns the size in bytes of path at ref.
+	GetFileSize(owner, repo, ref, path string) (int64, error)
+
+	// GetRepoStats returns language, contributor and activity statistics.
+	GetRepoStats(owner, repo string) (*RepoStats, error)
+}
+
+// errFileNotFound is returned by FileReader.GetFileContent for a path that
+// does not exist at the ref.
+var errFileNotFound = errors.New("file not found")
+
+// FileReader reads single files of a repository.
+type FileReader interface {
+	// GetFileContent returns the content of path at ref (a branch, tag or
+	// commit SHA), or errFileNotFound.
+	GetFileContent(owner, repo, ref, path string) ([]byte, error)
+}
+
+// FileSizeReader lists file sizes without fetching content.
+type FileSizeReader interface {
+	// GetFileSizes returns the size in bytes of every file at ref, by path.
+	GetFileSizes(owner, repo, ref string) (map[string]int64, error)
+}
+
+// ContentWriter commits files and opens pull requests.
+type ContentWriter interface {
+	FileReader
+
+	// CreateBranch creates branch pointing at the head of from.
+	CreateBranch(owner, repo, branch, from string) error
+
+	// CreateCommit commits a single file and returns the commit SHA ("" if
+	// the platform does not report it).
+	CreateCommit(owner, repo string, commit FileCommit) (string, error)
+
+	// CreatePR opens a pull request and returns its number.
+	CreatePR(owner, repo string, pr NewPR) (int, error)
+}
+
+// FileCommit writes Content as Path on Branch. Without an Author the commit
+// is attributed to the gateway's identity.
+type FileCommit struct {
+	Branch  string        `json:"branch"`
+	Path    string        `json:"path"`
+	Message string        `json:"message"`
+	Content []byte        `json:"-"`
+	Author  *CommitAuthor `json:"author,omitempty"`
+}
+
+// CommitAuthor is the author a commit is attributed to.
+type CommitAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// NewPR is a pull request to open from Head into Base.
+type NewPR struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// RepoProvisioner creates repositories and adds webhooks to them.
+type RepoProvisioner interface {
+	// CreateRepoFromTemplate creates repo.Owner/repo.Name from the template
+	// repository templateOwner/templateRepo.
+	CreateRepoFromTemplate(templateOwner, templateRepo string, repo NewRepo) (*NormalizedRepository, error)
+
+	// CreateWebhook adds a webhook and returns its ID.
+	CreateWebhook(owner, repo string, hook NewWebhook) (int64, error)
+}
+
+// NewRepo is a repository to create.
+type NewRepo struct {
+	Owner       string `json:"owner"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Private     bool   `json:"private,omitempty"`
+}
+
+// RepoLabel is an issue and pull request label. Color is six hex digits
+// without "#".
+type RepoLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description,omitempty"`
+}
+
+// RepoMilestone is a milestone. State is "open" or "closed"; DueOn is a
+// YYYY-MM-DD date or empty. Number is set on milestones read from the SCM.
+type RepoMilestone struct {
+	Number      int    `json:"number,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	State       string `json:"state,omitempty"`
+	DueOn       string `json:"due_on,omitempty"`
+}
+
+// LabelManager reads and writes a repository's labels and milestones.
+type LabelManager interface {
+	// ListLabels returns the repository's labels.
+	ListLabels(owner, repo string) ([]RepoLabel, error)
+
+	// SetLabel creates the label, or updates the one with the same name.
+	SetLabel(owner, repo string, label RepoLabel) error
+
+	// ListMilestones returns the repository's open and closed milestones.
+	ListMilestones(owner, repo string) ([]RepoMilestone, error)
+
+	// SetMilestone creates the milestone, or updates milestone.Number when
+	// it is set.
+	SetMilestone(owner, repo string, milestone RepoMilestone) error
+}
+
+// NewWebhook is a JSON webhook delivering Events to URL, signed with Secret.
+type NewWebhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+}
+
+// SBOMReader exports a repository's software bill of materials.
+type SBOMReader interface {
+	// GetSBOM returns the repository's SBOM as an SPDX JSON document.
+	GetSBOM(owner, repo string) (json.RawMessage, error)
+}
+
+// BranchProtector reads and sets the status checks a branch requires.
+type BranchProtector interface {
+	// GetRequiredContexts returns the status check contexts branch requires;
+	// protected is false if the branch requires none.
+	GetRequiredContexts(owner, repo, branch string) (contexts []string, protected bool, err error)
+
+	// SetRequiredContexts replaces the status check contexts branch requires.
+	SetRequiredContexts(owner, repo, branch string, contexts []string) error
+}
+
+// CIArtifactReader lists a commit's CI runs and streams their artifacts and
+// logs. The caller closes the returned body; size is -1 if unknown.
+type CIArtifactReader interface {
+	// ListWorkflowRuns returns the CI runs of headSHA with their jobs and
+	// artifacts.
+	ListWorkflowRuns(owner, repo, headSHA string) ([]WorkflowRun, error)
+
+	// OpenArtifact streams an artifact as a zip archive.
+	OpenArtifact(owner, repo string, artifactID int64) (body io.ReadCloser, size int64, err error)
+
+	// OpenLogs streams the logs of a run as a zip archive or, with jobID > 0,
+	// the log of one of its jobs as plain text.
+	OpenLogs(owner, repo string, runID, jobID int64) (body io.ReadCloser, size int64, err error)
+}
+
+// CITrigger starts CI runs.
+type CITrigger interface {
+	// TriggerCI starts the run described by req and returns its ID, or ""
+	// if the SCM does not report one (GitHub dispatches).
+	TriggerCI(owner, repo string, req DispatchRequest) (runID string, err error)
+}
+
+// Deployer records deployments to environments.
+type Deployer interface {
+	// CreateDeployment creates a deployment and returns its ID.
+	CreateDeployment(owner, repo string, d Deployment) (int64, error)
+
+	// SetDeploymentStatus reports the state of a deployment.
+	SetDeploymentStatus(owner, repo string, id int64, s DeploymentStatus) error
+}
+
+// ReleaseWriter reads and writes release notes.
+type ReleaseWriter interface {
+	// PreviousRelease returns the tag and publication time of the latest
+	// release published before the release of tag (before now if tag has
+	// none), or "" and the zero time if there is none.
+	PreviousRelease(owner, repo, tag string) (string, time.Time, error)
+
+	// SetReleaseNotes writes notes to the release of tag, creating a draft
+	// release if there is none. A release that already has notes is left
+	// alone and false returned.
+	SetReleaseNotes(owner, repo, tag, notes string) (bool, error)
+}
+
+// errUnsupported is returned when adapter lacks the named capability.
+func errUnsupported(adapter SCMAdapter, capability string) error {
+	return fmt.Errorf("%s adapter does not support %s", adapter.Platform(), capability)
 }
 
 // logNormalizedEvent prints a structured summary of a NormalizedEvent.
 func logNormalizedEvent(event *NormalizedEvent) {
 	log.Println("=== Normalized SCM Event ===")
+	log.Printf("  Event ID:   %s (delivery %s, schema v%d)\n", event.EventID, event.DeliveryID, event.SchemaVersion)
 	log.Printf("  Platform:   %s\n", event.Platform)
 	log.Printf("  Event Type: %s\n", event.EventType)
 	log.Printf("  Action:     %s\n", event.Action)
@@ -91,6 +544,18 @@ func logNormalizedEvent(event *NormalizedEvent) {
 	log.Printf("  State:      %s\n", event.PR.State)
 	log.Printf("  URL:        %s\n", event.PR.URL)
 	log.Printf("  Repo:       %s (owner: %s)\n", event.Repository.FullName, event.Repository.Owner)
+	for _, t := range event.Tickets {
+		log.Printf("  Ticket:     %s (from %s, validated=%t) %s\n", t.Key, t.Source, t.Validated, t.Summary)
+	}
+	if t := event.Thread; t != nil {
+		log.Printf("  Thread:     %s %s:%d resolved=%t (%d unresolved left)\n", t.ID, t.Path, t.Line, t.Resolved, t.Unresolved)
+	}
+	if event.EnrichmentSkipped != "" {
+		log.Printf("  Enrichment: skipped (%s)\n", event.EnrichmentSkipped)
+	}
+	for _, f := range event.PolicyFindings {
+		log.Printf("  Policy:     [%s/%s] %s — %s\n", f.Policy, f.Severity, f.Path, f.Message)
+	}
 	log.Printf("  Files (%d changed):\n", len(event.Files))
 	for _, f := range event.Files {
 		if f.Status == "renamed" {
@@ -0,0 +1,308 @@
+package main
+
+// Security alerts — GitHub's dependabot_alert, code_scanning_alert and
+// security_advisory webhooks are normalized into security.alert events whose
+// Security field carries the severity and the affected packages:
+//
+//	dependabot_alert      vulnerable dependency (package, manifest, fixed version)
+//	code_scanning_alert   code scanning finding (rule, tool, path and line)
+//	security_advisory     advisory published in the GitHub Advisory Database
+//
+// They are not about a pull request, so they skip enrichment and the
+// PR-oriented stages and are published to their own queue,
+// security_alert_events, instead of the Unified Event Bus. Its consumer
+// delivers them to the "security" sink (SECURITY_ALERTS_URL; logged only when
+// unset), so the security team receives them without the PR traffic.
+//
+// The events must be enabled in the GitHub App settings (Dependabot alerts,
+// Code scanning alerts and Security advisories permissions/events).
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	githubDependabotAlertEvent   = "dependabot_alert"
+	githubCodeScanningAlertEvent = "code_scanning_alert"
+	githubSecurityAdvisoryEvent  = "security_advisory"
+
+	EventTypeSecurityAlert = "security.alert"
+
+	// securityAlertsQueue is the queue security.alert events are published to.
+	securityAlertsQueue = "security_alert_events"
+
+	// securitySinkName is the name of the sink security alerts go to.
+	securitySinkName = "security"
+)
+
+// isGitHubSecurityEvent reports whether eventType is one of the GitHub
+// security webhooks.
+func isGitHubSecurityEvent(eventType string) bool {
+	switch eventType {
+	case githubDependabotAlertEvent, githubCodeScanningAlertEvent, githubSecurityAdvisoryEvent:
+		return true
+	}
+	return false
+}
+
+// normalizeSeverity maps the severities of the GitHub security APIs to
+// "critical", "high", "medium", "low" or "unknown". Code scanning rules
+// without a security severity report "error", "warning" or "note".
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "critical"
+	case "high", "error":
+		return "high"
+	case "medium", "moderate", "warning":
+		return "medium"
+	case "low", "note":
+		return "low"
+	}
+	return "unknown"
+}
+
+// ghAdvisoryPackage is a vulnerable package of a GitHub advisory.
+type ghAdvisoryPackage struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Severity               string `json:"severity"`
+	VulnerableVersionRange string `json:"vulnerable_version_range"`
+	FirstPatchedVersion    *struct {
+		Identifier string `json:"identifier"`
+	} `json:"first_patched_version"`
+}
+
+func (v ghAdvisoryPackage) normalize(manifest string) AffectedPackage {
+	p := AffectedPackage{
+		Ecosystem:          v.Package.Ecosystem,
+		Name:               v.Package.Name,
+		VulnerableVersions: v.VulnerableVersionRange,
+		Manifest:           manifest,
+	}
+	if v.FirstPatchedVersion != nil {
+		p.PatchedVersion = v.FirstPatchedVersion.Identifier
+	}
+	return p
+}
+
+// ghAdvisory is the subset of a GitHub security advisory we care about.
+type ghAdvisory struct {
+	GHSAID          string              `json:"ghsa_id"`
+	CVEID           string              `json:"cve_id"`
+	Summary         string              `json:"summary"`
+	Severity        string              `json:"severity"`
+	HTMLURL         string              `json:"html_url"`
+	WithdrawnAt     *time.Time          `json:"withdrawn_at"`
+	Vulnerabilities []ghAdvisoryPackage `json:"vulnerabilities"`
+}
+
+// githubSecurityAlert extracts the alert of a GitHub security webhook.
+func githubSecurityAlert(eventType string, payload []byte) (*NormalizedSecurityAlert, error) {
+	switch eventType {
+	case githubDependabotAlertEvent:
+		var p struct {
+			Alert struct {
+				Number     int    `json:"number"`
+				State      string `json:"state"`
+				HTMLURL    string `json:"html_url"`
+				Dependency struct {
+					ManifestPath string `json:"manifest_path"`
+				} `json:"dependency"`
+				SecurityAdvisory      ghAdvisory        `json:"security_advisory"`
+				SecurityVulnerability ghAdvisoryPackage `json:"security_vulnerability"`
+			} `json:"alert"`
+		}
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		a := p.Alert
+		severity := a.SecurityVulnerability.Severity
+		if severity == "" {
+			severity = a.SecurityAdvisory.Severity
+		}
+		return &NormalizedSecurityAlert{
+			Source:   eventType,
+			ID:       strconv.Itoa(a.Number),
+			State:    a.State,
+			Severity: normalizeSeverity(severity),
+			Summary:  a.SecurityAdvisory.Summary,
+			URL:      a.HTMLURL,
+			GHSA:     a.SecurityAdvisory.GHSAID,
+			CVE:      a.SecurityAdvisory.CVEID,
+			Packages: []AffectedPackage{a.SecurityVulnerability.normalize(a.Dependency.ManifestPath)},
+		}, nil
+
+	case githubCodeScanningAlertEvent:
+		var p struct {
+			Alert struct {
+				Number  int    `json:"number"`
+				State   string `json:"state"`
+				HTMLURL string `json:"html_url"`
+				Rule    struct {
+					ID                    string `json:"id"`
+					Severity              string `json:"severity"`
+					SecuritySeverityLevel string `json:"security_severity_level"`
+					Description           string `json:"description"`
+				} `json:"rule"`
+				Tool struct {
+					Name string `json:"name"`
+				} `json:"tool"`
+				MostRecentInstance struct {
+					Location struct {
+						Path      string `json:"path"`
+						StartLine int    `json:"start_line"`
+					} `json:"location"`
+				} `json:"most_recent_instance"`
+			} `json:"alert"`
+		}
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		a := p.Alert
+		severity := a.Rule.SecuritySeverityLevel
+		if severity == "" {
+			severity = a.Rule.Severity
+		}
+		return &NormalizedSecurityAlert{
+			Source:   eventType,
+			ID:       strconv.Itoa(a.Number),
+			State:    a.State,
+			Severity: normalizeSeverity(severity),
+			Summary:  a.Rule.Description,
+			URL:      a.HTMLURL,
+			Packages: []AffectedPackage{},
+			Rule:     a.Rule.ID,
+			Tool:     a.Tool.Name,
+			Path:     a.MostRecentInstance.Location.Path,
+			Line:     a.MostRecentInstance.Location.StartLine,
+		}, nil
+
+	case githubSecurityAdvisoryEvent:
+		var p struct {
+			Action           string     `json:"action"`
+			SecurityAdvisory ghAdvisory `json:"security_advisory"`
+		}
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		a := p.SecurityAdvisory
+		state := "published"
+		if a.WithdrawnAt != nil {
+			state = "withdrawn"
+		}
+		url := a.HTMLURL
+		if url == "" && a.GHSAID != "" {
+			url = "https://github.com/advisories/" + a.GHSAID
+		}
+		alert := &NormalizedSecurityAlert{
+			Source:   eventType,
+			ID:       a.GHSAID,
+			State:    state,
+			Severity: normalizeSeverity(a.Severity),
+			Summary:  a.Summary,
+			URL:      url,
+			GHSA:     a.GHSAID,
+			CVE:      a.CVEID,
+			Packages: []AffectedPackage{},
+		}
+		for _, v := range a.Vulnerabilities {
+			alert.Packages = append(alert.Packages, v.normalize(""))
+		}
+		return alert, nil
+	}
+	return nil, fmt.Errorf("not a security event: %q", eventType)
+}
+
+// normalizeGitHubSecurityEvent builds the security.alert event of a GitHub
+// security webhook.
+func normalizeGitHubSecurityEvent(eventType string, payload []byte) (*NormalizedEvent, error) {
+	alert, err := githubSecurityAlert(eventType, payload)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse %s payload: %w", eventType, err)
+	}
+	var p struct {
+		Action     string        `json:"action"`
+		Repository *ghRepository `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("GitHub adapter: failed to parse webhook payload: %w", err)
+	}
+
+	event := &NormalizedEvent{
+		Platform:   PlatformGitHub,
+		EventType:  EventTypeSecurityAlert,
+		Action:     p.Action,
+		Security:   alert,
+		RawPayload: payload,
+		ReceivedAt: time.Now(),
+	}
+	// Advisories are global and belong to no repository.
+	if repo := p.Repository; repo != nil {
+		event.Repository = repo.normalize()
+	}
+	return event, nil
+}
+
+// publishSecurityAlert stores a security.alert event and publishes it to the
+// security alerts queue, under the repo's privacy mode like any other event.
+func publishSecurityAlert(mq *RabbitMQ, rawType string, event *NormalizedEvent) {
+	event = anonymizeEvent(event)
+	alert := event.Security
+	log.Printf("[Security] %s %s alert %s (%s) in %q: %s\n",
+		alert.Severity, alert.Source, alert.ID, event.Action, event.Repository.FullName, alert.Summary)
+	store().Append(rawType, event)
+
+	if err := mq.PublishSecurityAlert(event); err != nil {
+		log.Printf("[Security] Warning: could not publish security alert: %v\n", err)
+		traceHop(event.EventID, "publish_failed", err.Error())
+		return
+	}
+	traceHop(event.EventID, "published", securityAlertsQueue)
+}
+
+// securitySink returns the sink security alerts are delivered to.
+func securitySink() Sink {
+	return &HTTPSink{name: securitySinkName, url: os.Getenv("SECURITY_ALERTS_URL")}
+}
+
+// StartSecurityAlertConsumer consumes the security alerts queue and delivers
+// each alert to the security sink, dead-lettering failed deliveries. It is
+// supervised like the other consumers and never returns; call it in a
+// goroutine from main.
+func StartSecurityAlertConsumer(mq *RabbitMQ) {
+	sink := securitySink()
+	if os.Getenv("SECURITY_ALERTS_URL") == "" {
+		log.Println("[Security] SECURITY_ALERTS_URL not set — security alerts will be logged only")
+	}
+	deliver := func(event *NormalizedEvent) {
+		traceHop(event.EventID, "consumed", securityAlertsQueue)
+		if isStandby() {
+			metrics.standbyHeld.Add(1)
+			traceHop(event.EventID, "held", "standby")
+			return
+		}
+		metrics.deliveries.Add(1)
+		start := time.Now()
+		err := sink.Deliver(event)
+		traceDelivery(event.EventID, sink.Name(), time.Since(start), err)
+		if err != nil {
+			metrics.deliveryFailures.Add(1)
+			log.Printf("[Security] Warning: could not deliver security alert %s: %v\n", event.EventID, err)
+			if body, mErr := json.Marshal(event); mErr == nil {
+				mq.deadLetter("sink:"+sink.Name(), err, body)
+			}
+		}
+	}
+	superviseConsumer("SecurityAlerts", func() error {
+		return mq.ConsumeNormalizedEvents(securityAlertsQueue, deliver)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+// Service lifecycle — running the gateway as a managed service, under systemd
+// or as a Windows service, as well as in a container or a terminal.
+//
+// The HTTP server is started once every background component is, and the
+// service manager is told the gateway is ready only after it listens:
+//
+//	systemd  Type=notify units get READY=1 on NOTIFY_SOCKET, STOPPING=1 on
+//	         shutdown and, with WatchdogSec=, a WATCHDOG=1 keep-alive (see
+//	         service_systemd.go)
+//	Windows  the gateway reports to the service control manager when it was
+//	         started by it, and stops on its Stop and Shutdown controls (see
+//	         service_windows.go)
+//
+// SIGINT and SIGTERM (Ctrl+C, Ctrl+Break, closing the console or logging
+// off on Windows) shut down gracefully: the server stops accepting requests
+// and finishes in-flight ones, buffered webhooks are published, usage
+// counters are saved and the broker connection is closed, all within
+// SHUTDOWN_TIMEOUT_SECONDS (default 30). SIGHUP, sent when a terminal
+// session ends, is logged and ignored.
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// listenAddr is the address the HTTP server listens on.
+const listenAddr = ":3000"
+
+// serveHTTP serves the registered handlers until the process is asked to
+// stop, then shuts the gateway down gracefully.
+func serveHTTP() {
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		serviceStopping()
+		log.Fatal(err)
+	}
+	srv := &http.Server{}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+	serviceReady()
+	log.Printf("[Service] Listening on %s\n", listenAddr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		log.Printf("[Service] Received %s, shutting down\n", sig)
+	case reason := <-serviceStopRequests():
+		log.Printf("[Service] %s, shutting down\n", reason)
+	case err := <-serveErr:
+		serviceStopping()
+		log.Fatal(err)
+	}
+	serviceStopping()
+	shutdownGateway(srv)
+	serviceStopped()
+}
+
+// shutdownGateway stops srv and flushes what would otherwise be lost.
+func shutdownGateway(srv *http.Server) {
+	timeout := time.Duration(envInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("[Service] Warning: requests still running at shutdown: %v\n", err)
+	}
+	// Webhooks already acknowledged to the SCM are in the ingest buffer.
+	for ingestBacklog() > 0 && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if n := ingestBacklog(); n > 0 {
+		log.Printf("[Service] Warning: %d buffered webhook(s) not published before shutdown\n", n)
+	}
+	if err := flushUsage(); err != nil {
+		log.Printf("[Service] Warning: could not save usage: %v\n", err)
+	}
+	if mq != nil {
+		mq.Close()
+	}
+	log.Println("[Service] Stopped")
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// initService ignores SIGHUP, so the gateway outlives the terminal session
+// it was started from.
+func initService() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			log.Println("[Service] Received hangup, ignoring")
+		}
+	}()
+}
+
+// serviceStopRequests returns nil: outside Windows, stop requests are signals.
+func serviceStopRequests() <-chan string { return nil }
+
+// serviceReady tells systemd the gateway is ready and starts the watchdog
+// keep-alive if the unit has WatchdogSec= set.
+func serviceReady() {
+	sdNotify("READY=1")
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	go func() {
+		for range time.Tick(time.Duration(usec) * time.Microsecond / 2) {
+			sdNotify("WATCHDOG=1")
+		}
+	}()
+}
+
+// serviceStopping tells systemd the gateway is shutting down.
+func serviceStopping() { sdNotify("STOPPING=1") }
+
+// serviceStopped has nothing to report outside Windows.
+func serviceStopped() {}
+
+// sdNotify sends state to systemd's NOTIFY_SOCKET, if set.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("[Service] Warning: could not notify systemd: %v\n", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("[Service] Warning: could not notify systemd: %v\n", err)
+	}
+}
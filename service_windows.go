@@ -0,0 +1,211 @@
+package main
+
+// Windows service support, talking to the service control manager through
+// advapi32 directly. Install the binary with
+//
+//	sc.exe create scm-gateway binPath= "C:\scm-gateway\server.exe" start= auto
+//
+// Started by the service control manager, the gateway runs from the
+// executable's directory, so the .env next to it is loaded, and logs to
+// gateway.log there: a service has no console.
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStateStopped      = 1
+	serviceStateStartPending = 2
+	serviceStateStopPending  = 3
+	serviceStateRunning      = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorCallNotImplemented             = 120
+	errorFailedServiceControllerConnect = 1063
+)
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+
+	serviceMainCallback    = syscall.NewCallback(serviceMain)
+	serviceHandlerCallback = syscall.NewCallback(serviceHandler)
+)
+
+// serviceStatus is SERVICE_STATUS.
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// serviceTableEntry is SERVICE_TABLE_ENTRYW.
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// winService is the state shared with the service control manager's threads.
+var winService struct {
+	mu       sync.Mutex
+	active   bool // started by the service control manager
+	handle   uintptr
+	status   serviceStatus
+	started  chan bool
+	stop     chan string
+	stopped  chan struct{}
+	returned chan struct{} // closed when StartServiceCtrlDispatcherW returns
+}
+
+// initService connects to the service control manager if it started the
+// process, and otherwise returns at once.
+func initService() {
+	winService.started = make(chan bool, 2)
+	winService.stop = make(chan string, 1)
+	winService.stopped = make(chan struct{})
+	winService.returned = make(chan struct{})
+	go func() {
+		defer close(winService.returned)
+		name, _ := syscall.UTF16PtrFromString("scm-gateway") // ignored for own-process services
+		table := []serviceTableEntry{{name: name, proc: serviceMainCallback}, {}}
+		r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+		if r == 0 {
+			if errno, ok := err.(syscall.Errno); !ok || errno != errorFailedServiceControllerConnect {
+				log.Printf("[Service] Warning: could not connect to the service control manager: %v\n", err)
+			}
+			winService.started <- false
+		}
+	}()
+	if !<-winService.started {
+		return // run interactively
+	}
+	winService.active = true
+
+	if exe, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exe)
+		if err := os.Chdir(dir); err != nil {
+			log.Printf("[Service] Warning: could not change to %s: %v\n", dir, err)
+		}
+		if f, err := os.OpenFile(filepath.Join(dir, "gateway.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600); err == nil {
+			log.SetOutput(f)
+		}
+	}
+	log.Println("[Service] Running as a Windows service")
+}
+
+// serviceMain is the ServiceMain the dispatcher runs on its own thread. It
+// returns, ending the service, once the gateway has stopped.
+func serviceMain(argc, argv uintptr) uintptr {
+	name, _ := syscall.UTF16PtrFromString("scm-gateway")
+	handle, _, err := procRegisterServiceCtrlHandlerEx.Call(uintptr(unsafe.Pointer(name)), serviceHandlerCallback, 0)
+	if handle == 0 {
+		log.Printf("[Service] Warning: could not register the service control handler: %v\n", err)
+		winService.started <- false
+		return 0
+	}
+	winService.mu.Lock()
+	winService.handle = handle
+	winService.mu.Unlock()
+	setServiceState(serviceStateStartPending, 0, 30*time.Second)
+	winService.started <- true
+
+	<-winService.stopped
+	setServiceState(serviceStateStopped, 0, 0)
+	return 0
+}
+
+// serviceHandler is the HandlerEx for service controls.
+func serviceHandler(control, eventType, eventData, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		serviceStopping()
+		reason := "Stop requested by the service control manager"
+		if control == serviceControlShutdown {
+			reason = "System shutting down"
+		}
+		select {
+		case winService.stop <- reason:
+		default:
+		}
+		return 0
+	case serviceControlInterrogate:
+		winService.mu.Lock()
+		status := winService.status
+		winService.mu.Unlock()
+		procSetServiceStatus.Call(winService.handle, uintptr(unsafe.Pointer(&status)))
+		return 0
+	}
+	return errorCallNotImplemented
+}
+
+// setServiceState reports state to the service control manager, if it
+// started the process.
+func setServiceState(state, accepts uint32, waitHint time.Duration) {
+	winService.mu.Lock()
+	defer winService.mu.Unlock()
+	if winService.handle == 0 {
+		return
+	}
+	s := &winService.status
+	if state == s.CurrentState {
+		s.CheckPoint++
+	} else {
+		s.CheckPoint = 0
+	}
+	s.ServiceType = serviceWin32OwnProcess
+	s.CurrentState = state
+	s.ControlsAccepted = accepts
+	s.WaitHint = uint32(waitHint / time.Millisecond)
+	if r, _, err := procSetServiceStatus.Call(winService.handle, uintptr(unsafe.Pointer(s))); r == 0 {
+		log.Printf("[Service] Warning: could not report service state %d: %v\n", state, err)
+	}
+}
+
+// serviceStopRequests delivers the service control manager's Stop and
+// Shutdown controls.
+func serviceStopRequests() <-chan string { return winService.stop }
+
+// serviceReady reports the service running.
+func serviceReady() {
+	setServiceState(serviceStateRunning, serviceAcceptStop|serviceAcceptShutdown, 0)
+}
+
+// serviceStopping reports the service stopping, for up to the shutdown
+// timeout.
+func serviceStopping() {
+	timeout := time.Duration(envInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+	setServiceState(serviceStateStopPending, 0, timeout+5*time.Second)
+}
+
+// serviceStopped reports the service stopped and waits for the dispatcher
+// to let go.
+func serviceStopped() {
+	if !winService.active {
+		return
+	}
+	close(winService.stopped)
+	select {
+	case <-winService.returned:
+	case <-time.After(5 * time.Second):
+	}
+}
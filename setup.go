@@ -0,0 +1,305 @@
+package main
+
+// GitHub App bootstrap via the App Manifest flow.
+//
+// With GITHUB_APP_SETUP=true and no GITHUB_APP_ID configured, the gateway
+// serves:
+//
+//	GET /setup            page that POSTs the app manifest to GitHub
+//	                      (?org=acme creates the app under an organization)
+//	GET /setup/callback   GitHub redirects here with a one-time code, which is
+//	                      exchanged for the new app's credentials
+//
+// The generated GITHUB_APP_ID, GITHUB_PRIVATE_KEY and WEBHOOK_SECRET_GITHUB are
+// appended to GITHUB_APP_SETUP_ENV_FILE (default .env) and applied to the
+// running process, so the gateway works without a restart. Once an app ID is
+// set the setup routes refuse to run again.
+//
+// Until then whoever completes the flow decides the gateway's credentials, so
+// /setup requires ?token=: ADMIN_TOKEN, or the setup token generated at
+// startup and printed to the log.
+//
+// The webhook and redirect URLs are built from GATEWAY_PUBLIC_URL, falling
+// back to the host the setup page was requested on.
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// appManifest is the GitHub App Manifest the setup page submits.
+// See https://docs.github.com/apps/sharing-github-apps/registering-a-github-app-from-a-manifest
+type appManifest struct {
+	Name               string            `json:"name"`
+	URL                string            `json:"url"`
+	HookAttributes     map[string]string `json:"hook_attributes"`
+	RedirectURL        string            `json:"redirect_url"`
+	Public             bool              `json:"public"`
+	DefaultPermissions map[string]string `json:"default_permissions"`
+	DefaultEvents      []string          `json:"default_events"`
+}
+
+// appConversion is GitHub's response to the manifest code exchange.
+type appConversion struct {
+	ID            int64  `json:"id"`
+	Slug          string `json:"slug"`
+	HTMLURL       string `json:"html_url"`
+	PEM           string `json:"pem"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// setupStates holds the CSRF state values of setup flows in progress.
+var setupStates = struct {
+	sync.Mutex
+	issued map[string]time.Time
+}{issued: map[string]time.Time{}}
+
+var setupPage = template.Must(template.New("setup").Parse(`<!DOCTYPE html>
+<html><body>
+<p>Creating the GitHub App <b>{{.Name}}</b>…</p>
+<form id="manifest" method="post" action="{{.Action}}">
+<input type="hidden" name="manifest" value="{{.Manifest}}">
+<noscript><button type="submit">Continue to GitHub</button></noscript>
+</form>
+<script>document.getElementById("manifest").submit()</script>
+</body></html>`))
+
+var (
+	setupTokenOnce sync.Once
+	setupTokenValue string
+)
+
+// setupToken returns the token /setup requires besides ADMIN_TOKEN,
+// generated on first use.
+func setupToken() string {
+	setupTokenOnce.Do(func() {
+		b := make([]byte, 16)
+		rand.Read(b)
+		setupTokenValue = hex.EncodeToString(b)
+	})
+	return setupTokenValue
+}
+
+// logSetupToken prints the setup URL with its token when the manifest flow
+// is available.
+func logSetupToken() {
+	if os.Getenv("GITHUB_APP_SETUP") != "true" || getAppIDFromEnv() != "" {
+		return
+	}
+	log.Printf("[Setup] GitHub App setup enabled: open /setup?token=%s\n", setupToken())
+}
+
+// setupAuthorized reports whether the request carries the setup token or
+// ADMIN_TOKEN, writing the error response if not.
+func setupAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	got := []byte(r.URL.Query().Get("token"))
+	if subtle.ConstantTimeCompare(got, []byte(setupToken())) == 1 {
+		return true
+	}
+	if admin := os.Getenv("ADMIN_TOKEN"); admin != "" && subtle.ConstantTimeCompare(got, []byte(admin)) == 1 {
+		return true
+	}
+	log.Println("[Setup] Warning: rejected /setup request without a valid token")
+	http.Error(w, "setup token required (see the gateway log)", http.StatusUnauthorized)
+	return false
+}
+
+// setupEnabled reports whether the manifest flow may run.
+func setupEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if os.Getenv("GITHUB_APP_SETUP") != "true" {
+		http.NotFound(w, r)
+		return false
+	}
+	if getAppIDFromEnv() != "" {
+		http.Error(w, "GitHub App already configured (GITHUB_APP_ID is set)", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// setupBaseURL is the public base URL the new app's hooks should point at.
+func setupBaseURL(r *http.Request) string {
+	if base := strings.TrimRight(os.Getenv("GATEWAY_PUBLIC_URL"), "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// newSetupState issues a one-time state value, valid for an hour.
+func newSetupState() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	state := hex.EncodeToString(b)
+
+	setupStates.Lock()
+	defer setupStates.Unlock()
+	for s, issued := range setupStates.issued {
+		if time.Since(issued) > time.Hour {
+			delete(setupStates.issued, s)
+		}
+	}
+	setupStates.issued[state] = time.Now()
+	return state
+}
+
+// consumeSetupState reports whether state was issued and unexpired, and
+// invalidates it.
+func consumeSetupState(state string) bool {
+	setupStates.Lock()
+	defer setupStates.Unlock()
+	issued, ok := setupStates.issued[state]
+	delete(setupStates.issued, state)
+	return ok && time.Since(issued) <= time.Hour
+}
+
+// SetupHandler serves the page that starts the manifest flow.
+func SetupHandler(w http.ResponseWriter, r *http.Request) {
+	if !setupEnabled(w, r) || !setupAuthorized(w, r) {
+		return
+	}
+	base := setupBaseURL(r)
+	name := os.Getenv("GITHUB_APP_NAME")
+	if name == "" {
+		name = "scm-gateway"
+	}
+	manifest := appManifest{
+		Name:           name,
+		URL:            base,
+		HookAttributes: map[string]string{"url": base + webhookPath + "/github"},
+		RedirectURL:    base + "/setup/callback",
+		Public:         false,
+		DefaultPermissions: map[string]string{
+			"pull_requests": "write",
+			"contents":      "read",
+			"checks":        "write",
+			"statuses":      "write",
+			"metadata":      "read",
+		},
+		DefaultEvents: requiredGitHubEvents,
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	action := "https://github.com/settings/apps/new"
+	if org := r.URL.Query().Get("org"); org != "" {
+		action = "https://github.com/organizations/" + url.PathEscape(org) + "/settings/apps/new"
+	}
+	action += "?state=" + newSetupState()
+
+	log.Printf("[Setup] Starting GitHub App manifest flow for %q (hooks → %s)\n", name, base)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	setupPage.Execute(w, map[string]string{
+		"Name":     name,
+		"Action":   action,
+		"Manifest": string(manifestJSON),
+	})
+}
+
+// SetupCallbackHandler exchanges the manifest code for the app credentials
+// and stores them.
+func SetupCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if !setupEnabled(w, r) {
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" || !consumeSetupState(r.URL.Query().Get("state")) {
+		http.Error(w, "missing code or invalid state; restart at /setup", http.StatusBadRequest)
+		return
+	}
+
+	app, err := convertAppManifest(code)
+	if err != nil {
+		log.Printf("[Setup] Error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := storeAppCredentials(app); err != nil {
+		log.Printf("[Setup] Error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Setup] Created GitHub App %s (ID %d)\n", app.Slug, app.ID)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<p>GitHub App <b>%s</b> created (ID %d). Credentials were saved; `+
+		`<a href="%s/installations/new">install it</a> on your repositories.</p>`,
+		template.HTMLEscapeString(app.Slug), app.ID, template.HTMLEscapeString(app.HTMLURL))
+}
+
+// convertAppManifest redeems the one-time manifest code.
+func convertAppManifest(code string) (*appConversion, error) {
+	req, err := http.NewRequest("POST", "https://api.github.com/app-manifests/"+url.PathEscape(code)+"/conversions", nil)
+	if err != nil {
+		return nil, err
+	}
+	setGitHubHeaders(req, "")
+
+	resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("manifest conversion failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("manifest conversion returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var app appConversion
+	if err := json.Unmarshal(body, &app); err != nil {
+		return nil, fmt.Errorf("manifest conversion: invalid response: %w", err)
+	}
+	return &app, nil
+}
+
+// storeAppCredentials appends the new app's credentials to the env file and
+// applies them to the running process.
+func storeAppCredentials(app *appConversion) error {
+	creds := map[string]string{
+		"GITHUB_APP_ID":         fmt.Sprint(app.ID),
+		"GITHUB_PRIVATE_KEY":    app.PEM,
+		"WEBHOOK_SECRET_GITHUB": app.WebhookSecret,
+	}
+	lines, err := godotenv.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	path := os.Getenv("GITHUB_APP_SETUP_ENV_FILE")
+	if path == "" {
+		path = ".env"
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\n# GitHub App %s, created %s\n%s\n", app.Slug, time.Now().UTC().Format(time.RFC3339), lines); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+
+	for k, v := range creds {
+		os.Setenv(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+// Sinks — named delivery targets for normalized events.
+//
+// The Platform BE is the default sink ("platform_be", PLATFORM_BE_URL).
+// Additional HTTP sinks are configured with EXTRA_SINKS as a comma-separated
+// list of name=url pairs, e.g. "analytics=https://analytics.internal/events".
+// FEDERATION_UPSTREAM_URL adds the "federation" sink, which forwards to
+// another gateway (see federation.go). Every sink receives every event from
+// the event bus consumer; admin replay can target a single sink by name.
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultSinkName is the name of the Platform BE sink.
+const defaultSinkName = "platform_be"
+
+// Sink is a destination normalized events are delivered to.
+type Sink interface {
+	Name() string
+	Deliver(event *NormalizedEvent) error
+}
+
+// HTTPSink POSTs events as JSON to a URL (see DeliverEvent). An empty URL
+// logs the event instead, matching the dev-mode behaviour of the Platform BE.
+type HTTPSink struct {
+	name string
+	url  string
+}
+
+func (s *HTTPSink) Name() string {
+	return s.name
+}
+
+func (s *HTTPSink) Deliver(event *NormalizedEvent) error {
+	return DeliverEvent(event, s.url)
+}
+
+var (
+	sinksOnce sync.Once
+	sinks     []Sink
+)
+
+// configuredSinks returns the sinks enabled by the environment, in order.
+func configuredSinks() []Sink {
+	sinksOnce.Do(func() {
+		sinks = append(sinks, &HTTPSink{name: defaultSinkName, url: os.Getenv("PLATFORM_BE_URL")})
+
+		for _, pair := range strings.Split(os.Getenv("EXTRA_SINKS"), ",") {
+			name, url, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || name == "" || url == "" {
+				if strings.TrimSpace(pair) != "" {
+					log.Printf("[Sinks] Warning: ignoring malformed EXTRA_SINKS entry %q\n", pair)
+				}
+				continue
+			}
+			sinks = append(sinks, &HTTPSink{name: name, url: url})
+		}
+		if s := federationSink(); s != nil {
+			sinks = append(sinks, s)
+		}
+	})
+	return sinks
+}
+
+// sinkByName returns the configured sink with the given name.
+func sinkByName(name string) (Sink, bool) {
+	for _, s := range configuredSinks() {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package main
+
+// Stale PR scan — the "stale_pr_scan" job (default daily at 09:00) emits
+// pull_request.stale for open PRs that have had no event for STALE_PR_DAYS
+// (default 14), so downstream tooling can nudge their authors or reviewers.
+//
+// Activity is taken from the PR snapshots (see pr_snapshots.go): the last
+// event the gateway saw for the PR. A PR only known from polling counts as
+// active from the time it was first polled, so after a restart no PR is
+// stale before STALE_PR_DAYS have passed. Each PR is reported once per idle
+// stretch: it is reported again only after a new event and another
+// STALE_PR_DAYS without one. The PR's current details are fetched through the
+// adapter, which also confirms it is still open.
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventTypeStale is emitted for an open PR without recent activity.
+const EventTypeStale = "pull_request.stale"
+
+var (
+	staleReportedMu sync.Mutex
+	// staleReported holds, per prSnapshotKey, the activity time of the PR
+	// when it was last reported stale.
+	staleReported = map[string]time.Time{}
+)
+
+// scanStalePRs is the "stale_pr_scan" scheduler job.
+func scanStalePRs() error {
+	cutoff := time.Now().Add(-time.Duration(envInt("STALE_PR_DAYS", 14)) * 24 * time.Hour)
+
+	staleReportedMu.Lock()
+	defer staleReportedMu.Unlock()
+
+	open := map[string]bool{}
+	reported, failures := 0, 0
+	for _, snap := range prSnapshots().List("", "", "open") {
+		key := prSnapshotKey(snap.Platform, snap.Repository, snap.Number)
+		open[key] = true
+		if snap.LastActiveAt.IsZero() || snap.LastActiveAt.After(cutoff) {
+			continue
+		}
+		if last, ok := staleReported[key]; ok && last.Equal(snap.LastActiveAt) {
+			continue
+		}
+		if err := publishStale(snap); err != nil {
+			log.Printf("[StalePRs] Warning: could not report %s#%d: %v\n", snap.Repository, snap.Number, err)
+			failures++
+			continue
+		}
+		staleReported[key] = snap.LastActiveAt
+		reported++
+	}
+	for key := range staleReported {
+		if !open[key] {
+			delete(staleReported, key)
+		}
+	}
+
+	if reported > 0 {
+		log.Printf("[StalePRs] Reported %d stale PR(s)\n", reported)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d stale PR(s) could not be reported", failures)
+	}
+	return nil
+}
+
+// publishStale emits the pull_request.stale event of snap's PR.
+func publishStale(snap PRSnapshot) error {
+	owner, name, ok := strings.Cut(snap.Repository, "/")
+	if !ok {
+		return fmt.Errorf("invalid repository %q", snap.Repository)
+	}
+	adapter, err := NewSCMAdapter(snap.Platform)
+	if err != nil {
+		return err
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		return errUnsupported(adapter, "reading PRs")
+	}
+	pr, err := reader.GetPRDetails(owner, name, snap.Number)
+	if err != nil {
+		return err
+	}
+	if pr.State != "" && pr.State != "open" {
+		return nil
+	}
+
+	event := anonymizeEvent(&NormalizedEvent{
+		EventID:       newEventID(),
+		SchemaVersion: NormalizedSchemaVersion,
+		Platform:      snap.Platform,
+		EventType:     EventTypeStale,
+		Action:        "stale",
+		PR:            *pr,
+		Repository:    NormalizedRepository{FullName: snap.Repository, Owner: owner, Name: name},
+		ReceivedAt:    time.Now(),
+	})
+	// Not applied to the snapshots: a stale report is not activity.
+	store().Append(EventTypeStale, event)
+	if mq == nil {
+		return fmt.Errorf("RabbitMQ not initialised")
+	}
+	return mq.PublishNormalizedEvent(event)
+}
@@ -0,0 +1,92 @@
+package main
+
+// Staleness guard — during a queue backlog an event can describe a PR state
+// that no longer holds by the time it is delivered: a synchronize for a PR
+// that has since merged, a close for a PR that was reopened. Before delivery,
+// events older than STALE_EVENT_AGE_SECONDS (default 60) are compared with
+// the PR's current state, fetched through the adapter and cached for
+// STALE_STATE_CACHE_SECONDS (default 30) so a burst for one PR costs one call.
+//
+//	STALE_EVENTS=flag   deliver stale events with StaleReason set
+//	STALE_EVENTS=drop   do not deliver stale events (sinks and subscriptions)
+//
+// Fresh events are never checked, so the guard only costs API calls while
+// the pipeline is behind.
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	prStateCacheOnce sync.Once
+	prStateCache     *ttlCache
+)
+
+// staleEventsMode returns "flag", "drop" or "" (guard off).
+func staleEventsMode() string {
+	switch mode := os.Getenv("STALE_EVENTS"); mode {
+	case "flag", "drop":
+		return mode
+	case "":
+		return ""
+	default:
+		log.Printf("[Staleness] Warning: unknown STALE_EVENTS %q, guard disabled\n", mode)
+		return ""
+	}
+}
+
+// currentPRState returns the PR's state as the SCM reports it now.
+func currentPRState(event *NormalizedEvent) (string, error) {
+	prStateCacheOnce.Do(func() {
+		prStateCache = newTTLCache("pr_state", time.Duration(envInt("STALE_STATE_CACHE_SECONDS", 30))*time.Second)
+	})
+	key := fmt.Sprintf("%s:%s#%d", event.Platform, event.Repository.FullName, event.PR.Number)
+	if state, ok := prStateCache.Get(key); ok {
+		return state.(string), nil
+	}
+
+	adapter, err := NewSCMAdapter(event.Platform)
+	if err != nil {
+		return "", err
+	}
+	reader, ok := adapter.(PRReader)
+	if !ok {
+		return "", errUnsupported(adapter, "reading PR details")
+	}
+	pr, err := reader.GetPRDetails(event.Repository.Owner, event.Repository.Name, event.PR.Number)
+	if err != nil {
+		return "", err
+	}
+	prStateCache.Set(key, pr.State)
+	return pr.State, nil
+}
+
+// staleReason returns why event no longer matches the PR's current state, or
+// "" if it still does (or could not be checked).
+func staleReason(event *NormalizedEvent) string {
+	if event.PR.Number == 0 || event.ReceivedAt.IsZero() {
+		return ""
+	}
+	if time.Since(event.ReceivedAt) < time.Duration(envInt("STALE_EVENT_AGE_SECONDS", 60))*time.Second {
+		return ""
+	}
+
+	current, err := currentPRState(event)
+	if err != nil {
+		log.Printf("[Staleness] Warning: could not check PR #%d of %s: %v\n",
+			event.PR.Number, event.Repository.FullName, err)
+		return ""
+	}
+	open := current == "open"
+	switch {
+	case event.Action == "closed" && open:
+		return "PR has been reopened since"
+	case isFileEnrichableAction(event.Action) && !open:
+		return fmt.Sprintf("PR is %s now", current)
+	}
+	return ""
+}
@@ -0,0 +1,290 @@
+package main
+
+// Active/passive deployments — for disaster recovery a second gateway,
+// typically in another region, runs as a warm standby. A standby consumes
+// webhooks and normalizes them into the event store like the primary, but
+// delivers nothing: no sinks, no subscriptions, no write-backs to the SCM and
+// no webhook repair. POST /admin/promote switches it active.
+//
+//	GATEWAY_ROLE=standby   start as standby (default "active")
+//	GATEWAY_REGION         this deployment's identity in the role lease (default: hostname)
+//	ROLE_LEASE=true        fence with a Lease: only the region holding it delivers
+//	ROLE_LEASE_NAME        Lease object name (default "scm-gateway-active")
+//
+// The role lease uses the same namespace and LEADER_LEASE_SECONDS as leader
+// election, so both deployments must reach the same API server. The leader
+// replica of the active region renews it. Promotion takes the lease over, and
+// the old primary steps down to standby at its next check; a primary that
+// cannot read the lease for a full lease duration fences itself the same way.
+// Without ROLE_LEASE the role lives in memory and a restart returns to
+// GATEWAY_ROLE.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	standby      atomic.Bool
+	wantActive   atomic.Bool // configured or promoted role, as opposed to a fenced one
+	roleLeaseRef atomic.Pointer[roleLease]
+)
+
+// isStandby reports whether this deployment must hold back deliveries.
+func isStandby() bool {
+	return standby.Load()
+}
+
+// gatewayRole names the current role for status output.
+func gatewayRole() string {
+	if isStandby() {
+		return "standby"
+	}
+	return "active"
+}
+
+// setStandby switches the role, logging transitions.
+func setStandby(on bool, reason string) {
+	if standby.Swap(on) == on {
+		return
+	}
+	if on {
+		log.Printf("[Role] Standing by — deliveries held: %s\n", reason)
+	} else {
+		log.Printf("[Role] Active — delivering events: %s\n", reason)
+	}
+}
+
+// gatewayRegion returns this deployment's identity in the role lease.
+func gatewayRegion() string {
+	if region := os.Getenv("GATEWAY_REGION"); region != "" {
+		return region
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// InitGatewayRole applies GATEWAY_ROLE. It must run before the consumers
+// start so a standby never delivers its first events.
+func InitGatewayRole() {
+	on := os.Getenv("GATEWAY_ROLE") == "standby"
+	wantActive.Store(!on)
+	standby.Store(on)
+	log.Printf("[Role] Starting as %s (region %s)\n", gatewayRole(), gatewayRegion())
+}
+
+// roleLease is the Lease naming the active region.
+type roleLease struct {
+	client  *leaseClient
+	name    string
+	region  string
+	checked time.Time // last time the lease was read successfully
+}
+
+// get returns the lease, or nil if it does not exist yet.
+func (l *roleLease) get() (*k8sLease, error) {
+	body, status, err := l.client.do("GET", l.client.baseURL+"/"+l.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("GET lease returned %d: %s", status, string(body))
+	}
+	var lease k8sLease
+	if err := json.Unmarshal(body, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// claim makes this region the holder of lease, creating it when nil. It
+// reports false when another writer updated the lease first.
+func (l *roleLease) claim(lease *k8sLease) (bool, error) {
+	now := time.Now().UTC().Format(k8sMicroTime)
+	method, url := "PUT", l.client.baseURL+"/"+l.name
+	if lease == nil {
+		lease = &k8sLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name = l.name
+		method, url = "POST", l.client.baseURL
+	}
+	if lease.Spec.HolderIdentity != l.region {
+		lease.Spec.HolderIdentity = l.region
+		lease.Spec.AcquireTime = now
+		lease.Spec.LeaseTransitions++
+	}
+	lease.Spec.LeaseDurationSeconds = l.client.duration
+	lease.Spec.RenewTime = now
+
+	_, status, err := l.client.do(method, url, lease)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusOK || status == http.StatusCreated, nil
+}
+
+// check reads the lease and brings the role in line with it.
+func (l *roleLease) check() {
+	lease, err := l.get()
+	if err != nil {
+		log.Printf("[Role] Warning: role lease check failed: %v\n", err)
+		if !isStandby() && time.Since(l.checked) > time.Duration(l.client.duration)*time.Second {
+			setStandby(true, "role lease unreachable, fencing this region")
+		}
+		return
+	}
+	l.checked = time.Now()
+
+	switch {
+	case lease != nil && lease.Spec.HolderIdentity == l.region:
+		if isLeader() {
+			if _, err := l.claim(lease); err != nil {
+				log.Printf("[Role] Warning: role lease renewal failed: %v\n", err)
+			}
+		}
+		wantActive.Store(true)
+		setStandby(false, fmt.Sprintf("region %s holds lease %q", l.region, l.name))
+
+	case lease == nil || lease.Spec.HolderIdentity == "" || leaseExpired(lease):
+		if wantActive.Load() && isLeader() {
+			if ok, err := l.claim(lease); err != nil {
+				log.Printf("[Role] Warning: could not acquire role lease: %v\n", err)
+			} else if ok {
+				setStandby(false, fmt.Sprintf("acquired lease %q", l.name))
+			}
+		}
+
+	default:
+		// Another region was promoted: stay fenced even if its lease lapses.
+		wantActive.Store(false)
+		setStandby(true, fmt.Sprintf("lease %q held by %s", l.name, lease.Spec.HolderIdentity))
+	}
+}
+
+// leaseExpired reports whether the holder of lease stopped renewing it.
+func leaseExpired(lease *k8sLease) bool {
+	renewed, err := time.Parse(k8sMicroTime, lease.Spec.RenewTime)
+	return err != nil ||
+		time.Since(renewed) > time.Duration(lease.Spec.LeaseDurationSeconds)*time.Second
+}
+
+// StartRoleLease keeps the role in step with the role lease until the process
+// exits. It returns immediately unless ROLE_LEASE=true.
+func StartRoleLease() {
+	if os.Getenv("ROLE_LEASE") != "true" {
+		return
+	}
+	c, err := newLeaseClient()
+	if err != nil {
+		// Fail safe: never deliver rather than risk two active regions.
+		setStandby(true, fmt.Sprintf("role lease unavailable: %v", err))
+		return
+	}
+	name := os.Getenv("ROLE_LEASE_NAME")
+	if name == "" {
+		name = "scm-gateway-active"
+	}
+	l := &roleLease{client: c, name: name, region: gatewayRegion(), checked: time.Now()}
+	roleLeaseRef.Store(l)
+	log.Printf("[Role] Following role lease %q as region %s\n", name, l.region)
+
+	interval := time.Duration(c.duration) * time.Second / 3
+	for {
+		l.check()
+		time.Sleep(interval)
+	}
+}
+
+// AdminPromoteHandler switches a standby deployment active.
+//
+//	POST /admin/promote[?force=true][&replay=15m|RFC3339]
+//
+// With ROLE_LEASE=true the lease is taken over, which fences the old primary;
+// a lease another region is still renewing is only taken with force=true.
+// replay re-publishes the events stored since then to the Unified Event Bus,
+// covering what the old primary may not have delivered. Events held while on
+// standby were not enriched, so their automations and reviewers do not run.
+func AdminPromoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	force, _ := strconv.ParseBool(q.Get("force"))
+	var replaySince time.Time
+	if v := q.Get("replay"); v != "" {
+		since, ok := parseSince(v)
+		if !ok {
+			http.Error(w, "replay must be an RFC 3339 timestamp or a duration", http.StatusBadRequest)
+			return
+		}
+		if mq == nil {
+			http.Error(w, "replay needs RabbitMQ, which is not connected", http.StatusServiceUnavailable)
+			return
+		}
+		replaySince = since
+	}
+
+	previous := ""
+	if l := roleLeaseRef.Load(); l != nil {
+		lease, err := l.get()
+		if err != nil {
+			http.Error(w, "could not read role lease: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if lease != nil {
+			previous = lease.Spec.HolderIdentity
+			if previous != l.region && previous != "" && !leaseExpired(lease) && !force {
+				http.Error(w, fmt.Sprintf("lease %q is still held by %s; pass force=true to fence it", l.name, previous),
+					http.StatusConflict)
+				return
+			}
+		}
+		ok, err := l.claim(lease)
+		if err != nil {
+			http.Error(w, "could not take over role lease: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if !ok {
+			http.Error(w, "role lease changed concurrently, retry", http.StatusConflict)
+			return
+		}
+	}
+	wantActive.Store(true)
+	setStandby(false, "promoted via /admin/promote")
+
+	replayed, failed := 0, 0
+	if v := q.Get("replay"); v != "" {
+		for _, stored := range store().Since(replaySince) {
+			if err := mq.PublishNormalizedEvent(stored.Event); err != nil {
+				failed++
+				continue
+			}
+			traceHop(stored.EventID, "replayed", "promotion")
+			replayed++
+		}
+		log.Printf("[Role] Replayed %d event(s) stored since %s (%d failed)\n",
+			replayed, replaySince.Format(time.RFC3339), failed)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":          "success",
+		"role":            gatewayRole(),
+		"region":          gatewayRegion(),
+		"previous_holder": previous,
+		"replayed":        replayed,
+		"replay_failed":   failed,
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+// Payload transformation for outgoing subscriptions.
+//
+// A subscription with a "template" gets the rendered template as its request
+// body instead of the normalized event JSON, so consumers with a fixed schema
+// can be fed directly. The template is a Go text/template executed against
+// the NormalizedEvent:
+//
+//	{"text": "PR #{{.PR.Number}} {{.Action}} in {{.Repository.FullName}}",
+//	 "author": {{json .PR.Author}},
+//	 "files": {{json (filenames .Files)}}}
+//
+// Besides the text/template builtins, templates can use:
+//
+//	json       JSON-encode a value (strings come out quoted and escaped)
+//	lower      strings.ToLower
+//	upper      strings.ToUpper
+//	join       strings.Join
+//	filenames  the Filename of each NormalizedFile
+//
+// The body is sent with the subscription's content_type (application/json
+// by default) and signed after rendering.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// subscriptionTemplateFuncs are the helpers available to subscription
+// templates.
+var subscriptionTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"filenames": func(files []NormalizedFile) []string {
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Filename)
+		}
+		return names
+	},
+}
+
+// parseSubscriptionTemplate compiles a subscription's template.
+func parseSubscriptionTemplate(text string) (*template.Template, error) {
+	return template.New("subscription").Funcs(subscriptionTemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// payload returns the request body for event: the rendered template if the
+// subscription has one, otherwise the normalized event JSON in raw.
+func (s *Subscription) payload(event *NormalizedEvent, raw []byte) ([]byte, error) {
+	if s.Template == "" {
+		return raw, nil
+	}
+	tmpl, err := parseSubscriptionTemplate(s.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("template failed: %w", err)
+	}
+	return body.Bytes(), nil
+}
@@ -0,0 +1,539 @@
+package main
+
+// Outgoing webhook subscriptions — external systems register a URL (plus an
+// optional HMAC secret and filters) and receive every matching normalized
+// event, GitHub-style:
+//
+//	POST   /subscriptions        register (returns the subscription)
+//	GET    /subscriptions        list
+//	GET    /subscriptions/{id}   show one
+//	DELETE /subscriptions/{id}   remove
+//	GET    /subscriptions/{id}/deliveries                 delivery history
+//	POST   /subscriptions/{id}/deliveries/{did}/redeliver resend one delivery
+//
+// All endpoints require the admin token. Subscriptions are persisted to
+// SUBSCRIPTIONS_FILE (JSON) when set. Each delivery is POSTed with
+// X-Gateway-Event, X-Gateway-Delivery and, if a secret is set,
+// X-Hub-Signature-256 headers, and retried up to three times. After
+// SUBSCRIPTION_MAX_FAILURES consecutive failed deliveries (default 20) the
+// subscription is deactivated until it is re-registered.
+//
+// The last SUBSCRIPTION_DELIVERY_LOG deliveries per subscription (default 50)
+// are kept in memory, payload included, so failed ones can be redelivered.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriptionAttempts is how often one delivery is tried before it counts
+// as failed.
+const subscriptionAttempts = 3
+
+// defaultSubscriptionDeliveryLog is how many deliveries are kept per
+// subscription when SUBSCRIPTION_DELIVERY_LOG is unset.
+const defaultSubscriptionDeliveryLog = 50
+
+// Subscription is an external webhook registered to receive normalized
+// events. Empty filters match everything.
+type Subscription struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events,omitempty"`    // normalized event types, e.g. "pull_request.opened"
+	Platforms []string `json:"platforms,omitempty"` // "github", "bitbucket", …
+	Repos     []string `json:"repos,omitempty"`     // full-name globs, e.g. "acme/*"
+	// Template, when set, renders the request body (see subscription_transform.go).
+	Template    string    `json:"template,omitempty"`
+	ContentType string    `json:"content_type,omitempty"` // default application/json
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Retry state.
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastDeliveryAt      time.Time `json:"last_delivery_at,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// SubscriptionDelivery is one delivery of an event to a subscription,
+// covering all of its attempts.
+type SubscriptionDelivery struct {
+	ID          string    `json:"id"` // sent as X-Gateway-Delivery
+	EventID     string    `json:"event_id"`
+	EventType   string    `json:"event_type"`
+	Redelivery  bool      `json:"redelivery"`
+	Success     bool      `json:"success"`
+	Attempts    int       `json:"attempts"`
+	StatusCode  int       `json:"status_code,omitempty"` // of the last attempt; 0 if no response
+	DurationMs  int64     `json:"duration_ms"`           // of the last attempt
+	Error       string    `json:"error,omitempty"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	payload     []byte
+}
+
+// matches reports whether event passes the subscription's filters.
+func (s *Subscription) matches(event *NormalizedEvent) bool {
+	if len(s.Events) > 0 && !containsFold(s.Events, event.EventType) && !containsFold(s.Events, "*") {
+		return false
+	}
+	if len(s.Platforms) > 0 && !containsFold(s.Platforms, string(event.Platform)) {
+		return false
+	}
+	if len(s.Repos) > 0 && !matchAnyGlob(s.Repos, event.Repository.FullName) {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether list contains v, ignoring case.
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns a copy safe to return from the API.
+func (s Subscription) redacted() Subscription {
+	if s.Secret != "" {
+		s.Secret = "********"
+	}
+	return s
+}
+
+// SubscriptionStore holds subscriptions, optionally persisted to a file.
+type SubscriptionStore struct {
+	mu         sync.Mutex
+	path       string
+	subs       map[string]*Subscription
+	deliveries map[string][]*SubscriptionDelivery // by subscription ID, oldest first
+	maxLog     int
+}
+
+var (
+	subscriptionsOnce sync.Once
+	subscriptionStore *SubscriptionStore
+)
+
+// subscriptions returns the package-level store, loading it on first use.
+func subscriptions() *SubscriptionStore {
+	subscriptionsOnce.Do(func() {
+		subscriptionStore = NewSubscriptionStore(os.Getenv("SUBSCRIPTIONS_FILE"))
+	})
+	return subscriptionStore
+}
+
+// NewSubscriptionStore returns a store persisted to path, or in-memory only
+// when path is empty.
+func NewSubscriptionStore(path string) *SubscriptionStore {
+	s := &SubscriptionStore{
+		path:       path,
+		subs:       map[string]*Subscription{},
+		deliveries: map[string][]*SubscriptionDelivery{},
+		maxLog:     envInt("SUBSCRIPTION_DELIVERY_LOG", defaultSubscriptionDeliveryLog),
+	}
+	if path == "" {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Subscriptions] Warning: could not read %s: %v\n", path, err)
+		}
+		return s
+	}
+	var list []*Subscription
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("[Subscriptions] Warning: could not parse %s: %v\n", path, err)
+		return s
+	}
+	for _, sub := range list {
+		s.subs[sub.ID] = sub
+	}
+	log.Printf("[Subscriptions] Loaded %d subscription(s) from %s\n", len(list), path)
+	return s
+}
+
+// save writes the store to its file. Callers must hold s.mu.
+func (s *SubscriptionStore) save() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("[Subscriptions] Warning: could not persist subscriptions: %v\n", err)
+	}
+}
+
+// sorted returns the subscriptions by creation time. Callers must hold s.mu.
+func (s *SubscriptionStore) sorted() []*Subscription {
+	list := make([]*Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		list = append(list, sub)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Add registers sub, assigning its ID.
+func (s *SubscriptionStore) Add(sub Subscription) Subscription {
+	sub.ID = newEventID()
+	sub.Active = true
+	sub.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.ID] = &sub
+	s.save()
+	return sub
+}
+
+// Get returns a copy of the subscription with the given ID.
+func (s *SubscriptionStore) Get(id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
+// List returns copies of all subscriptions.
+func (s *SubscriptionStore) List() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Subscription
+	for _, sub := range s.sorted() {
+		out = append(out, *sub)
+	}
+	return out
+}
+
+// Delete removes a subscription and reports whether it existed.
+func (s *SubscriptionStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[id]; !ok {
+		return false
+	}
+	delete(s.subs, id)
+	delete(s.deliveries, id)
+	s.save()
+	return true
+}
+
+// Deliveries returns the subscription's delivery log, newest first.
+func (s *SubscriptionStore) Deliveries(id string) []SubscriptionDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := s.deliveries[id]
+	out := make([]SubscriptionDelivery, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		out = append(out, *history[i])
+	}
+	return out
+}
+
+// delivery returns one logged delivery, payload included.
+func (s *SubscriptionStore) delivery(subID, deliveryID string) (SubscriptionDelivery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.deliveries[subID] {
+		if d.ID == deliveryID {
+			return *d, true
+		}
+	}
+	return SubscriptionDelivery{}, false
+}
+
+// recordResult logs a delivery and updates the subscription's retry state.
+func (s *SubscriptionStore) recordResult(id string, d *SubscriptionDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return
+	}
+	history := append(s.deliveries[id], d)
+	if len(history) > s.maxLog {
+		history = history[len(history)-s.maxLog:]
+	}
+	s.deliveries[id] = history
+
+	sub.LastDeliveryAt = d.DeliveredAt
+	if d.Success {
+		recovered := sub.ConsecutiveFailures > 0
+		sub.ConsecutiveFailures = 0
+		sub.LastError = ""
+		if recovered {
+			s.save()
+		}
+		return
+	}
+	sub.ConsecutiveFailures++
+	sub.LastError = d.Error
+	if max := envInt("SUBSCRIPTION_MAX_FAILURES", 20); sub.ConsecutiveFailures >= max && sub.Active {
+		sub.Active = false
+		log.Printf("[Subscriptions] Deactivated %s (%s) after %d consecutive failures\n", sub.ID, sub.URL, sub.ConsecutiveFailures)
+	}
+	// Persist failures so the retry state survives a restart.
+	s.save()
+}
+
+// Dispatch delivers event to every active matching subscription. Each
+// delivery runs in its own goroutine so a slow subscriber never holds up
+// the event bus.
+func (s *SubscriptionStore) Dispatch(event *NormalizedEvent) {
+	s.mu.Lock()
+	var targets []Subscription
+	for _, sub := range s.subs {
+		if sub.Active && sub.matches(event) {
+			targets = append(targets, *sub)
+		}
+	}
+	s.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[Subscriptions] Warning: could not marshal event %s: %v\n", event.EventID, err)
+		return
+	}
+	for _, sub := range targets {
+		go func(sub Subscription) {
+			payload, err := sub.payload(event, body)
+			var d *SubscriptionDelivery
+			if err != nil {
+				d = &SubscriptionDelivery{
+					ID:          newEventID(),
+					EventID:     event.EventID,
+					EventType:   event.EventType,
+					Error:       err.Error(),
+					DeliveredAt: time.Now(),
+				}
+			} else {
+				d = deliverToSubscription(sub, event.EventID, event.EventType, payload)
+			}
+			if d.Success {
+				countDelivery(event)
+			} else {
+				log.Printf("[Subscriptions] Warning: delivery of event %s to %s failed: %s\n", event.EventID, sub.URL, d.Error)
+			}
+			s.recordResult(sub.ID, d)
+		}(sub)
+	}
+}
+
+// Redeliver resends a logged delivery to its subscription under a new
+// delivery ID, synchronously, and logs the result.
+func (s *SubscriptionStore) Redeliver(subID, deliveryID string) (*SubscriptionDelivery, error) {
+	sub, ok := s.Get(subID)
+	if !ok {
+		return nil, fmt.Errorf("subscription %s not found", subID)
+	}
+	orig, ok := s.delivery(subID, deliveryID)
+	if !ok {
+		return nil, fmt.Errorf("delivery %s not found", deliveryID)
+	}
+	d := deliverToSubscription(sub, orig.EventID, orig.EventType, orig.payload)
+	d.Redelivery = true
+	s.recordResult(subID, d)
+	log.Printf("[Subscriptions] Redelivered %s to %s as %s (success=%t)\n", deliveryID, sub.URL, d.ID, d.Success)
+	return d, nil
+}
+
+// deliverToSubscription POSTs body to the subscriber, retrying with backoff,
+// and returns the outcome.
+func deliverToSubscription(sub Subscription, eventID, eventType string, body []byte) *SubscriptionDelivery {
+	d := &SubscriptionDelivery{
+		ID:        newEventID(),
+		EventID:   eventID,
+		EventType: eventType,
+		payload:   body,
+	}
+	for attempt := 1; attempt <= subscriptionAttempts; attempt++ {
+		start := time.Now()
+		code, err := postSubscription(sub, eventType, d.ID, body)
+		d.Attempts = attempt
+		d.StatusCode = code
+		d.DurationMs = time.Since(start).Milliseconds()
+		d.DeliveredAt = time.Now()
+		if err == nil {
+			d.Success = true
+			d.Error = ""
+			return d
+		}
+		d.Error = err.Error()
+		if attempt < subscriptionAttempts {
+			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+		}
+	}
+	return d
+}
+
+// postSubscription makes one signed delivery attempt and returns the
+// response status code (0 if there was no response).
+func postSubscription(sub Subscription, eventType, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	contentType := sub.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Gateway-Event", eventType)
+	req.Header.Set("X-Gateway-Delivery", deliveryID)
+	if sub.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(sub.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("subscriber returned %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.StatusCode, nil
+}
+
+// SubscriptionsHandler serves the subscription management API.
+func SubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/subscriptions"), "/"), "/")
+	id := parts[0]
+	if len(parts) > 1 {
+		subscriptionDeliveriesHandler(w, r, id, parts[1:])
+		return
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		var sub Subscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an absolute http(s) URL", http.StatusBadRequest)
+			return
+		}
+		if sub.Template != "" {
+			if _, err := parseSubscriptionTemplate(sub.Template); err != nil {
+				http.Error(w, "invalid template: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		sub = subscriptions().Add(sub)
+		log.Printf("[Subscriptions] Registered %s → %s\n", sub.ID, sub.URL)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":       "success",
+			"subscription": sub.redacted(),
+		})
+
+	case id == "" && r.Method == http.MethodGet:
+		list := subscriptions().List()
+		for i := range list {
+			list[i] = list[i].redacted()
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":        "success",
+			"subscriptions": list,
+		})
+
+	case id != "" && r.Method == http.MethodGet:
+		sub, ok := subscriptions().Get(id)
+		if !ok {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":       "success",
+			"subscription": sub.redacted(),
+		})
+
+	case id != "" && r.Method == http.MethodDelete:
+		if !subscriptions().Delete(id) {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// subscriptionDeliveriesHandler serves /subscriptions/{id}/deliveries and
+// /subscriptions/{id}/deliveries/{did}/redeliver.
+func subscriptionDeliveriesHandler(w http.ResponseWriter, r *http.Request, id string, rest []string) {
+	if _, ok := subscriptions().Get(id); !ok {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(rest) == 1 && rest[0] == "deliveries" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "success",
+			"deliveries": subscriptions().Deliveries(id),
+		})
+
+	case len(rest) == 3 && rest[0] == "deliveries" && rest[2] == "redeliver":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		d, err := subscriptions().Redeliver(id, rest[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":   "success",
+			"delivery": d,
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
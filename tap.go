@@ -0,0 +1,157 @@
+package main
+
+// Sampling tap — copies a sample of normalized events, plus every event
+// matching a filter, to a debugging sink: for building datasets or
+// debugging downstream modeling on real traffic.
+//
+//	TAP_SINK_URL   where tapped events go: an HTTP endpoint, or
+//	               file:///path/events.jsonl to append JSON lines
+//	TAP_PERCENT    share of all events tapped, 0-100 (default 0)
+//	TAP_EVENTS     event types always tapped, e.g. "pull_request.opened"
+//	TAP_PLATFORMS  platforms always tapped, e.g. "bitbucket"
+//	TAP_REPOS      full-name globs always tapped, e.g. "acme/*"
+//	TAP_BUFFER     events waiting to be tapped (default 1000)
+//
+// The filter lists are comma-separated and combine like a subscription's
+// (see subscriptions.go): an event matches when it passes every list that
+// is set. Sampling is deterministic on the event ID, like the canary's.
+//
+// The tap never affects primary delivery: events are queued and delivered
+// by a goroutine of their own, dropped when the queue is full, and never
+// retried or dead-lettered. GET /admin/metrics counts tapped, dropped and
+// failed events.
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// tapFileSink appends events as JSON lines to a file.
+type tapFileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (s *tapFileSink) Name() string {
+	return "tap"
+}
+
+func (s *tapFileSink) Deliver(event *NormalizedEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("tap: failed to marshal event: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// eventTap queues tapped events for its sink.
+type eventTap struct {
+	sink    Sink
+	percent int
+	filter  Subscription // only the Events, Platforms and Repos filters
+	queue   chan *NormalizedEvent
+}
+
+var (
+	tapOnce sync.Once
+	tap     *eventTap
+)
+
+// tapSink returns the configured tap, or nil if disabled.
+func tapSink() *eventTap {
+	tapOnce.Do(func() {
+		target := os.Getenv("TAP_SINK_URL")
+		if target == "" {
+			return
+		}
+		t := &eventTap{
+			percent: envInt("TAP_PERCENT", 0),
+			filter: Subscription{
+				Events:    tapList("TAP_EVENTS"),
+				Platforms: tapList("TAP_PLATFORMS"),
+				Repos:     tapList("TAP_REPOS"),
+			},
+			queue: make(chan *NormalizedEvent, envInt("TAP_BUFFER", 1000)),
+		}
+		if t.percent > 100 {
+			log.Printf("[Tap] Warning: TAP_PERCENT=%d out of range, tap disabled\n", t.percent)
+			return
+		}
+		if !t.filtered() && t.percent == 0 {
+			log.Println("[Tap] Warning: TAP_SINK_URL is set but neither TAP_PERCENT nor a filter is; tap disabled")
+			return
+		}
+		if path, ok := strings.CutPrefix(target, "file://"); ok {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				log.Printf("[Tap] Warning: could not open %s, tap disabled: %v\n", path, err)
+				return
+			}
+			t.sink = &tapFileSink{file: f}
+		} else {
+			t.sink = &HTTPSink{name: "tap", url: target}
+		}
+		go t.run()
+		tap = t
+		log.Printf("[Tap] Tapping %d%% of events, plus filter matches, to %s\n", t.percent, redactURL(target))
+	})
+	return tap
+}
+
+// tapList reads a comma-separated filter list.
+func tapList(name string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// filtered reports whether any filter list is set.
+func (t *eventTap) filtered() bool {
+	return len(t.filter.Events) > 0 || len(t.filter.Platforms) > 0 || len(t.filter.Repos) > 0
+}
+
+// selects reports whether event is sampled or matches the filter.
+func (t *eventTap) selects(event *NormalizedEvent) bool {
+	if t.filtered() && t.filter.matches(event) {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(event.EventID))
+	return int(h.Sum32()%100) < t.percent
+}
+
+// Tap queues event for the tap sink if selected, without blocking.
+func (t *eventTap) Tap(event *NormalizedEvent) {
+	if !t.selects(event) {
+		return
+	}
+	select {
+	case t.queue <- event:
+	default:
+		metrics.tapDropped.Add(1)
+	}
+}
+
+// run delivers queued events until the process exits.
+func (t *eventTap) run() {
+	for event := range t.queue {
+		if err := t.sink.Deliver(event); err != nil {
+			metrics.tapFailures.Add(1)
+			log.Printf("[Tap] Warning: could not tap event %s: %v\n", event.EventID, err)
+			continue
+		}
+		metrics.tapped.Add(1)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+// Issue-tracker linkage.
+//
+// Ticket keys are detected in the PR's source branch, title and description
+// using TICKET_KEY_PATTERN (default `[A-Z][A-Z0-9]+-[0-9]+`, e.g. PROJ-123).
+// When JIRA_BASE_URL is set, each key is validated against the Jira REST API
+// (JIRA_EMAIL / JIRA_API_TOKEN basic auth); keys Jira does not know are
+// dropped so strings like "UTF-8" do not end up as ticket links.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTicketKeyPattern = `\b[A-Z][A-Z0-9]+-[0-9]+\b`
+
+// TicketRef links a normalized event to an issue-tracker ticket.
+type TicketRef struct {
+	Key       string
+	Source    string // "branch", "title" or "description"
+	URL       string
+	Summary   string
+	Status    string
+	Validated bool // true when the key was confirmed by the tracker API
+}
+
+var (
+	ticketPatternOnce sync.Once
+	ticketKeyPattern  *regexp.Regexp
+)
+
+// ticketPattern returns the compiled TICKET_KEY_PATTERN, falling back to the
+// default when the variable is unset or invalid.
+func ticketPattern() *regexp.Regexp {
+	ticketPatternOnce.Do(func() {
+		pattern := os.Getenv("TICKET_KEY_PATTERN")
+		if pattern != "" {
+			re, err := regexp.Compile(pattern)
+			if err == nil {
+				ticketKeyPattern = re
+				return
+			}
+			log.Printf("[Tickets] Warning: invalid TICKET_KEY_PATTERN %q: %v — using default\n", pattern, err)
+		}
+		ticketKeyPattern = regexp.MustCompile(defaultTicketKeyPattern)
+	})
+	return ticketKeyPattern
+}
+
+// detectTicketKeys returns the unique ticket keys found in texts, in order of
+// first appearance.
+func detectTicketKeys(texts ...string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, text := range texts {
+		for _, key := range ticketPattern().FindAllString(text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// enrichTickets attaches TicketRefs for every ticket key referenced by the
+// PR, validating them against Jira when it is configured. Jira is queried
+// in ctx.
+func enrichTickets(ctx context.Context, event *NormalizedEvent) {
+	sources := []struct{ name, text string }{
+		{"branch", event.PR.SourceBranch},
+		{"title", event.PR.Title},
+		{"description", event.PR.Description},
+	}
+
+	seen := map[string]bool{}
+	var refs []TicketRef
+	for _, src := range sources {
+		for _, key := range detectTicketKeys(src.text) {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			ref, ok := jira().lookup(ctx, key)
+			if !ok {
+				log.Printf("[Tickets] Dropping %s: not found in Jira\n", key)
+				continue
+			}
+			ref.Source = src.name
+			refs = append(refs, ref)
+		}
+	}
+	event.Tickets = refs
+}
+
+// jiraClient validates ticket keys against Jira, caching results per key
+// for JIRA_CACHE_SECONDS (default 3600).
+type jiraClient struct {
+	cache *ttlCache
+}
+
+type jiraLookup struct {
+	ref   TicketRef
+	found bool
+}
+
+var (
+	jiraOnce sync.Once
+	jiraRef  *jiraClient
+)
+
+// jira returns the Jira client, its cache sized from JIRA_CACHE_SECONDS.
+func jira() *jiraClient {
+	jiraOnce.Do(func() {
+		jiraRef = &jiraClient{cache: newTTLCache("jira_tickets", time.Duration(envInt("JIRA_CACHE_SECONDS", 3600))*time.Second)}
+	})
+	return jiraRef
+}
+
+// lookup returns the TicketRef for key and whether it should be kept. Without
+// JIRA_BASE_URL every key is kept unvalidated; on API errors the key is kept
+// unvalidated rather than silently lost.
+func (c *jiraClient) lookup(ctx context.Context, key string) (TicketRef, bool) {
+	baseURL := strings.TrimRight(os.Getenv("JIRA_BASE_URL"), "/")
+	if baseURL == "" {
+		return TicketRef{Key: key}, true
+	}
+
+	if cached, ok := c.cache.Get(key); ok {
+		lookup := cached.(jiraLookup)
+		return lookup.ref, lookup.found
+	}
+
+	ref := TicketRef{Key: key, URL: fmt.Sprintf("%s/browse/%s", baseURL, key)}
+	found, err := fetchJiraIssue(ctx, baseURL, &ref)
+	if err != nil {
+		log.Printf("[Tickets] Warning: could not validate %s: %v\n", key, err)
+		return ref, true
+	}
+
+	c.cache.Set(key, jiraLookup{ref: ref, found: found})
+	return ref, found
+}
+
+// fetchJiraIssue fills ref with the issue's summary and status. It returns
+// false (and no error) when Jira reports the issue does not exist.
+func fetchJiraIssue(ctx context.Context, baseURL string, ref *TicketRef) (bool, error) {
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary,status", baseURL, ref.Key)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	if user := os.Getenv("JIRA_EMAIL"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("JIRA_API_TOKEN"))
+	}
+	setAPIHeaders(req, "application/json")
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 400 {
+		return false, fmt.Errorf("Jira API %d", resp.StatusCode)
+	}
+
+	var issue struct {
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return false, fmt.Errorf("failed to parse Jira issue: %w", err)
+	}
+	ref.Summary = issue.Fields.Summary
+	ref.Status = issue.Fields.Status.Name
+	ref.Validated = true
+	return true, nil
+}
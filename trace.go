@@ -0,0 +1,276 @@
+package main
+
+// Event tracing — records the trail of each webhook through the gateway so a
+// single delivery can be debugged after the fact via GET /admin/trace/{id}.
+//
+// A trace collects queue hops (received, queued, normalized, published,
+// consumed), every outbound HTTP call made on behalf of the event, and each
+// sink delivery attempt. Outbound calls are captured by wrapping
+// http.DefaultTransport and attributed by the event ID in the request's
+// context (see request_scope.go): the calls of the event's adapter, its
+// enrichers and the reviewers and analyzers using that adapter. Scheduled
+// jobs, sinks and subscriptions make their calls without one and are not
+// attributed.
+//
+// The last TRACE_MAX traces (default 2000) are kept in memory.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTraceMax = 2000
+
+// TraceHop is one step of an event through the pipeline.
+type TraceHop struct {
+	Stage  string    `json:"stage"`
+	At     time.Time `json:"at"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// TraceAPICall is one outbound HTTP request made on behalf of the event.
+type TraceAPICall struct {
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// TraceDelivery is one attempt to deliver the normalized event to a sink.
+type TraceDelivery struct {
+	Sink       string    `json:"sink"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// EventTrace is the recorded trail of one webhook delivery.
+type EventTrace struct {
+	EventID    string          `json:"event_id"`
+	DeliveryID string          `json:"delivery_id"`
+	Platform   SCMPlatform     `json:"platform"`
+	EventType  string          `json:"event_type"`
+	Hops       []TraceHop      `json:"hops"`
+	APICalls   []TraceAPICall  `json:"api_calls"`
+	Deliveries []TraceDelivery `json:"deliveries"`
+	RawPayload json.RawMessage `json:"raw_payload,omitempty"` // kept only if the event never reached the store
+}
+
+// traceLog is a bounded, goroutine-safe collection of traces by event ID.
+type traceLog struct {
+	mu     sync.Mutex
+	max    int
+	traces map[string]*EventTrace
+	order  []string
+}
+
+var (
+	tracesOnce sync.Once
+	traces     *traceLog
+)
+
+// traceIDKey is the context key of the event ID outbound calls are traced to.
+type traceIDKey struct{}
+
+// traceLogs returns the package-level trace log.
+func traceLogs() *traceLog {
+	tracesOnce.Do(func() {
+		traces = &traceLog{max: envInt("TRACE_MAX", defaultTraceMax), traces: map[string]*EventTrace{}}
+	})
+	return traces
+}
+
+// with runs fn on the trace for eventID, creating it if needed.
+func (l *traceLog) with(eventID string, fn func(t *EventTrace)) {
+	if eventID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, ok := l.traces[eventID]
+	if !ok {
+		t = &EventTrace{EventID: eventID}
+		l.traces[eventID] = t
+		l.order = append(l.order, eventID)
+		if len(l.order) > l.max {
+			delete(l.traces, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	fn(t)
+}
+
+// len returns the number of traces kept.
+func (l *traceLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.traces)
+}
+
+// find returns a copy of the trace whose delivery ID or event ID is id.
+func (l *traceLog) find(id string) (EventTrace, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.traces[id]; ok {
+		return *t, true
+	}
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if t := l.traces[l.order[i]]; t.DeliveryID == id {
+			return *t, true
+		}
+	}
+	return EventTrace{}, false
+}
+
+// traceReceived starts the trace of a webhook accepted by the gateway.
+func traceReceived(msg RawWebhookMessage) {
+	traceLogs().with(msg.EventID, func(t *EventTrace) {
+		t.DeliveryID, t.Platform, t.EventType = msg.DeliveryID, msg.Platform, msg.EventType
+		t.Hops = append(t.Hops, TraceHop{Stage: "received", At: time.Now()})
+	})
+}
+
+// traceHop records a pipeline step for eventID.
+func traceHop(eventID, stage, detail string) {
+	traceLogs().with(eventID, func(t *EventTrace) {
+		t.Hops = append(t.Hops, TraceHop{Stage: stage, At: time.Now(), Detail: detail})
+	})
+}
+
+// traceRawPayload keeps the raw payload of an event that failed before it
+// could be stored.
+func traceRawPayload(eventID string, payload []byte) {
+	traceLogs().with(eventID, func(t *EventTrace) {
+		if json.Valid(payload) {
+			t.RawPayload = payload
+		}
+	})
+}
+
+// traceDelivery records a sink delivery attempt.
+func traceDelivery(eventID, sink string, elapsed time.Duration, err error) {
+	d := TraceDelivery{Sink: sink, DurationMS: elapsed.Milliseconds(), At: time.Now()}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	traceLogs().with(eventID, func(t *EventTrace) {
+		t.Deliveries = append(t.Deliveries, d)
+	})
+}
+
+// withTraceID returns a context whose outbound HTTP calls are traced to
+// eventID.
+func withTraceID(ctx context.Context, eventID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, eventID)
+}
+
+// traceIDFrom returns the event ID outbound calls made in ctx are traced to.
+func traceIDFrom(ctx context.Context) string {
+	eventID, _ := ctx.Value(traceIDKey{}).(string)
+	return eventID
+}
+
+// tracingTransport records outbound requests on the trace of the event in
+// their context.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (tt *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	countAPICall(req.Context()) // see usage.go
+	eventID := traceIDFrom(req.Context())
+	if eventID == "" {
+		resp, err := tt.base.RoundTrip(req)
+		if err == nil {
+			noteDeprecation(req, resp) // see outbound_headers.go
+		}
+		return resp, err
+	}
+
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	if apiRecordingEnabled() {
+		resp, err = recordExchange(tt.base, req, eventID)
+	} else {
+		resp, err = tt.base.RoundTrip(req)
+	}
+	call := TraceAPICall{
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		DurationMS: time.Since(start).Milliseconds(),
+		At:         start,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Status = resp.StatusCode
+		noteDeprecation(req, resp)
+	}
+	traceLogs().with(eventID, func(t *EventTrace) {
+		t.APICalls = append(t.APICalls, call)
+	})
+	return resp, err
+}
+
+// installTracingTransport wraps http.DefaultTransport, which every SCM,
+// tracker and sink client in the gateway uses, so outbound calls can be
+// traced.
+func installTracingTransport() {
+	http.DefaultTransport = &tracingTransport{base: http.DefaultTransport}
+}
+
+// AdminTraceHandler returns the full trail of one webhook delivery.
+//
+//	GET /admin/trace/{delivery_id}
+//
+// The ID may be the SCM delivery ID (X-GitHub-Delivery / X-Request-UUID) or
+// the gateway event ID. The response combines the recorded trace with the
+// stored raw payload and normalized output, when still retained.
+func AdminTraceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/admin/trace/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "usage: /admin/trace/{delivery_id}", http.StatusBadRequest)
+		return
+	}
+
+	trace, ok := traceLogs().find(id)
+	if !ok {
+		http.Error(w, "no trace for "+id, http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status": "success",
+		"trace":  trace,
+	}
+	if stored, ok := store().Get(trace.EventID); ok {
+		normalized := *stored.Event
+		normalized.RawPayload = nil
+		response["normalized"] = normalized
+		if json.Valid(stored.Event.RawPayload) {
+			response["raw_payload"] = json.RawMessage(stored.Event.RawPayload)
+		}
+	} else if trace.RawPayload != nil {
+		response["raw_payload"] = trace.RawPayload
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
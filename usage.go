@@ -0,0 +1,267 @@
+package main
+
+// Tenant usage — monthly counters per tenant (a repository owner on one
+// platform: a GitHub organization, a Bitbucket workspace, …) for chargeback:
+//
+//	events         normalized events stored, including ones the gateway
+//	               emits itself (auto-merged, dependency-blocked)
+//	api_calls      outbound HTTP calls made on behalf of the tenant's events,
+//	               counted by the tenant in the request's context (see
+//	               request_scope.go): normalization, enrichers, reviewers
+//	               and analyzers; scheduled jobs, sinks and subscriptions
+//	               are not attributed
+//	storage_bytes  JSON size of the events stored in the event store
+//	deliveries     successful deliveries to sinks and subscriptions
+//
+// Reports are served as JSON or CSV:
+//
+//	GET /admin/usage[?month=2026-10][&platform=github&tenant=acme][&format=csv]
+//
+// The month defaults to the current one (UTC). Counters are kept in memory
+// and, with USAGE_FILE set, saved by the "usage_flush" job (every five
+// minutes) and reloaded on startup, so a restart loses at most the last few
+// minutes. Months older than USAGE_RETENTION_MONTHS (default 13) are dropped
+// at each flush.
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const usageMonthLayout = "2006-01"
+
+// TenantUsage is one tenant's usage in one month.
+type TenantUsage struct {
+	Month        string      `json:"month"`
+	Platform     SCMPlatform `json:"platform"`
+	Tenant       string      `json:"tenant"`
+	Events       int64       `json:"events"`
+	APICalls     int64       `json:"api_calls"`
+	StorageBytes int64       `json:"storage_bytes"`
+	Deliveries   int64       `json:"deliveries"`
+}
+
+// usageLedger is a goroutine-safe set of TenantUsage, keyed by month,
+// platform and tenant.
+type usageLedger struct {
+	mu    sync.Mutex
+	path  string
+	usage map[string]*TenantUsage
+	dirty bool
+}
+
+var (
+	usageOnce   sync.Once
+	tenantUsage *usageLedger
+)
+
+// usageTenantKey is the context key of the tenant outbound calls are
+// charged to.
+type usageTenantKey struct{}
+
+// usageTenant identifies a tenant: a repository owner on one platform.
+type usageTenant struct {
+	platform SCMPlatform
+	owner    string
+}
+
+// usage returns the process-wide ledger, loading USAGE_FILE on first use.
+func usage() *usageLedger {
+	usageOnce.Do(func() {
+		tenantUsage = &usageLedger{path: os.Getenv("USAGE_FILE"), usage: map[string]*TenantUsage{}}
+		if tenantUsage.path == "" {
+			return
+		}
+		data, err := os.ReadFile(tenantUsage.path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("[Usage] Warning: could not read %s: %v\n", tenantUsage.path, err)
+			}
+			return
+		}
+		var saved []TenantUsage
+		if err := json.Unmarshal(data, &saved); err != nil {
+			log.Printf("[Usage] Warning: %s is not a usage file: %v\n", tenantUsage.path, err)
+			return
+		}
+		for i := range saved {
+			u := saved[i]
+			tenantUsage.usage[usageKey(u.Month, u.Platform, u.Tenant)] = &u
+		}
+		log.Printf("[Usage] Loaded %d tenant-month(s) from %s\n", len(saved), tenantUsage.path)
+	})
+	return tenantUsage
+}
+
+func usageKey(month string, platform SCMPlatform, tenant string) string {
+	return month + "|" + string(platform) + "|" + tenant
+}
+
+// add applies f to the current month's usage of the tenant owning owner.
+func (l *usageLedger) add(platform SCMPlatform, owner string, f func(u *TenantUsage)) {
+	if owner == "" {
+		return
+	}
+	month := time.Now().UTC().Format(usageMonthLayout)
+	tenant := strings.ToLower(owner)
+	key := usageKey(month, platform, tenant)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.usage[key]
+	if !ok {
+		u = &TenantUsage{Month: month, Platform: platform, Tenant: tenant}
+		l.usage[key] = u
+	}
+	f(u)
+	l.dirty = true
+}
+
+// countStoredEvent records an event written to the event store.
+func countStoredEvent(event *NormalizedEvent) {
+	size := 0
+	if data, err := json.Marshal(event); err == nil {
+		size = len(data)
+	}
+	usage().add(event.Platform, event.Repository.Owner, func(u *TenantUsage) {
+		u.Events++
+		u.StorageBytes += int64(size)
+	})
+}
+
+// countDelivery records a successful delivery of event.
+func countDelivery(event *NormalizedEvent) {
+	usage().add(event.Platform, event.Repository.Owner, func(u *TenantUsage) { u.Deliveries++ })
+}
+
+// withUsageTenant returns a context whose outbound HTTP calls are charged to
+// the owner of repo ("owner/name").
+func withUsageTenant(ctx context.Context, platform SCMPlatform, repo string) context.Context {
+	owner, _, _ := strings.Cut(repo, "/")
+	return context.WithValue(ctx, usageTenantKey{}, usageTenant{platform: platform, owner: owner})
+}
+
+// countAPICall records an outbound call for the tenant in ctx, if any.
+func countAPICall(ctx context.Context) {
+	tenant, ok := ctx.Value(usageTenantKey{}).(usageTenant)
+	if !ok {
+		return
+	}
+	usage().add(tenant.platform, tenant.owner, func(u *TenantUsage) { u.APICalls++ })
+}
+
+// report returns the usage of month, optionally of one platform and tenant,
+// sorted by platform and tenant.
+func (l *usageLedger) report(month string, platform SCMPlatform, tenant string) []TenantUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := []TenantUsage{}
+	for _, u := range l.usage {
+		if u.Month != month || (platform != "" && u.Platform != platform) ||
+			(tenant != "" && u.Tenant != strings.ToLower(tenant)) {
+			continue
+		}
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Platform != out[j].Platform {
+			return out[i].Platform < out[j].Platform
+		}
+		return out[i].Tenant < out[j].Tenant
+	})
+	return out
+}
+
+// flushUsage is the "usage_flush" scheduler job: it drops months past
+// retention and saves the ledger to USAGE_FILE.
+func flushUsage() error {
+	l := usage()
+	now := time.Now().UTC()
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	oldest := firstOfMonth.AddDate(0, 1-envInt("USAGE_RETENTION_MONTHS", 13), 0).Format(usageMonthLayout)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, u := range l.usage {
+		if u.Month < oldest {
+			delete(l.usage, key)
+			l.dirty = true
+		}
+	}
+	if l.path == "" || !l.dirty {
+		return nil
+	}
+	saved := make([]TenantUsage, 0, len(l.usage))
+	for _, u := range l.usage {
+		saved = append(saved, *u)
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+// AdminUsageHandler reports one month's usage per tenant.
+//
+//	GET /admin/usage[?month=YYYY-MM][&platform=P&tenant=T][&format=csv]
+func AdminUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	month := q.Get("month")
+	if month == "" {
+		month = time.Now().UTC().Format(usageMonthLayout)
+	} else if _, err := time.Parse(usageMonthLayout, month); err != nil {
+		http.Error(w, "month must be YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	rows := usage().report(month, SCMPlatform(q.Get("platform")), q.Get("tenant"))
+
+	if q.Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"month":   month,
+			"tenants": rows,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"usage-%s.csv\"", month))
+	out := csv.NewWriter(w)
+	out.Write([]string{"month", "platform", "tenant", "events", "api_calls", "storage_bytes", "deliveries"})
+	for _, u := range rows {
+		out.Write([]string{
+			u.Month, string(u.Platform), u.Tenant,
+			strconv.FormatInt(u.Events, 10),
+			strconv.FormatInt(u.APICalls, 10),
+			strconv.FormatInt(u.StorageBytes, 10),
+			strconv.FormatInt(u.Deliveries, 10),
+		})
+	}
+	out.Flush()
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"io"
 	"log"
@@ -25,16 +26,87 @@ func verifyWebhookSignature(payload []byte, signature string, secret string) boo
 	return hmac.Equal([]byte(expected), []byte(signature))
 }
 
+// webhookSecret returns the HMAC secret for platform: WEBHOOK_SECRET_<PLATFORM>
+// (e.g. WEBHOOK_SECRET_BITBUCKET) when set, so SCMs onboarded by different
+// teams can use their own secrets, otherwise the shared WEBHOOK_SECRET.
+func webhookSecret(platform SCMPlatform) string {
+	if secret := os.Getenv("WEBHOOK_SECRET_" + strings.ToUpper(string(platform))); secret != "" {
+		return secret
+	}
+	return os.Getenv("WEBHOOK_SECRET")
+}
+
+// verifyHubSignature checks the HMAC signature GitHub, Bitbucket and Gitea
+// attach to every delivery, writing the error response and returning false if it
+// is missing or wrong.
+func verifyHubSignature(w http.ResponseWriter, r *http.Request, body []byte, secret string) bool {
+	if secret == "" {
+		log.Println("Error: WEBHOOK_SECRET environment variable not set")
+		http.Error(w, "webhook secret not configured", http.StatusInternalServerError)
+		return false
+	}
+
+	// GitHub uses X-Hub-Signature-256; Bitbucket uses X-Hub-Signature.
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = r.Header.Get("X-Hub-Signature")
+	}
+	// Gitea and Forgejo also send the bare hex digest in their own header.
+	for _, h := range []string{"X-Gitea-Signature", "X-Forgejo-Signature"} {
+		if signature == "" {
+			signature = r.Header.Get(h)
+		}
+	}
+	if signature == "" {
+		log.Println("Error: webhook signature header missing")
+		http.Error(w, "signature missing", http.StatusBadRequest)
+		return false
+	}
+	if !verifyWebhookSignature(body, signature, secret) {
+		log.Println("Error: webhook signature verification failed")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// verifyGerritToken authenticates a Gerrit delivery. The Gerrit webhooks
+// plugin cannot sign payloads, so the remote URL carries a shared token:
+// /webhook?token=$GERRIT_WEBHOOK_TOKEN.
+func verifyGerritToken(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("GERRIT_WEBHOOK_TOKEN")
+	if token == "" {
+		log.Println("Error: GERRIT_WEBHOOK_TOKEN environment variable not set")
+		http.Error(w, "webhook secret not configured", http.StatusInternalServerError)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		log.Println("Error: Gerrit webhook token verification failed")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
 // WebhookHandler is the single HTTP endpoint that receives webhooks from any
-// supported SCM platform (GitHub, Bitbucket).
+// supported SCM platform (GitHub, Bitbucket Cloud and Server, Gerrit, Gitea,
+// CodeCommit). It also serves the
+// per-platform routes /webhook/{platform}, which pin the platform instead of
+// detecting it.
 //
 // Processing flow (mirrors the sequence diagram):
-//  1. Read and verify the HMAC signature  → reject invalid payloads early.
-//  2. Detect which SCM platform sent the event.
+//  1. Detect which SCM platform sent the event.
+//  2. Verify the HMAC signature (Gerrit: shared token, Bitbucket Connect: JWT,
+//     CodeCommit: SNS signature) → reject invalid payloads early.
 //  3. Return 200 OK immediately  (non-blocking acknowledgement to the SCM).
 //  4. Publish the raw event to RabbitMQ (raw_webhook_events queue).
 //     The SCM Adapter consumer picks it up asynchronously, normalizes it,
 //     and forwards it to the Unified Event Bus (normalized_pr_events queue).
+//
+// With WEBHOOK_RESPONSES=structured the acknowledgement waits for step 4 so
+// the SCM's delivery log shows what happened: 202 with the assigned event ID
+// once buffered for publishing, 204 when the event was filtered out, 503 when
+// it could not be accepted.
 func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("=== Webhook received ===")
 
@@ -45,67 +117,173 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// --- Step 2: Verify signature ---
-	webhookSecret := os.Getenv("WEBHOOK_SECRET")
-	if webhookSecret == "" {
-		log.Println("Error: WEBHOOK_SECRET environment variable not set")
-		http.Error(w, "webhook secret not configured", http.StatusInternalServerError)
-		return
-	}
-
-	// GitHub uses X-Hub-Signature-256; Bitbucket uses X-Hub-Signature.
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if signature == "" {
-		signature = r.Header.Get("X-Hub-Signature")
+	// --- Step 2: Detect platform ---
+	// An explicit ?platform= wins; otherwise headers, falling back to the
+	// payload shape for proxies that strip custom headers (and for Gerrit,
+	// whose webhooks plugin sends none).
+	platform := DetectPlatform(r.Header)
+	pinned := strings.TrimPrefix(r.URL.Path, webhookPath+"/")
+	if pinned != r.URL.Path {
+		// Per-platform route, e.g. /webhook/github.
+		platform = SCMPlatform(strings.ToLower(pinned))
+		if !isSupportedPlatform(platform) && platform != PlatformGitLab {
+			http.NotFound(w, r)
+			return
+		}
+	} else if override := r.URL.Query().Get("platform"); override != "" {
+		platform = SCMPlatform(strings.ToLower(override))
+		if !isSupportedPlatform(platform) {
+			http.Error(w, "unknown platform: "+override, http.StatusBadRequest)
+			return
+		}
+	} else if platform == PlatformUnknown {
+		platform = DetectPlatformFromPayload(body)
 	}
-	if signature == "" {
-		log.Println("Error: webhook signature header missing")
-		http.Error(w, "signature missing", http.StatusBadRequest)
+	log.Printf("Detected SCM platform: %s\n", platform)
+	if platform == PlatformGitLab {
+		http.Error(w, "GitLab webhooks are not supported yet", http.StatusNotImplemented)
 		return
 	}
-	if !verifyWebhookSignature(body, signature, webhookSecret) {
-		log.Println("Error: webhook signature verification failed")
-		http.Error(w, "invalid signature", http.StatusUnauthorized)
+
+	// --- Step 3: Verify signature ---
+	envelopeEvent := ""
+	if platform == PlatformGerrit {
+		if !verifyGerritToken(w, r) {
+			return
+		}
+	} else if platform == PlatformCodeCommit {
+		// SNS delivery: signed by AWS, the event wrapped in an envelope.
+		if !verifySNSMessage(w, body) {
+			return
+		}
+		body, envelopeEvent = unwrapSNSMessage(body)
+	} else if platform == PlatformBitbucket && strings.HasPrefix(r.Header.Get("Authorization"), "JWT ") {
+		// Bitbucket Connect app webhook: JWT-signed and wrapped in an envelope.
+		body, envelopeEvent = unwrapConnectPayload(body)
+		if !verifyConnectWebhook(w, r, body) {
+			return
+		}
+	} else if !verifyHubSignature(w, r, body, webhookSecret(platform)) {
 		return
 	}
 	log.Println("Signature verified successfully")
 
-	// --- Step 3: Detect platform ---
-	platform := DetectPlatform(r.Header)
-	log.Printf("Detected SCM platform: %s\n", platform)
-
-	// Resolve the raw event-type string from the appropriate header.
+	// Resolve the raw event-type string from the appropriate header,
+	// inferring it from the payload if the header did not survive.
 	eventType := r.Header.Get("X-GitHub-Event") // GitHub
-	if platform == PlatformBitbucket {
-		eventType = r.Header.Get("X-Event-Key") // Bitbucket
+	if platform == PlatformBitbucket || platform == PlatformBitbucketServer {
+		eventType = r.Header.Get("X-Event-Key") // Bitbucket Cloud and Server
+	}
+	if platform == PlatformGitea {
+		eventType = giteaEventType(r.Header)
+	}
+	if eventType == "" {
+		eventType = envelopeEvent
+	}
+	if eventType == "" {
+		eventType = inferEventType(platform, body)
 	}
 	log.Printf("Event type: %s\n", eventType)
 
+	// Hook verification events are answered directly and never queued.
+	if isPingEvent(platform, eventType) {
+		handlePing(w, platform, body)
+		return
+	}
+	if platform == PlatformGitHub && eventType == githubInstallationEvent {
+		handleInstallationEvent(w, body)
+		return
+	}
+	if trigger, ok := releaseTrigger(platform, eventType, body); ok {
+		handleReleaseTrigger(w, platform, trigger)
+		return
+	}
+
 	// --- Step 4: Acknowledge immediately ---
 	// The SCM expects a fast 200 OK. All further processing happens after the
-	// response is sent, keeping the webhook round-trip non-blocking.
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("received"))
+	// response is sent, keeping the webhook round-trip non-blocking. In
+	// structured mode the answer waits until the event is buffered.
+	structured := structuredWebhookResponses()
+	if !structured {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("received"))
+	}
 
 	// --- Step 5: Skip non-PR events ---
-	isPREvent := eventType == "pull_request" || strings.HasPrefix(eventType, "pullrequest:")
+	isPREvent := eventType == "pull_request" || eventType == githubReviewThreadEvent || strings.HasPrefix(eventType, "pullrequest:") ||
+		(platform == PlatformGitHub && isGitHubSecurityEvent(eventType)) ||
+		(platform == PlatformGerrit && isGerritChangeEvent(eventType)) ||
+		(platform == PlatformGitea && isGiteaPREvent(eventType)) ||
+		(platform == PlatformBitbucketServer && isBitbucketServerPREvent(eventType)) ||
+		(platform == PlatformCodeCommit && isCodeCommitPREvent(eventType))
 	if !isPREvent {
 		log.Printf("Skipping non-PR event: %s\n", eventType)
+		if structured {
+			respondFiltered(w, "unsupported event type: "+eventType)
+		}
 		return
 	}
 
 	// --- Step 6: Publish raw event to the message queue ---
 	if mq == nil {
 		log.Println("Warning: RabbitMQ not initialised, raw event dropped")
+		if structured {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+				"status": "error",
+				"error":  "event queue unavailable",
+			})
+		}
 		return
 	}
 
 	msg := RawWebhookMessage{
-		Platform:  platform,
-		EventType: eventType,
-		Payload:   body,
+		EventID:    newEventID(),
+		DeliveryID: DeliveryID(r.Header),
+		Platform:   platform,
+		EventType:  eventType,
+		Payload:    body,
+		Repo:       payloadRepoFullName(platform, body),
+	}
+	traceReceived(msg)
+	if msg.Repo != "" {
+		registry.MarkDelivery(platform, msg.Repo) // webhooks are flowing; see pr_poll.go
+	}
+	// Published by the ingest workers so a slow broker never blocks the
+	// request goroutine (see ingest.go).
+	if !enqueueIngest(msg) {
+		log.Printf("Warning: ingest buffer full, raw event %s dropped\n", msg.EventID)
+		traceHop(msg.EventID, "queue_failed", "ingest buffer full")
+		if structured {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+				"status":   "error",
+				"event_id": msg.EventID,
+				"error":    "ingest buffer full",
+			})
+		}
+		return
 	}
-	if err := mq.PublishRawEvent(msg); err != nil {
-		log.Printf("Warning: could not publish raw event to queue: %v\n", err)
+	if structured {
+		w.Header().Set("X-Gateway-Event-ID", msg.EventID)
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"status":      "accepted",
+			"event_id":    msg.EventID,
+			"delivery_id": msg.DeliveryID,
+			"platform":    msg.Platform,
+			"event_type":  msg.EventType,
+		})
 	}
 }
+
+// structuredWebhookResponses reports whether webhook deliveries are answered
+// with 202/204 and JSON bodies (WEBHOOK_RESPONSES=structured) rather than
+// the legacy immediate 200 "received".
+func structuredWebhookResponses() bool {
+	return os.Getenv("WEBHOOK_RESPONSES") == "structured"
+}
+
+// respondFiltered answers a delivery the gateway accepted but will not
+// process. 204 carries no body, so the reason goes in a header.
+func respondFiltered(w http.ResponseWriter, reason string) {
+	w.Header().Set("X-Gateway-Filtered", reason)
+	w.WriteHeader(http.StatusNoContent)
+}
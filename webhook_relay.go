@@ -0,0 +1,181 @@
+package main
+
+// Webhook relay — for gateways that cannot be reached by the SCM (a laptop
+// behind NAT), the gateway connects out to a smee.io-compatible relay and
+// pulls deliveries instead of waiting for them:
+//
+//	WEBHOOK_RELAY_URL   relay channel, e.g. https://smee.io/AbC123 (point the
+//	                    GitHub App's webhook URL at the same channel)
+//
+// The relay streams each delivery as a Server-Sent Event whose data is a JSON
+// object of the original request headers (lower-cased) plus "body" and
+// "query". Every delivery is replayed through WebhookHandler exactly as if
+// it had been POSTed to /webhook, so signature verification, platform
+// detection and the rest of the pipeline are unchanged. The body is kept
+// byte-for-byte as the relay sent it; smee re-serializes JSON bodies
+// compactly, which matches GitHub's signed payloads.
+//
+// The connection is re-established with backoff (up to a minute) whenever
+// it drops. Deliveries made while disconnected are not replayed by smee;
+// use the SCM's redeliver button for those. Relays that only speak
+// WebSocket (Hookdeck, `gh webhook forward`) are not supported.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	relayMinBackoff = time.Second
+	relayMaxBackoff = time.Minute
+)
+
+// relayFields are the keys of a relayed delivery that are not headers.
+var relayFields = map[string]bool{"body": true, "query": true, "timestamp": true}
+
+// relayResponse records the status WebhookHandler answers a relayed delivery
+// with; nobody is waiting for the body.
+type relayResponse struct {
+	header http.Header
+	status int
+}
+
+func (r *relayResponse) Header() http.Header { return r.header }
+
+func (r *relayResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (r *relayResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// StartWebhookRelay pulls deliveries from WEBHOOK_RELAY_URL until the process
+// exits. It is a no-op when the variable is unset.
+func StartWebhookRelay() {
+	relayURL := os.Getenv("WEBHOOK_RELAY_URL")
+	if relayURL == "" {
+		return
+	}
+	backoff := relayMinBackoff
+	for {
+		started := time.Now()
+		err := streamRelay(relayURL)
+		if time.Since(started) > relayMaxBackoff {
+			backoff = relayMinBackoff
+		}
+		log.Printf("[Relay] Warning: connection to %s lost: %v (reconnecting in %s)\n", redactURL(relayURL), err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, relayMaxBackoff)
+	}
+}
+
+// streamRelay reads the relay's event stream and dispatches each delivery
+// until the stream ends.
+func streamRelay(relayURL string) error {
+	req, err := http.NewRequest("GET", relayURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("User-Agent", userAgent())
+
+	// No timeout: the stream stays open for as long as the relay allows.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("relay returned %d", resp.StatusCode)
+	}
+	log.Printf("[Relay] ✓ Connected to %s\n", redactURL(relayURL))
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024) // webhook payloads can be large
+	event, data := "", ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends the event. Unnamed events are deliveries;
+			// "ready" and "ping" are the relay's own.
+			if data != "" && (event == "" || event == "message") {
+				dispatchRelayed([]byte(data))
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream closed by relay")
+}
+
+// dispatchRelayed replays one relayed delivery through WebhookHandler.
+func dispatchRelayed(data []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		log.Printf("[Relay] Warning: could not parse relayed delivery: %v\n", err)
+		return
+	}
+	body := []byte(fields["body"])
+	// Form-encoded deliveries are relayed as a JSON string.
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		body = []byte(text)
+	}
+
+	target := webhookPath
+	var query map[string]string
+	if json.Unmarshal(fields["query"], &query) == nil && len(query) > 0 {
+		params := url.Values{}
+		for k, v := range query {
+			params.Set(k, v)
+		}
+		target += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[Relay] Warning: could not build relayed request: %v\n", err)
+		return
+	}
+	for name, raw := range fields {
+		var value string
+		if relayFields[name] || json.Unmarshal(raw, &value) != nil {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "host", "content-length", "connection", "accept-encoding":
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp := &relayResponse{header: http.Header{}}
+	WebhookHandler(resp, req)
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	log.Printf("[Relay] Delivery %s answered %d\n", DeliveryID(req.Header), resp.status)
+}